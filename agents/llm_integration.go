@@ -1,12 +1,16 @@
 package agents
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"strings"
 	"time"
 
+	"github.com/benozo/conduit/lib/tokens"
+	"github.com/benozo/conduit/lib/tracing"
+	"github.com/benozo/conduit/lib/usage"
 	"github.com/benozo/conduit/mcp"
 )
 
@@ -63,33 +67,43 @@ func (lam *LLMAgentManager) ExecuteTaskWithLLM(taskID string) error {
 	}
 
 	// Update task status
-	task.Status = TaskStatusRunning
+	setTaskStatus(task, TaskStatusRunning)
 	task.Progress = 0.0
 	now := time.Now()
 	task.StartedAt = &now
 	agent.State = StateThinking
 
+	ctx, span := startTaskSpan(lam.ctx, task, agent)
+	defer span.End()
+	// Model calls are accounted to the agent, with the task as the run
+	ctx = usage.WithScope(ctx, usage.Scope{Agent: agent.ID, Run: task.ID})
+
 	// Create execution context
 	execCtx := &ExecutionContext{
 		TaskID:    task.ID,
 		AgentID:   agent.ID,
 		SessionID: fmt.Sprintf("session_%d", time.Now().UnixNano()),
-		Context:   lam.ctx,
+		Context:   ctx,
 		Memory:    agent.Memory,
-		Logger:    &defaultLogger{},
 	}
+	execCtx.Logger = newDefaultLogger(ctx, execCtx.SessionID)
 
-	// Execute task with LLM reasoning
-	err = lam.executeTaskWithLLMReasoning(execCtx, task, agent)
+	// Images in the task input are shown to the model
+	execCtx.Images, err = mcp.ImagesInput(mcp.ContextInput{Inputs: task.Input})
+	if err == nil {
+		// Execute task with LLM reasoning
+		err = lam.executeTaskWithLLMReasoning(execCtx, task, agent)
+	}
 	if err != nil {
-		task.Status = TaskStatusFailed
+		setTaskStatus(task, TaskStatusFailed)
 		task.Error = err.Error()
 		agent.State = StateError
+		span.RecordError(err)
 		return err
 	}
 
 	// Mark task as completed
-	task.Status = TaskStatusCompleted
+	setTaskStatus(task, TaskStatusCompleted)
 	task.Progress = 1.0
 	completedAt := time.Now()
 	task.CompletedAt = &completedAt
@@ -116,24 +130,14 @@ func (lam *LLMAgentManager) executeTaskWithLLMReasoning(execCtx *ExecutionContex
 	// Create reasoning prompt for the LLM
 	reasoningPrompt := lam.createReasoningPrompt(task, agent)
 
-	// Get LLM analysis
-	analysis, err := lam.getLLMAnalysis(reasoningPrompt, execCtx)
+	// Get the LLM's action plan
+	analysis, actionPlan, err := lam.getLLMPlan(reasoningPrompt, execCtx, agent)
 	if err != nil {
 		reasoningStep.Status = TaskStatusFailed
 		reasoningStep.Error = err.Error()
 		return fmt.Errorf("LLM reasoning failed: %w", err)
 	}
 
-	//	log.Printf("LLM Analysis: %s", analysis)
-
-	// Parse LLM response to extract action plan
-	actionPlan, err := lam.parseLLMActionPlan(analysis)
-	if err != nil {
-		reasoningStep.Status = TaskStatusFailed
-		reasoningStep.Error = err.Error()
-		return fmt.Errorf("failed to parse LLM action plan: %w", err)
-	}
-
 	reasoningStep.Output = map[string]interface{}{
 		"llm_analysis": analysis,
 		"action_plan":  actionPlan,
@@ -142,8 +146,10 @@ func (lam *LLMAgentManager) executeTaskWithLLMReasoning(execCtx *ExecutionContex
 	reasoningStep.Status = TaskStatusCompleted
 	reasoningStep.CompletedAt = timePtr(time.Now())
 
-	// Step 2: Execute LLM-planned actions
+	// Step 2: Execute LLM-planned actions. Images returned by tools are
+	// kept for the final response.
 	agent.State = StateActing
+	var toolImages []mcp.Image
 	for i, action := range actionPlan {
 		// Resolve step dependencies before execution
 		resolvedAction := lam.resolveStepDependencies(action, task.Steps)
@@ -168,13 +174,14 @@ func (lam *LLMAgentManager) executeTaskWithLLMReasoning(execCtx *ExecutionContex
 
 			// Ask LLM for error recovery strategy
 			if lam.shouldRetryWithLLM(err, resolvedAction) {
-				recoveryAction, recoveryErr := lam.getLLMErrorRecovery(err, resolvedAction, execCtx)
+				recoveryAction, recoveryErr := lam.getLLMErrorRecovery(err, resolvedAction, execCtx, agent)
 				if recoveryErr == nil {
 					// Resolve dependencies for recovery action too
 					resolvedRecoveryAction := lam.resolveStepDependencies(recoveryAction, task.Steps)
 					// Try recovery action
 					result, err = lam.executeAction(execCtx, resolvedRecoveryAction, agent)
 					if err == nil {
+						toolImages = append(toolImages, takeToolImages(result)...)
 						actionStep.Status = TaskStatusCompleted
 						actionStep.Output = result
 						actionStep.CompletedAt = timePtr(time.Now())
@@ -187,6 +194,7 @@ func (lam *LLMAgentManager) executeTaskWithLLMReasoning(execCtx *ExecutionContex
 			return err
 		}
 
+		toolImages = append(toolImages, takeToolImages(result)...)
 		actionStep.Output = result
 		actionStep.Status = TaskStatusCompleted
 		actionStep.CompletedAt = timePtr(time.Now())
@@ -209,7 +217,8 @@ func (lam *LLMAgentManager) executeTaskWithLLMReasoning(execCtx *ExecutionContex
 	finalPrompt := lam.createFinalResponsePrompt(task, agent)
 
 	// Get LLM final response
-	finalResponse, err := lam.getLLMAnalysis(finalPrompt, execCtx)
+	images := append(append([]mcp.Image(nil), execCtx.Images...), toolImages...)
+	finalResponse, err := lam.getLLMAnalysis(finalPrompt, execCtx, images)
 	if err != nil {
 		finalResponseStep.Status = TaskStatusFailed
 		finalResponseStep.Error = err.Error()
@@ -246,27 +255,7 @@ This is a MODIFICATION task. You must:
 
 AVAILABLE TOOLS: %s
 
-IMPORTANT: Respond ONLY with valid JSON. No explanations, no thinking tags, no markdown.
-
-Required JSON format:
-{
-  "analysis": "your reasoning about the modifications needed",
-  "steps": [
-    {
-      "name": "read_current",
-      "description": "Read existing HTML file",
-      "tool": "read_existing_html",
-      "input": {"filename": "FILENAME"}
-    },
-    {
-      "name": "create_updated",
-      "description": "Create updated HTML with modifications",
-      "tool": "create_html_page",
-      "input": {"filename": "FILENAME", "content": "COMPLETE_UPDATED_HTML"}
-    }
-  ],
-  "reasoning": "why these modifications improve the page"
-}`
+Plan these as tool calls.`
 	} else {
 		promptTemplate = `%s
 
@@ -276,39 +265,67 @@ INPUT: %s
 
 AVAILABLE TOOLS: %s
 
-IMPORTANT: Respond ONLY with valid JSON. No explanations, no thinking tags, no markdown.
-
-Required JSON format:
-{
-  "analysis": "your reasoning about the task",
-  "steps": [
-    {
-      "name": "step_name",
-      "description": "what this step does",
-      "tool": "tool_to_use",
-      "input": {"param1": "value1", "param2": "value2"}
-    }
-  ],
-  "reasoning": "why you chose this approach"
-}`
+Plan the tool calls that complete the task.`
 	}
 
+	// Large inputs are cut to what the rest of the prompt leaves of the
+	// model's context window
+	counter, budget := lam.promptBudget(agent)
+	fixed := counter.Count(fmt.Sprintf(promptTemplate, agent.SystemPrompt, task.Title, task.Description, "", availableTools))
+	input := tokens.Truncate(counter, formatInput(task.Input), budget-fixed)
+
 	prompt := fmt.Sprintf(promptTemplate,
 		agent.SystemPrompt,
-		task.Title, task.Description, formatInput(task.Input),
+		task.Title, task.Description, input,
 		availableTools)
 
 	return prompt
 }
 
-// getLLMAnalysis gets analysis from the LLM
-func (lam *LLMAgentManager) getLLMAnalysis(prompt string, execCtx *ExecutionContext) (string, error) {
+// promptBudget returns the token counter and prompt budget for the model
+// reasoning for agent
+func (lam *LLMAgentManager) promptBudget(agent *Agent) (tokens.Counter, int) {
+	window, maxTokens := 0, 0
+	if agent.Config != nil {
+		window, maxTokens = agent.Config.ContextWindow, agent.Config.MaxTokens
+	}
+	if window <= 0 {
+		window = tokens.ContextWindow(lam.modelName)
+	}
+	return tokens.ForModel(lam.modelName), tokens.Budget(window, maxTokens)
+}
+
+// getLLMAnalysis gets analysis from the LLM, sending images with the prompt
+func (lam *LLMAgentManager) getLLMAnalysis(prompt string, execCtx *ExecutionContext, images []mcp.Image) (string, error) {
+	spanCtx, span := tracing.Start(execCtx.Context, "agent.reasoning",
+		tracing.String("task.id", execCtx.TaskID),
+		tracing.String("agent.id", execCtx.AgentID),
+	)
+	defer span.End()
+
+	ctx, req := lam.reasoningRequest(spanCtx, execCtx, prompt, images)
+
+	// Get LLM response
+	response, err := lam.modelFunc(ctx, req, execCtx.Memory, nil)
+	if err != nil {
+		span.RecordError(err)
+		return "", fmt.Errorf("LLM request failed: %w", err)
+	}
+
+	return response, nil
+}
+
+// reasoningRequest builds the model input and request for a reasoning call
+func (lam *LLMAgentManager) reasoningRequest(spanCtx context.Context, execCtx *ExecutionContext, prompt string, images []mcp.Image) (mcp.ContextInput, mcp.MCPRequest) {
 	// Create context input for LLM
 	ctx := mcp.ContextInput{
 		ContextID: execCtx.SessionID,
 		Inputs: map[string]interface{}{
 			"query": prompt,
 		},
+	}.WithContext(spanCtx)
+	if len(images) > 0 {
+		ctx.Inputs[mcp.InputImages] = images
 	}
 
 	// Create MCP request
@@ -319,142 +336,94 @@ func (lam *LLMAgentManager) getLLMAnalysis(prompt string, execCtx *ExecutionCont
 		Temperature: 0.3, // Lower temperature for more focused reasoning
 		Stream:      false,
 	}
-
-	// Get LLM response
-	response, err := lam.modelFunc(ctx, req, execCtx.Memory, nil)
-	if err != nil {
-		return "", fmt.Errorf("LLM request failed: %w", err)
-	}
-
-	return response, nil
+	return ctx, req
 }
 
-// parseLLMActionPlan parses the LLM response to extract action plan
-func (lam *LLMAgentManager) parseLLMActionPlan(analysis string) ([]Action, error) {
-	log.Printf("🔍 Parsing LLM response (first 500 chars): %s", truncateString(analysis, 500))
-
-	// Clean up the response - remove thinking tags and other artifacts
-	cleanedResponse := analysis
-
-	// Remove <think>...</think> blocks
-	if thinkStart := strings.Index(cleanedResponse, "<think>"); thinkStart != -1 {
-		if thinkEnd := strings.Index(cleanedResponse, "</think>"); thinkEnd != -1 {
-			cleanedResponse = cleanedResponse[:thinkStart] + cleanedResponse[thinkEnd+8:]
-		}
-	}
-
-	// Remove markdown code blocks
-	cleanedResponse = strings.ReplaceAll(cleanedResponse, "```json", "")
-	cleanedResponse = strings.ReplaceAll(cleanedResponse, "```", "")
-
-	// Trim whitespace
-	cleanedResponse = strings.TrimSpace(cleanedResponse)
-
-	log.Printf("🔍 Cleaned response: %s", truncateString(cleanedResponse, 300))
-
-	// Find the start of JSON
-	jsonStart := strings.Index(cleanedResponse, "{")
-	if jsonStart == -1 {
-		log.Printf("❌ No JSON start found in response")
-		return lam.createFallbackActionPlan(analysis)
-	}
-
-	// Extract JSON using brace counting for proper nesting
-	jsonStr := lam.extractCompleteJSON(cleanedResponse[jsonStart:])
-	if jsonStr == "" {
-		log.Printf("❌ Failed to extract complete JSON")
-		return lam.createFallbackActionPlan(analysis)
-	}
-
-	log.Printf("🔍 Extracted JSON length: %d chars", len(jsonStr))
+// llmPlan is the action plan the LLM is asked for
+type llmPlan struct {
+	Analysis  string   `json:"analysis"`
+	Steps     []Action `json:"steps"`
+	Reasoning string   `json:"reasoning"`
+}
 
-	var llmResponse struct {
-		Analysis string `json:"analysis"`
-		Steps    []struct {
-			Name        string                 `json:"name"`
-			Description string                 `json:"description"`
-			Tool        string                 `json:"tool"`
-			Input       map[string]interface{} `json:"input"`
-		} `json:"steps"`
-		Reasoning string `json:"reasoning"`
+// actionSchema describes one planned tool call, limited to the agent's tools
+func actionSchema(agent *Agent) map[string]interface{} {
+	tool := map[string]interface{}{"type": "string", "description": "tool to call"}
+	if len(agent.Tools) > 0 {
+		tool["enum"] = agent.Tools
+	}
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"name":        map[string]interface{}{"type": "string", "description": "short step name"},
+			"description": map[string]interface{}{"type": "string", "description": "what this step does"},
+			"tool":        tool,
+			"input":       map[string]interface{}{"type": "object", "description": "the tool's arguments"},
+		},
+		"required": []string{"name", "tool", "input"},
 	}
+}
 
-	if err := json.Unmarshal([]byte(jsonStr), &llmResponse); err != nil {
-		log.Printf("❌ JSON parsing failed: %v", err)
-		// Fallback if JSON parsing fails
-		return lam.createFallbackActionPlan(analysis)
+// planSchema describes an llmPlan
+func planSchema(agent *Agent) map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"analysis":  map[string]interface{}{"type": "string", "description": "your reasoning about the task"},
+			"steps":     map[string]interface{}{"type": "array", "items": actionSchema(agent)},
+			"reasoning": map[string]interface{}{"type": "string", "description": "why you chose this approach"},
+		},
+		"required": []string{"steps"},
 	}
+}
 
-	log.Printf("✅ Successfully parsed %d steps from LLM response", len(llmResponse.Steps))
-
-	// Convert to Action structs
-	var actions []Action
-	for _, step := range llmResponse.Steps {
-		actions = append(actions, Action{
-			Name:        step.Name,
-			Description: step.Description,
-			Tool:        step.Tool,
-			Input:       step.Input,
-		})
+// planAttempts returns how often the agent's model is asked for a plan
+// that matches the schema
+func planAttempts(agent *Agent) int {
+	if agent.Config != nil && agent.Config.PlanAttempts > 0 {
+		return agent.Config.PlanAttempts
 	}
-
-	return actions, nil
+	return mcp.DefaultStructuredAttempts
 }
 
-// extractCompleteJSON extracts a complete JSON object using brace counting
-func (lam *LLMAgentManager) extractCompleteJSON(text string) string {
-	if !strings.HasPrefix(text, "{") {
-		return ""
-	}
+// generatePlan asks the LLM for a value matching schema. When the replies
+// never match, the last one is returned in a *mcp.StructuredOutputError.
+func (lam *LLMAgentManager) generatePlan(prompt string, execCtx *ExecutionContext, agent *Agent, schema map[string]interface{}, out interface{}) error {
+	spanCtx, span := tracing.Start(execCtx.Context, "agent.reasoning",
+		tracing.String("task.id", execCtx.TaskID),
+		tracing.String("agent.id", execCtx.AgentID),
+	)
+	defer span.End()
+
+	ctx, req := lam.reasoningRequest(spanCtx, execCtx, prompt, execCtx.Images)
+	err := mcp.GenerateStructured(ctx, lam.modelFunc, schema, prompt, out,
+		mcp.WithRequest(req), mcp.WithMemory(execCtx.Memory), mcp.MaxAttempts(planAttempts(agent)))
+	span.RecordError(err)
+	return err
+}
 
-	// Limit maximum JSON size to prevent memory issues
-	maxJSONSize := 50000 // 50KB limit
-	if len(text) > maxJSONSize {
-		log.Printf("⚠️ Response too large (%d chars), truncating for JSON extraction", len(text))
-		text = text[:maxJSONSize]
+// getLLMPlan asks the LLM for an action plan. Replies that never match the
+// plan schema fall back to createFallbackActionPlan.
+func (lam *LLMAgentManager) getLLMPlan(prompt string, execCtx *ExecutionContext, agent *Agent) (string, []Action, error) {
+	var plan llmPlan
+	err := lam.generatePlan(prompt, execCtx, agent, planSchema(agent), &plan)
+	var invalid *mcp.StructuredOutputError
+	if errors.As(err, &invalid) {
+		slog.Warn("LLM action plan did not match the schema", "attempts", invalid.Attempts, "problems", invalid.Problems)
+		actions, err := lam.createFallbackActionPlan(invalid.Output)
+		return invalid.Output, actions, err
 	}
-
-	braceCount := 0
-	inString := false
-	escape := false
-
-	for i, char := range text {
-		if escape {
-			escape = false
-			continue
-		}
-
-		if char == '\\' && inString {
-			escape = true
-			continue
-		}
-
-		if char == '"' {
-			inString = !inString
-			continue
-		}
-
-		if !inString {
-			if char == '{' {
-				braceCount++
-			} else if char == '}' {
-				braceCount--
-				if braceCount == 0 {
-					result := text[:i+1]
-					log.Printf("🔍 Successfully extracted JSON: %d chars", len(result))
-					return result
-				}
-			}
-		}
+	if err != nil {
+		return "", nil, fmt.Errorf("LLM request failed: %w", err)
 	}
 
-	log.Printf("❌ Could not find complete JSON in text")
-	return ""
+	slog.Debug("parsed LLM action plan", "steps", len(plan.Steps))
+	return plan.Analysis, plan.Steps, nil
 }
 
 // createFallbackActionPlan creates a simple action plan when LLM parsing fails
 func (lam *LLMAgentManager) createFallbackActionPlan(analysis string) ([]Action, error) {
-	log.Printf("🔄 Creating intelligent fallback action plan")
+	slog.Debug("creating fallback action plan")
 
 	// Try to detect if this looks like an HTML creation task
 	if strings.Contains(analysis, "html") || strings.Contains(analysis, "HTML") ||
@@ -469,7 +438,7 @@ func (lam *LLMAgentManager) createFallbackActionPlan(analysis string) ([]Action,
 			if htmlEnd := strings.Index(htmlContent, "</html>"); htmlEnd != -1 {
 				htmlContent = htmlContent[:htmlEnd+7]
 
-				log.Printf("🔍 Found HTML content in response, creating fallback HTML action")
+				slog.Debug("found HTML content in LLM response, using HTML fallback action")
 				actions := []Action{
 					{
 						Name:        "create_html_fallback",
@@ -486,7 +455,7 @@ func (lam *LLMAgentManager) createFallbackActionPlan(analysis string) ([]Action,
 		}
 
 		// If no HTML found, create a simple HTML template
-		log.Printf("🔍 Creating simple HTML template as fallback")
+		slog.Debug("using simple HTML template as fallback action")
 		simpleHTML := `<!DOCTYPE html>
 <html lang="en">
 <head>
@@ -528,7 +497,7 @@ func (lam *LLMAgentManager) createFallbackActionPlan(analysis string) ([]Action,
 		},
 	}
 
-	log.Printf("Using fallback action plan due to LLM parsing failure")
+	slog.Warn("using fallback action plan due to LLM parsing failure")
 	return actions, nil
 }
 
@@ -539,34 +508,21 @@ func (lam *LLMAgentManager) shouldRetryWithLLM(err error, action Action) bool {
 }
 
 // getLLMErrorRecovery asks LLM to suggest error recovery
-func (lam *LLMAgentManager) getLLMErrorRecovery(err error, failedAction Action, execCtx *ExecutionContext) (Action, error) {
+func (lam *LLMAgentManager) getLLMErrorRecovery(err error, failedAction Action, execCtx *ExecutionContext, agent *Agent) (Action, error) {
 	prompt := fmt.Sprintf(`The following action failed:
 Action: %s
 Tool: %s
 Input: %s
 Error: %s
 
-Please suggest a corrected action. Respond with JSON:
-{
-  "name": "corrected_action_name",
-  "description": "what the corrected action does",
-  "tool": "tool_to_use",
-  "input": {"param1": "value1"}
-}`,
+Please suggest a corrected action.`,
 		failedAction.Name, failedAction.Tool, formatInput(failedAction.Input), err.Error())
 
-	response, err := lam.getLLMAnalysis(prompt, execCtx)
-	if err != nil {
-		return Action{}, err
-	}
-
-	// Parse recovery action
-	actions, err := lam.parseLLMActionPlan(response)
-	if err != nil || len(actions) == 0 {
-		return Action{}, fmt.Errorf("failed to parse recovery action")
+	var action Action
+	if err := lam.generatePlan(prompt, execCtx, agent, actionSchema(agent), &action); err != nil {
+		return Action{}, fmt.Errorf("failed to get recovery action: %w", err)
 	}
-
-	return actions[0], nil
+	return action, nil
 }
 
 // resolveStepDependencies resolves references to previous step results in action inputs
@@ -650,7 +606,8 @@ func (lam *LLMAgentManager) resolveStepReference(reference string, completedStep
 	return nil
 }
 
-// formatInput formats input parameters for display
+// formatInput formats input parameters for display. Images are sent to the
+// model separately and only counted here.
 func formatInput(input map[string]interface{}) string {
 	if len(input) == 0 {
 		return "{}"
@@ -658,39 +615,43 @@ func formatInput(input map[string]interface{}) string {
 
 	parts := []string{}
 	for k, v := range input {
+		if k == mcp.InputImages {
+			images, _ := mcp.ImagesInput(mcp.ContextInput{Inputs: input})
+			parts = append(parts, fmt.Sprintf("%s: %s", k, mcp.WithImageNote("", len(images))))
+			continue
+		}
 		parts = append(parts, fmt.Sprintf("%s: %v", k, v))
 	}
 	return strings.Join(parts, ", ")
 }
 
-// truncateString truncates a string to maxLength with ellipsis
-func truncateString(s string, maxLength int) string {
-	if len(s) <= maxLength {
-		return s
+// takeToolImages removes the images from an action result, leaving their
+// text and a note in the output, and returns them
+func takeToolImages(result map[string]interface{}) []mcp.Image {
+	_, images, ok := mcp.SplitToolContent(result["output"])
+	if !ok {
+		return nil
 	}
-	return s[:maxLength] + "..."
+	result["output"] = mcp.FormatToolResult(result["output"])
+	return images
 }
 
 // createFinalResponsePrompt creates a prompt for generating the final user response
 func (lam *LLMAgentManager) createFinalResponsePrompt(task *Task, agent *Agent) string {
 	// Collect outputs from all completed steps
-	var stepOutputs []string
+	var stepOutputs []mcp.ChatMessage
 	for _, step := range task.Steps {
 		if step.Status == TaskStatusCompleted && step.Output != nil {
 			if stepName := step.Name; stepName != "llm_reasoning" {
 				// Format the step output for the prompt
 				stepOutput := fmt.Sprintf("Tool '%s' result: %v", stepName, step.Output)
-				stepOutputs = append(stepOutputs, stepOutput)
+				stepOutputs = append(stepOutputs, mcp.ChatMessage{Role: mcp.RoleUser, Content: stepOutput})
 			}
 		}
 	}
 
-	stepResultsText := strings.Join(stepOutputs, "\n")
-	if stepResultsText == "" {
-		stepResultsText = "No tool results available."
-	}
-
-	return fmt.Sprintf(`%s
+	buildPrompt := func(stepResultsText string) string {
+		return fmt.Sprintf(`%s
 
 USER QUERY: %s
 
@@ -705,9 +666,25 @@ Based on the user's query and the tool execution results above, provide a helpfu
 - Don't mention technical details about tool execution
 
 Respond only with the final answer text, no JSON or formatting markers.`,
-		agent.SystemPrompt,
-		getStringFromInput(task.Input, "user_query"),
-		stepResultsText)
+			agent.SystemPrompt,
+			getStringFromInput(task.Input, "user_query"),
+			stepResultsText)
+	}
+
+	// Keep the latest tool results that fit alongside the rest of the prompt
+	counter, budget := lam.promptBudget(agent)
+	stepOutputs, _ = tokens.Fit(context.Background(), stepOutputs, budget-counter.Count(buildPrompt("")), counter, tokens.DropOldest())
+
+	results := make([]string, len(stepOutputs))
+	for i, output := range stepOutputs {
+		results[i] = output.Content
+	}
+	stepResultsText := strings.Join(results, "\n")
+	if stepResultsText == "" {
+		stepResultsText = "No tool results available."
+	}
+
+	return buildPrompt(stepResultsText)
 }
 
 // Helper function to extract string values from task input
@@ -3,9 +3,12 @@ package agents
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"time"
 
+	"github.com/benozo/conduit/lib/logging"
+	"github.com/benozo/conduit/lib/metrics"
+	"github.com/benozo/conduit/lib/tracing"
 	"github.com/benozo/conduit/mcp"
 )
 
@@ -87,6 +90,7 @@ func (am *AgentManager) CreateTask(agentID, title, description string, input map
 	}
 
 	am.tasks[taskID] = task
+	metrics.ObserveTaskTransition("", string(TaskStatusPending))
 	return task, nil
 }
 
@@ -103,7 +107,7 @@ func (am *AgentManager) ExecuteTask(taskID string) error {
 	}
 
 	// Update task status
-	task.Status = TaskStatusRunning
+	setTaskStatus(task, TaskStatusRunning)
 	task.Progress = 0.0
 	now := time.Now()
 	task.StartedAt = &now
@@ -111,27 +115,31 @@ func (am *AgentManager) ExecuteTask(taskID string) error {
 	// Update agent state
 	agent.State = StateThinking
 
+	ctx, span := startTaskSpan(am.ctx, task, agent)
+	defer span.End()
+
 	// Create execution context
 	execCtx := &ExecutionContext{
 		TaskID:    task.ID,
 		AgentID:   agent.ID,
 		SessionID: fmt.Sprintf("session_%d", time.Now().UnixNano()),
-		Context:   am.ctx,
+		Context:   ctx,
 		Memory:    agent.Memory,
-		Logger:    &defaultLogger{},
 	}
+	execCtx.Logger = newDefaultLogger(ctx, execCtx.SessionID)
 
 	// Execute the task
 	err = am.executeTaskSteps(execCtx, task, agent)
 	if err != nil {
-		task.Status = TaskStatusFailed
+		setTaskStatus(task, TaskStatusFailed)
 		task.Error = err.Error()
 		agent.State = StateError
+		span.RecordError(err)
 		return err
 	}
 
 	// Mark task as completed
-	task.Status = TaskStatusCompleted
+	setTaskStatus(task, TaskStatusCompleted)
 	task.Progress = 1.0
 	completedAt := time.Now()
 	task.CompletedAt = &completedAt
@@ -279,6 +287,9 @@ func (am *AgentManager) createActionPlan(execCtx *ExecutionContext, task *Task,
 func (am *AgentManager) executeAction(execCtx *ExecutionContext, action Action, agent *Agent) (map[string]interface{}, error) {
 	execCtx.Logger.Info("Executing action", "action", action.Name, "tool", action.Tool)
 
+	_, span := startStepSpan(execCtx, action)
+	defer span.End()
+
 	// TODO: Integrate with actual MCP server tool execution
 	// For now, simulate tool execution
 	result := map[string]interface{}{
@@ -357,23 +368,57 @@ func DefaultAgentConfig() *AgentConfig {
 	}
 }
 
-// defaultLogger provides a simple logger implementation
-type defaultLogger struct{}
+// defaultLogger writes structured records through slog, carrying the task's
+// session and trace IDs from its context
+type defaultLogger struct {
+	ctx context.Context
+}
+
+// newDefaultLogger creates a logger bound to an execution context
+func newDefaultLogger(ctx context.Context, sessionID string) *defaultLogger {
+	return &defaultLogger{ctx: logging.WithSessionID(ctx, sessionID)}
+}
 
 func (l *defaultLogger) Info(msg string, fields ...interface{}) {
-	log.Printf("[INFO] %s %v", msg, fields)
+	slog.InfoContext(l.ctx, msg, fields...)
 }
 
 func (l *defaultLogger) Error(msg string, fields ...interface{}) {
-	log.Printf("[ERROR] %s %v", msg, fields)
+	slog.ErrorContext(l.ctx, msg, fields...)
 }
 
 func (l *defaultLogger) Debug(msg string, fields ...interface{}) {
-	log.Printf("[DEBUG] %s %v", msg, fields)
+	slog.DebugContext(l.ctx, msg, fields...)
 }
 
 func (l *defaultLogger) Warn(msg string, fields ...interface{}) {
-	log.Printf("[WARN] %s %v", msg, fields)
+	slog.WarnContext(l.ctx, msg, fields...)
+}
+
+// startTaskSpan traces a task execution; steps run under the returned context
+func startTaskSpan(ctx context.Context, task *Task, agent *Agent) (context.Context, *tracing.Span) {
+	return tracing.Start(ctx, "agent.task",
+		tracing.String("task.id", task.ID),
+		tracing.String("task.title", task.Title),
+		tracing.String("agent.id", agent.ID),
+		tracing.String("agent.name", agent.Name),
+	)
+}
+
+// startStepSpan traces a single action step of a task
+func startStepSpan(execCtx *ExecutionContext, action Action) (context.Context, *tracing.Span) {
+	return tracing.Start(execCtx.Context, "agent.step",
+		tracing.String("task.id", execCtx.TaskID),
+		tracing.String("agent.id", execCtx.AgentID),
+		tracing.String("step.name", action.Name),
+		tracing.String("tool.name", action.Tool),
+	)
+}
+
+// setTaskStatus updates a task's status and records the transition
+func setTaskStatus(task *Task, status TaskStatus) {
+	metrics.ObserveTaskTransition(string(task.Status), string(status))
+	task.Status = status
 }
 
 // timePtr returns a pointer to a time value
@@ -385,7 +430,7 @@ func timePtr(t time.Time) *time.Time {
 func (am *AgentManager) ExecuteTaskAsync(taskID string) error {
 	go func() {
 		if err := am.ExecuteTask(taskID); err != nil {
-			log.Printf("Task execution failed: %v", err)
+			slog.Error("task execution failed", "task_id", taskID, "error", err)
 		}
 	}()
 	return nil
@@ -399,7 +444,7 @@ func (am *AgentManager) CancelTask(taskID string) error {
 	}
 
 	if task.Status == TaskStatusRunning {
-		task.Status = TaskStatusCancelled
+		setTaskStatus(task, TaskStatusCancelled)
 		return nil
 	}
 
@@ -37,9 +37,13 @@ func (mam *MCPAgentManager) ExecuteToolWithMCP(toolName string, params map[strin
 func (mam *MCPAgentManager) executeAction(execCtx *ExecutionContext, action Action, agent *Agent) (map[string]interface{}, error) {
 	execCtx.Logger.Info("Executing MCP action", "action", action.Name, "tool", action.Tool)
 
+	ctx, span := startStepSpan(execCtx, action)
+	defer span.End()
+
 	// Execute the tool using the MCP server
-	result, err := mam.ExecuteToolWithMCP(action.Tool, action.Input, agent.Memory)
+	result, err := mam.mcpServer.GetToolRegistry().CallContext(ctx, action.Tool, action.Input, agent.Memory)
 	if err != nil {
+		span.RecordError(err)
 		execCtx.Logger.Error("Tool execution failed", "tool", action.Tool, "error", err)
 		return nil, fmt.Errorf("tool execution failed: %w", err)
 	}
@@ -71,7 +75,7 @@ func (mam *MCPAgentManager) ExecuteTask(taskID string) error {
 	}
 
 	// Update task status
-	task.Status = TaskStatusRunning
+	setTaskStatus(task, TaskStatusRunning)
 	task.Progress = 0.0
 	now := time.Now()
 	task.StartedAt = &now
@@ -79,27 +83,31 @@ func (mam *MCPAgentManager) ExecuteTask(taskID string) error {
 	// Update agent state
 	agent.State = StateThinking
 
+	ctx, span := startTaskSpan(mam.ctx, task, agent)
+	defer span.End()
+
 	// Create execution context
 	execCtx := &ExecutionContext{
 		TaskID:    task.ID,
 		AgentID:   agent.ID,
 		SessionID: fmt.Sprintf("session_%d", time.Now().UnixNano()),
-		Context:   mam.ctx,
+		Context:   ctx,
 		Memory:    agent.Memory,
-		Logger:    &defaultLogger{},
 	}
+	execCtx.Logger = newDefaultLogger(ctx, execCtx.SessionID)
 
 	// Execute the task using MCP-enabled execution
 	err = mam.executeTaskStepsWithMCP(execCtx, task, agent)
 	if err != nil {
-		task.Status = TaskStatusFailed
+		setTaskStatus(task, TaskStatusFailed)
 		task.Error = err.Error()
 		agent.State = StateError
+		span.RecordError(err)
 		return err
 	}
 
 	// Mark task as completed
-	task.Status = TaskStatusCompleted
+	setTaskStatus(task, TaskStatusCompleted)
 	task.Progress = 1.0
 	completedAt := time.Now()
 	task.CompletedAt = &completedAt
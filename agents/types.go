@@ -32,6 +32,14 @@ type AgentConfig struct {
 	Timeout       time.Duration `json:"timeout"`
 	EnableMemory  bool          `json:"enable_memory"`
 	EnableLogging bool          `json:"enable_logging"`
+
+	// ContextWindow overrides the model's context size in tokens; prompts
+	// are cut to fit it, less MaxTokens for the response
+	ContextWindow int `json:"context_window,omitempty"`
+
+	// PlanAttempts is how many times the model is asked for an action plan
+	// that matches the plan schema (default 3)
+	PlanAttempts int `json:"plan_attempts,omitempty"`
 }
 
 // AgentState represents the current state of an agent
@@ -103,6 +111,8 @@ type ExecutionContext struct {
 	Context   context.Context
 	Memory    *mcp.Memory
 	Logger    Logger
+	// Images are the task's images, sent with every reasoning call
+	Images []mcp.Image
 }
 
 // Logger interface for agent logging
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	conduit "github.com/benozo/conduit/lib"
+	"github.com/benozo/conduit/lib/openapi"
+	"github.com/benozo/conduit/lib/plugins"
+	"github.com/benozo/conduit/lib/scripting"
+	"github.com/benozo/conduit/mcp"
+)
+
+// EnvConfigFile names the config file when --config is not given
+const EnvConfigFile = "CONDUIT_CONFIG"
+
+// cliOptions holds the parsed command line of the serve and validate commands
+type cliOptions struct {
+	configFile string
+	agents     bool
+	config     *conduit.Config
+}
+
+// newFlagSet declares the flags shared by serve and validate. Each flag is
+// applied to the config only when set, so precedence is file < env < flags.
+func newFlagSet(name string, output io.Writer) (*flag.FlagSet, *cliOptions, func(*conduit.Config) error) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(output)
+	opts := &cliOptions{}
+
+	fs.StringVar(&opts.configFile, "config", os.Getenv(EnvConfigFile), "path to a YAML or JSON config file")
+	mode := fs.String("mode", "", "server mode: stdio, http or both")
+	port := fs.Int("port", 0, "HTTP port")
+	ollamaURL := fs.String("ollama-url", "", "Ollama URL for the default model")
+	tlsCert := fs.String("tls-cert", "", "TLS certificate file (enables HTTPS with --tls-key)")
+	tlsKey := fs.String("tls-key", "", "TLS private key file")
+	toolList := fs.String("tools", "", "comma-separated tool packages: text, memory, utility, rag")
+	provider := fs.String("model-provider", "", "model provider: ollama, openai, deepinfra, openai-compatible, anthropic or gemini")
+	model := fs.String("model", "", "model name")
+	modelURL := fs.String("model-url", "", "model API base URL")
+	openAPIList := fs.String("openapi", "", "comma-separated OpenAPI document paths or URLs to import as tools")
+	scriptsDir := fs.String("scripts-dir", "", "directory of Starlark tool scripts (*.star)")
+	pluginList := fs.String("plugins", "", "comma-separated plugin manifest files")
+	logLevel := fs.String("log-level", "", "log level: debug, info, warn or error")
+	logFormat := fs.String("log-format", "", "log format: text or json")
+
+	// Legacy mode switches kept for existing scripts
+	stdio := fs.Bool("stdio", false, "shorthand for --mode=stdio")
+	httpOnly := fs.Bool("http", false, "shorthand for --mode=http")
+	both := fs.Bool("both", false, "shorthand for --mode=both")
+	fs.BoolVar(&opts.agents, "agents", false, "run the AI agents demo")
+
+	apply := func(config *conduit.Config) error {
+		var err error
+		fs.Visit(func(f *flag.Flag) {
+			if err != nil {
+				return
+			}
+			switch f.Name {
+			case "mode":
+				config.Mode, err = mcp.ParseServerMode(*mode)
+			case "stdio":
+				if *stdio {
+					config.Mode = mcp.ModeStdio
+				}
+			case "http":
+				if *httpOnly {
+					config.Mode = mcp.ModeHTTP
+				}
+			case "both":
+				if *both {
+					config.Mode = mcp.ModeBoth
+				}
+			case "port":
+				config.Port = *port
+			case "ollama-url":
+				config.OllamaURL = *ollamaURL
+			case "tls-cert":
+				config.CertFile = *tlsCert
+				config.EnableHTTPS = true
+			case "tls-key":
+				config.KeyFile = *tlsKey
+				config.EnableHTTPS = true
+			case "tools":
+				config.Tools = conduit.SplitList(*toolList)
+			case "model-provider", "model", "model-url":
+				if config.Model == nil {
+					config.Model = &conduit.ModelConfig{Temperature: 0.7, MaxTokens: 1000, TopK: 40}
+				}
+				switch f.Name {
+				case "model-provider":
+					config.Model.Provider = *provider
+				case "model":
+					config.Model.Model = *model
+				case "model-url":
+					config.Model.URL = *modelURL
+				}
+			case "openapi":
+				for _, spec := range conduit.SplitList(*openAPIList) {
+					config.OpenAPI = append(config.OpenAPI, conduit.OpenAPISource{Spec: spec})
+				}
+			case "scripts-dir":
+				config.ScriptsDir = *scriptsDir
+			case "plugins":
+				config.Plugins = conduit.SplitList(*pluginList)
+			case "log-level":
+				config.LogLevel = *logLevel
+			case "log-format":
+				config.LogFormat = *logFormat
+			}
+		})
+		return err
+	}
+
+	return fs, opts, apply
+}
+
+// parseCommand parses args and builds the effective config from defaults,
+// the config file, CONDUIT_* environment variables and flags, in that order
+func parseCommand(name string, args []string, output io.Writer) (*cliOptions, error) {
+	fs, opts, apply := newFlagSet(name, output)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+
+	config := conduit.DefaultConfig()
+	if opts.configFile != "" {
+		loaded, err := conduit.LoadConfig(opts.configFile)
+		if err != nil {
+			return nil, err
+		}
+		config = loaded
+	}
+	if err := config.ApplyEnv(); err != nil {
+		return nil, err
+	}
+	if err := apply(config); err != nil {
+		return nil, err
+	}
+	config.Finalize()
+
+	opts.config = config
+	return opts, nil
+}
+
+// runValidate checks the effective configuration and reports the result on stderr
+func runValidate(args []string) int {
+	opts, err := parseCommand("validate", args, os.Stderr)
+	if err == flag.ErrHelp {
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "conduit: %v\n", err)
+		return 2
+	}
+
+	if err := opts.config.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	for _, path := range opts.config.Plugins {
+		if _, err := plugins.LoadManifest(path); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	if opts.config.ScriptsDir != "" {
+		loader := scripting.NewLoader(opts.config.ScriptsDir, conduit.NewEnhancedServer(opts.config), scripting.Limits{})
+		if _, err := loader.Load(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	for _, src := range opts.config.OpenAPI {
+		spec, err := openapi.Load(context.Background(), src.Spec)
+		if err == nil {
+			_, err = spec.Tools(openAPIOptions(src))
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "openapi %s: %v\n", src.Spec, err)
+			return 1
+		}
+	}
+
+	source := "defaults"
+	if opts.configFile != "" {
+		source = strconv.Quote(opts.configFile)
+	}
+	fmt.Fprintf(os.Stderr, "configuration OK (%s): mode=%s port=%d tools=%v\n",
+		source, opts.config.Mode, opts.config.Port, opts.config.Tools)
+	return 0
+}
+
+// openAPIOptions converts a config entry to loader options
+func openAPIOptions(src conduit.OpenAPISource) openapi.Options {
+	return openapi.Options{
+		BaseURL:          src.BaseURL,
+		Headers:          src.Headers,
+		Operations:       src.Operations,
+		Prefix:           src.Prefix,
+		MaxResponseBytes: src.MaxResponseBytes,
+		Timeout:          src.Timeout,
+	}
+}
+
+func usage() {
+	fmt.Fprint(os.Stderr, `Usage: conduit [command] [flags]
+
+Commands:
+  serve      start the MCP server (default)
+  validate   check the configuration and exit
+
+Client commands (against --url URL, --cmd CMD or -- CMD ARGS...):
+  tools list                         list a server's tools
+  tools call <name> --arg key=value  call a tool
+  prompts list                       list a server's prompts
+  resources list                     list a server's resources
+  repl                               interactive session
+
+Run "conduit serve -h" for the list of flags.
+`)
+}
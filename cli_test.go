@@ -0,0 +1,94 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/benozo/conduit/mcp"
+)
+
+func TestParseCommandPrecedence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "conduit.yaml")
+	content := "mode: http\nport: 9000\nlog_level: warn\ntools: [text]\nmodel:\n  provider: ollama\n  model: from-file\n"
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONDUIT_PORT", "9100")
+	t.Setenv("CONDUIT_MODEL", "from-env")
+
+	tests := []struct {
+		name      string
+		args      []string
+		wantMode  mcp.ServerMode
+		wantPort  int
+		wantModel string
+		wantTools []string
+	}{
+		{"file then env", []string{"--config", file}, mcp.ModeHTTP, 9100, "from-env", []string{"text"}},
+		{"flags win", []string{"--config", file, "--port", "9200", "--model", "from-flag", "--tools", "memory,utility"},
+			mcp.ModeHTTP, 9200, "from-flag", []string{"memory", "utility"}},
+		{"legacy switch", []string{"--config", file, "--stdio"}, mcp.ModeStdio, 9100, "from-env", []string{"text"}},
+		{"mode flag", []string{"--config", file, "--mode", "both"}, mcp.ModeBoth, 9100, "from-env", []string{"text"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := parseCommand("serve", tt.args, io.Discard)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c := opts.config
+			if c.Mode != tt.wantMode || c.Port != tt.wantPort || c.Model.Model != tt.wantModel ||
+				!reflect.DeepEqual(c.Tools, tt.wantTools) || c.LogLevel != "warn" {
+				t.Errorf("got mode %v port %d model %q tools %v level %q", c.Mode, c.Port, c.Model.Model, c.Tools, c.LogLevel)
+			}
+		})
+	}
+}
+
+func TestParseCommandErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"unknown flag", []string{"--nope"}},
+		{"positional argument", []string{"extra"}},
+		{"bad mode", []string{"--mode", "sideways"}},
+		{"missing config file", []string{"--config", filepath.Join(t.TempDir(), "missing.yaml")}},
+	}
+	for _, tt := range tests {
+		if _, err := parseCommand("validate", tt.args, io.Discard); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
+
+func TestTLSFlagsEnableHTTPS(t *testing.T) {
+	opts, err := parseCommand("serve", []string{"--tls-cert", "cert.pem", "--tls-key", "key.pem"}, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := opts.config; !c.EnableHTTPS || c.CertFile != "cert.pem" || c.KeyFile != "key.pem" {
+		t.Errorf("got https %v cert %q key %q", c.EnableHTTPS, c.CertFile, c.KeyFile)
+	}
+}
+
+func TestToolsFlagEnablesRAG(t *testing.T) {
+	t.Setenv("RAG_CHUNK_SIZE", "256")
+	opts, err := parseCommand("validate", []string{"--tools", "text,rag"}, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := opts.config.Validate(); err != nil {
+		t.Fatalf("rag enabled by flag: %v", err)
+	}
+	if opts.config.RAG.Chunking.Size != 256 {
+		t.Errorf("RAG_* variables not applied: chunk size %d", opts.config.RAG.Chunking.Size)
+	}
+
+	if code := runValidate([]string{"--tools", "text,rag"}); code != 0 {
+		t.Errorf("validate --tools text,rag exited with %d", code)
+	}
+}
@@ -0,0 +1,432 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/benozo/conduit/mcp"
+)
+
+// stringList collects a repeatable flag
+type stringList []string
+
+func (l *stringList) String() string     { return strings.Join(*l, ",") }
+func (l *stringList) Set(v string) error { *l = append(*l, v); return nil }
+
+// clientOptions selects the server to talk to and how results are printed
+type clientOptions struct {
+	url     string
+	command string
+	json    bool
+	trace   bool
+	timeout time.Duration
+	args    stringList
+	rawArgs string
+}
+
+func newClientFlagSet(name string, withArgs bool) (*flag.FlagSet, *clientOptions) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	opts := &clientOptions{}
+	fs.StringVar(&opts.url, "url", "", "HTTP URL of the server (the /rpc endpoint is used)")
+	fs.StringVar(&opts.command, "cmd", "", "stdio server command line, e.g. \"conduit --stdio\"")
+	fs.BoolVar(&opts.json, "json", false, "print raw JSON results")
+	fs.BoolVar(&opts.trace, "trace", false, "print the JSON-RPC exchange to stderr")
+	fs.DurationVar(&opts.timeout, "timeout", 60*time.Second, "per-request timeout")
+	if withArgs {
+		fs.Var(&opts.args, "arg", "tool argument as key=value, typed using the tool schema (repeatable)")
+		fs.StringVar(&opts.rawArgs, "args", "", "tool arguments as a JSON object")
+	}
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: conduit %s [flags] (--url URL | --cmd CMD | -- CMD ARGS...)\n", name)
+		fs.PrintDefaults()
+	}
+	return fs, opts
+}
+
+// connect starts or dials the server selected by opts and performs the MCP handshake
+func (o *clientOptions) connect(ctx context.Context, command []string) (*mcp.Client, error) {
+	if o.command != "" {
+		command = strings.Fields(o.command)
+	}
+
+	var transport mcp.Transport
+	switch {
+	case o.url != "" && len(command) > 0:
+		return nil, fmt.Errorf("use either --url or a server command, not both")
+	case o.url != "":
+		transport = mcp.NewHTTPTransport(rpcURL(o.url))
+	case len(command) > 0:
+		t, err := mcp.NewStdioTransport(command[0], command[1:]...)
+		if err != nil {
+			return nil, err
+		}
+		transport = t
+	default:
+		return nil, fmt.Errorf("no server given: use --url URL, --cmd CMD or -- CMD ARGS")
+	}
+
+	client := mcp.NewClient(transport)
+	if o.trace {
+		client.Trace = os.Stderr
+	}
+
+	initCtx, cancel := context.WithTimeout(ctx, o.timeout)
+	defer cancel()
+	if _, err := client.Initialize(initCtx); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("initialize failed: %w", err)
+	}
+	return client, nil
+}
+
+// rpcURL points a bare server URL at its JSON-RPC endpoint
+func rpcURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Path != "" && u.Path != "/") {
+		return raw
+	}
+	u.Path = "/rpc"
+	return u.String()
+}
+
+// runClient dispatches "tools", "prompts" and "resources" subcommands
+func runClient(group string, args []string) int {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprintf(os.Stderr, "Usage: conduit %s <command> [flags]\n", group)
+		return 2
+	}
+	action, args := args[0], args[1:]
+
+	var toolName string
+	if group == "tools" && action == "call" {
+		if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+			fmt.Fprintln(os.Stderr, "Usage: conduit tools call <name> [--arg key=value ...] [flags]")
+			return 2
+		}
+		toolName, args = args[0], args[1:]
+	}
+
+	switch group + " " + action {
+	case "tools list", "tools call", "prompts list", "resources list":
+	default:
+		fmt.Fprintf(os.Stderr, "conduit: unknown command %q\n", group+" "+action)
+		return 2
+	}
+
+	fs, opts := newClientFlagSet(group+" "+action, toolName != "")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+
+	ctx := context.Background()
+	client, err := opts.connect(ctx, fs.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "conduit: %v\n", err)
+		return 1
+	}
+	defer client.Close()
+
+	session := &clientSession{client: client, opts: opts, out: os.Stdout}
+	switch group + " " + action {
+	case "tools list":
+		err = session.listTools(ctx)
+	case "tools call":
+		err = session.callTool(ctx, toolName, opts.args, opts.rawArgs)
+	case "prompts list":
+		err = session.listPrompts(ctx)
+	case "resources list":
+		err = session.listResources(ctx)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "conduit: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// runREPL starts an interactive session against a server
+func runREPL(args []string) int {
+	fs, opts := newClientFlagSet("repl", false)
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+
+	ctx := context.Background()
+	client, err := opts.connect(ctx, fs.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "conduit: %v\n", err)
+		return 1
+	}
+	defer client.Close()
+
+	session := &clientSession{client: client, opts: opts, out: os.Stdout}
+	session.repl(ctx, os.Stdin)
+	return 0
+}
+
+// clientSession runs client commands and prints their results
+type clientSession struct {
+	client *mcp.Client
+	opts   *clientOptions
+	out    io.Writer
+	tools  []mcp.MCPTool
+}
+
+func (s *clientSession) fetchTools(ctx context.Context) ([]mcp.MCPTool, error) {
+	if s.tools != nil {
+		return s.tools, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.opts.timeout)
+	defer cancel()
+	tools, err := s.client.ListTools(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(tools, func(i, j int) bool { return tools[i].Name < tools[j].Name })
+	s.tools = tools
+	return tools, nil
+}
+
+func (s *clientSession) listTools(ctx context.Context) error {
+	tools, err := s.fetchTools(ctx)
+	if err != nil {
+		return err
+	}
+	if s.opts.json {
+		return s.printJSON(tools)
+	}
+
+	width := 0
+	for _, t := range tools {
+		if len(t.Name) > width {
+			width = len(t.Name)
+		}
+	}
+	for _, t := range tools {
+		fmt.Fprintf(s.out, "%-*s  %s\n", width, t.Name, t.Description)
+		if params := describeParams(t.InputSchema); params != "" {
+			fmt.Fprintf(s.out, "%-*s  (%s)\n", width, "", params)
+		}
+	}
+	return nil
+}
+
+func (s *clientSession) callTool(ctx context.Context, name string, pairs []string, rawArgs string) error {
+	tools, err := s.fetchTools(ctx)
+	if err != nil {
+		return err
+	}
+
+	var schema interface{}
+	for _, t := range tools {
+		if t.Name == name {
+			schema = t.InputSchema
+		}
+	}
+
+	args := map[string]interface{}{}
+	if rawArgs != "" {
+		if err := json.Unmarshal([]byte(rawArgs), &args); err != nil {
+			return fmt.Errorf("invalid --args JSON: %w", err)
+		}
+	}
+	parsed, err := mcp.ParseToolArguments(schema, pairs)
+	if err != nil {
+		return err
+	}
+	for k, v := range parsed {
+		args[k] = v
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.opts.timeout)
+	defer cancel()
+	result, err := s.client.CallTool(ctx, name, args)
+	if err != nil {
+		return err
+	}
+	if s.opts.json {
+		if err := s.printJSON(result); err != nil {
+			return err
+		}
+	} else {
+		for _, c := range result.Content {
+			if c.Type == "text" {
+				fmt.Fprintln(s.out, c.Text)
+			} else {
+				fmt.Fprintf(s.out, "[%s content]\n", c.Type)
+			}
+		}
+	}
+	if result.IsError {
+		return fmt.Errorf("tool %s reported an error", name)
+	}
+	return nil
+}
+
+func (s *clientSession) listPrompts(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, s.opts.timeout)
+	defer cancel()
+	prompts, err := s.client.ListPrompts(ctx)
+	if err != nil {
+		return err
+	}
+	if s.opts.json {
+		return s.printJSON(prompts)
+	}
+	if len(prompts) == 0 {
+		fmt.Fprintln(s.out, "no prompts")
+	}
+	for _, p := range prompts {
+		var args []string
+		for _, a := range p.Arguments {
+			if a.Required {
+				args = append(args, a.Name+"*")
+			} else {
+				args = append(args, a.Name)
+			}
+		}
+		fmt.Fprintf(s.out, "%s  %s", p.Name, p.Description)
+		if len(args) > 0 {
+			fmt.Fprintf(s.out, " (%s)", strings.Join(args, ", "))
+		}
+		fmt.Fprintln(s.out)
+	}
+	return nil
+}
+
+func (s *clientSession) listResources(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, s.opts.timeout)
+	defer cancel()
+	resources, err := s.client.ListResources(ctx)
+	if err != nil {
+		return err
+	}
+	if s.opts.json {
+		return s.printJSON(resources)
+	}
+	if len(resources) == 0 {
+		fmt.Fprintln(s.out, "no resources")
+	}
+	for _, r := range resources {
+		fmt.Fprintf(s.out, "%s  %s  %s\n", r.URI, r.Name, r.MimeType)
+	}
+	return nil
+}
+
+func (s *clientSession) printJSON(v interface{}) error {
+	enc := json.NewEncoder(s.out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// repl reads commands line by line until EOF or "quit"
+func (s *clientSession) repl(ctx context.Context, in io.Reader) {
+	fmt.Fprintln(s.out, `Connected. Type "help" for commands.`)
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(s.out, "mcp> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(s.out)
+			return
+		}
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		var err error
+		switch fields[0] {
+		case "quit", "exit":
+			return
+		case "help":
+			fmt.Fprint(s.out, `Commands:
+  tools                       list tools
+  call <name> [key=value...]  call a tool; values are typed using its schema
+  prompts                     list prompts
+  resources                   list resources
+  json on|off                 toggle raw JSON output
+  trace on|off                toggle JSON-RPC tracing on stderr
+  quit                        leave the session
+`)
+		case "tools":
+			s.tools = nil
+			err = s.listTools(ctx)
+		case "call":
+			if len(fields) < 2 {
+				err = fmt.Errorf("usage: call <name> [key=value...]")
+				break
+			}
+			err = s.callTool(ctx, fields[1], fields[2:], "")
+		case "prompts":
+			err = s.listPrompts(ctx)
+		case "resources":
+			err = s.listResources(ctx)
+		case "json":
+			s.opts.json = len(fields) < 2 || fields[1] == "on"
+		case "trace":
+			if len(fields) < 2 || fields[1] == "on" {
+				s.client.Trace = os.Stderr
+			} else {
+				s.client.Trace = nil
+			}
+		default:
+			err = fmt.Errorf("unknown command %q", fields[0])
+		}
+		if err != nil {
+			fmt.Fprintf(s.out, "error: %v\n", err)
+		}
+	}
+}
+
+// describeParams summarizes a tool's input schema as "name: type" pairs, marking required ones with *
+func describeParams(schema interface{}) string {
+	s, ok := schema.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	properties, _ := s["properties"].(map[string]interface{})
+	required := map[string]bool{}
+	if list, ok := s["required"].([]interface{}); ok {
+		for _, r := range list {
+			if name, ok := r.(string); ok {
+				required[name] = true
+			}
+		}
+	}
+
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		typ := "any"
+		if prop, ok := properties[name].(map[string]interface{}); ok {
+			if t, ok := prop["type"].(string); ok {
+				typ = t
+			}
+		}
+		if required[name] {
+			name += "*"
+		}
+		parts = append(parts, name+": "+typ)
+	}
+	return strings.Join(parts, ", ")
+}
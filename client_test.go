@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benozo/conduit/mcp"
+)
+
+func TestRPCURL(t *testing.T) {
+	tests := map[string]string{
+		"http://localhost:8080":      "http://localhost:8080/rpc",
+		"http://localhost:8080/":     "http://localhost:8080/rpc",
+		"https://example.com/custom": "https://example.com/custom",
+		"http://localhost:8080/rpc":  "http://localhost:8080/rpc",
+	}
+	for in, want := range tests {
+		if got := rpcURL(in); got != want {
+			t.Errorf("rpcURL(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestDescribeParams(t *testing.T) {
+	schema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"text":  map[string]interface{}{"type": "string"},
+			"count": map[string]interface{}{"type": "integer"},
+			"extra": map[string]interface{}{},
+		},
+		"required": []interface{}{"text"},
+	}
+	if got, want := describeParams(schema), "count: integer, extra: any, text*: string"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := describeParams(nil); got != "" {
+		t.Errorf("nil schema described as %q", got)
+	}
+}
+
+// pipeSession connects a client session to an in-process stdio server
+// offering the add tool
+func pipeSession(t *testing.T, out io.Writer) *clientSession {
+	t.Helper()
+	tools := mcp.NewToolRegistry()
+	tools.Register("add", func(params map[string]interface{}, memory *mcp.Memory) (interface{}, error) {
+		return params["a"].(float64) + params["b"].(float64), nil
+	})
+	return pipeSessionWith(t, out, tools)
+}
+
+func pipeSessionWith(t *testing.T, out io.Writer, tools *mcp.ToolRegistry) *clientSession {
+	t.Helper()
+
+	clientIn, serverOut := io.Pipe()
+	serverIn, clientOut := io.Pipe()
+	server := mcp.NewStdioServerWithSchemaProvider(tools, mcp.NewMemory(), addSchema{})
+	server.SetIO(serverIn, serverOut)
+	go server.Run()
+
+	client := mcp.NewClient(mcp.NewIOTransport(clientIn, clientOut))
+	t.Cleanup(func() { client.Close() })
+	if _, err := client.Initialize(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return &clientSession{client: client, opts: &clientOptions{timeout: time.Second}, out: out}
+}
+
+// addSchema declares the add tool's numeric parameters
+type addSchema struct{}
+
+func (addSchema) GetToolMetadata() map[string]interface{} { return nil }
+
+func (addSchema) GetToolSchema(name string) (interface{}, bool) {
+	if name != "add" {
+		return nil, false
+	}
+	return map[string]interface{}{
+		"description": "Add two numbers",
+		"inputSchema": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"a": map[string]interface{}{"type": "number"},
+				"b": map[string]interface{}{"type": "number"},
+			},
+			"required": []interface{}{"a", "b"},
+		},
+	}, true
+}
+
+func TestREPL(t *testing.T) {
+	var out bytes.Buffer
+	session := pipeSession(t, &out)
+	session.repl(context.Background(), strings.NewReader("tools\ncall add a=2 b=3\ncall\nbogus\nprompts\nquit\ncall add a=1 b=1\n"))
+
+	got := out.String()
+	for _, want := range []string{
+		"add  Add two numbers\n     (a*: number, b*: number)\n",
+		"mcp> 5\n",
+		"error: usage: call <name>",
+		`error: unknown command "bogus"`,
+		"no prompts\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output does not contain %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "2\n") {
+		t.Errorf("commands after quit were run:\n%s", got)
+	}
+}
+
+func TestCallToolJSONOutput(t *testing.T) {
+	var out bytes.Buffer
+	session := pipeSession(t, &out)
+	session.opts.json = true
+	if err := session.callTool(context.Background(), "add", []string{"b=1"}, `{"a": 1.5}`); err != nil {
+		t.Fatal(err)
+	}
+	if want := "{\n  \"content\": [\n    {\n      \"type\": \"text\",\n      \"text\": \"2.5\"\n    }\n  ]\n}\n"; out.String() != want {
+		t.Errorf("got:\n%s", out.String())
+	}
+	if err := session.callTool(context.Background(), "add", nil, "{broken"); err == nil {
+		t.Error("expected an error for invalid --args JSON")
+	}
+}
+
+func TestCallToolReportsToolErrors(t *testing.T) {
+	tools := mcp.NewToolRegistry()
+	tools.Register("fail", func(map[string]interface{}, *mcp.Memory) (interface{}, error) {
+		return nil, fmt.Errorf("disk full")
+	})
+	var out bytes.Buffer
+	session := pipeSessionWith(t, &out, tools)
+	err := session.callTool(context.Background(), "fail", nil, "")
+	if err == nil || !strings.Contains(err.Error(), "tool fail reported an error") {
+		t.Errorf("got %v, want the tool's failure as an error", err)
+	}
+	if !strings.Contains(out.String(), "Tool error: disk full") {
+		t.Errorf("failure text not printed:\n%s", out.String())
+	}
+}
@@ -3,4 +3,4 @@
 # This script ensures the correct working directory when launching Conduit
 
 cd "$(dirname "$0")"
-exec go run main.go "$@"
+exec go run . "$@"
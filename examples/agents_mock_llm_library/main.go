@@ -3,10 +3,10 @@ package main
 import (
 	"fmt"
 	"log"
-	"strings"
 
 	"github.com/benozo/conduit/agents"
 	conduit "github.com/benozo/conduit/lib"
+	"github.com/benozo/conduit/lib/mockllm"
 	"github.com/benozo/conduit/lib/tools"
 	"github.com/benozo/conduit/mcp"
 )
@@ -205,96 +205,70 @@ func main() {
 
 // createMockLLM creates a mock LLM that demonstrates intelligent reasoning
 func createMockLLM() mcp.ModelFunc {
-	return func(ctx mcp.ContextInput, req mcp.MCPRequest, memory *mcp.Memory, onToken mcp.StreamCallback) (string, error) {
-		query := fmt.Sprintf("%v", ctx.Inputs["query"])
-
-		// Simulate LLM reasoning based on the query content
-		if strings.Contains(query, "Calculate") && strings.Contains(query, "total cost") {
-			// Mathematical reasoning response
-			return `{
-  "analysis": "This is a multiplication problem. I need to calculate 3 items × $15 each. I should use the multiply tool to get the result.",
-  "steps": [
-    {
-      "name": "calculate_total",
-      "description": "Multiply number of items by price per item",
-      "tool": "multiply",
-      "input": {"a": 3.0, "b": 15.0}
-    },
-    {
-      "name": "store_result",
-      "description": "Remember the calculation result",
-      "tool": "remember",
-      "input": {"key": "last_calculation", "value": "3 items × $15 = $45"}
-    }
-  ],
-  "reasoning": "I identified this as a multiplication problem and planned to use the multiply tool followed by storing the result for reference."
-}`, nil
-		}
-
-		if strings.Contains(query, "Analyze") && strings.Contains(query, "text") {
-			// Text analysis reasoning response
-			return `{
-  "analysis": "This text is about AI and technology. I should analyze its content and store it for future reference since it seems important.",
-  "steps": [
-    {
-      "name": "count_words",
-      "description": "Count words in the text to understand its length",
-      "tool": "word_count",
-      "input": {"text": "Artificial Intelligence is transforming technology"}
-    },
-    {
-      "name": "store_content",
-      "description": "Store this important text about AI",
-      "tool": "remember",
-      "input": {"key": "ai_insight", "value": "Artificial Intelligence is transforming technology"}
-    },
-    {
-      "name": "generate_id",
-      "description": "Generate a unique ID for this analysis session",
-      "tool": "uuid",
-      "input": {}
-    }
-  ],
-  "reasoning": "I analyzed the text content, decided to count words for metrics, store the important content, and generate a session ID for tracking."
-}`, nil
-		}
-
-		if strings.Contains(query, "7") && strings.Contains(query, "8") {
-			// Direct calculation response
-			return `{
-  "analysis": "I need to calculate 7 × 8 and store the result as requested.",
-  "steps": [
-    {
-      "name": "calculate_product",
-      "description": "Multiply 7 by 8",
-      "tool": "multiply",
-      "input": {"a": 7.0, "b": 8.0}
-    },
-    {
-      "name": "store_calculation",
-      "description": "Store the calculation result",
-      "tool": "remember",
-      "input": {"key": "direct_calc", "value": "7 × 8 = 56"}
-    }
-  ],
-  "reasoning": "Direct multiplication requested, I'll use the multiply tool and store the result."
-}`, nil
-		}
-
+	return mockllm.New().
+		// Mathematical reasoning response
+		On(mockllm.Contains("Calculate", "total cost"), mockllm.Plan(
+			"This is a multiplication problem. I need to calculate 3 items × $15 each. I should use the multiply tool to get the result.",
+			mockllm.Step{
+				Name:        "calculate_total",
+				Description: "Multiply number of items by price per item",
+				Tool:        "multiply",
+				Input:       map[string]interface{}{"a": 3.0, "b": 15.0},
+			},
+			mockllm.Step{
+				Name:        "store_result",
+				Description: "Remember the calculation result",
+				Tool:        "remember",
+				Input:       map[string]interface{}{"key": "last_calculation", "value": "3 items × $15 = $45"},
+			},
+		)).
+		// Text analysis reasoning response
+		On(mockllm.Contains("Analyze", "text"), mockllm.Plan(
+			"This text is about AI and technology. I should analyze its content and store it for future reference since it seems important.",
+			mockllm.Step{
+				Name:        "count_words",
+				Description: "Count words in the text to understand its length",
+				Tool:        "word_count",
+				Input:       map[string]interface{}{"text": "Artificial Intelligence is transforming technology"},
+			},
+			mockllm.Step{
+				Name:        "store_content",
+				Description: "Store this important text about AI",
+				Tool:        "remember",
+				Input:       map[string]interface{}{"key": "ai_insight", "value": "Artificial Intelligence is transforming technology"},
+			},
+			mockllm.Step{
+				Name:        "generate_id",
+				Description: "Generate a unique ID for this analysis session",
+				Tool:        "uuid",
+			},
+		)).
+		// Direct calculation response
+		On(mockllm.Contains("7", "8"), mockllm.Plan(
+			"I need to calculate 7 × 8 and store the result as requested.",
+			mockllm.Step{
+				Name:        "calculate_product",
+				Description: "Multiply 7 by 8",
+				Tool:        "multiply",
+				Input:       map[string]interface{}{"a": 7.0, "b": 8.0},
+			},
+			mockllm.Step{
+				Name:        "store_calculation",
+				Description: "Store the calculation result",
+				Tool:        "remember",
+				Input:       map[string]interface{}{"key": "direct_calc", "value": "7 × 8 = 56"},
+			},
+		)).
 		// Default reasoning response
-		return `{
-  "analysis": "I need to analyze this task and determine the best approach using available tools.",
-  "steps": [
-    {
-      "name": "general_analysis",
-      "description": "Perform general analysis of the task",
-      "tool": "uuid",
-      "input": {}
-    }
-  ],
-  "reasoning": "This appears to be a general task that requires basic analysis."
-}`, nil
-	}
+		Default(mockllm.Plan(
+			"I need to analyze this task and determine the best approach using available tools.",
+			mockllm.Step{
+				Name:        "general_analysis",
+				Description: "Perform general analysis of the task",
+				Tool:        "uuid",
+			},
+		)).
+		ModelFunc()
 }
 
 // printTaskSteps prints the execution steps with LLM reasoning
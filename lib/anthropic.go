@@ -0,0 +1,431 @@
+package conduit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/benozo/conduit/lib/httpclient"
+	"github.com/benozo/conduit/mcp"
+)
+
+// Anthropic Messages API defaults
+const (
+	AnthropicMessagesURL      = "https://api.anthropic.com/v1/messages"
+	AnthropicVersion          = "2023-06-01"
+	AnthropicDefaultModel     = "claude-sonnet-4-5"
+	anthropicDefaultMaxTokens = 1024
+)
+
+// AnthropicRequest is a Messages API request
+type AnthropicRequest struct {
+	Model         string             `json:"model"`
+	System        string             `json:"system,omitempty"`
+	Messages      []AnthropicMessage `json:"messages"`
+	Tools         []AnthropicTool    `json:"tools,omitempty"`
+	MaxTokens     int                `json:"max_tokens"`
+	Temperature   float64            `json:"temperature,omitempty"`
+	TopP          float64            `json:"top_p,omitempty"`
+	TopK          int                `json:"top_k,omitempty"`
+	StopSequences []string           `json:"stop_sequences,omitempty"`
+	Stream        bool               `json:"stream,omitempty"`
+}
+
+// AnthropicMessage is one user or assistant turn made of content blocks
+type AnthropicMessage struct {
+	Role    string                  `json:"role"`
+	Content []AnthropicContentBlock `json:"content"`
+}
+
+// AnthropicContentBlock is a text, image, tool_use or tool_result block
+type AnthropicContentBlock struct {
+	Type string `json:"type"`
+	Text string `json:"text,omitempty"`
+
+	// image
+	Source *AnthropicImageSource `json:"source,omitempty"`
+
+	// tool_use
+	ID    string                 `json:"id,omitempty"`
+	Name  string                 `json:"name,omitempty"`
+	Input map[string]interface{} `json:"input,omitempty"`
+
+	// tool_result
+	ToolUseID string `json:"tool_use_id,omitempty"`
+	Content   string `json:"content,omitempty"`
+	// Blocks, when set, are sent as the tool_result content in place of
+	// Content, so results can include images
+	Blocks []AnthropicContentBlock `json:"-"`
+}
+
+// AnthropicImageSource is inline base64 data or a URL the API fetches
+type AnthropicImageSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+// MarshalJSON sends Blocks as the content array when present, and always
+// sends a tool_use input, which the API requires even when empty
+func (b AnthropicContentBlock) MarshalJSON() ([]byte, error) {
+	type plain AnthropicContentBlock
+	if b.Type == "tool_use" {
+		input := b.Input
+		if input == nil {
+			input = map[string]interface{}{}
+		}
+		return json.Marshal(struct {
+			plain
+			Input map[string]interface{} `json:"input"`
+		}{plain(b), input})
+	}
+	if len(b.Blocks) == 0 {
+		return json.Marshal(plain(b))
+	}
+	return json.Marshal(struct {
+		plain
+		Content []AnthropicContentBlock `json:"content"`
+	}{plain(b), b.Blocks})
+}
+
+// anthropicContent builds the text block of a message followed by its images
+func anthropicContent(text string, images []mcp.Image) []AnthropicContentBlock {
+	var blocks []AnthropicContentBlock
+	if text != "" {
+		blocks = append(blocks, AnthropicContentBlock{Type: "text", Text: text})
+	}
+	for _, img := range images {
+		source := &AnthropicImageSource{Type: "base64", MediaType: img.MIMEType, Data: img.Base64()}
+		if img.Remote() {
+			source = &AnthropicImageSource{Type: "url", URL: img.URL}
+		}
+		blocks = append(blocks, AnthropicContentBlock{Type: "image", Source: source})
+	}
+	return blocks
+}
+
+// AnthropicTool describes a tool the model may use
+type AnthropicTool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	InputSchema map[string]interface{} `json:"input_schema"`
+}
+
+// AnthropicUsage reports token usage
+type AnthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// AnthropicResponse is a non-streaming Messages API response
+type AnthropicResponse struct {
+	Model      string                  `json:"model"`
+	Content    []AnthropicContentBlock `json:"content"`
+	StopReason string                  `json:"stop_reason"`
+	Usage      AnthropicUsage          `json:"usage"`
+}
+
+// AnthropicChatModel is a ChatModel for the Anthropic Messages API
+type AnthropicChatModel struct {
+	URL     string
+	APIKey  string
+	Version string
+	// Model, MaxTokens and Temperature are defaults for requests that don't set them
+	Model       string
+	MaxTokens   int
+	Temperature float64
+	TopK        int
+	Client      *httpclient.Client
+}
+
+// NewAnthropicChatModel creates a chat model from config. The URL override
+// makes it possible to point at a proxy or a local stub server.
+func NewAnthropicChatModel(config *ModelConfig) (*AnthropicChatModel, error) {
+	if config == nil {
+		return nil, fmt.Errorf("model config is nil")
+	}
+
+	m := &AnthropicChatModel{
+		URL:         config.URL,
+		APIKey:      config.APIKey,
+		Version:     AnthropicVersion,
+		Model:       config.Model,
+		MaxTokens:   config.MaxTokens,
+		Temperature: config.Temperature,
+		TopK:        config.TopK,
+		Client:      newModelClient("anthropic", config.HTTP, 300*time.Second),
+	}
+	if m.URL == "" {
+		m.URL = AnthropicMessagesURL
+	}
+	if m.Model == "" {
+		m.Model = AnthropicDefaultModel
+	}
+	if m.MaxTokens <= 0 {
+		m.MaxTokens = anthropicDefaultMaxTokens
+	}
+	return m, nil
+}
+
+// Chat sends the conversation and returns the reply. Content is streamed to
+// req.OnToken when it is set.
+func (m *AnthropicChatModel) Chat(ctx context.Context, req mcp.ChatRequest) (*mcp.ChatResponse, error) {
+	payload := m.buildRequest(req)
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal Anthropic request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", m.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Anthropic request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-api-key", m.APIKey)
+	httpReq.Header.Set("anthropic-version", m.Version)
+	if payload.Stream {
+		httpReq.Header.Set("Accept", "text/event-stream")
+	}
+
+	slog.DebugContext(ctx, "anthropic request", "model", payload.Model, "messages", len(payload.Messages), "tools", len(payload.Tools), "stream", payload.Stream)
+
+	client := m.Client
+	if client == nil {
+		client = httpclient.New("anthropic", httpclient.Options{})
+	}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call Anthropic: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result AnthropicResponse
+	if payload.Stream {
+		result, err = readAnthropicStream(resp.Body, req.OnToken)
+	} else {
+		err = json.NewDecoder(resp.Body).Decode(&result)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode Anthropic response: %w", err)
+	}
+
+	if result.Model == "" {
+		result.Model = payload.Model
+	}
+	observeTokens(ctx, "anthropic", result.Model, result.Usage.InputTokens, result.Usage.OutputTokens)
+
+	message := mcp.ChatMessage{Role: mcp.RoleAssistant}
+	var text strings.Builder
+	for _, block := range result.Content {
+		switch block.Type {
+		case "text":
+			text.WriteString(block.Text)
+		case "tool_use":
+			args := block.Input
+			if args == nil {
+				args = map[string]interface{}{}
+			}
+			message.ToolCalls = append(message.ToolCalls, mcp.ChatToolCall{ID: block.ID, Name: block.Name, Arguments: args})
+		}
+	}
+	message.Content = text.String()
+
+	return &mcp.ChatResponse{
+		Model:      result.Model,
+		Message:    message,
+		StopReason: anthropicStopReason(result.StopReason),
+		Usage: mcp.Usage{
+			PromptTokens:     result.Usage.InputTokens,
+			CompletionTokens: result.Usage.OutputTokens,
+			TotalTokens:      result.Usage.InputTokens + result.Usage.OutputTokens,
+		},
+	}, nil
+}
+
+// buildRequest converts a ChatRequest to the Messages API format. System
+// messages move to the system field, and tool results become tool_result
+// blocks in a user turn. Consecutive turns from the same role are merged.
+func (m *AnthropicChatModel) buildRequest(req mcp.ChatRequest) AnthropicRequest {
+	payload := AnthropicRequest{
+		Model:         req.Model,
+		MaxTokens:     req.MaxTokens,
+		Temperature:   req.Temperature,
+		TopP:          req.TopP,
+		TopK:          req.TopK,
+		StopSequences: req.Stop,
+		Stream:        req.OnToken != nil,
+	}
+	if payload.Model == "" {
+		payload.Model = m.Model
+	}
+	if payload.MaxTokens == 0 {
+		payload.MaxTokens = m.MaxTokens
+	}
+	if payload.Temperature == 0 {
+		payload.Temperature = m.Temperature
+	}
+	if payload.TopK == 0 {
+		payload.TopK = m.TopK
+	}
+
+	var system []string
+	for _, msg := range req.Messages {
+		var role string
+		var blocks []AnthropicContentBlock
+		switch msg.Role {
+		case mcp.RoleSystem:
+			system = append(system, msg.Content)
+			continue
+		case mcp.RoleTool:
+			role = "user"
+			result := AnthropicContentBlock{Type: "tool_result", ToolUseID: msg.ToolCallID, Content: msg.Content}
+			if len(msg.Images) > 0 {
+				result.Blocks = anthropicContent(msg.Content, msg.Images)
+			}
+			blocks = []AnthropicContentBlock{result}
+		case mcp.RoleAssistant:
+			role = "assistant"
+			if msg.Content != "" {
+				blocks = append(blocks, AnthropicContentBlock{Type: "text", Text: msg.Content})
+			}
+			for _, call := range msg.ToolCalls {
+				blocks = append(blocks, AnthropicContentBlock{Type: "tool_use", ID: call.ID, Name: call.Name, Input: call.Arguments})
+			}
+		default:
+			role = "user"
+			blocks = anthropicContent(msg.Content, msg.Images)
+		}
+		if len(blocks) == 0 {
+			continue
+		}
+
+		if n := len(payload.Messages); n > 0 && payload.Messages[n-1].Role == role {
+			payload.Messages[n-1].Content = append(payload.Messages[n-1].Content, blocks...)
+		} else {
+			payload.Messages = append(payload.Messages, AnthropicMessage{Role: role, Content: blocks})
+		}
+	}
+	payload.System = strings.Join(system, "\n\n")
+
+	for _, tool := range req.Tools {
+		payload.Tools = append(payload.Tools, AnthropicTool{
+			Name:        tool.Name,
+			Description: tool.Description,
+			InputSchema: tool.Parameters,
+		})
+	}
+	return payload
+}
+
+// anthropicStreamEvent covers the fields used from every streaming event type
+type anthropicStreamEvent struct {
+	Type         string                `json:"type"`
+	Index        int                   `json:"index"`
+	Message      AnthropicResponse     `json:"message"`
+	ContentBlock AnthropicContentBlock `json:"content_block"`
+	Delta        struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		PartialJSON string `json:"partial_json"`
+		StopReason  string `json:"stop_reason"`
+	} `json:"delta"`
+	Usage AnthropicUsage `json:"usage"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// readAnthropicStream assembles a response from server-sent events, passing
+// text deltas to onToken as they arrive
+func readAnthropicStream(body io.Reader, onToken func(string)) (AnthropicResponse, error) {
+	var result AnthropicResponse
+	toolInputs := map[int]*strings.Builder{}
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		var event anthropicStreamEvent
+		if err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), &event); err != nil {
+			return result, fmt.Errorf("invalid stream event: %w", err)
+		}
+
+		switch event.Type {
+		case "message_start":
+			result.Model = event.Message.Model
+			result.Usage.InputTokens = event.Message.Usage.InputTokens
+		case "content_block_start":
+			for len(result.Content) <= event.Index {
+				result.Content = append(result.Content, AnthropicContentBlock{})
+			}
+			result.Content[event.Index] = event.ContentBlock
+			if event.ContentBlock.Type == "tool_use" {
+				toolInputs[event.Index] = &strings.Builder{}
+			}
+		case "content_block_delta":
+			if event.Index >= len(result.Content) {
+				continue
+			}
+			switch event.Delta.Type {
+			case "text_delta":
+				result.Content[event.Index].Text += event.Delta.Text
+				if onToken != nil && event.Delta.Text != "" {
+					onToken(event.Delta.Text)
+				}
+			case "input_json_delta":
+				if b, ok := toolInputs[event.Index]; ok {
+					b.WriteString(event.Delta.PartialJSON)
+				}
+			}
+		case "content_block_stop":
+			if b, ok := toolInputs[event.Index]; ok && event.Index < len(result.Content) {
+				input := map[string]interface{}{}
+				if raw := strings.TrimSpace(b.String()); raw != "" {
+					if err := json.Unmarshal([]byte(raw), &input); err != nil {
+						return result, fmt.Errorf("invalid tool input for %s: %w", result.Content[event.Index].Name, err)
+					}
+				}
+				result.Content[event.Index].Input = input
+			}
+		case "message_delta":
+			if event.Delta.StopReason != "" {
+				result.StopReason = event.Delta.StopReason
+			}
+			result.Usage.OutputTokens = event.Usage.OutputTokens
+		case "error":
+			return result, fmt.Errorf("stream error %s: %s", event.Error.Type, event.Error.Message)
+		}
+	}
+	return result, scanner.Err()
+}
+
+// anthropicStopReason maps stop_reason onto the mcp stop reasons
+func anthropicStopReason(reason string) string {
+	switch reason {
+	case "tool_use":
+		return mcp.StopReasonToolCalls
+	case "max_tokens":
+		return mcp.StopReasonLength
+	default:
+		return mcp.StopReasonEnd
+	}
+}
+
+// CreateAnthropicModelWithConfig creates an Anthropic model function with
+// configuration. An optional "system" input becomes the system prompt.
+func CreateAnthropicModelWithConfig(config *ModelConfig) mcp.ModelFunc {
+	chat, _ := NewAnthropicChatModel(config)
+	return instrumentModel("anthropic", fixedModel(chat.Model), mcp.ModelFuncFromChat(chat))
+}
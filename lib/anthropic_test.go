@@ -0,0 +1,193 @@
+package conduit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/benozo/conduit/mcp"
+)
+
+// anthropicStub serves canned Messages API replies and records the last request
+func anthropicStub(t *testing.T, reply func(w http.ResponseWriter, req AnthropicRequest)) (*httptest.Server, *AnthropicRequest, *http.Header) {
+	t.Helper()
+	var got AnthropicRequest
+	var headers http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header.Clone()
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("invalid request body: %v", err)
+		}
+		reply(w, got)
+	}))
+	t.Cleanup(server.Close)
+	return server, &got, &headers
+}
+
+func TestAnthropicChat(t *testing.T) {
+	server, got, headers := anthropicStub(t, func(w http.ResponseWriter, _ AnthropicRequest) {
+		fmt.Fprint(w, `{
+			"model": "claude-test",
+			"content": [
+				{"type": "text", "text": "Let me add those."},
+				{"type": "tool_use", "id": "toolu_1", "name": "add", "input": {"a": 1, "b": 2}}
+			],
+			"stop_reason": "tool_use",
+			"usage": {"input_tokens": 12, "output_tokens": 7}
+		}`)
+	})
+
+	model, err := NewAnthropicChatModel(&ModelConfig{URL: server.URL, APIKey: "sk-test", Model: "claude-test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := model.Chat(context.Background(), mcp.ChatRequest{
+		Messages: []mcp.ChatMessage{
+			{Role: mcp.RoleSystem, Content: "be brief"},
+			{Role: mcp.RoleUser, Content: "what is 1+2?"},
+			{Role: mcp.RoleAssistant, ToolCalls: []mcp.ChatToolCall{{ID: "toolu_0", Name: "lookup"}}},
+			{Role: mcp.RoleTool, ToolCallID: "toolu_0", Content: "nothing found"},
+			{Role: mcp.RoleUser, Content: "try adding"},
+		},
+		Tools: []mcp.ToolDefinition{{Name: "add", Description: "Add", Parameters: map[string]interface{}{"type": "object"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if headers.Get("x-api-key") != "sk-test" || headers.Get("anthropic-version") != AnthropicVersion {
+		t.Errorf("headers %v", *headers)
+	}
+	if got.System != "be brief" || got.MaxTokens != anthropicDefaultMaxTokens || got.Stream {
+		t.Errorf("request system=%q max_tokens=%d stream=%v", got.System, got.MaxTokens, got.Stream)
+	}
+	// The tool result and the following user message share one user turn
+	var roles []string
+	for _, msg := range got.Messages {
+		roles = append(roles, msg.Role)
+	}
+	if !reflect.DeepEqual(roles, []string{"user", "assistant", "user"}) {
+		t.Errorf("roles %v", roles)
+	}
+	// A tool call without arguments still sends "input": {}
+	if call := got.Messages[1].Content[0]; call.Type != "tool_use" || call.Input == nil {
+		t.Errorf("replayed tool call %+v", call)
+	}
+	if last := got.Messages[2].Content; len(last) != 2 || last[0].Type != "tool_result" || last[0].ToolUseID != "toolu_0" || last[1].Text != "try adding" {
+		t.Errorf("merged user turn %+v", last)
+	}
+	if len(got.Tools) != 1 || got.Tools[0].Name != "add" || got.Tools[0].InputSchema["type"] != "object" {
+		t.Errorf("tools %+v", got.Tools)
+	}
+
+	if resp.Message.Content != "Let me add those." || resp.StopReason != mcp.StopReasonToolCalls {
+		t.Errorf("response %+v", resp)
+	}
+	want := []mcp.ChatToolCall{{ID: "toolu_1", Name: "add", Arguments: map[string]interface{}{"a": float64(1), "b": float64(2)}}}
+	if !reflect.DeepEqual(resp.Message.ToolCalls, want) {
+		t.Errorf("tool calls %+v", resp.Message.ToolCalls)
+	}
+	if resp.Usage != (mcp.Usage{PromptTokens: 12, CompletionTokens: 7, TotalTokens: 19}) {
+		t.Errorf("usage %+v", resp.Usage)
+	}
+}
+
+func TestAnthropicStream(t *testing.T) {
+	events := []string{
+		`{"type":"message_start","message":{"model":"claude-test","usage":{"input_tokens":5}}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hel"}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"lo"}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"word_count"}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"text\": \"a"}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":" b\"}"}}`,
+		`{"type":"content_block_stop","index":1}`,
+		`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":9}}`,
+		`{"type":"message_stop"}`,
+	}
+	server, got, headers := anthropicStub(t, func(w http.ResponseWriter, _ AnthropicRequest) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range events {
+			fmt.Fprintf(w, "event: x\ndata: %s\n\n", event)
+		}
+	})
+
+	model, _ := NewAnthropicChatModel(&ModelConfig{URL: server.URL})
+	var tokens []string
+	resp, err := model.Chat(context.Background(), mcp.ChatRequest{
+		Messages: []mcp.ChatMessage{{Role: mcp.RoleUser, Content: "hi"}},
+		OnToken:  func(token string) { tokens = append(tokens, token) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !got.Stream || headers.Get("Accept") != "text/event-stream" || got.Model != AnthropicDefaultModel {
+		t.Errorf("request stream=%v accept=%q model=%q", got.Stream, headers.Get("Accept"), got.Model)
+	}
+	if !reflect.DeepEqual(tokens, []string{"Hel", "lo"}) || resp.Message.Content != "Hello" {
+		t.Errorf("tokens %v, content %q", tokens, resp.Message.Content)
+	}
+	want := []mcp.ChatToolCall{{ID: "toolu_1", Name: "word_count", Arguments: map[string]interface{}{"text": "a b"}}}
+	if !reflect.DeepEqual(resp.Message.ToolCalls, want) {
+		t.Errorf("tool calls %+v", resp.Message.ToolCalls)
+	}
+	if resp.Model != "claude-test" || resp.StopReason != mcp.StopReasonToolCalls || resp.Usage.TotalTokens != 14 {
+		t.Errorf("response model=%q stop=%q usage=%+v", resp.Model, resp.StopReason, resp.Usage)
+	}
+}
+
+func TestAnthropicErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply func(w http.ResponseWriter)
+		want  string
+	}{
+		{"status", func(w http.ResponseWriter) {
+			http.Error(w, `{"error":{"message":"bad key"}}`, http.StatusUnauthorized)
+		}, "status 401"},
+		{"stream error", func(w http.ResponseWriter) {
+			fmt.Fprint(w, "data: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"busy\"}}\n\n")
+		}, "overloaded_error: busy"},
+		{"bad tool input", func(w http.ResponseWriter) {
+			fmt.Fprint(w, "data: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"tool_use\",\"name\":\"t\"}}\n\n")
+			fmt.Fprint(w, "data: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"input_json_delta\",\"partial_json\":\"{oops\"}}\n\n")
+			fmt.Fprint(w, "data: {\"type\":\"content_block_stop\",\"index\":0}\n\n")
+		}, "invalid tool input for t"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, _, _ := anthropicStub(t, func(w http.ResponseWriter, _ AnthropicRequest) { tt.reply(w) })
+			model, _ := NewAnthropicChatModel(&ModelConfig{URL: server.URL})
+			_, err := model.Chat(context.Background(), mcp.ChatRequest{
+				Messages: []mcp.ChatMessage{{Role: mcp.RoleUser, Content: "hi"}},
+				OnToken:  func(string) {},
+			})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnthropicModelFunc(t *testing.T) {
+	server, got, _ := anthropicStub(t, func(w http.ResponseWriter, _ AnthropicRequest) {
+		fmt.Fprint(w, `{"content":[{"type":"text","text":"pong"}],"stop_reason":"end_turn"}`)
+	})
+
+	model := CreateAnthropicModelWithConfig(&ModelConfig{URL: server.URL, Model: "claude-test"})
+	input := mcp.ContextInput{Inputs: map[string]interface{}{"query": "ping", "system": "reply pong"}}
+	text, err := model(input, mcp.MCPRequest{}, mcp.NewMemory(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "pong" || got.System != "reply pong" || got.Model != "claude-test" {
+		t.Errorf("text %q, system %q, model %q", text, got.System, got.Model)
+	}
+}
@@ -0,0 +1,51 @@
+package conduit
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/benozo/conduit/lib/cache"
+	"github.com/benozo/conduit/mcp"
+)
+
+// caches holds the cache opened for each config section, so every model
+// built from the same config shares one LRU and one store connection
+var caches sync.Map // *cache.Config -> *cache.Cache
+
+// modelCache returns the response cache configured for a model, or nil
+func modelCache(config *ModelConfig) (*cache.Cache, error) {
+	if config.Cache == nil {
+		return nil, nil
+	}
+	if c, ok := caches.Load(config.Cache); ok {
+		return c.(*cache.Cache), nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	c, err := config.Cache.Open(ctx)
+	if err != nil {
+		return nil, err
+	}
+	actual, _ := caches.LoadOrStore(config.Cache, c)
+	return actual.(*cache.Cache), nil
+}
+
+// cacheTarget describes a configured model to the cache
+func cacheTarget(provider string, config *ModelConfig) cache.Target {
+	return cache.Target{Provider: provider, Model: config.Model, Temperature: config.Temperature}
+}
+
+// cachedChat wraps chat with the model's response cache, if configured.
+// Factories that can't return an error serve uncached when the cache fails
+// to open.
+func cachedChat(provider string, config *ModelConfig, chat mcp.ChatModel) mcp.ChatModel {
+	c, err := modelCache(config)
+	if err != nil {
+		slog.Warn("model cache disabled", "provider", provider, "error", err)
+		return chat
+	}
+	return c.WrapChat(cacheTarget(provider, config), chat)
+}
@@ -0,0 +1,377 @@
+// Package cache serves repeated model calls from a cache. Calls are keyed by
+// provider, model, normalized prompt or messages and sampling parameters;
+// entries live in an in-memory LRU backed by an optional persistent store.
+// With an embedding provider, a miss can still be served by a cached call
+// whose prompt is semantically close enough.
+//
+//	c := cache.New(cache.Options{TTL: 24 * time.Hour, Store: diskStore})
+//	model = c.WrapModel(cache.Target{Provider: "openai", Model: "gpt-4o-mini"}, model)
+//
+// Calls sampled with a temperature above zero are not cached unless
+// Options.CacheSampled is set, since their output is meant to vary.
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/benozo/conduit/lib/metrics"
+	"github.com/benozo/conduit/lib/rag"
+	"github.com/benozo/conduit/lib/usage"
+	"github.com/benozo/conduit/mcp"
+)
+
+// DefaultThreshold is the cosine similarity a semantic match needs when
+// Options.Threshold is unset
+const DefaultThreshold = 0.95
+
+// Options configure a Cache
+type Options struct {
+	// Capacity is the number of entries kept in memory (default 1000)
+	Capacity int
+	// TTL is how long entries are served; zero keeps them until evicted
+	TTL time.Duration
+	// Store persists entries beyond the in-memory LRU, e.g. a DiskStore or
+	// PostgresStore; nil keeps entries in memory only
+	Store Store
+	// CacheSampled caches calls with a temperature above zero too
+	CacheSampled bool
+	// Embedder enables semantic matching of prompts
+	Embedder rag.EmbeddingProvider
+	// Threshold is the cosine similarity a semantic match needs
+	Threshold float64
+}
+
+// Cache serves repeated model calls. It is safe for concurrent use.
+type Cache struct {
+	memory    *MemoryStore
+	store     Store
+	ttl       time.Duration
+	sampled   bool
+	embedder  rag.EmbeddingProvider
+	threshold float64
+}
+
+// New creates a cache
+func New(opts Options) *Cache {
+	threshold := opts.Threshold
+	if threshold <= 0 {
+		threshold = DefaultThreshold
+	}
+	return &Cache{
+		memory:    NewMemoryStore(opts.Capacity),
+		store:     opts.Store,
+		ttl:       opts.TTL,
+		sampled:   opts.CacheSampled,
+		embedder:  opts.Embedder,
+		threshold: threshold,
+	}
+}
+
+// Target describes the model behind a wrapped function. Model and
+// Temperature are the defaults used when a request doesn't set them.
+type Target struct {
+	Provider    string
+	Model       string
+	Temperature float64
+}
+
+// Request results recorded in conduit_model_cache_requests_total
+const (
+	ResultHit         = "hit"
+	ResultSemanticHit = "semantic_hit"
+	ResultMiss        = "miss"
+	ResultBypass      = "bypass"
+)
+
+type bypassKey struct{}
+
+// NoCache returns a context whose model calls skip the cache, both for
+// reading and writing
+func NoCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bypassKey{}, true)
+}
+
+func bypassed(ctx context.Context) bool {
+	skip, _ := ctx.Value(bypassKey{}).(bool)
+	return skip
+}
+
+// WrapModel caches fn's responses. Streaming callers receive a cached
+// response as a single token. A nil cache returns fn unchanged.
+func (c *Cache) WrapModel(t Target, fn mcp.ModelFunc) mcp.ModelFunc {
+	if c == nil {
+		return fn
+	}
+	return func(ctx mcp.ContextInput, req mcp.MCPRequest, memory *mcp.Memory, onToken mcp.StreamCallback) (string, error) {
+		model := pick(req.Model, t.Model)
+		l, ok := modelLookup(t.Provider, model, ctx.Inputs, req)
+		if !ok || c.bypass(ctx.Context(), req.Temperature, t.Temperature) {
+			metrics.ObserveCacheRequest(t.Provider, ResultBypass)
+			return fn(ctx, req, memory, onToken)
+		}
+
+		entry, embedding := c.get(ctx.Context(), t.Provider, l)
+		if entry != nil {
+			usage.Cached(ctx.Context())
+			if onToken != nil && entry.Output != "" {
+				onToken(ctx.ContextID, entry.Output)
+			}
+			return entry.Output, nil
+		}
+
+		output, err := fn(ctx, req, memory, onToken)
+		if err != nil {
+			return output, err
+		}
+		c.put(ctx.Context(), t.Provider, model, l, embedding, &Entry{Output: output})
+		return output, nil
+	}
+}
+
+// WrapChat caches chat's responses, including tool-call requests, so a tool
+// loop replays the same calls. Tools themselves still run. A nil cache
+// returns chat unchanged.
+func (c *Cache) WrapChat(t Target, chat mcp.ChatModel) mcp.ChatModel {
+	if c == nil {
+		return chat
+	}
+	return mcp.ChatModelFunc(func(ctx context.Context, req mcp.ChatRequest) (*mcp.ChatResponse, error) {
+		model := pick(req.Model, t.Model)
+		l, ok := chatLookup(t.Provider, model, req)
+		if !ok || c.bypass(ctx, req.Temperature, t.Temperature) {
+			metrics.ObserveCacheRequest(t.Provider, ResultBypass)
+			return chat.Chat(ctx, req)
+		}
+
+		entry, embedding := c.get(ctx, t.Provider, l)
+		if entry != nil && entry.Response != nil {
+			usage.Cached(ctx)
+			resp := cloneResponse(entry.Response)
+			if req.OnToken != nil && resp.Message.Content != "" {
+				req.OnToken(resp.Message.Content)
+			}
+			return resp, nil
+		}
+
+		resp, err := chat.Chat(ctx, req)
+		if err != nil || resp == nil {
+			return resp, err
+		}
+		c.put(ctx, t.Provider, model, l, embedding, &Entry{Response: cloneResponse(resp)})
+		return resp, nil
+	})
+}
+
+// cloneResponse copies a response's slices, so callers such as the tool
+// loop, which fills in missing call IDs, can't change a cached entry
+func cloneResponse(resp *mcp.ChatResponse) *mcp.ChatResponse {
+	clone := *resp
+	clone.Message.ToolCalls = append([]mcp.ChatToolCall(nil), resp.Message.ToolCalls...)
+	clone.Message.Images = append([]mcp.Image(nil), resp.Message.Images...)
+	return &clone
+}
+
+// bypass reports whether a call must not use the cache: the context asks
+// so, or the call is sampled and sampled calls aren't cached
+func (c *Cache) bypass(ctx context.Context, requested, fallback float64) bool {
+	if bypassed(ctx) {
+		return true
+	}
+	temperature := requested
+	if temperature == 0 {
+		temperature = fallback
+	}
+	return temperature > 0 && !c.sampled
+}
+
+// get looks a call up in memory, then in the store, then semantically. The
+// prompt's embedding is returned so a miss can be stored with it.
+func (c *Cache) get(ctx context.Context, provider string, l lookup) (*Entry, []float32) {
+	if entry, _ := c.memory.Get(ctx, l.partition, l.key); entry != nil {
+		metrics.ObserveCacheRequest(provider, ResultHit)
+		return entry, nil
+	}
+	if c.store != nil {
+		entry, err := c.store.Get(ctx, l.partition, l.key)
+		if err != nil {
+			slog.WarnContext(ctx, "model cache read failed", "provider", provider, "error", err)
+		}
+		if entry != nil {
+			c.memory.Put(ctx, entry)
+			metrics.ObserveCacheRequest(provider, ResultHit)
+			return entry, nil
+		}
+	}
+
+	if c.embedder == nil || l.prompt == "" {
+		metrics.ObserveCacheRequest(provider, ResultMiss)
+		return nil, nil
+	}
+	embedding, err := c.embedder.Embed(ctx, l.prompt)
+	if err != nil {
+		slog.WarnContext(ctx, "model cache embedding failed", "provider", provider, "error", err)
+		metrics.ObserveCacheRequest(provider, ResultMiss)
+		return nil, nil
+	}
+	if match := c.nearest(ctx, l.partition, embedding); match != nil {
+		// Remember the match under this prompt's key so repeats are exact hits
+		alias := *match
+		alias.Key, alias.Prompt, alias.Embedding = l.key, l.prompt, embedding
+		c.memory.Put(ctx, &alias)
+		metrics.ObserveCacheRequest(provider, ResultSemanticHit)
+		return match, nil
+	}
+	metrics.ObserveCacheRequest(provider, ResultMiss)
+	return nil, embedding
+}
+
+// nearest returns the most similar entry of a partition, if it passes the
+// threshold
+func (c *Cache) nearest(ctx context.Context, partition string, embedding []float32) *Entry {
+	candidates, _ := c.memory.Embedded(ctx, partition)
+	if c.store != nil {
+		stored, err := c.store.Embedded(ctx, partition)
+		if err != nil {
+			slog.WarnContext(ctx, "model cache partition read failed", "error", err)
+		}
+		candidates = append(candidates, stored...)
+	}
+
+	var best *Entry
+	bestScore := c.threshold
+	for _, entry := range candidates {
+		if score := cosine(embedding, entry.Embedding); score >= bestScore {
+			best, bestScore = entry, score
+		}
+	}
+	return best
+}
+
+// put stores a response in memory and in the store
+func (c *Cache) put(ctx context.Context, provider, model string, l lookup, embedding []float32, entry *Entry) {
+	now := time.Now()
+	entry.Key, entry.Partition = l.key, l.partition
+	entry.Provider, entry.Model = provider, model
+	entry.Prompt, entry.Embedding = l.prompt, embedding
+	entry.CreatedAt = now
+	if c.ttl > 0 {
+		entry.ExpiresAt = now.Add(c.ttl)
+	}
+
+	c.memory.Put(ctx, entry)
+	if c.store != nil {
+		if err := c.store.Put(ctx, entry); err != nil {
+			slog.WarnContext(ctx, "model cache write failed", "provider", provider, "error", err)
+		}
+	}
+}
+
+// lookup locates a call: partition hashes everything but the prompt, and
+// key hashes the partition with the prompt
+type lookup struct {
+	partition string
+	key       string
+	prompt    string
+}
+
+func newLookup(kind string, context interface{}, prompt string) (lookup, bool) {
+	canonical, err := json.Marshal(context)
+	if err != nil {
+		return lookup{}, false
+	}
+	prompt = normalize(prompt)
+	partition := hash(kind, normalize(string(canonical)))
+	return lookup{partition: partition, key: hash(partition, prompt), prompt: prompt}, true
+}
+
+// modelLookup keys a ModelFunc call. The query input is the prompt;
+// context and session IDs are left out because they differ between runs.
+func modelLookup(provider, model string, inputs map[string]interface{}, req mcp.MCPRequest) (lookup, bool) {
+	rest := make(map[string]interface{}, len(inputs))
+	for k, v := range inputs {
+		if k != "query" {
+			rest[k] = v
+		}
+	}
+	var prompt string
+	if query, ok := inputs["query"]; ok && query != nil {
+		prompt = fmt.Sprint(query)
+	}
+	return newLookup("model", map[string]interface{}{
+		"provider":    provider,
+		"model":       model,
+		"inputs":      rest,
+		"temperature": req.Temperature,
+		"top_k":       req.TopK,
+	}, prompt)
+}
+
+// chatLookup keys a chat call. A trailing user message is the prompt; its
+// images stay in the partition so only the same images match.
+func chatLookup(provider, model string, req mcp.ChatRequest) (lookup, bool) {
+	messages, prompt := req.Messages, ""
+	var images []mcp.Image
+	if n := len(messages); n > 0 && messages[n-1].Role == mcp.RoleUser {
+		messages, prompt, images = messages[:n-1], messages[n-1].Content, messages[n-1].Images
+	}
+	return newLookup("chat", map[string]interface{}{
+		"provider":    provider,
+		"model":       model,
+		"messages":    messages,
+		"images":      images,
+		"tools":       req.Tools,
+		"temperature": req.Temperature,
+		"top_p":       req.TopP,
+		"top_k":       req.TopK,
+		"max_tokens":  req.MaxTokens,
+		"stop":        req.Stop,
+		// Structured calls with the same prompt differ only by schema
+		"response_schema": req.ResponseSchema,
+	}, prompt)
+}
+
+var whitespace = regexp.MustCompile(`\s+`)
+
+// normalize collapses whitespace so formatting changes don't miss the cache
+func normalize(s string) string {
+	return strings.TrimSpace(whitespace.ReplaceAllString(s, " "))
+}
+
+func hash(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:16])
+}
+
+func pick(requested, fallback string) string {
+	if requested != "" {
+		return requested
+	}
+	return fallback
+}
+
+// cosine returns the cosine similarity of two vectors, or 0 when their
+// lengths differ
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
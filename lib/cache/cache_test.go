@@ -0,0 +1,174 @@
+package cache
+
+import (
+	"context"
+	"testing"
+
+	"github.com/benozo/conduit/mcp"
+)
+
+func chatRequest(prompt string, edit func(*mcp.ChatRequest)) mcp.ChatRequest {
+	req := mcp.ChatRequest{Messages: []mcp.ChatMessage{
+		{Role: mcp.RoleSystem, Content: "be brief"},
+		{Role: mcp.RoleUser, Content: prompt},
+	}}
+	if edit != nil {
+		edit(&req)
+	}
+	return req
+}
+
+func TestChatLookup(t *testing.T) {
+	base := chatRequest("what is go?", nil)
+	tests := []struct {
+		name string
+		req  mcp.ChatRequest
+		same bool
+	}{
+		{"identical", chatRequest("what is go?", nil), true},
+		{"whitespace", chatRequest("  what   is\ngo? ", nil), true},
+		{"different prompt", chatRequest("what is rust?", nil), false},
+		{"different system", chatRequest("what is go?", func(r *mcp.ChatRequest) { r.Messages[0].Content = "be verbose" }), false},
+		{"temperature", chatRequest("what is go?", func(r *mcp.ChatRequest) { r.Temperature = 0.7 }), false},
+		{"max tokens", chatRequest("what is go?", func(r *mcp.ChatRequest) { r.MaxTokens = 10 }), false},
+		{"tools", chatRequest("what is go?", func(r *mcp.ChatRequest) {
+			r.Tools = []mcp.ToolDefinition{{Name: "search"}}
+		}), false},
+		{"response schema", chatRequest("what is go?", func(r *mcp.ChatRequest) {
+			r.ResponseSchema = map[string]interface{}{"type": "object"}
+		}), false},
+		{"streaming callback ignored", chatRequest("what is go?", func(r *mcp.ChatRequest) { r.OnToken = func(string) {} }), true},
+	}
+
+	want, ok := chatLookup("openai", "gpt-4o", base)
+	if !ok {
+		t.Fatal("base request not cacheable")
+	}
+	for _, tt := range tests {
+		got, ok := chatLookup("openai", "gpt-4o", tt.req)
+		if !ok {
+			t.Fatalf("%s: not cacheable", tt.name)
+		}
+		if same := got.key == want.key; same != tt.same {
+			t.Errorf("%s: same key = %v, want %v", tt.name, same, tt.same)
+		}
+	}
+
+	schemaA, _ := chatLookup("openai", "gpt-4o", chatRequest("q", func(r *mcp.ChatRequest) {
+		r.ResponseSchema = map[string]interface{}{"type": "object", "required": []string{"a"}}
+	}))
+	schemaB, _ := chatLookup("openai", "gpt-4o", chatRequest("q", func(r *mcp.ChatRequest) {
+		r.ResponseSchema = map[string]interface{}{"type": "object", "required": []string{"b"}}
+	}))
+	if schemaA.partition == schemaB.partition {
+		t.Error("requests with different response schemas share a partition")
+	}
+	if other, _ := chatLookup("ollama", "gpt-4o", base); other.key == want.key {
+		t.Error("providers share cache keys")
+	}
+}
+
+func TestModelLookup(t *testing.T) {
+	inputs := func(extra map[string]interface{}) map[string]interface{} {
+		m := map[string]interface{}{"query": "hello", "system": "be brief"}
+		for k, v := range extra {
+			m[k] = v
+		}
+		return m
+	}
+	base, _ := modelLookup("openai", "gpt-4o", inputs(nil), mcp.MCPRequest{})
+
+	tests := []struct {
+		name   string
+		inputs map[string]interface{}
+		req    mcp.MCPRequest
+		same   bool
+	}{
+		{"session ignored", inputs(nil), mcp.MCPRequest{SessionID: "s2"}, true},
+		{"different system", inputs(map[string]interface{}{"system": "be verbose"}), mcp.MCPRequest{}, false},
+		{"extra input", inputs(map[string]interface{}{mcp.InputResponseSchema: map[string]interface{}{"type": "object"}}), mcp.MCPRequest{}, false},
+		{"top k", inputs(nil), mcp.MCPRequest{TopK: 5}, false},
+	}
+	for _, tt := range tests {
+		got, _ := modelLookup("openai", "gpt-4o", tt.inputs, tt.req)
+		if same := got.key == base.key; same != tt.same {
+			t.Errorf("%s: same key = %v, want %v", tt.name, same, tt.same)
+		}
+	}
+}
+
+func TestWrapChat(t *testing.T) {
+	calls := 0
+	chat := mcp.ChatModelFunc(func(ctx context.Context, req mcp.ChatRequest) (*mcp.ChatResponse, error) {
+		calls++
+		return &mcp.ChatResponse{Message: mcp.ChatMessage{
+			Role:      mcp.RoleAssistant,
+			ToolCalls: []mcp.ChatToolCall{{Name: "search"}},
+		}}, nil
+	})
+	cached := New(Options{}).WrapChat(Target{Provider: "ollama", Model: "llama3"}, chat)
+	ctx := context.Background()
+
+	first, err := cached.Chat(ctx, chatRequest("find it", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Callers such as the tool loop fill in missing IDs in place
+	first.Message.ToolCalls[0].ID = "call_1"
+
+	second, err := cached.Chat(ctx, chatRequest("find it", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Fatalf("model called %d times, want a cache hit", calls)
+	}
+	if id := second.Message.ToolCalls[0].ID; id != "" {
+		t.Errorf("cached entry changed by a caller: ID %q", id)
+	}
+	second.Message.ToolCalls[0].ID = "call_2"
+	if third, _ := cached.Chat(ctx, chatRequest("find it", nil)); third.Message.ToolCalls[0].ID != "" {
+		t.Error("a cache hit shares its tool calls with the cached entry")
+	}
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		req  mcp.ChatRequest
+	}{
+		{"sampled", ctx, chatRequest("find it", func(r *mcp.ChatRequest) { r.Temperature = 0.5 })},
+		{"NoCache", NoCache(ctx), chatRequest("find it", nil)},
+	}
+	for _, tt := range tests {
+		before := calls
+		if _, err := cached.Chat(tt.ctx, tt.req); err != nil {
+			t.Fatal(err)
+		}
+		if calls != before+1 {
+			t.Errorf("%s: served from cache", tt.name)
+		}
+	}
+}
+
+func TestWrapModel(t *testing.T) {
+	calls := 0
+	model := func(ctx mcp.ContextInput, req mcp.MCPRequest, memory *mcp.Memory, onToken mcp.StreamCallback) (string, error) {
+		calls++
+		return "answer", nil
+	}
+	cached := New(Options{}).WrapModel(Target{Provider: "openai", Model: "gpt-4o"}, model)
+	input := mcp.ContextInput{ContextID: "a", Inputs: map[string]interface{}{"query": "question"}}
+
+	if _, err := cached(input, mcp.MCPRequest{}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	var streamed string
+	input.ContextID = "b"
+	got, err := cached(input, mcp.MCPRequest{}, nil, func(_, token string) { streamed += token })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 || got != "answer" || streamed != "answer" {
+		t.Errorf("calls %d, got %q, streamed %q", calls, got, streamed)
+	}
+}
@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/benozo/conduit/lib/rag"
+	"github.com/benozo/conduit/lib/rag/embeddings"
+)
+
+// Config is the cache section of a model config
+type Config struct {
+	Capacity int           `json:"capacity,omitempty" yaml:"capacity,omitempty"`
+	TTL      time.Duration `json:"ttl,omitempty" yaml:"ttl,omitempty"`
+
+	// Dir persists entries as files; PostgresDSN persists them in a table
+	// shared by every process using the database. Set at most one.
+	Dir         string `json:"dir,omitempty" yaml:"dir,omitempty"`
+	PostgresDSN string `json:"postgres_dsn,omitempty" yaml:"postgres_dsn,omitempty"`
+	Table       string `json:"table,omitempty" yaml:"table,omitempty"`
+
+	CacheSampled bool `json:"cache_sampled,omitempty" yaml:"cache_sampled,omitempty"`
+
+	Semantic *SemanticConfig `json:"semantic,omitempty" yaml:"semantic,omitempty"`
+}
+
+// SemanticConfig enables semantic matching with an embedding provider
+type SemanticConfig struct {
+	Threshold  float64             `json:"threshold,omitempty" yaml:"threshold,omitempty"`
+	Embeddings rag.EmbeddingConfig `json:"embeddings" yaml:"embeddings"`
+}
+
+// Validate reports conflicting stores and out-of-range settings
+func (c *Config) Validate() error {
+	var problems []string
+	if c.Capacity < 0 {
+		problems = append(problems, "capacity must not be negative")
+	}
+	if c.TTL < 0 {
+		problems = append(problems, "ttl must not be negative")
+	}
+	if c.Dir != "" && c.PostgresDSN != "" {
+		problems = append(problems, "set either dir or postgres_dsn, not both")
+	}
+	if s := c.Semantic; s != nil {
+		if s.Threshold < 0 || s.Threshold > 1 {
+			problems = append(problems, "semantic threshold must be between 0 and 1")
+		}
+		switch s.Embeddings.Provider {
+		case "openai", "ollama":
+		default:
+			problems = append(problems, fmt.Sprintf("unsupported semantic embedding provider %q (want openai or ollama)", s.Embeddings.Provider))
+		}
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Open creates the cache described by c, connecting its store
+func (c *Config) Open(ctx context.Context) (*Cache, error) {
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid cache config: %w", err)
+	}
+
+	opts := Options{Capacity: c.Capacity, TTL: c.TTL, CacheSampled: c.CacheSampled}
+	switch {
+	case c.Dir != "":
+		store, err := NewDiskStore(c.Dir)
+		if err != nil {
+			return nil, err
+		}
+		opts.Store = store
+	case c.PostgresDSN != "":
+		store, err := OpenPostgresStore(ctx, c.PostgresDSN, c.Table)
+		if err != nil {
+			return nil, err
+		}
+		opts.Store = store
+	}
+
+	if s := c.Semantic; s != nil {
+		e := s.Embeddings
+		switch e.Provider {
+		case "openai":
+			opts.Embedder = embeddings.NewOpenAIEmbeddings(e.APIKey, e.Model, e.Dimensions, e.Timeout)
+		case "ollama":
+			opts.Embedder = embeddings.NewOllamaEmbeddings(e.Host, e.Model, e.Dimensions, e.Timeout)
+		}
+		opts.Threshold = s.Threshold
+	}
+	return New(opts), nil
+}
@@ -0,0 +1,123 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DiskStore keeps one JSON file per entry under dir/<partition>/<key>.json
+type DiskStore struct {
+	dir string
+}
+
+// NewDiskStore creates a store in dir, creating it if needed
+func NewDiskStore(dir string) (*DiskStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	return &DiskStore{dir: dir}, nil
+}
+
+func (d *DiskStore) path(partition, key string) string {
+	return filepath.Join(d.dir, partition, key+".json")
+}
+
+// Get implements Store, removing the entry's file once it has expired
+func (d *DiskStore) Get(ctx context.Context, partition, key string) (*Entry, error) {
+	entry, err := readEntry(d.path(partition, key))
+	if err != nil || entry == nil {
+		return nil, err
+	}
+	if entry.Expired(time.Now()) {
+		os.Remove(d.path(partition, key))
+		return nil, nil
+	}
+	return entry, nil
+}
+
+// Put implements Store. The file is written to a temporary name and
+// renamed, so readers never see a partial entry.
+func (d *DiskStore) Put(ctx context.Context, entry *Entry) error {
+	path := d.path(entry.Partition, entry.Key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create cache partition: %w", err)
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode cache entry: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".entry-*")
+	if err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Delete implements Store
+func (d *DiskStore) Delete(ctx context.Context, partition, key string) error {
+	err := os.Remove(d.path(partition, key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Embedded implements Store by reading every entry in the partition
+func (d *DiskStore) Embedded(ctx context.Context, partition string) ([]*Entry, error) {
+	files, err := os.ReadDir(filepath.Join(d.dir, partition))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache partition: %w", err)
+	}
+
+	now := time.Now()
+	var entries []*Entry
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		entry, err := readEntry(filepath.Join(d.dir, partition, f.Name()))
+		if err != nil || entry == nil {
+			continue
+		}
+		if len(entry.Embedding) > 0 && !entry.Expired(now) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+func readEntry(path string) (*Entry, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache entry: %w", err)
+	}
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("failed to decode cache entry %s: %w", path, err)
+	}
+	return &entry, nil
+}
@@ -0,0 +1,156 @@
+package cache
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+// DefaultTable is the table PostgresStore uses when none is given
+const DefaultTable = "conduit_model_cache"
+
+var tableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// PostgresStore keeps entries in a PostgreSQL table, shared by every process
+// using the same database
+type PostgresStore struct {
+	db    *sql.DB
+	table string
+}
+
+// NewPostgresStore creates the cache table in db if needed. An empty table
+// uses DefaultTable.
+func NewPostgresStore(ctx context.Context, db *sql.DB, table string) (*PostgresStore, error) {
+	if table == "" {
+		table = DefaultTable
+	}
+	if !tableName.MatchString(table) {
+		return nil, fmt.Errorf("invalid cache table name %q", table)
+	}
+
+	schema := fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %[1]s (
+			key TEXT PRIMARY KEY,
+			partition TEXT NOT NULL,
+			entry JSONB NOT NULL,
+			has_embedding BOOLEAN NOT NULL DEFAULT false,
+			expires_at TIMESTAMPTZ
+		);
+		CREATE INDEX IF NOT EXISTS %[1]s_partition_idx ON %[1]s (partition) WHERE has_embedding;`, table)
+	if _, err := db.ExecContext(ctx, schema); err != nil {
+		return nil, fmt.Errorf("failed to create cache table: %w", err)
+	}
+	return &PostgresStore{db: db, table: table}, nil
+}
+
+// OpenPostgresStore connects to dsn and creates the cache table if needed
+func OpenPostgresStore(ctx context.Context, dsn, table string) (*PostgresStore, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cache database: %w", err)
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping cache database: %w", err)
+	}
+	store, err := NewPostgresStore(ctx, db, table)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+// Get implements Store
+func (p *PostgresStore) Get(ctx context.Context, partition, key string) (*Entry, error) {
+	var data []byte
+	err := p.db.QueryRowContext(ctx, fmt.Sprintf(
+		`SELECT entry FROM %s WHERE key = $1 AND (expires_at IS NULL OR expires_at > now())`, p.table), key).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache entry: %w", err)
+	}
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("failed to decode cache entry: %w", err)
+	}
+	return &entry, nil
+}
+
+// Put implements Store
+func (p *PostgresStore) Put(ctx context.Context, entry *Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode cache entry: %w", err)
+	}
+	var expiresAt *time.Time
+	if !entry.ExpiresAt.IsZero() {
+		expiresAt = &entry.ExpiresAt
+	}
+	_, err = p.db.ExecContext(ctx, fmt.Sprintf(`
+		INSERT INTO %s (key, partition, entry, has_embedding, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (key) DO UPDATE SET
+			partition = EXCLUDED.partition,
+			entry = EXCLUDED.entry,
+			has_embedding = EXCLUDED.has_embedding,
+			expires_at = EXCLUDED.expires_at`, p.table),
+		entry.Key, entry.Partition, data, len(entry.Embedding) > 0, expiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	return nil
+}
+
+// Delete implements Store
+func (p *PostgresStore) Delete(ctx context.Context, partition, key string) error {
+	if _, err := p.db.ExecContext(ctx, fmt.Sprintf(`DELETE FROM %s WHERE key = $1`, p.table), key); err != nil {
+		return fmt.Errorf("failed to delete cache entry: %w", err)
+	}
+	return nil
+}
+
+// Embedded implements Store
+func (p *PostgresStore) Embedded(ctx context.Context, partition string) ([]*Entry, error) {
+	rows, err := p.db.QueryContext(ctx, fmt.Sprintf(
+		`SELECT entry FROM %s WHERE partition = $1 AND has_embedding AND (expires_at IS NULL OR expires_at > now())`, p.table), partition)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache partition: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []*Entry
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("failed to read cache entry: %w", err)
+		}
+		var entry Entry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			continue
+		}
+		entries = append(entries, &entry)
+	}
+	return entries, rows.Err()
+}
+
+// Purge deletes expired entries
+func (p *PostgresStore) Purge(ctx context.Context) (int64, error) {
+	result, err := p.db.ExecContext(ctx, fmt.Sprintf(`DELETE FROM %s WHERE expires_at <= now()`, p.table))
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge cache: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// Close closes the database
+func (p *PostgresStore) Close() error {
+	return p.db.Close()
+}
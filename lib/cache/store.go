@@ -0,0 +1,152 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/benozo/conduit/mcp"
+)
+
+// Entry is a cached model response
+type Entry struct {
+	Key string `json:"key"`
+	// Partition groups entries that differ only in the prompt, which is
+	// where semantic matching looks for neighbours
+	Partition string `json:"partition"`
+	Provider  string `json:"provider,omitempty"`
+	Model     string `json:"model,omitempty"`
+
+	// Prompt is the text embedded for semantic matching
+	Prompt    string    `json:"prompt,omitempty"`
+	Embedding []float32 `json:"embedding,omitempty"`
+
+	// Output is set for ModelFunc calls, Response for chat calls
+	Output   string            `json:"output,omitempty"`
+	Response *mcp.ChatResponse `json:"response,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	// ExpiresAt is zero for entries that never expire
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+}
+
+// Expired reports whether the entry's TTL has passed
+func (e *Entry) Expired(now time.Time) bool {
+	return !e.ExpiresAt.IsZero() && now.After(e.ExpiresAt)
+}
+
+// Store holds cache entries. Get returns nil, nil for missing or expired
+// entries.
+type Store interface {
+	Get(ctx context.Context, partition, key string) (*Entry, error)
+	Put(ctx context.Context, entry *Entry) error
+	Delete(ctx context.Context, partition, key string) error
+	// Embedded returns the unexpired entries of a partition that carry an
+	// embedding, for semantic matching
+	Embedded(ctx context.Context, partition string) ([]*Entry, error)
+}
+
+// MemoryStore is an in-memory LRU store
+type MemoryStore struct {
+	mu         sync.Mutex
+	capacity   int
+	order      *list.List
+	entries    map[string]*list.Element
+	partitions map[string]map[string]struct{}
+}
+
+// DefaultCapacity is the MemoryStore size used when none is given
+const DefaultCapacity = 1000
+
+// NewMemoryStore creates an LRU holding up to capacity entries
+func NewMemoryStore(capacity int) *MemoryStore {
+	if capacity <= 0 {
+		capacity = DefaultCapacity
+	}
+	return &MemoryStore{
+		capacity:   capacity,
+		order:      list.New(),
+		entries:    make(map[string]*list.Element),
+		partitions: make(map[string]map[string]struct{}),
+	}
+}
+
+// Get implements Store
+func (m *MemoryStore) Get(ctx context.Context, partition, key string) (*Entry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	el, ok := m.entries[key]
+	if !ok {
+		return nil, nil
+	}
+	entry := el.Value.(*Entry)
+	if entry.Expired(time.Now()) {
+		m.remove(el)
+		return nil, nil
+	}
+	m.order.MoveToFront(el)
+	return entry, nil
+}
+
+// Put implements Store, evicting the least recently used entry when full
+func (m *MemoryStore) Put(ctx context.Context, entry *Entry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if el, ok := m.entries[entry.Key]; ok {
+		m.remove(el)
+	}
+	m.entries[entry.Key] = m.order.PushFront(entry)
+	if m.partitions[entry.Partition] == nil {
+		m.partitions[entry.Partition] = make(map[string]struct{})
+	}
+	m.partitions[entry.Partition][entry.Key] = struct{}{}
+
+	for m.order.Len() > m.capacity {
+		m.remove(m.order.Back())
+	}
+	return nil
+}
+
+// Delete implements Store
+func (m *MemoryStore) Delete(ctx context.Context, partition, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if el, ok := m.entries[key]; ok {
+		m.remove(el)
+	}
+	return nil
+}
+
+// Embedded implements Store
+func (m *MemoryStore) Embedded(ctx context.Context, partition string) ([]*Entry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	var entries []*Entry
+	for key := range m.partitions[partition] {
+		entry := m.entries[key].Value.(*Entry)
+		if len(entry.Embedding) > 0 && !entry.Expired(now) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// Len returns the number of entries held
+func (m *MemoryStore) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.order.Len()
+}
+
+func (m *MemoryStore) remove(el *list.Element) {
+	entry := m.order.Remove(el).(*Entry)
+	delete(m.entries, entry.Key)
+	if keys := m.partitions[entry.Partition]; keys != nil {
+		delete(keys, entry.Key)
+		if len(keys) == 0 {
+			delete(m.partitions, entry.Partition)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+// Package cassette records model and tool interactions to a JSONL file and
+// replays them, so agent and swarm tests can run offline and deterministically.
+//
+// Recording:
+//
+//	rec, _ := cassette.NewRecorder("testdata/run.jsonl")
+//	defer rec.Close()
+//	server.SetModel(rec.WrapModel(model))
+//	server.GetToolRegistry().Use(rec.ToolMiddleware())
+//
+// Replaying:
+//
+//	rep, _ := cassette.Load("testdata/run.jsonl", cassette.Strict())
+//	server.SetModel(rep.Model())
+//	server.GetToolRegistry().Use(rep.ToolMiddleware())
+package cassette
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/benozo/conduit/mcp"
+)
+
+// Entry kinds
+const (
+	KindModel = "model"
+	KindTool  = "tool"
+)
+
+// Entry is one recorded interaction, stored as a JSON line
+type Entry struct {
+	Kind string    `json:"kind"`
+	Key  string    `json:"key"`
+	Time time.Time `json:"time"`
+
+	// Model calls
+	Model  string                 `json:"model,omitempty"`
+	Inputs map[string]interface{} `json:"inputs,omitempty"`
+	Tokens []string               `json:"tokens,omitempty"`
+	Output string                 `json:"output,omitempty"`
+
+	// Tool calls
+	Tool   string                 `json:"tool,omitempty"`
+	Params map[string]interface{} `json:"params,omitempty"`
+	Result json.RawMessage        `json:"result,omitempty"`
+
+	Error string `json:"error,omitempty"`
+}
+
+// Normalizer rewrites a canonical prompt before hashing, e.g. to mask timestamps
+type Normalizer func(string) string
+
+var whitespace = regexp.MustCompile(`\s+`)
+
+// modelKey hashes the model name and inputs. Context and session IDs are left
+// out because they often differ between runs.
+func modelKey(req mcp.MCPRequest, inputs map[string]interface{}, normalize Normalizer) string {
+	canonical := canonicalJSON(map[string]interface{}{
+		"model":  req.Model,
+		"inputs": inputs,
+	})
+	return hashKey(KindModel, canonical, normalize)
+}
+
+func toolKey(name string, params map[string]interface{}, normalize Normalizer) string {
+	canonical := canonicalJSON(map[string]interface{}{
+		"tool":   name,
+		"params": params,
+	})
+	return hashKey(KindTool, canonical, normalize)
+}
+
+func hashKey(kind, canonical string, normalize Normalizer) string {
+	// Collapse whitespace so formatting changes in prompts don't break matching
+	canonical = strings.TrimSpace(whitespace.ReplaceAllString(canonical, " "))
+	if normalize != nil {
+		canonical = normalize(canonical)
+	}
+	sum := sha256.Sum256([]byte(kind + "\x00" + canonical))
+	return hex.EncodeToString(sum[:16])
+}
+
+// canonicalJSON encodes v with sorted map keys. v is first decoded back into
+// plain maps and slices, so live values such as structs encode exactly like
+// the same values read back from a cassette file.
+func canonicalJSON(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	var plain interface{}
+	if err := json.Unmarshal(data, &plain); err != nil {
+		return ""
+	}
+	data, err = json.Marshal(plain)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
@@ -0,0 +1,182 @@
+package cassette
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/benozo/conduit/mcp"
+)
+
+// echoModel streams its query back word by word
+func echoModel(ctx mcp.ContextInput, req mcp.MCPRequest, memory *mcp.Memory, onToken mcp.StreamCallback) (string, error) {
+	query, _ := ctx.Inputs["query"].(string)
+	for _, word := range strings.Fields(query) {
+		if onToken != nil {
+			onToken(ctx.ContextID, word+" ")
+		}
+	}
+	return "echo: " + query, nil
+}
+
+func failingModel(mcp.ContextInput, mcp.MCPRequest, *mcp.Memory, mcp.StreamCallback) (string, error) {
+	return "", errors.New("live model called")
+}
+
+func TestRecordReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.jsonl")
+	recorder, err := NewRecorder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// note is a struct whose fields are not in alphabetical order, so it only
+	// matches its replayed form once both are normalized to plain JSON
+	type note struct {
+		Title string `json:"title"`
+		Body  string `json:"body"`
+	}
+	inputs := func() map[string]interface{} {
+		return map[string]interface{}{
+			"query":  "describe the picture",
+			"system": "be brief",
+			"note":   note{Title: "t", Body: "b"},
+		}
+	}
+	req := mcp.MCPRequest{Model: "test-model"}
+
+	var recorded []string
+	model := recorder.WrapModel(echoModel)
+	output, err := model(mcp.ContextInput{Inputs: inputs()}, req, mcp.NewMemory(), func(_, token string) {
+		recorded = append(recorded, token)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	registry := mcp.NewToolRegistry()
+	registry.Register("add", func(params map[string]interface{}, memory *mcp.Memory) (interface{}, error) {
+		return map[string]interface{}{"sum": params["a"].(float64) + params["b"].(float64)}, nil
+	})
+	registry.Use(recorder.ToolMiddleware())
+	if _, err := registry.Call("add", map[string]interface{}{"a": 1.0, "b": 2.0}, mcp.NewMemory()); err != nil {
+		t.Fatal(err)
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	replayer, err := Load(path, Strict())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var replayed []string
+	got, err := replayer.Model()(mcp.ContextInput{Inputs: inputs()}, req, mcp.NewMemory(), func(_, token string) {
+		replayed = append(replayed, token)
+	})
+	if err != nil {
+		t.Fatalf("replay failed: %v (misses %v)", err, replayer.Misses())
+	}
+	if got != output {
+		t.Errorf("replayed output %q, recorded %q", got, output)
+	}
+	if !reflect.DeepEqual(replayed, recorded) {
+		t.Errorf("replayed tokens %q, recorded %q", replayed, recorded)
+	}
+
+	replayRegistry := mcp.NewToolRegistry()
+	replayRegistry.Register("add", func(map[string]interface{}, *mcp.Memory) (interface{}, error) {
+		return nil, errors.New("live tool called")
+	})
+	replayRegistry.Use(replayer.ToolMiddleware())
+	result, err := replayRegistry.Call("add", map[string]interface{}{"a": 1.0, "b": 2.0}, mcp.NewMemory())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum := result.(map[string]interface{})["sum"]; sum != 3.0 {
+		t.Errorf("replayed sum %v, want 3", sum)
+	}
+	if n := replayer.Unused(); n != 0 {
+		t.Errorf("%d recorded entries unused", n)
+	}
+}
+
+func TestReplayerMatching(t *testing.T) {
+	entries := []Entry{
+		{Kind: KindModel, Model: "m", Inputs: map[string]interface{}{"query": "hello   world"}, Output: "first"},
+		{Kind: KindModel, Model: "m", Inputs: map[string]interface{}{"query": "hello world"}, Output: "second"},
+		{Kind: KindModel, Model: "m", Inputs: map[string]interface{}{"query": "broken"}, Error: "recorded failure"},
+	}
+	call := func(r *Replayer, query string) (string, error) {
+		return r.Model()(mcp.ContextInput{Inputs: map[string]interface{}{"query": query}}, mcp.MCPRequest{Model: "m"}, nil, nil)
+	}
+
+	tests := []struct {
+		name    string
+		opts    []ReplayOption
+		queries []string
+		want    []string
+		wantErr error
+	}{
+		{
+			name:    "whitespace-insensitive and in recorded order",
+			queries: []string{"hello world", "hello  world"},
+			want:    []string{"first", "second"},
+		},
+		{
+			name:    "last recording repeats",
+			queries: []string{"hello world", "hello world", "hello world"},
+			want:    []string{"first", "second", "second"},
+		},
+		{
+			name:    "strict miss",
+			opts:    []ReplayOption{Strict(), WithFallbackModel(echoModel)},
+			queries: []string{"unknown"},
+			wantErr: ErrNoMatch,
+		},
+		{
+			name:    "fallback model",
+			opts:    []ReplayOption{WithFallbackModel(echoModel)},
+			queries: []string{"unknown"},
+			want:    []string{"echo: unknown"},
+		},
+		{
+			name:    "normalizer",
+			opts:    []ReplayOption{WithReplayNormalizer(func(s string) string { return strings.ReplaceAll(s, "HELLO", "hello") })},
+			queries: []string{"HELLO world"},
+			want:    []string{"first"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewReplayer(entries, tt.opts...)
+			for i, query := range tt.queries {
+				got, err := call(r, query)
+				if tt.wantErr != nil {
+					if !errors.Is(err, tt.wantErr) {
+						t.Fatalf("got error %v, want %v", err, tt.wantErr)
+					}
+					if len(r.Misses()) != 1 {
+						t.Errorf("misses %v, want one", r.Misses())
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != tt.want[i] {
+					t.Errorf("call %d: got %q, want %q", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+
+	if _, err := call(NewReplayer(entries, Strict()), "broken"); err == nil || err.Error() != "recorded failure" {
+		t.Errorf("recorded error not replayed: %v", err)
+	}
+	if _, err := call(NewReplayer(nil, WithFallbackModel(failingModel)), "x"); err == nil {
+		t.Error("fallback model not called")
+	}
+}
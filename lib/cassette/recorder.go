@@ -0,0 +1,135 @@
+package cassette
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/benozo/conduit/mcp"
+)
+
+// Recorder appends model and tool interactions to a cassette file
+type Recorder struct {
+	mu        sync.Mutex
+	file      *os.File
+	encoder   *json.Encoder
+	normalize Normalizer
+	err       error
+}
+
+// RecorderOption configures a Recorder
+type RecorderOption func(*Recorder)
+
+// WithRecordNormalizer sets the prompt normalizer; it must match the replayer's
+func WithRecordNormalizer(n Normalizer) RecorderOption {
+	return func(r *Recorder) { r.normalize = n }
+}
+
+// NewRecorder creates or truncates the cassette at path
+func NewRecorder(path string, opts ...RecorderOption) (*Recorder, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create cassette directory: %w", err)
+		}
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cassette: %w", err)
+	}
+
+	r := &Recorder{file: file, encoder: json.NewEncoder(file)}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r, nil
+}
+
+// WrapModel returns a ModelFunc that calls model and records the prompt, tokens and output
+func (r *Recorder) WrapModel(model mcp.ModelFunc) mcp.ModelFunc {
+	return func(ctx mcp.ContextInput, req mcp.MCPRequest, memory *mcp.Memory, onToken mcp.StreamCallback) (string, error) {
+		var tokensMu sync.Mutex
+		var tokens []string
+		recordToken := func(contextID, token string) {
+			tokensMu.Lock()
+			tokens = append(tokens, token)
+			tokensMu.Unlock()
+			if onToken != nil {
+				onToken(contextID, token)
+			}
+		}
+
+		output, err := model(ctx, req, memory, recordToken)
+
+		entry := Entry{
+			Kind:   KindModel,
+			Key:    modelKey(req, ctx.Inputs, r.normalize),
+			Time:   time.Now().UTC(),
+			Model:  req.Model,
+			Inputs: ctx.Inputs,
+			Tokens: tokens,
+			Output: output,
+		}
+		if err != nil {
+			entry.Error = err.Error()
+		}
+		r.write(entry)
+		return output, err
+	}
+}
+
+// ToolMiddleware records every tool call made through a ToolRegistry
+func (r *Recorder) ToolMiddleware() mcp.ToolMiddleware {
+	return func(name string, next mcp.ToolFunc) mcp.ToolFunc {
+		return func(params map[string]interface{}, memory *mcp.Memory) (interface{}, error) {
+			result, err := next(params, memory)
+
+			entry := Entry{
+				Kind:   KindTool,
+				Key:    toolKey(name, params, r.normalize),
+				Time:   time.Now().UTC(),
+				Tool:   name,
+				Params: params,
+			}
+			if err != nil {
+				entry.Error = err.Error()
+			} else if data, merr := json.Marshal(result); merr == nil {
+				entry.Result = data
+			} else {
+				entry.Error = fmt.Sprintf("unrecordable result: %v", merr)
+			}
+			r.write(entry)
+			return result, err
+		}
+	}
+}
+
+// Err returns the first write error, if any
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Close flushes and closes the cassette, returning any write error
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.file.Close(); err != nil && r.err == nil {
+		r.err = err
+	}
+	return r.err
+}
+
+func (r *Recorder) write(entry Entry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return
+	}
+	if err := r.encoder.Encode(entry); err != nil {
+		r.err = fmt.Errorf("failed to write cassette entry: %w", err)
+	}
+}
@@ -0,0 +1,187 @@
+package cassette
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/benozo/conduit/mcp"
+)
+
+// ErrNoMatch is returned in strict mode when a call has no recorded entry
+var ErrNoMatch = errors.New("cassette: no recorded interaction matches")
+
+// Replayer serves recorded interactions back by prompt or call hash
+type Replayer struct {
+	mu        sync.Mutex
+	queues    map[string][]Entry // by key, in recorded order
+	served    map[string]int
+	last      map[string]Entry
+	strict    bool
+	normalize Normalizer
+	model     mcp.ModelFunc
+	misses    []string
+}
+
+// ReplayOption configures a Replayer
+type ReplayOption func(*Replayer)
+
+// Strict makes unmatched calls fail with ErrNoMatch instead of falling through
+func Strict() ReplayOption {
+	return func(r *Replayer) { r.strict = true }
+}
+
+// WithFallbackModel calls model for unmatched prompts when not strict
+func WithFallbackModel(model mcp.ModelFunc) ReplayOption {
+	return func(r *Replayer) { r.model = model }
+}
+
+// WithReplayNormalizer sets the prompt normalizer; it must match the recorder's
+func WithReplayNormalizer(n Normalizer) ReplayOption {
+	return func(r *Replayer) { r.normalize = n }
+}
+
+// Load reads a cassette file for replay
+func Load(path string, opts ...ReplayOption) (*Replayer, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cassette: %w", err)
+	}
+	defer file.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("cassette %s line %d: %w", path, line, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read cassette: %w", err)
+	}
+	return NewReplayer(entries, opts...), nil
+}
+
+// NewReplayer creates a replayer from entries
+func NewReplayer(entries []Entry, opts ...ReplayOption) *Replayer {
+	r := &Replayer{
+		queues: make(map[string][]Entry),
+		served: make(map[string]int),
+		last:   make(map[string]Entry),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	for _, entry := range entries {
+		// Re-key with the replay normalizer so recordings can be re-normalized later
+		switch entry.Kind {
+		case KindModel:
+			entry.Key = modelKey(mcp.MCPRequest{Model: entry.Model}, entry.Inputs, r.normalize)
+		case KindTool:
+			entry.Key = toolKey(entry.Tool, entry.Params, r.normalize)
+		}
+		r.queues[entry.Key] = append(r.queues[entry.Key], entry)
+	}
+	return r
+}
+
+// Model returns a ModelFunc that replays recorded outputs and token streams
+func (r *Replayer) Model() mcp.ModelFunc {
+	return func(ctx mcp.ContextInput, req mcp.MCPRequest, memory *mcp.Memory, onToken mcp.StreamCallback) (string, error) {
+		key := modelKey(req, ctx.Inputs, r.normalize)
+		entry, ok := r.next(key, fmt.Sprintf("model %s inputs=%s", req.Model, canonicalJSON(ctx.Inputs)))
+		if !ok {
+			if !r.strict && r.model != nil {
+				return r.model(ctx, req, memory, onToken)
+			}
+			return "", fmt.Errorf("%w: model %s (key %s)", ErrNoMatch, req.Model, key)
+		}
+
+		if onToken != nil {
+			for _, token := range entry.Tokens {
+				onToken(ctx.ContextID, token)
+			}
+		}
+		if entry.Error != "" {
+			return entry.Output, errors.New(entry.Error)
+		}
+		return entry.Output, nil
+	}
+}
+
+// ToolMiddleware replays recorded tool results. Unmatched calls run the real
+// tool unless the replayer is strict.
+func (r *Replayer) ToolMiddleware() mcp.ToolMiddleware {
+	return func(name string, next mcp.ToolFunc) mcp.ToolFunc {
+		return func(params map[string]interface{}, memory *mcp.Memory) (interface{}, error) {
+			key := toolKey(name, params, r.normalize)
+			entry, ok := r.next(key, fmt.Sprintf("tool %s params=%s", name, canonicalJSON(params)))
+			if !ok {
+				if r.strict {
+					return nil, fmt.Errorf("%w: tool %s (key %s)", ErrNoMatch, name, key)
+				}
+				return next(params, memory)
+			}
+
+			if entry.Error != "" {
+				return nil, errors.New(entry.Error)
+			}
+			var result interface{}
+			if len(entry.Result) > 0 {
+				if err := json.Unmarshal(entry.Result, &result); err != nil {
+					return nil, fmt.Errorf("cassette: invalid recorded result for %s: %w", name, err)
+				}
+			}
+			return result, nil
+		}
+	}
+}
+
+// next returns the next recorded entry for key. Once a key's recordings are
+// used up, the last one is repeated.
+func (r *Replayer) next(key, description string) (Entry, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if queue := r.queues[key]; len(queue) > 0 {
+		entry := queue[0]
+		r.queues[key] = queue[1:]
+		r.served[key]++
+		r.last[key] = entry
+		return entry, true
+	}
+	if entry, ok := r.last[key]; ok {
+		r.served[key]++
+		return entry, true
+	}
+	r.misses = append(r.misses, description)
+	return Entry{}, false
+}
+
+// Misses describes the calls that matched no recording
+func (r *Replayer) Misses() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.misses...)
+}
+
+// Unused returns the number of recorded entries that were never served
+func (r *Replayer) Unused() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, queue := range r.queues {
+		n += len(queue)
+	}
+	return n
+}
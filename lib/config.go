@@ -0,0 +1,283 @@
+package conduit
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/benozo/conduit/lib/logging"
+	"github.com/benozo/conduit/lib/rag"
+	"github.com/benozo/conduit/lib/tokens"
+	"github.com/benozo/conduit/mcp"
+)
+
+// Built-in tool packages that can be enabled through Config.Tools
+const (
+	ToolPackageText    = "text"
+	ToolPackageMemory  = "memory"
+	ToolPackageUtility = "utility"
+	ToolPackageRAG     = "rag"
+)
+
+// ToolPackages lists every built-in tool package name
+var ToolPackages = []string{ToolPackageText, ToolPackageMemory, ToolPackageUtility, ToolPackageRAG}
+
+// OpenAPISource configures tools imported from an OpenAPI document (see lib/openapi)
+type OpenAPISource struct {
+	// Spec is a file path or URL
+	Spec    string `json:"spec" yaml:"spec"`
+	BaseURL string `json:"base_url,omitempty" yaml:"base_url,omitempty"`
+	// Headers are sent with every request; values are expanded from the environment
+	Headers          map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+	Operations       []string          `json:"operations,omitempty" yaml:"operations,omitempty"`
+	Prefix           string            `json:"prefix,omitempty" yaml:"prefix,omitempty"`
+	MaxResponseBytes int               `json:"max_response_bytes,omitempty" yaml:"max_response_bytes,omitempty"`
+	Timeout          time.Duration     `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+}
+
+// LoadConfig reads a YAML or JSON config file on top of DefaultConfig.
+// Unknown keys are rejected so typos are caught early.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	config := DefaultConfig()
+	// JSON is a subset of YAML, so one decoder handles both formats
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(config); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	if config.Environment == nil {
+		config.Environment = make(map[string]string)
+	}
+	return config, nil
+}
+
+// ApplyEnv overrides config values from CONDUIT_* environment variables.
+// RAG_* variables are read by Finalize.
+func (c *Config) ApplyEnv() error {
+	if v := os.Getenv("CONDUIT_PORT"); v != "" {
+		port, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid CONDUIT_PORT: %w", err)
+		}
+		c.Port = port
+	}
+	if v := os.Getenv("CONDUIT_MODE"); v != "" {
+		mode, err := mcp.ParseServerMode(v)
+		if err != nil {
+			return fmt.Errorf("invalid CONDUIT_MODE: %w", err)
+		}
+		c.Mode = mode
+	}
+	if v := os.Getenv("CONDUIT_OLLAMA_URL"); v != "" {
+		c.OllamaURL = v
+	}
+	if v := os.Getenv("CONDUIT_ENABLE_HTTPS"); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid CONDUIT_ENABLE_HTTPS: %w", err)
+		}
+		c.EnableHTTPS = enabled
+	}
+	if v := os.Getenv("CONDUIT_TLS_CERT"); v != "" {
+		c.CertFile = v
+	}
+	if v := os.Getenv("CONDUIT_TLS_KEY"); v != "" {
+		c.KeyFile = v
+	}
+	if v := os.Getenv(logging.EnvLevel); v != "" {
+		c.LogLevel = v
+	}
+	if v := os.Getenv(logging.EnvFormat); v != "" {
+		c.LogFormat = v
+	}
+	if v, ok := os.LookupEnv("CONDUIT_TOOLS"); ok {
+		c.Tools = SplitList(v)
+	}
+	if v := os.Getenv("CONDUIT_SCRIPTS_DIR"); v != "" {
+		c.ScriptsDir = v
+	}
+	if v, ok := os.LookupEnv("CONDUIT_PLUGINS"); ok {
+		c.Plugins = SplitList(v)
+	}
+
+	c.applyModelEnv()
+	return nil
+}
+
+// Finalize fills in the settings that depend on the merged configuration.
+// Call it once the file, environment and flags have all been applied: the
+// rag tools can be enabled by any of them, so only then is it known whether
+// a RAG config (defaults overridden by RAG_* variables) is needed.
+func (c *Config) Finalize() {
+	if c.ToolEnabled(ToolPackageRAG) {
+		if c.RAG == nil {
+			c.RAG = rag.DefaultRAGConfig()
+		}
+		c.RAG.LoadFromEnv()
+	}
+}
+
+// applyModelEnv overrides the model config, creating it when a provider is set
+func (c *Config) applyModelEnv() {
+	provider := os.Getenv("CONDUIT_MODEL_PROVIDER")
+	if c.Model == nil {
+		if provider == "" {
+			return
+		}
+		c.Model = &ModelConfig{Temperature: 0.7, MaxTokens: 1000, TopK: 40}
+	}
+
+	if provider != "" {
+		c.Model.Provider = provider
+	}
+	if v := os.Getenv("CONDUIT_MODEL"); v != "" {
+		c.Model.Model = v
+	}
+	if v := os.Getenv("CONDUIT_MODEL_URL"); v != "" {
+		c.Model.URL = v
+	}
+	if v := os.Getenv("CONDUIT_MODEL_API_KEY"); v != "" {
+		c.Model.APIKey = v
+	}
+}
+
+// ToolEnabled reports whether a built-in tool package is enabled
+func (c *Config) ToolEnabled(name string) bool {
+	for _, t := range c.Tools {
+		if strings.EqualFold(t, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the configuration for errors without starting anything
+func (c *Config) Validate() error {
+	var problems []string
+	addf := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if c.Port <= 0 || c.Port > 65535 {
+		addf("port must be between 1 and 65535, got %d", c.Port)
+	}
+	if c.Mode < mcp.ModeStdio || c.Mode > mcp.ModeBoth {
+		addf("invalid mode: %v", c.Mode)
+	}
+
+	if c.EnableHTTPS {
+		if c.CertFile == "" || c.KeyFile == "" {
+			addf("enable_https requires cert_file and key_file")
+		}
+		for _, f := range []string{c.CertFile, c.KeyFile} {
+			if f == "" {
+				continue
+			}
+			if _, err := os.Stat(f); err != nil {
+				addf("TLS file %s: %v", f, err)
+			}
+		}
+	}
+
+	if _, err := logging.New(logging.Options{Level: c.LogLevel, Format: c.LogFormat}); err != nil {
+		addf("%v", err)
+	}
+
+	if c.Model != nil {
+		if _, err := CreateModelFunctionFromConfig(c.Model); err != nil {
+			addf("model: %v", err)
+		}
+		if c.Model.Model == "" {
+			addf("model: model name is required")
+		}
+		switch strings.ToLower(c.Model.Provider) {
+		case "openai", "deepinfra", "anthropic", "gemini":
+			if c.Model.APIKey == "" {
+				addf("model: api_key is required for provider %s", c.Model.Provider)
+			}
+		}
+		if c.Model.MaxToolIterations < 0 {
+			addf("model: max_tool_iterations must not be negative")
+		}
+		if c.Model.ContextWindow < 0 {
+			addf("model: context_window must not be negative")
+		}
+		switch strings.ToLower(c.Model.ContextStrategy) {
+		case "", tokens.StrategyDropOldest, tokens.StrategyKeepLast, tokens.StrategySummarize:
+		default:
+			addf("model: unknown context_strategy %q (want %s, %s or %s)", c.Model.ContextStrategy,
+				tokens.StrategyDropOldest, tokens.StrategyKeepLast, tokens.StrategySummarize)
+		}
+		if c.Model.Cache != nil {
+			if err := c.Model.Cache.Validate(); err != nil {
+				addf("model: cache: %v", err)
+			}
+		}
+	}
+
+	for _, t := range c.Tools {
+		if !isToolPackage(t) {
+			addf("unknown tool package %q (available: %s)", t, strings.Join(ToolPackages, ", "))
+		}
+	}
+	if c.ScriptsDir != "" {
+		if info, err := os.Stat(c.ScriptsDir); err != nil {
+			addf("scripts_dir: %v", err)
+		} else if !info.IsDir() {
+			addf("scripts_dir: %s is not a directory", c.ScriptsDir)
+		}
+	}
+	for i, src := range c.OpenAPI {
+		if src.Spec == "" {
+			addf("openapi[%d]: spec is required", i)
+		}
+	}
+	if c.ToolEnabled(ToolPackageRAG) {
+		if c.RAG == nil {
+			addf("rag tools are enabled but no rag configuration is set")
+		} else if err := c.RAG.Validate(); err != nil {
+			addf("rag: %v", err)
+		}
+	}
+	if c.Usage != nil {
+		if err := c.Usage.Validate(); err != nil {
+			addf("usage: %v", err)
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration:\n  - %s", strings.Join(problems, "\n  - "))
+	}
+	return nil
+}
+
+// SplitList splits a comma-separated list, dropping empty entries
+func SplitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func isToolPackage(name string) bool {
+	for _, p := range ToolPackages {
+		if strings.EqualFold(p, name) {
+			return true
+		}
+	}
+	return false
+}
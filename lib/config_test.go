@@ -0,0 +1,150 @@
+package conduit
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/benozo/conduit/mcp"
+)
+
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		check   func(c *Config) bool
+		wantErr string
+	}{
+		{
+			name:    "yaml over defaults",
+			file:    "conduit.yaml",
+			content: "mode: http\nport: 9090\ntools: [text, rag]\nrag:\n  chunking:\n    size: 500\n",
+			check: func(c *Config) bool {
+				return c.Mode == mcp.ModeHTTP && c.Port == 9090 && c.OllamaURL == "http://localhost:11434" &&
+					reflect.DeepEqual(c.Tools, []string{"text", "rag"}) && c.RAG.Chunking.Size == 500
+			},
+		},
+		{
+			name:    "json",
+			file:    "conduit.json",
+			content: `{"mode": "stdio", "model": {"provider": "ollama", "model": "llama3.2"}}`,
+			check: func(c *Config) bool {
+				return c.Mode == mcp.ModeStdio && c.Model.Model == "llama3.2" && c.Port == 8080
+			},
+		},
+		{name: "unknown key", file: "typo.yaml", content: "prot: 9090\n", wantErr: "prot"},
+		{name: "bad mode", file: "mode.yaml", content: "mode: sideways\n", wantErr: "sideways"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := LoadConfig(writeConfig(t, tt.file, tt.content))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want one mentioning %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.check(config) {
+				t.Errorf("unexpected config %+v", config)
+			}
+		})
+	}
+
+	if _, err := LoadConfig(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	t.Setenv("CONDUIT_PORT", "7000")
+	t.Setenv("CONDUIT_MODE", "stdio")
+	t.Setenv("CONDUIT_TOOLS", "text, rag")
+	t.Setenv("CONDUIT_MODEL_PROVIDER", "openai")
+	t.Setenv("CONDUIT_MODEL_API_KEY", "sk-test")
+	t.Setenv("RAG_CHUNK_SIZE", "321")
+	t.Setenv("RAG_SEARCH_THRESHOLD", "not-a-number")
+
+	config := DefaultConfig()
+	if err := config.ApplyEnv(); err != nil {
+		t.Fatal(err)
+	}
+	if config.Port != 7000 || config.Mode != mcp.ModeStdio || !reflect.DeepEqual(config.Tools, []string{"text", "rag"}) {
+		t.Errorf("server settings not overridden: %+v", config)
+	}
+	if config.Model == nil || config.Model.Provider != "openai" || config.Model.APIKey != "sk-test" || config.Model.MaxTokens != 1000 {
+		t.Errorf("model config %+v", config.Model)
+	}
+	if config.RAG != nil {
+		t.Errorf("rag config set before Finalize: %+v", config.RAG)
+	}
+	config.Finalize()
+	if config.RAG == nil || config.RAG.Chunking.Size != 321 || config.RAG.Search.Threshold != 0.7 {
+		t.Errorf("rag config %+v", config.RAG)
+	}
+
+	for key, value := range map[string]string{"CONDUIT_PORT": "eighty", "CONDUIT_MODE": "sideways", "CONDUIT_ENABLE_HTTPS": "maybe"} {
+		t.Run(key, func(t *testing.T) {
+			t.Setenv(key, value)
+			if err := DefaultConfig().ApplyEnv(); err == nil || !strings.Contains(err.Error(), key) {
+				t.Errorf("got %v, want an error naming %s", err, key)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(c *Config)
+		want []string
+	}{
+		{name: "defaults", edit: func(*Config) {}},
+		{name: "port", edit: func(c *Config) { c.Port = 70000 }, want: []string{"port must be between"}},
+		{name: "https without files", edit: func(c *Config) { c.EnableHTTPS = true }, want: []string{"requires cert_file and key_file"}},
+		{name: "missing tls file", edit: func(c *Config) {
+			c.EnableHTTPS, c.CertFile, c.KeyFile = true, "/nonexistent/cert.pem", "/nonexistent/key.pem"
+		}, want: []string{"TLS file /nonexistent/cert.pem", "TLS file /nonexistent/key.pem"}},
+		{name: "log format", edit: func(c *Config) { c.LogFormat = "xml" }, want: []string{"unknown log format"}},
+		{name: "api key", edit: func(c *Config) { c.Model = &ModelConfig{Provider: "openai", Model: "gpt-4o"} }, want: []string{"api_key is required"}},
+		{name: "model name", edit: func(c *Config) { c.Model = &ModelConfig{Provider: "ollama"} }, want: []string{"model name is required"}},
+		{name: "unknown tool package", edit: func(c *Config) { c.Tools = []string{"text", "shell"} }, want: []string{`unknown tool package "shell"`}},
+		{name: "rag without config", edit: func(c *Config) { c.Tools = []string{"rag"} }, want: []string{"no rag configuration"}},
+		{name: "several problems", edit: func(c *Config) { c.Port = 0; c.LogLevel = "loud" }, want: []string{"port", "unknown log level"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			tt.edit(config)
+			err := config.Validate()
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %q", err, want)
+				}
+			}
+		})
+	}
+}
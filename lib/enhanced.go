@@ -2,7 +2,8 @@ package conduit
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
+	"sync"
 
 	"github.com/benozo/conduit/mcp"
 )
@@ -17,6 +18,7 @@ type ToolMetadata struct {
 // EnhancedServer extends the base Conduit server with metadata support
 type EnhancedServer struct {
 	*Server
+	metadataMu   sync.RWMutex
 	toolMetadata map[string]ToolMetadata
 }
 
@@ -34,13 +36,37 @@ func (es *EnhancedServer) RegisterToolWithSchema(name string, tool mcp.ToolFunc,
 	es.Server.RegisterTool(name, tool)
 
 	// Store metadata for schema generation
+	es.metadataMu.Lock()
 	es.toolMetadata[name] = metadata
+	es.metadataMu.Unlock()
 
-	fmt.Printf("✓ Registered tool '%s': %s\n", name, metadata.Description)
+	slog.Debug("registered tool", "tool", name, "description", metadata.Description)
+}
+
+// RegisterContextToolWithSchema registers a context-aware tool with schema metadata
+func (es *EnhancedServer) RegisterContextToolWithSchema(name string, tool mcp.ContextToolFunc, metadata ToolMetadata) {
+	es.Server.RegisterContextTool(name, tool)
+
+	es.metadataMu.Lock()
+	es.toolMetadata[name] = metadata
+	es.metadataMu.Unlock()
+
+	slog.Debug("registered tool", "tool", name, "description", metadata.Description)
+}
+
+// UnregisterTool removes a tool and its metadata
+func (es *EnhancedServer) UnregisterTool(name string) {
+	es.Server.UnregisterTool(name)
+
+	es.metadataMu.Lock()
+	delete(es.toolMetadata, name)
+	es.metadataMu.Unlock()
 }
 
 // GetToolMetadata returns all stored tool metadata (implements EnhancedSchemaProvider)
 func (es *EnhancedServer) GetToolMetadata() map[string]interface{} {
+	es.metadataMu.RLock()
+	defer es.metadataMu.RUnlock()
 	result := make(map[string]interface{})
 	for name, metadata := range es.toolMetadata {
 		result[name] = map[string]interface{}{
@@ -54,7 +80,9 @@ func (es *EnhancedServer) GetToolMetadata() map[string]interface{} {
 
 // GetToolSchema returns the schema for a specific tool (implements EnhancedSchemaProvider)
 func (es *EnhancedServer) GetToolSchema(toolName string) (interface{}, bool) {
+	es.metadataMu.RLock()
 	metadata, exists := es.toolMetadata[toolName]
+	es.metadataMu.RUnlock()
 	if !exists {
 		return nil, false
 	}
@@ -68,11 +96,15 @@ func (es *EnhancedServer) GetToolSchema(toolName string) (interface{}, bool) {
 
 // GetCustomToolCount returns the number of custom tools with metadata
 func (es *EnhancedServer) GetCustomToolCount() int {
+	es.metadataMu.RLock()
+	defer es.metadataMu.RUnlock()
 	return len(es.toolMetadata)
 }
 
 // ListCustomTools returns a list of custom tool names and descriptions
 func (es *EnhancedServer) ListCustomTools() []map[string]string {
+	es.metadataMu.RLock()
+	defer es.metadataMu.RUnlock()
 	var tools []map[string]string
 	for name, metadata := range es.toolMetadata {
 		tools = append(tools, map[string]string{
@@ -86,8 +118,8 @@ func (es *EnhancedServer) ListCustomTools() []map[string]string {
 // Start starts the enhanced server with schema provider support
 func (es *EnhancedServer) Start() error {
 	// Configure the model if not set
-	if es.Server.model == nil {
-		es.Server.model = CreateOllamaModel(es.Server.config.OllamaURL)
+	if err := es.Server.resolveModel(es); err != nil {
+		return err
 	}
 
 	// Create unified server with enhanced schema support
@@ -97,8 +129,14 @@ func (es *EnhancedServer) Start() error {
 	if es.Server.config.Mode == mcp.ModeHTTP || es.Server.config.Mode == mcp.ModeBoth {
 		es.Server.unified.SetPort(fmt.Sprintf(":%d", es.Server.config.Port))
 	}
+	es.Server.applyTLS()
+	es.Server.applyCORS()
+	es.Server.applyUsage()
 
-	log.Printf("Starting enhanced server with %d custom tools...", len(es.toolMetadata))
+	if err := es.Server.setupLogging(); err != nil {
+		return err
+	}
+	slog.Info("starting enhanced server", "port", es.Server.config.Port, "mode", es.Server.config.Mode, "custom_tools", es.GetCustomToolCount())
 	return es.Server.unified.Run()
 }
 
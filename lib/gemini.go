@@ -0,0 +1,486 @@
+package conduit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/benozo/conduit/lib/httpclient"
+	"github.com/benozo/conduit/mcp"
+)
+
+// Gemini API defaults
+const (
+	GeminiBaseURL      = "https://generativelanguage.googleapis.com/v1beta"
+	GeminiDefaultModel = "gemini-2.5-flash"
+)
+
+// SafetySetting sets the blocking threshold for one harm category, e.g.
+// HARM_CATEGORY_HARASSMENT / BLOCK_ONLY_HIGH. It is passed to Gemini as is.
+type SafetySetting struct {
+	Category  string `json:"category" yaml:"category"`
+	Threshold string `json:"threshold" yaml:"threshold"`
+}
+
+// GeminiRequest is a generateContent request
+type GeminiRequest struct {
+	Contents          []GeminiContent         `json:"contents"`
+	SystemInstruction *GeminiContent          `json:"systemInstruction,omitempty"`
+	Tools             []GeminiTool            `json:"tools,omitempty"`
+	SafetySettings    []SafetySetting         `json:"safetySettings,omitempty"`
+	GenerationConfig  *GeminiGenerationConfig `json:"generationConfig,omitempty"`
+}
+
+// GeminiContent is one user or model turn made of parts
+type GeminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []GeminiPart `json:"parts"`
+}
+
+// GeminiPart holds text, an image, a function call or a function response
+type GeminiPart struct {
+	Text             string                  `json:"text,omitempty"`
+	InlineData       *GeminiBlob             `json:"inlineData,omitempty"`
+	FileData         *GeminiFileData         `json:"fileData,omitempty"`
+	FunctionCall     *GeminiFunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *GeminiFunctionResponse `json:"functionResponse,omitempty"`
+}
+
+// GeminiBlob is inline media with base64 data
+type GeminiBlob struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
+// GeminiFileData refers to media by URI, e.g. a file uploaded with the
+// Files API
+type GeminiFileData struct {
+	MimeType string `json:"mimeType,omitempty"`
+	FileURI  string `json:"fileUri"`
+}
+
+// GeminiFunctionCall is a call requested by the model
+type GeminiFunctionCall struct {
+	ID   string                 `json:"id,omitempty"`
+	Name string                 `json:"name"`
+	Args map[string]interface{} `json:"args"`
+}
+
+// GeminiFunctionResponse returns a tool result to the model
+type GeminiFunctionResponse struct {
+	ID       string                 `json:"id,omitempty"`
+	Name     string                 `json:"name"`
+	Response map[string]interface{} `json:"response"`
+}
+
+// GeminiTool groups the function declarations offered to the model
+type GeminiTool struct {
+	FunctionDeclarations []GeminiFunctionDeclaration `json:"functionDeclarations"`
+}
+
+// GeminiFunctionDeclaration describes a tool the model may call
+type GeminiFunctionDeclaration struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Parameters  map[string]interface{} `json:"parameters,omitempty"`
+}
+
+// GeminiGenerationConfig holds the sampling options
+type GeminiGenerationConfig struct {
+	Temperature     float64  `json:"temperature,omitempty"`
+	TopP            float64  `json:"topP,omitempty"`
+	TopK            int      `json:"topK,omitempty"`
+	MaxOutputTokens int      `json:"maxOutputTokens,omitempty"`
+	StopSequences   []string `json:"stopSequences,omitempty"`
+	// ResponseMimeType is application/json in JSON mode
+	ResponseMimeType string `json:"responseMimeType,omitempty"`
+}
+
+// GeminiResponse is a generateContent response, or one chunk of a stream
+type GeminiResponse struct {
+	Candidates []struct {
+		Content      GeminiContent `json:"content"`
+		FinishReason string        `json:"finishReason"`
+	} `json:"candidates"`
+	PromptFeedback *struct {
+		BlockReason string `json:"blockReason"`
+	} `json:"promptFeedback,omitempty"`
+	UsageMetadata *GeminiUsage `json:"usageMetadata,omitempty"`
+	ModelVersion  string       `json:"modelVersion,omitempty"`
+	Error         *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Status  string `json:"status"`
+	} `json:"error,omitempty"`
+}
+
+// GeminiUsage reports token usage
+type GeminiUsage struct {
+	PromptTokenCount     int `json:"promptTokenCount"`
+	CandidatesTokenCount int `json:"candidatesTokenCount"`
+	TotalTokenCount      int `json:"totalTokenCount"`
+}
+
+// GeminiChatModel is a ChatModel for the Gemini generateContent API
+type GeminiChatModel struct {
+	// BaseURL is the API root; requests go to {BaseURL}/models/{model}:generateContent
+	BaseURL string
+	APIKey  string
+	// Model, Temperature, TopK and MaxTokens are defaults for requests that don't set them
+	Model          string
+	Temperature    float64
+	TopK           int
+	MaxTokens      int
+	SafetySettings []SafetySetting
+	Client         *httpclient.Client
+}
+
+// NewGeminiChatModel creates a chat model from config. The URL overrides the
+// API root so tests can point at a local fake.
+func NewGeminiChatModel(config *ModelConfig) (*GeminiChatModel, error) {
+	if config == nil {
+		return nil, fmt.Errorf("model config is nil")
+	}
+
+	m := &GeminiChatModel{
+		BaseURL:        strings.TrimRight(config.URL, "/"),
+		APIKey:         config.APIKey,
+		Model:          config.Model,
+		Temperature:    config.Temperature,
+		TopK:           config.TopK,
+		MaxTokens:      config.MaxTokens,
+		SafetySettings: config.SafetySettings,
+		Client:         newModelClient("gemini", config.HTTP, 300*time.Second),
+	}
+	if m.BaseURL == "" {
+		m.BaseURL = GeminiBaseURL
+	}
+	if m.Model == "" {
+		m.Model = GeminiDefaultModel
+	}
+	return m, nil
+}
+
+// Chat sends the conversation and returns the reply. When req.OnToken is set
+// it uses streamGenerateContent and forwards text as it arrives.
+func (m *GeminiChatModel) Chat(ctx context.Context, req mcp.ChatRequest) (*mcp.ChatResponse, error) {
+	model := req.Model
+	if model == "" {
+		model = m.Model
+	}
+	stream := req.OnToken != nil
+
+	body, err := json.Marshal(m.buildRequest(req))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal Gemini request: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/models/%s:generateContent", m.BaseURL, strings.TrimPrefix(model, "models/"))
+	if stream {
+		url = fmt.Sprintf("%s/models/%s:streamGenerateContent?alt=sse", m.BaseURL, strings.TrimPrefix(model, "models/"))
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Gemini request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if m.APIKey != "" {
+		httpReq.Header.Set("x-goog-api-key", m.APIKey)
+	}
+
+	slog.DebugContext(ctx, "gemini request", "model", model, "messages", len(req.Messages), "tools", len(req.Tools), "stream", stream)
+
+	client := m.Client
+	if client == nil {
+		client = httpclient.New("gemini", httpclient.Options{})
+	}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call Gemini: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var chunks []GeminiResponse
+	if stream {
+		chunks, err = readGeminiStream(resp.Body, req.OnToken)
+	} else {
+		var result GeminiResponse
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		chunks = []GeminiResponse{result}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode Gemini response: %w", err)
+	}
+
+	return geminiChatResponse(ctx, model, chunks)
+}
+
+// geminiChatResponse merges the response chunks into a ChatResponse
+func geminiChatResponse(ctx context.Context, model string, chunks []GeminiResponse) (*mcp.ChatResponse, error) {
+	message := mcp.ChatMessage{Role: mcp.RoleAssistant}
+	var text strings.Builder
+	var finishReason string
+	var usage GeminiUsage
+	for _, chunk := range chunks {
+		if chunk.PromptFeedback != nil && chunk.PromptFeedback.BlockReason != "" {
+			return nil, fmt.Errorf("Gemini blocked the prompt: %s", chunk.PromptFeedback.BlockReason)
+		}
+		if chunk.UsageMetadata != nil {
+			usage = *chunk.UsageMetadata
+		}
+		if chunk.ModelVersion != "" {
+			model = chunk.ModelVersion
+		}
+		if len(chunk.Candidates) == 0 {
+			continue
+		}
+		candidate := chunk.Candidates[0]
+		if candidate.FinishReason != "" {
+			finishReason = candidate.FinishReason
+		}
+		for _, part := range candidate.Content.Parts {
+			text.WriteString(part.Text)
+			if call := part.FunctionCall; call != nil {
+				args := call.Args
+				if args == nil {
+					args = map[string]interface{}{}
+				}
+				message.ToolCalls = append(message.ToolCalls, mcp.ChatToolCall{ID: call.ID, Name: call.Name, Arguments: args})
+			}
+		}
+	}
+	message.Content = text.String()
+	observeTokens(ctx, "gemini", model, usage.PromptTokenCount, usage.CandidatesTokenCount)
+
+	stopReason := mcp.StopReasonEnd
+	switch {
+	case len(message.ToolCalls) > 0:
+		stopReason = mcp.StopReasonToolCalls
+	case finishReason == "MAX_TOKENS":
+		stopReason = mcp.StopReasonLength
+	case finishReason != "" && finishReason != "STOP" && message.Content == "":
+		return nil, fmt.Errorf("Gemini returned no content (finish reason %s)", finishReason)
+	}
+
+	return &mcp.ChatResponse{
+		Model:      model,
+		Message:    message,
+		StopReason: stopReason,
+		Usage: mcp.Usage{
+			PromptTokens:     usage.PromptTokenCount,
+			CompletionTokens: usage.CandidatesTokenCount,
+			TotalTokens:      usage.TotalTokenCount,
+		},
+	}, nil
+}
+
+// geminiImageParts converts images to inline data, or to file data for
+// images given by URI
+func geminiImageParts(images []mcp.Image) []GeminiPart {
+	var parts []GeminiPart
+	for _, img := range images {
+		if img.Remote() {
+			parts = append(parts, GeminiPart{FileData: &GeminiFileData{MimeType: img.MIMEType, FileURI: img.URL}})
+		} else {
+			parts = append(parts, GeminiPart{InlineData: &GeminiBlob{MimeType: img.MIMEType, Data: img.Base64()}})
+		}
+	}
+	return parts
+}
+
+// buildRequest converts a ChatRequest to the generateContent format. System
+// messages become the system instruction, assistant turns use the "model"
+// role and tool results are sent back as function responses.
+func (m *GeminiChatModel) buildRequest(req mcp.ChatRequest) GeminiRequest {
+	payload := GeminiRequest{
+		SafetySettings: m.SafetySettings,
+		GenerationConfig: &GeminiGenerationConfig{
+			Temperature:     firstNonZero(req.Temperature, m.Temperature),
+			TopP:            req.TopP,
+			TopK:            req.TopK,
+			MaxOutputTokens: req.MaxTokens,
+			StopSequences:   req.Stop,
+		},
+	}
+	if payload.GenerationConfig.TopK == 0 {
+		payload.GenerationConfig.TopK = m.TopK
+	}
+	if payload.GenerationConfig.MaxOutputTokens == 0 {
+		payload.GenerationConfig.MaxOutputTokens = m.MaxTokens
+	}
+	// Gemini rejects JSON mode combined with function calling
+	if req.ResponseSchema != nil && len(req.Tools) == 0 {
+		payload.GenerationConfig.ResponseMimeType = "application/json"
+	}
+
+	var system []GeminiPart
+	for _, msg := range req.Messages {
+		var role string
+		var parts []GeminiPart
+		switch msg.Role {
+		case mcp.RoleSystem:
+			system = append(system, GeminiPart{Text: msg.Content})
+			continue
+		case mcp.RoleTool:
+			role = "user"
+			parts = []GeminiPart{{FunctionResponse: &GeminiFunctionResponse{
+				ID:       msg.ToolCallID,
+				Name:     msg.Name,
+				Response: geminiToolResponse(msg.Content),
+			}}}
+			parts = append(parts, geminiImageParts(msg.Images)...)
+		case mcp.RoleAssistant:
+			role = "model"
+			if msg.Content != "" {
+				parts = append(parts, GeminiPart{Text: msg.Content})
+			}
+			for _, call := range msg.ToolCalls {
+				args := call.Arguments
+				if args == nil {
+					args = map[string]interface{}{}
+				}
+				parts = append(parts, GeminiPart{FunctionCall: &GeminiFunctionCall{ID: call.ID, Name: call.Name, Args: args}})
+			}
+		default:
+			role = "user"
+			if msg.Content != "" || len(msg.Images) == 0 {
+				parts = []GeminiPart{{Text: msg.Content}}
+			}
+			parts = append(parts, geminiImageParts(msg.Images)...)
+		}
+		if len(parts) == 0 {
+			continue
+		}
+
+		if n := len(payload.Contents); n > 0 && payload.Contents[n-1].Role == role {
+			payload.Contents[n-1].Parts = append(payload.Contents[n-1].Parts, parts...)
+		} else {
+			payload.Contents = append(payload.Contents, GeminiContent{Role: role, Parts: parts})
+		}
+	}
+	if len(system) > 0 {
+		payload.SystemInstruction = &GeminiContent{Parts: system}
+	}
+
+	if len(req.Tools) > 0 {
+		tool := GeminiTool{}
+		for _, def := range req.Tools {
+			tool.FunctionDeclarations = append(tool.FunctionDeclarations, GeminiFunctionDeclaration{
+				Name:        def.Name,
+				Description: def.Description,
+				Parameters:  geminiSchema(def.Parameters),
+			})
+		}
+		payload.Tools = []GeminiTool{tool}
+	}
+	return payload
+}
+
+// geminiToolResponse wraps a tool result as the object Gemini expects. JSON
+// objects are passed through; anything else goes under "content".
+func geminiToolResponse(content string) map[string]interface{} {
+	var obj map[string]interface{}
+	if err := json.Unmarshal([]byte(content), &obj); err == nil && obj != nil {
+		return obj
+	}
+	return map[string]interface{}{"content": content}
+}
+
+// geminiSchemaKeys are the JSON Schema keywords Gemini accepts in function parameters
+var geminiSchemaKeys = map[string]bool{
+	"type": true, "format": true, "description": true, "nullable": true, "enum": true,
+	"properties": true, "required": true, "items": true, "minItems": true, "maxItems": true,
+	"minimum": true, "maximum": true, "minLength": true, "maxLength": true, "pattern": true,
+	"anyOf": true, "propertyOrdering": true, "title": true,
+}
+
+// geminiSchema drops the schema keywords Gemini rejects, such as
+// additionalProperties and $schema. Objects without properties are omitted
+// entirely, since Gemini refuses empty object schemas.
+func geminiSchema(schema map[string]interface{}) map[string]interface{} {
+	if props, ok := schema["properties"].(map[string]interface{}); schema["type"] == "object" && (!ok || len(props) == 0) {
+		return nil
+	}
+	return sanitizeGeminiSchema(schema)
+}
+
+func sanitizeGeminiSchema(schema map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(schema))
+	for key, value := range schema {
+		if !geminiSchemaKeys[key] {
+			continue
+		}
+		switch key {
+		case "properties":
+			props, _ := value.(map[string]interface{})
+			clean := make(map[string]interface{}, len(props))
+			for name, prop := range props {
+				if p, ok := prop.(map[string]interface{}); ok {
+					clean[name] = sanitizeGeminiSchema(p)
+				}
+			}
+			out[key] = clean
+		case "items":
+			if items, ok := value.(map[string]interface{}); ok {
+				out[key] = sanitizeGeminiSchema(items)
+			}
+		case "anyOf":
+			if options, ok := value.([]interface{}); ok {
+				var clean []interface{}
+				for _, option := range options {
+					if o, ok := option.(map[string]interface{}); ok {
+						clean = append(clean, sanitizeGeminiSchema(o))
+					}
+				}
+				out[key] = clean
+			}
+		default:
+			out[key] = value
+		}
+	}
+	return out
+}
+
+// readGeminiStream decodes the server-sent chunks of streamGenerateContent,
+// passing text to onToken as it arrives
+func readGeminiStream(body io.Reader, onToken func(string)) ([]GeminiResponse, error) {
+	var chunks []GeminiResponse
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		var chunk GeminiResponse
+		if err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), &chunk); err != nil {
+			return chunks, fmt.Errorf("invalid stream chunk: %w", err)
+		}
+		if chunk.Error != nil {
+			return chunks, fmt.Errorf("stream error %s: %s", chunk.Error.Status, chunk.Error.Message)
+		}
+		if onToken != nil && len(chunk.Candidates) > 0 {
+			for _, part := range chunk.Candidates[0].Content.Parts {
+				if part.Text != "" {
+					onToken(part.Text)
+				}
+			}
+		}
+		chunks = append(chunks, chunk)
+	}
+	return chunks, scanner.Err()
+}
+
+// CreateGeminiModelWithConfig creates a Gemini model function with
+// configuration. An optional "system" input becomes the system instruction.
+func CreateGeminiModelWithConfig(config *ModelConfig) mcp.ModelFunc {
+	chat, _ := NewGeminiChatModel(config)
+	return instrumentModel("gemini", fixedModel(chat.Model), mcp.ModelFuncFromChat(chat))
+}
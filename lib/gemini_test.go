@@ -0,0 +1,189 @@
+package conduit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/benozo/conduit/mcp"
+)
+
+// geminiStub serves canned generateContent replies and records the last request
+func geminiStub(t *testing.T, reply func(w http.ResponseWriter)) (*httptest.Server, *GeminiRequest, **http.Request) {
+	t.Helper()
+	var got GeminiRequest
+	var last *http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		last = r
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("invalid request body: %v", err)
+		}
+		reply(w)
+	}))
+	t.Cleanup(server.Close)
+	return server, &got, &last
+}
+
+func TestGeminiChat(t *testing.T) {
+	server, got, last := geminiStub(t, func(w http.ResponseWriter) {
+		fmt.Fprint(w, `{
+			"candidates": [{
+				"content": {"role": "model", "parts": [
+					{"text": "Adding."},
+					{"functionCall": {"id": "call_1", "name": "add", "args": {"a": 1, "b": 2}}}
+				]},
+				"finishReason": "STOP"
+			}],
+			"usageMetadata": {"promptTokenCount": 10, "candidatesTokenCount": 4, "totalTokenCount": 14},
+			"modelVersion": "gemini-test-001"
+		}`)
+	})
+
+	model, err := NewGeminiChatModel(&ModelConfig{
+		URL:            server.URL + "/",
+		APIKey:         "g-key",
+		Model:          "gemini-test",
+		Temperature:    0.3,
+		SafetySettings: []SafetySetting{{Category: "HARM_CATEGORY_HARASSMENT", Threshold: "BLOCK_ONLY_HIGH"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := model.Chat(context.Background(), mcp.ChatRequest{
+		Messages: []mcp.ChatMessage{
+			{Role: mcp.RoleSystem, Content: "be brief"},
+			{Role: mcp.RoleUser, Content: "what is 1+2?"},
+			{Role: mcp.RoleAssistant, ToolCalls: []mcp.ChatToolCall{{ID: "call_0", Name: "lookup"}}},
+			{Role: mcp.RoleTool, ToolCallID: "call_0", Name: "lookup", Content: "not found"},
+			{Role: mcp.RoleTool, ToolCallID: "call_9", Name: "stats", Content: `{"count": 2}`},
+		},
+		Tools: []mcp.ToolDefinition{
+			{Name: "add", Description: "Add", Parameters: map[string]interface{}{
+				"type":                 "object",
+				"$schema":              "http://json-schema.org/draft-07/schema#",
+				"additionalProperties": false,
+				"properties": map[string]interface{}{
+					"a": map[string]interface{}{"type": "number", "additionalProperties": false},
+				},
+			}},
+			{Name: "now", Parameters: map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := *last
+	if r.URL.Path != "/models/gemini-test:generateContent" || r.Header.Get("x-goog-api-key") != "g-key" {
+		t.Errorf("request %s with key %q", r.URL.Path, r.Header.Get("x-goog-api-key"))
+	}
+	if got.SystemInstruction == nil || got.SystemInstruction.Parts[0].Text != "be brief" {
+		t.Errorf("system instruction %+v", got.SystemInstruction)
+	}
+	if got.GenerationConfig.Temperature != 0.3 || len(got.SafetySettings) != 1 {
+		t.Errorf("generation config %+v, safety %+v", got.GenerationConfig, got.SafetySettings)
+	}
+
+	var roles []string
+	for _, content := range got.Contents {
+		roles = append(roles, content.Role)
+	}
+	if !reflect.DeepEqual(roles, []string{"user", "model", "user"}) {
+		t.Errorf("roles %v", roles)
+	}
+	// Both tool results share one user turn; non-object results are wrapped
+	results := got.Contents[2].Parts
+	if len(results) != 2 ||
+		!reflect.DeepEqual(results[0].FunctionResponse.Response, map[string]interface{}{"content": "not found"}) ||
+		!reflect.DeepEqual(results[1].FunctionResponse.Response, map[string]interface{}{"count": float64(2)}) {
+		t.Errorf("function responses %+v", results)
+	}
+
+	decls := got.Tools[0].FunctionDeclarations
+	wantAdd := map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{"a": map[string]interface{}{"type": "number"}},
+	}
+	if !reflect.DeepEqual(decls[0].Parameters, wantAdd) {
+		t.Errorf("add parameters %v", decls[0].Parameters)
+	}
+	if decls[1].Parameters != nil {
+		t.Errorf("empty object schema should be omitted, got %v", decls[1].Parameters)
+	}
+
+	want := []mcp.ChatToolCall{{ID: "call_1", Name: "add", Arguments: map[string]interface{}{"a": float64(1), "b": float64(2)}}}
+	if resp.Message.Content != "Adding." || !reflect.DeepEqual(resp.Message.ToolCalls, want) || resp.StopReason != mcp.StopReasonToolCalls {
+		t.Errorf("response %+v", resp)
+	}
+	if resp.Model != "gemini-test-001" || resp.Usage != (mcp.Usage{PromptTokens: 10, CompletionTokens: 4, TotalTokens: 14}) {
+		t.Errorf("model %q, usage %+v", resp.Model, resp.Usage)
+	}
+}
+
+func TestGeminiStream(t *testing.T) {
+	server, _, last := geminiStub(t, func(w http.ResponseWriter) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":\"Hel\"}]}}]}\n\n")
+		fmt.Fprint(w, "data: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":\"lo\"}]},\"finishReason\":\"MAX_TOKENS\"}],"+
+			"\"usageMetadata\":{\"promptTokenCount\":3,\"candidatesTokenCount\":2,\"totalTokenCount\":5}}\n\n")
+	})
+
+	model, _ := NewGeminiChatModel(&ModelConfig{URL: server.URL})
+	var tokens []string
+	resp, err := model.Chat(context.Background(), mcp.ChatRequest{
+		Model:    "models/gemini-other",
+		Messages: []mcp.ChatMessage{{Role: mcp.RoleUser, Content: "hi"}},
+		OnToken:  func(token string) { tokens = append(tokens, token) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := *last
+	if r.URL.Path != "/models/gemini-other:streamGenerateContent" || r.URL.Query().Get("alt") != "sse" {
+		t.Errorf("request %s", r.URL)
+	}
+	if !reflect.DeepEqual(tokens, []string{"Hel", "lo"}) || resp.Message.Content != "Hello" {
+		t.Errorf("tokens %v, content %q", tokens, resp.Message.Content)
+	}
+	if resp.StopReason != mcp.StopReasonLength || resp.Usage.TotalTokens != 5 {
+		t.Errorf("stop %q, usage %+v", resp.StopReason, resp.Usage)
+	}
+}
+
+func TestGeminiErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream bool
+		reply  string
+		status int
+		want   string
+	}{
+		{"status", false, `{"error":{"code":400,"message":"bad"}}`, http.StatusBadRequest, "status 400"},
+		{"blocked", false, `{"promptFeedback":{"blockReason":"SAFETY"}}`, http.StatusOK, "blocked the prompt: SAFETY"},
+		{"no content", false, `{"candidates":[{"content":{"parts":[]},"finishReason":"SAFETY"}]}`, http.StatusOK, "finish reason SAFETY"},
+		{"stream error", true, "data: {\"error\":{\"code\":503,\"message\":\"busy\",\"status\":\"UNAVAILABLE\"}}\n\n", http.StatusOK, "UNAVAILABLE: busy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, _, _ := geminiStub(t, func(w http.ResponseWriter) {
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.reply)
+			})
+			model, _ := NewGeminiChatModel(&ModelConfig{URL: server.URL})
+			req := mcp.ChatRequest{Messages: []mcp.ChatMessage{{Role: mcp.RoleUser, Content: "hi"}}}
+			if tt.stream {
+				req.OnToken = func(string) {}
+			}
+			_, err := model.Chat(context.Background(), req)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
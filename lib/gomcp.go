@@ -2,8 +2,12 @@ package conduit
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
+	"os"
 
+	"github.com/benozo/conduit/lib/logging"
+	"github.com/benozo/conduit/lib/rag"
+	"github.com/benozo/conduit/lib/usage"
 	"github.com/benozo/conduit/mcp"
 )
 
@@ -18,15 +22,36 @@ type Server struct {
 
 // Config holds server configuration
 type Config struct {
-	Port          int               `json:"port"`
-	OllamaURL     string            `json:"ollama_url"`
-	Mode          mcp.ServerMode    `json:"mode"`
-	Environment   map[string]string `json:"environment"`
-	EnableCORS    bool              `json:"enable_cors"`
-	EnableHTTPS   bool              `json:"enable_https"`
-	CertFile      string            `json:"cert_file"`
-	KeyFile       string            `json:"key_file"`
-	EnableLogging bool              `json:"enable_logging"`
+	Port          int               `json:"port" yaml:"port"`
+	OllamaURL     string            `json:"ollama_url" yaml:"ollama_url"`
+	Mode          mcp.ServerMode    `json:"mode" yaml:"mode"`
+	Environment   map[string]string `json:"environment" yaml:"environment"`
+	EnableCORS    bool              `json:"enable_cors" yaml:"enable_cors"`
+	EnableHTTPS   bool              `json:"enable_https" yaml:"enable_https"`
+	CertFile      string            `json:"cert_file" yaml:"cert_file"`
+	KeyFile       string            `json:"key_file" yaml:"key_file"`
+	EnableLogging bool              `json:"enable_logging" yaml:"enable_logging"`
+	LogLevel      string            `json:"log_level" yaml:"log_level"`
+	LogFormat     string            `json:"log_format" yaml:"log_format"`
+	// KeepDefaultLogger stops Start from replacing the slog default with a
+	// logger built from the settings above, so an embedding application that
+	// installs its own logger keeps it.
+	KeepDefaultLogger bool `json:"keep_default_logger,omitempty" yaml:"keep_default_logger,omitempty"`
+
+	// Model configures the default model; when nil, Ollama at OllamaURL is used
+	Model *ModelConfig `json:"model,omitempty" yaml:"model,omitempty"`
+	// Tools lists the built-in tool packages to enable (text, memory, utility, rag)
+	Tools []string `json:"tools,omitempty" yaml:"tools,omitempty"`
+	// RAG configures the RAG engine used by the rag tool package
+	RAG *rag.RAGConfig `json:"rag,omitempty" yaml:"rag,omitempty"`
+	// Plugins lists plugin manifest files whose tools are registered at startup
+	Plugins []string `json:"plugins,omitempty" yaml:"plugins,omitempty"`
+	// OpenAPI lists OpenAPI documents whose operations are registered as tools
+	OpenAPI []OpenAPISource `json:"openapi,omitempty" yaml:"openapi,omitempty"`
+	// ScriptsDir holds Starlark tool scripts (*.star), reloaded when they change
+	ScriptsDir string `json:"scripts_dir,omitempty" yaml:"scripts_dir,omitempty"`
+	// Usage sets model prices and the budgets enforced on model calls
+	Usage *usage.Config `json:"usage,omitempty" yaml:"usage,omitempty"`
 }
 
 // DefaultConfig returns a sensible default configuration
@@ -39,6 +64,9 @@ func DefaultConfig() *Config {
 		EnableCORS:    true,
 		EnableHTTPS:   false,
 		EnableLogging: true,
+		LogLevel:      "info",
+		LogFormat:     logging.FormatText,
+		Tools:         []string{ToolPackageText, ToolPackageMemory, ToolPackageUtility},
 	}
 }
 
@@ -72,6 +100,17 @@ func (s *Server) RegisterTool(name string, tool mcp.ToolFunc) {
 	s.tools.Register(name, tool)
 }
 
+// RegisterContextTool adds a tool that receives the calling request's
+// context, so it can stop when the client cancels or disconnects
+func (s *Server) RegisterContextTool(name string, tool mcp.ContextToolFunc) {
+	s.tools.RegisterContext(name, tool)
+}
+
+// UnregisterTool removes a tool from the server
+func (s *Server) UnregisterTool(name string) {
+	s.tools.Unregister(name)
+}
+
 // SetModel sets a custom model function
 func (s *Server) SetModel(model mcp.ModelFunc) {
 	s.model = model
@@ -94,8 +133,8 @@ func (s *Server) GetConfig() *Config {
 
 // Start starts the server with the configured mode
 func (s *Server) Start() error {
-	if s.model == nil {
-		s.model = createDefaultOllamaModel(s.config.OllamaURL)
+	if err := s.resolveModel(nil); err != nil {
+		return err
 	}
 
 	s.unified = mcp.NewUnifiedServer(s.model, s.tools)
@@ -104,14 +143,84 @@ func (s *Server) Start() error {
 	if s.config.Port != 8080 {
 		s.unified.SetPort(fmt.Sprintf(":%d", s.config.Port))
 	}
+	s.applyTLS()
+	s.applyCORS()
+	s.applyUsage()
 
-	if s.config.EnableLogging {
-		log.Printf("Starting conduit server on port %d (mode: %v)", s.config.Port, s.config.Mode)
+	if err := s.setupLogging(); err != nil {
+		return err
 	}
+	slog.Info("starting conduit server", "port", s.config.Port, "mode", s.config.Mode)
 
 	return s.unified.Run()
 }
 
+// resolveModel picks the configured model, falling back to Ollama at OllamaURL.
+// Configured models get the server's tools, described by provider when set.
+func (s *Server) resolveModel(provider mcp.EnhancedSchemaProvider) error {
+	if s.model != nil {
+		return nil
+	}
+	if s.config.Model != nil {
+		model, err := CreateToolAwareModelFromConfig(s.config.Model, s.tools, provider)
+		if err != nil {
+			return fmt.Errorf("invalid model config: %w", err)
+		}
+		s.model = model
+		return nil
+	}
+	s.model = createDefaultOllamaModel(s.config.OllamaURL)
+	return nil
+}
+
+// applyTLS enables HTTPS on the unified server when configured
+func (s *Server) applyTLS() {
+	if s.config.EnableHTTPS {
+		s.unified.SetTLS(s.config.CertFile, s.config.KeyFile)
+	}
+}
+
+// applyCORS passes EnableCORS on to the HTTP endpoints
+func (s *Server) applyCORS() {
+	s.unified.SetCORS(s.config.EnableCORS)
+}
+
+// applyUsage installs the configured prices and budgets on the default
+// usage tracker
+func (s *Server) applyUsage() {
+	if s.config.Usage != nil {
+		s.config.Usage.Apply(usage.Default)
+	}
+}
+
+// setupLogging installs the structured logger on stderr unless
+// KeepDefaultLogger is set, in which case it only validates the config.
+// CONDUIT_LOG_LEVEL and CONDUIT_LOG_FORMAT override the config; disabling
+// logging keeps errors only.
+func (s *Server) setupLogging() error {
+	opts := logging.Options{Level: s.config.LogLevel, Format: s.config.LogFormat}
+	if level := os.Getenv(logging.EnvLevel); level != "" {
+		opts.Level = level
+	}
+	if format := os.Getenv(logging.EnvFormat); format != "" {
+		opts.Format = format
+	}
+	if !s.config.EnableLogging {
+		opts.Level = "error"
+	}
+
+	if s.config.KeepDefaultLogger {
+		if _, err := logging.New(opts); err != nil {
+			return fmt.Errorf("invalid logging config: %w", err)
+		}
+		return nil
+	}
+	if _, err := logging.Setup(opts); err != nil {
+		return fmt.Errorf("invalid logging config: %w", err)
+	}
+	return nil
+}
+
 // StartWithMode starts the server with a specific mode, overriding config
 func (s *Server) StartWithMode(mode mcp.ServerMode) error {
 	originalMode := s.config.Mode
@@ -0,0 +1,49 @@
+package conduit
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+)
+
+func TestSetupLogging(t *testing.T) {
+	original := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(original) })
+	t.Setenv("CONDUIT_LOG_LEVEL", "")
+	t.Setenv("CONDUIT_LOG_FORMAT", "")
+
+	var buf bytes.Buffer
+	own := slog.New(slog.NewTextHandler(&buf, nil))
+
+	config := DefaultConfig()
+	config.LogLevel = "warn"
+	config.LogFormat = "json"
+	config.KeepDefaultLogger = true
+	slog.SetDefault(own)
+	if err := NewServer(config).setupLogging(); err != nil {
+		t.Fatal(err)
+	}
+	if slog.Default() != own {
+		t.Error("KeepDefaultLogger replaced the default logger")
+	}
+
+	config.KeepDefaultLogger = false
+	if err := NewServer(config).setupLogging(); err != nil {
+		t.Fatal(err)
+	}
+	if slog.Default() == own {
+		t.Fatal("the configured logger was not installed")
+	}
+	if slog.Default().Enabled(context.Background(), slog.LevelInfo) {
+		t.Error("the configured warn level was not applied")
+	}
+
+	config.LogLevel = "loud"
+	for _, keep := range []bool{true, false} {
+		config.KeepDefaultLogger = keep
+		if err := NewServer(config).setupLogging(); err == nil {
+			t.Errorf("KeepDefaultLogger=%v: invalid level accepted", keep)
+		}
+	}
+}
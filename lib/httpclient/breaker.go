@@ -0,0 +1,115 @@
+package httpclient
+
+import (
+	"sync"
+	"time"
+)
+
+// Breaker states
+const (
+	StateClosed   = "closed"
+	StateOpen     = "open"
+	StateHalfOpen = "half-open"
+)
+
+// Breaker is a consecutive-failure circuit breaker. After Threshold failures
+// in a row it opens and rejects calls for Cooldown, then lets a single probe
+// through: success closes it again, failure reopens it.
+type Breaker struct {
+	Threshold int
+	Cooldown  time.Duration
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	probing  bool
+	now      func() time.Time
+}
+
+// NewBreaker creates a closed breaker. A threshold of zero or less disables it.
+func NewBreaker(threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{Threshold: threshold, Cooldown: cooldown, now: time.Now}
+}
+
+// Allow reports whether a call may proceed. In the half-open state only one
+// probe is allowed at a time.
+func (b *Breaker) Allow() bool {
+	if b == nil || b.Threshold <= 0 {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state() {
+	case StateOpen:
+		return false
+	case StateHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+	}
+	return true
+}
+
+// Success records a call that reached a healthy backend
+func (b *Breaker) Success() {
+	if b == nil || b.Threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.openedAt = time.Time{}
+	b.probing = false
+}
+
+// Failure records a call that failed because the backend is unavailable. It
+// returns true when this failure opened the breaker.
+func (b *Breaker) Failure() bool {
+	if b == nil || b.Threshold <= 0 {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	wasProbing := b.probing
+	b.probing = false
+	b.failures++
+	if wasProbing || (b.openedAt.IsZero() && b.failures >= b.Threshold) {
+		b.openedAt = b.now()
+		return true
+	}
+	return false
+}
+
+// Abandon releases a probe whose outcome is unknown, e.g. because the
+// caller cancelled it, so the next call can probe instead
+func (b *Breaker) Abandon() {
+	if b == nil || b.Threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// State returns StateClosed, StateOpen or StateHalfOpen
+func (b *Breaker) State() string {
+	if b == nil || b.Threshold <= 0 {
+		return StateClosed
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state()
+}
+
+func (b *Breaker) state() string {
+	if b.openedAt.IsZero() {
+		return StateClosed
+	}
+	if b.now().Sub(b.openedAt) < b.Cooldown {
+		return StateOpen
+	}
+	return StateHalfOpen
+}
@@ -0,0 +1,113 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrCircuitOpen is returned without calling the backend while its circuit
+// breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// Error is a failed provider call, classified as transient or permanent.
+// Transient errors (rate limits, 5xx, network failures, an open breaker) may
+// succeed if retried later; permanent ones (bad request, auth) will not.
+type Error struct {
+	Provider string
+	// StatusCode is the HTTP status, or 0 when no response was received
+	StatusCode int
+	// Body is the start of the response body, usually the provider's error message
+	Body       string
+	RetryAfter time.Duration
+	Transient  bool
+	Err        error
+}
+
+func (e *Error) Error() string {
+	switch {
+	case e.StatusCode != 0 && e.Body != "":
+		return fmt.Sprintf("%s returned status %d: %s", e.Provider, e.StatusCode, e.Body)
+	case e.StatusCode != 0:
+		return fmt.Sprintf("%s returned status %d", e.Provider, e.StatusCode)
+	default:
+		return fmt.Sprintf("%s: %v", e.Provider, e.Err)
+	}
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// IsTransient reports whether err is worth retrying later
+func IsTransient(err error) bool {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Transient
+	}
+	return errors.Is(err, ErrCircuitOpen)
+}
+
+// IsPermanent reports whether err is a classified failure that retrying
+// will not fix. Unclassified errors are neither transient nor permanent.
+func IsPermanent(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && !e.Transient
+}
+
+// RetryAfterOf returns the delay the provider asked for, if any
+func RetryAfterOf(err error) time.Duration {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.RetryAfter
+	}
+	return 0
+}
+
+// retryableStatus reports whether a status code is transient: rate limits,
+// server errors and Anthropic's 529 overloaded
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests,
+		http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout, 529:
+		return true
+	}
+	return false
+}
+
+// transportError classifies an error from http.Client.Do. Cancellation by
+// the caller is permanent; refused, reset and timed-out connections are transient.
+func transportError(ctx context.Context, provider string, err error) *Error {
+	if ctx.Err() != nil {
+		return &Error{Provider: provider, Err: ctx.Err()}
+	}
+	return &Error{Provider: provider, Err: err, Transient: true}
+}
+
+// parseRetryAfter reads Retry-After (seconds or HTTP date) and the
+// retry-after-ms header some providers send
+func parseRetryAfter(h http.Header, now time.Time) time.Duration {
+	if ms := h.Get("retry-after-ms"); ms != "" {
+		if v, err := strconv.ParseFloat(ms, 64); err == nil && v >= 0 {
+			return time.Duration(v * float64(time.Millisecond))
+		}
+	}
+	value := strings.TrimSpace(h.Get("Retry-After"))
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.ParseFloat(value, 64); err == nil && secs >= 0 {
+		return time.Duration(secs * float64(time.Second))
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
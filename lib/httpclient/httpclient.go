@@ -0,0 +1,253 @@
+// Package httpclient is the HTTP layer shared by the model providers. It adds
+// per-provider timeouts, retries with exponential backoff and jitter that
+// honor Retry-After, a circuit breaker per backend, connection pooling and
+// errors classified as transient or permanent.
+package httpclient
+
+import (
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/benozo/conduit/lib/metrics"
+)
+
+// Defaults used for zero Options fields
+const (
+	DefaultTimeout             = 120 * time.Second
+	DefaultMaxRetries          = 3
+	DefaultBaseBackoff         = 500 * time.Millisecond
+	DefaultMaxBackoff          = 30 * time.Second
+	DefaultBreakerThreshold    = 5
+	DefaultBreakerCooldown     = 30 * time.Second
+	DefaultMaxIdleConnsPerHost = 10
+	DefaultIdleConnTimeout     = 90 * time.Second
+)
+
+// Options configures a Client. Zero values use the defaults above; negative
+// MaxRetries or BreakerThreshold disable retries or the breaker.
+type Options struct {
+	// Timeout bounds each attempt, including reading a streamed body
+	Timeout     time.Duration `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	MaxRetries  int           `json:"max_retries,omitempty" yaml:"max_retries,omitempty"`
+	BaseBackoff time.Duration `json:"base_backoff,omitempty" yaml:"base_backoff,omitempty"`
+	// MaxBackoff caps the delay between attempts. A Retry-After longer than
+	// this is not waited out; the error is returned instead.
+	MaxBackoff time.Duration `json:"max_backoff,omitempty" yaml:"max_backoff,omitempty"`
+
+	// BreakerThreshold consecutive failures open the backend's breaker for BreakerCooldown
+	BreakerThreshold int           `json:"breaker_threshold,omitempty" yaml:"breaker_threshold,omitempty"`
+	BreakerCooldown  time.Duration `json:"breaker_cooldown,omitempty" yaml:"breaker_cooldown,omitempty"`
+
+	// Connection pooling
+	MaxIdleConnsPerHost int           `json:"max_idle_conns_per_host,omitempty" yaml:"max_idle_conns_per_host,omitempty"`
+	MaxConnsPerHost     int           `json:"max_conns_per_host,omitempty" yaml:"max_conns_per_host,omitempty"`
+	IdleConnTimeout     time.Duration `json:"idle_conn_timeout,omitempty" yaml:"idle_conn_timeout,omitempty"`
+}
+
+func (o Options) withDefaults() Options {
+	if o.Timeout == 0 {
+		o.Timeout = DefaultTimeout
+	}
+	if o.MaxRetries == 0 {
+		o.MaxRetries = DefaultMaxRetries
+	}
+	if o.BaseBackoff == 0 {
+		o.BaseBackoff = DefaultBaseBackoff
+	}
+	if o.MaxBackoff == 0 {
+		o.MaxBackoff = DefaultMaxBackoff
+	}
+	if o.BreakerThreshold == 0 {
+		o.BreakerThreshold = DefaultBreakerThreshold
+	}
+	if o.BreakerCooldown == 0 {
+		o.BreakerCooldown = DefaultBreakerCooldown
+	}
+	if o.MaxIdleConnsPerHost == 0 {
+		o.MaxIdleConnsPerHost = DefaultMaxIdleConnsPerHost
+	}
+	if o.IdleConnTimeout == 0 {
+		o.IdleConnTimeout = DefaultIdleConnTimeout
+	}
+	return o
+}
+
+// Client sends provider requests with retries and a circuit breaker
+type Client struct {
+	provider string
+	opts     Options
+	http     *http.Client
+}
+
+// New creates a client for provider, which labels errors, logs and metrics
+func New(provider string, opts Options) *Client {
+	opts = opts.withDefaults()
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = opts.MaxIdleConnsPerHost
+	transport.MaxConnsPerHost = opts.MaxConnsPerHost
+	transport.IdleConnTimeout = opts.IdleConnTimeout
+	transport.DialContext = (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+
+	return &Client{
+		provider: provider,
+		opts:     opts,
+		http:     &http.Client{Timeout: opts.Timeout, Transport: transport},
+	}
+}
+
+// Options returns the effective options, defaults filled in
+func (c *Client) Options() Options {
+	return c.opts
+}
+
+// Do sends req, retrying transient failures. It returns the response only
+// for 2xx statuses; anything else is returned as an *Error with the start of
+// the body, and the response is closed. Requests with a body must be
+// replayable (http.NewRequest sets GetBody for bytes and strings readers).
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	breaker := breakerFor(c.provider, req.URL.Host, c.opts)
+
+	var lastErr *Error
+	for attempt := 0; ; attempt++ {
+		attemptReq := req
+		if attempt > 0 {
+			attemptReq = req.Clone(ctx)
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, &Error{Provider: c.provider, Err: err}
+				}
+				attemptReq.Body = body
+			}
+		}
+
+		if !breaker.Allow() {
+			if lastErr != nil {
+				// The breaker opened while retrying; report the failure that opened it
+				return nil, lastErr
+			}
+			return nil, &Error{Provider: c.provider, Err: ErrCircuitOpen, Transient: true, RetryAfter: c.opts.BreakerCooldown}
+		}
+
+		resp, err := c.http.Do(attemptReq)
+		var callErr *Error
+		switch {
+		case err != nil:
+			callErr = transportError(ctx, c.provider, err)
+		case resp.StatusCode >= 200 && resp.StatusCode < 300:
+			breaker.Success()
+			return resp, nil
+		default:
+			callErr = statusError(c.provider, resp)
+		}
+
+		switch {
+		case ctx.Err() != nil:
+			breaker.Abandon()
+		case callErr.Transient && callErr.StatusCode != http.StatusTooManyRequests:
+			if breaker.Failure() {
+				slog.WarnContext(ctx, "model backend circuit breaker opened", "provider", c.provider, "host", req.URL.Host, "cooldown", c.opts.BreakerCooldown)
+				metrics.ObserveModelBreakerOpen(c.provider)
+			}
+		default:
+			// The backend answered, even if it rejected the request
+			breaker.Success()
+		}
+
+		canReplay := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+		if !callErr.Transient || attempt >= c.opts.MaxRetries || !canReplay {
+			return nil, callErr
+		}
+		if callErr.RetryAfter > c.opts.MaxBackoff {
+			return nil, callErr
+		}
+
+		lastErr = callErr
+		delay := c.backoff(attempt, callErr.RetryAfter)
+		slog.DebugContext(ctx, "retrying model request", "provider", c.provider, "attempt", attempt+1, "delay", delay, "error", callErr)
+		metrics.ObserveModelRetry(c.provider, retryReason(callErr))
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, &Error{Provider: c.provider, Err: ctx.Err()}
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns the delay before the next attempt: the provider's
+// Retry-After when given, otherwise exponential backoff with equal jitter
+func (c *Client) backoff(attempt int, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		return retryAfter
+	}
+	d := c.opts.BaseBackoff << attempt
+	if d <= 0 || d > c.opts.MaxBackoff {
+		d = c.opts.MaxBackoff
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int64N(int64(half)+1))
+}
+
+// statusError reads the start of a failed response and closes it
+func statusError(provider string, resp *http.Response) *Error {
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return &Error{
+		Provider:   provider,
+		StatusCode: resp.StatusCode,
+		Body:       strings.TrimSpace(string(body)),
+		RetryAfter: parseRetryAfter(resp.Header, time.Now()),
+		Transient:  retryableStatus(resp.StatusCode),
+	}
+}
+
+func retryReason(err *Error) string {
+	if err.StatusCode != 0 {
+		return strconv.Itoa(err.StatusCode)
+	}
+	return "network"
+}
+
+// breakers holds one breaker per provider and host, shared by every client
+// so all agents talking to a backend see the same state
+var breakers sync.Map
+
+func breakerFor(provider, host string, opts Options) *Breaker {
+	if opts.BreakerThreshold < 0 {
+		return nil
+	}
+	key := provider + " " + host
+	if b, ok := breakers.Load(key); ok {
+		return b.(*Breaker)
+	}
+	b, _ := breakers.LoadOrStore(key, NewBreaker(opts.BreakerThreshold, opts.BreakerCooldown))
+	return b.(*Breaker)
+}
+
+// BreakerState returns the state of the breaker for a provider's host
+func BreakerState(provider, host string) string {
+	if b, ok := breakers.Load(provider + " " + host); ok {
+		return b.(*Breaker).State()
+	}
+	return StateClosed
+}
+
+// ResetBreakers closes every breaker, e.g. after a backend is known to have recovered
+func ResetBreakers() {
+	breakers.Range(func(key, _ interface{}) bool {
+		breakers.Delete(key)
+		return true
+	})
+}
@@ -0,0 +1,183 @@
+package httpclient
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	c := New("test", Options{BaseBackoff: 100 * time.Millisecond, MaxBackoff: time.Second})
+
+	tests := []struct {
+		name       string
+		attempt    int
+		retryAfter time.Duration
+		min, max   time.Duration
+	}{
+		{name: "first attempt", attempt: 0, min: 50 * time.Millisecond, max: 100 * time.Millisecond},
+		{name: "doubles", attempt: 2, min: 200 * time.Millisecond, max: 400 * time.Millisecond},
+		{name: "capped", attempt: 10, min: 500 * time.Millisecond, max: time.Second},
+		{name: "overflow capped", attempt: 80, min: 500 * time.Millisecond, max: time.Second},
+		{name: "retry-after wins", attempt: 3, retryAfter: 7 * time.Second, min: 7 * time.Second, max: 7 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 50; i++ {
+				if d := c.backoff(tt.attempt, tt.retryAfter); d < tt.min || d > tt.max {
+					t.Fatalf("backoff %v outside [%v, %v]", d, tt.min, tt.max)
+				}
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		header map[string]string
+		want   time.Duration
+	}{
+		{nil, 0},
+		{map[string]string{"Retry-After": "3"}, 3 * time.Second},
+		{map[string]string{"Retry-After": "1.5"}, 1500 * time.Millisecond},
+		{map[string]string{"Retry-After": now.Add(10 * time.Second).Format(http.TimeFormat)}, 10 * time.Second},
+		{map[string]string{"Retry-After": now.Add(-time.Second).Format(http.TimeFormat)}, 0},
+		{map[string]string{"Retry-After": "soon"}, 0},
+		{map[string]string{"Retry-After": "3", "retry-after-ms": "250"}, 250 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		h := http.Header{}
+		for k, v := range tt.header {
+			h.Set(k, v)
+		}
+		if got := parseRetryAfter(h, now); got != tt.want {
+			t.Errorf("%v: got %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := NewBreaker(2, time.Minute)
+	b.now = func() time.Time { return now }
+
+	steps := []struct {
+		name      string
+		do        func() bool
+		want      bool
+		wantState string
+	}{
+		{"closed allows", b.Allow, true, StateClosed},
+		{"first failure stays closed", b.Failure, false, StateClosed},
+		{"second failure opens", b.Failure, true, StateOpen},
+		{"open rejects", b.Allow, false, StateOpen},
+		{"cooldown passes", func() bool { now = now.Add(time.Minute); return true }, true, StateHalfOpen},
+		{"one probe allowed", b.Allow, true, StateHalfOpen},
+		{"second probe rejected", b.Allow, false, StateHalfOpen},
+		{"failed probe reopens", b.Failure, true, StateOpen},
+		{"cooldown passes again", func() bool { now = now.Add(time.Minute); return true }, true, StateHalfOpen},
+		{"probe allowed", b.Allow, true, StateHalfOpen},
+		{"abandoned probe frees the slot", func() bool { b.Abandon(); return b.Allow() }, true, StateHalfOpen},
+		{"successful probe closes", func() bool { b.Success(); return b.Allow() }, true, StateClosed},
+	}
+	for _, step := range steps {
+		if got := step.do(); got != step.want {
+			t.Fatalf("%s: got %v, want %v", step.name, got, step.want)
+		}
+		if state := b.State(); state != step.wantState {
+			t.Fatalf("%s: state %s, want %s", step.name, state, step.wantState)
+		}
+	}
+
+	var disabled *Breaker
+	if !disabled.Allow() || disabled.Failure() || disabled.State() != StateClosed {
+		t.Error("nil breaker must always allow")
+	}
+}
+
+func TestDoRetries(t *testing.T) {
+	tests := []struct {
+		name          string
+		statuses      []int
+		wantCalls     int32
+		wantErr       bool
+		wantTransient bool
+	}{
+		{name: "success", statuses: []int{200}, wantCalls: 1},
+		{name: "retries 503", statuses: []int{503, 502, 200}, wantCalls: 3},
+		{name: "retries 429", statuses: []int{429, 200}, wantCalls: 2},
+		{name: "gives up after max retries", statuses: []int{500, 500, 500}, wantCalls: 3, wantErr: true, wantTransient: true},
+		{name: "does not retry 400", statuses: []int{400, 200}, wantCalls: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ResetBreakers()
+			var calls atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := calls.Add(1)
+				if body, _ := io.ReadAll(r.Body); string(body) != "payload" {
+					t.Errorf("attempt %d got body %q", n, body)
+				}
+				w.WriteHeader(tt.statuses[min(int(n), len(tt.statuses))-1])
+				w.Write([]byte("status body"))
+			}))
+			defer srv.Close()
+
+			c := New("test", Options{MaxRetries: 2, BaseBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond, BreakerThreshold: -1})
+			req, _ := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader("payload"))
+			resp, err := c.Do(req)
+			if resp != nil {
+				resp.Body.Close()
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				var e *Error
+				if !errors.As(err, &e) || e.Body != "status body" || IsTransient(err) != tt.wantTransient || IsPermanent(err) == tt.wantTransient {
+					t.Errorf("error %#v not classified as transient=%v", err, tt.wantTransient)
+				}
+			}
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Errorf("%d calls, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestDoOpensBreaker(t *testing.T) {
+	ResetBreakers()
+	defer ResetBreakers()
+
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	c := New("test", Options{MaxRetries: -1, BreakerThreshold: 2, BreakerCooldown: time.Hour})
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+		if _, err := c.Do(req); err == nil {
+			t.Fatal("expected a 503 error")
+		}
+	}
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	_, err := c.Do(req)
+	if !errors.Is(err, ErrCircuitOpen) || !IsTransient(err) || RetryAfterOf(err) != time.Hour {
+		t.Fatalf("got %v, want an open breaker error", err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("backend called %d times, want 2", calls.Load())
+	}
+	if state := BreakerState("test", req.URL.Host); state != StateOpen {
+		t.Errorf("breaker state %s", state)
+	}
+}
@@ -0,0 +1,226 @@
+// Package langchain connects conduit with LangChainGo. It wraps any
+// llms.Model as a conduit ChatModel or ModelFunc, exposes ToolRegistry
+// tools as tools.Tool values, and serves a vectorstores.VectorStore as a
+// rag.VectorDB.
+//
+//	llm, _ := ollama.New(ollama.WithModel("llama3.2"))
+//	server.SetModel(langchain.NewModelFunc(llm))
+//
+//	executor := agents.NewExecutor(agents.NewOneShotAgent(llm,
+//		langchain.Tools(server.GetToolRegistry(), server.GetMemory(), nil)))
+package langchain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/benozo/conduit/lib/metrics"
+	"github.com/benozo/conduit/lib/usage"
+	"github.com/benozo/conduit/mcp"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// Provider labels the metrics of wrapped models
+const Provider = "langchain"
+
+// ChatModel adapts an llms.Model to mcp.ChatModel. Tool definitions, tool
+// calls and images are passed as the structured llms types, so models with
+// native tool calling work with mcp.RunToolLoop.
+type ChatModel struct {
+	LLM llms.Model
+	// Options are added to every call, before the request's own settings
+	Options []llms.CallOption
+}
+
+// NewChatModel wraps llm as a ChatModel
+func NewChatModel(llm llms.Model, opts ...llms.CallOption) *ChatModel {
+	return &ChatModel{LLM: llm, Options: opts}
+}
+
+// NewModelFunc wraps llm as a ModelFunc. The "query" input is the user
+// message, with the "system" and images inputs when set.
+func NewModelFunc(llm llms.Model, opts ...llms.CallOption) mcp.ModelFunc {
+	return mcp.ModelFuncFromChat(NewChatModel(llm, opts...))
+}
+
+// Chat converts the request to llms messages and options and calls the model
+func (m *ChatModel) Chat(ctx context.Context, req mcp.ChatRequest) (*mcp.ChatResponse, error) {
+	resp, err := m.LLM.GenerateContent(ctx, Messages(req.Messages), m.callOptions(req)...)
+	if err != nil {
+		return nil, fmt.Errorf("langchain model call failed: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("langchain model returned no choices")
+	}
+	choice := resp.Choices[0]
+
+	message := mcp.ChatMessage{Role: mcp.RoleAssistant, Content: choice.Content}
+	calls := choice.ToolCalls
+	if len(calls) == 0 && choice.FuncCall != nil {
+		calls = []llms.ToolCall{{Type: "function", FunctionCall: choice.FuncCall}}
+	}
+	for _, call := range calls {
+		if call.FunctionCall == nil {
+			continue
+		}
+		args := map[string]interface{}{}
+		if raw := strings.TrimSpace(call.FunctionCall.Arguments); raw != "" {
+			if err := json.Unmarshal([]byte(raw), &args); err != nil {
+				return nil, fmt.Errorf("invalid arguments for tool %s: %w", call.FunctionCall.Name, err)
+			}
+		}
+		message.ToolCalls = append(message.ToolCalls, mcp.ChatToolCall{ID: call.ID, Name: call.FunctionCall.Name, Arguments: args})
+	}
+
+	stopReason := mcp.StopReasonEnd
+	switch {
+	case len(message.ToolCalls) > 0:
+		stopReason = mcp.StopReasonToolCalls
+	case choice.StopReason == "length" || choice.StopReason == "max_tokens":
+		stopReason = mcp.StopReasonLength
+	}
+
+	u := choiceUsage(choice.GenerationInfo)
+	if u.TotalTokens > 0 {
+		metrics.ObserveModelTokens(Provider, req.Model, u.PromptTokens, u.CompletionTokens)
+		usage.Report(ctx, u.PromptTokens, u.CompletionTokens)
+	}
+	return &mcp.ChatResponse{Model: req.Model, Message: message, StopReason: stopReason, Usage: u}, nil
+}
+
+// callOptions maps the request's settings onto llms call options. Zero
+// values are left out so the model's own defaults apply.
+func (m *ChatModel) callOptions(req mcp.ChatRequest) []llms.CallOption {
+	opts := append([]llms.CallOption(nil), m.Options...)
+	if req.Model != "" {
+		opts = append(opts, llms.WithModel(req.Model))
+	}
+	if req.Temperature != 0 {
+		opts = append(opts, llms.WithTemperature(req.Temperature))
+	}
+	if req.TopP != 0 {
+		opts = append(opts, llms.WithTopP(req.TopP))
+	}
+	if req.TopK != 0 {
+		opts = append(opts, llms.WithTopK(req.TopK))
+	}
+	if req.MaxTokens != 0 {
+		opts = append(opts, llms.WithMaxTokens(req.MaxTokens))
+	}
+	if len(req.Stop) > 0 {
+		opts = append(opts, llms.WithStopWords(req.Stop))
+	}
+	if req.ResponseSchema != nil {
+		opts = append(opts, llms.WithJSONMode())
+	}
+	if len(req.Tools) > 0 {
+		defs := make([]llms.Tool, len(req.Tools))
+		for i, tool := range req.Tools {
+			defs[i] = llms.Tool{Type: "function", Function: &llms.FunctionDefinition{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
+			}}
+		}
+		opts = append(opts, llms.WithTools(defs))
+	}
+	if req.OnToken != nil {
+		opts = append(opts, llms.WithStreamingFunc(func(_ context.Context, chunk []byte) error {
+			req.OnToken(string(chunk))
+			return nil
+		}))
+	}
+	return opts
+}
+
+// Messages converts a conversation to llms messages. Images returned by
+// tools follow the tool responses as a human message, since tool responses
+// only carry text.
+func Messages(messages []mcp.ChatMessage) []llms.MessageContent {
+	var out []llms.MessageContent
+	var toolImages []mcp.Image
+	flushToolImages := func() {
+		if len(toolImages) > 0 {
+			out = append(out, llms.MessageContent{
+				Role:  llms.ChatMessageTypeHuman,
+				Parts: contentParts("Images returned by the tools:", toolImages),
+			})
+			toolImages = nil
+		}
+	}
+
+	for _, msg := range messages {
+		if msg.Role != mcp.RoleTool {
+			flushToolImages()
+		}
+		switch msg.Role {
+		case mcp.RoleSystem:
+			out = append(out, llms.TextParts(llms.ChatMessageTypeSystem, msg.Content))
+		case mcp.RoleAssistant:
+			content := llms.MessageContent{Role: llms.ChatMessageTypeAI}
+			if msg.Content != "" {
+				content.Parts = append(content.Parts, llms.TextPart(msg.Content))
+			}
+			for _, call := range msg.ToolCalls {
+				args, _ := json.Marshal(call.Arguments)
+				content.Parts = append(content.Parts, llms.ToolCall{
+					ID:           call.ID,
+					Type:         "function",
+					FunctionCall: &llms.FunctionCall{Name: call.Name, Arguments: string(args)},
+				})
+			}
+			out = append(out, content)
+		case mcp.RoleTool:
+			out = append(out, llms.MessageContent{
+				Role:  llms.ChatMessageTypeTool,
+				Parts: []llms.ContentPart{llms.ToolCallResponse{ToolCallID: msg.ToolCallID, Name: msg.Name, Content: msg.Content}},
+			})
+			toolImages = append(toolImages, msg.Images...)
+		default:
+			out = append(out, llms.MessageContent{Role: llms.ChatMessageTypeHuman, Parts: contentParts(msg.Content, msg.Images)})
+		}
+	}
+	flushToolImages()
+	return out
+}
+
+// contentParts builds a text part followed by the images, inline images as
+// binary parts and remote ones as image URLs
+func contentParts(text string, images []mcp.Image) []llms.ContentPart {
+	parts := []llms.ContentPart{llms.TextPart(text)}
+	for _, img := range images {
+		if img.Remote() {
+			parts = append(parts, llms.ImageURLPart(img.URL))
+		} else {
+			parts = append(parts, llms.BinaryPart(img.MIMEType, img.Data))
+		}
+	}
+	return parts
+}
+
+// choiceUsage reads token counts from generation info. Providers name them
+// differently: OpenAI and Ollama report PromptTokens and CompletionTokens,
+// Anthropic InputTokens and OutputTokens.
+func choiceUsage(info map[string]any) mcp.Usage {
+	prompt := firstInt(info, "PromptTokens", "InputTokens")
+	completion := firstInt(info, "CompletionTokens", "OutputTokens")
+	return mcp.Usage{PromptTokens: prompt, CompletionTokens: completion, TotalTokens: prompt + completion}
+}
+
+func firstInt(info map[string]any, keys ...string) int {
+	for _, key := range keys {
+		switch v := info[key].(type) {
+		case int:
+			return v
+		case int32:
+			return int(v)
+		case int64:
+			return int(v)
+		case float64:
+			return int(v)
+		}
+	}
+	return 0
+}
@@ -0,0 +1,130 @@
+package langchain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/benozo/conduit/mcp"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/tools"
+)
+
+// Tool exposes a registered conduit tool as a langchaingo tools.Tool. Its
+// input is a JSON object of arguments; tools with a single string argument
+// also accept the plain text. The argument schema is part of the
+// description, so text-based agents know how to call it, and is available
+// from Definition for models with native function calling.
+type Tool struct {
+	def      mcp.ToolDefinition
+	registry *mcp.ToolRegistry
+	memory   *mcp.Memory
+}
+
+var _ tools.Tool = (*Tool)(nil)
+
+// Tools wraps every tool in registry, described with provider's schemas when
+// set and the built-in fallbacks otherwise. provider may be nil.
+func Tools(registry *mcp.ToolRegistry, memory *mcp.Memory, provider mcp.EnhancedSchemaProvider) []tools.Tool {
+	defs := mcp.ToolDefinitions(registry, provider)
+	out := make([]tools.Tool, len(defs))
+	for i, def := range defs {
+		out[i] = NewTool(registry, memory, def)
+	}
+	return out
+}
+
+// NewTool wraps the registered tool def describes
+func NewTool(registry *mcp.ToolRegistry, memory *mcp.Memory, def mcp.ToolDefinition) *Tool {
+	return &Tool{def: def, registry: registry, memory: memory}
+}
+
+// Name returns the tool name
+func (t *Tool) Name() string {
+	return t.def.Name
+}
+
+// Description returns the tool description followed by its input schema
+func (t *Tool) Description() string {
+	schema, _ := json.Marshal(t.def.Parameters)
+	return fmt.Sprintf("%s\nInput: a JSON object matching this schema: %s", strings.TrimSpace(t.def.Description), schema)
+}
+
+// Schema returns the JSON Schema of the tool's arguments
+func (t *Tool) Schema() map[string]interface{} {
+	return t.def.Parameters
+}
+
+// Definition describes the tool for llms.WithTools
+func (t *Tool) Definition() llms.Tool {
+	return llms.Tool{Type: "function", Function: &llms.FunctionDefinition{
+		Name:        t.def.Name,
+		Description: t.def.Description,
+		Parameters:  t.def.Parameters,
+	}}
+}
+
+// Call runs the tool. Invalid input and tool failures are returned as the
+// result text, like langchaingo's own tools do, so the agent can correct
+// itself instead of aborting.
+func (t *Tool) Call(ctx context.Context, input string) (string, error) {
+	args, err := t.arguments(input)
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err), nil
+	}
+	result, err := t.registry.CallContext(ctx, t.def.Name, args, t.memory)
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err), nil
+	}
+	return mcp.FormatToolResult(result), nil
+}
+
+// arguments parses the agent's input against the tool's schema
+func (t *Tool) arguments(input string) (map[string]interface{}, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return map[string]interface{}{}, nil
+	}
+	if raw, ok := mcp.ExtractJSON(input); ok && strings.HasPrefix(raw, "{") {
+		var args map[string]interface{}
+		if err := json.Unmarshal([]byte(raw), &args); err == nil {
+			return args, nil
+		}
+	}
+	if name, ok := soleStringArgument(t.def.Parameters); ok {
+		return map[string]interface{}{name: input}, nil
+	}
+	return nil, fmt.Errorf("tool %s expects a JSON object of arguments", t.def.Name)
+}
+
+// soleStringArgument returns the name of a schema's only string property,
+// or its only required one when there are several properties
+func soleStringArgument(schema map[string]interface{}) (string, bool) {
+	properties, _ := schema["properties"].(map[string]interface{})
+	candidates := make([]string, 0, len(properties))
+	for name := range properties {
+		candidates = append(candidates, name)
+	}
+	if len(candidates) != 1 {
+		candidates = candidates[:0]
+		switch required := schema["required"].(type) {
+		case []string:
+			candidates = append(candidates, required...)
+		case []interface{}:
+			for _, name := range required {
+				if s, ok := name.(string); ok {
+					candidates = append(candidates, s)
+				}
+			}
+		}
+	}
+	if len(candidates) != 1 {
+		return "", false
+	}
+	prop, _ := properties[candidates[0]].(map[string]interface{})
+	if t, ok := prop["type"].(string); ok && t != "string" {
+		return "", false
+	}
+	return candidates[0], true
+}
@@ -0,0 +1,379 @@
+package langchain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/benozo/conduit/lib/rag"
+	"github.com/tmc/langchaingo/schema"
+	"github.com/tmc/langchaingo/vectorstores"
+)
+
+// Metadata keys the chunks are stored with, so search results can be
+// mapped back to conduit chunks and documents
+const (
+	MetadataDocumentID    = "document_id"
+	MetadataDocumentTitle = "document_title"
+	MetadataSourcePath    = "source_path"
+	MetadataChunkID       = "chunk_id"
+	MetadataChunkIndex    = "chunk_index"
+)
+
+// ErrVectorSearchUnsupported is returned by SearchSimilar when the store
+// embeds with its own embedder and can only be searched by text
+var ErrVectorSearchUnsupported = errors.New("vector search is not supported by this store; search by text instead")
+
+// VectorDB serves a langchaingo vector store as a rag.VectorDB, e.g. as the
+// database of a rag.RAGEngineImpl.
+//
+// By default the embeddings conduit computes are handed to the store with
+// vectorstores.WithEmbedder, so chunks aren't embedded twice and
+// SearchSimilar works. pgvector, qdrant, weaviate, pinecone, redis, milvus,
+// mongovector, opensearch and azureaisearch accept this. For stores that
+// reject it, such as chroma, use WithStoreEmbedder; they then embed with
+// their own embedder and can only be searched by text.
+//
+// Vector stores can't list, fetch or delete entries, so documents and
+// chunks stored through this VectorDB are also indexed in memory. Deleted
+// documents stay in the store and are only filtered out of this VectorDB's
+// search results; see DeleteDocument.
+type VectorDB struct {
+	store        vectorstores.VectorStore
+	options      []vectorstores.Option
+	ownEmbedding bool
+
+	mu        sync.RWMutex
+	documents map[string]rag.Document
+	chunks    map[string]rag.DocumentChunk
+	byDoc     map[string][]string
+	// deleted maps deleted documents to their number of chunks, which
+	// searches fetch in excess to make up for the filtered ones
+	deleted       map[string]int
+	deletedChunks int
+}
+
+var _ rag.VectorDB = (*VectorDB)(nil)
+
+// VectorDBOption configures a VectorDB
+type VectorDBOption func(*VectorDB)
+
+// WithStoreOptions passes options, e.g. a namespace, to every store call
+func WithStoreOptions(opts ...vectorstores.Option) VectorDBOption {
+	return func(db *VectorDB) { db.options = append(db.options, opts...) }
+}
+
+// WithStoreEmbedder lets the store embed chunks and queries itself
+func WithStoreEmbedder() VectorDBOption {
+	return func(db *VectorDB) { db.ownEmbedding = true }
+}
+
+// NewVectorDB wraps store as a rag.VectorDB
+func NewVectorDB(store vectorstores.VectorStore, opts ...VectorDBOption) *VectorDB {
+	db := &VectorDB{
+		store:     store,
+		documents: make(map[string]rag.Document),
+		chunks:    make(map[string]rag.DocumentChunk),
+		byDoc:     make(map[string][]string),
+		deleted:   make(map[string]int),
+	}
+	for _, opt := range opts {
+		opt(db)
+	}
+	return db
+}
+
+// StoreDocument records a document; its content reaches the store with its
+// chunks
+func (db *VectorDB) StoreDocument(ctx context.Context, doc rag.Document) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.documents[doc.ID] = doc
+	db.deletedChunks -= db.deleted[doc.ID]
+	delete(db.deleted, doc.ID)
+	return nil
+}
+
+// GetDocument returns a document stored through this VectorDB
+func (db *VectorDB) GetDocument(ctx context.Context, id string) (*rag.Document, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	doc, ok := db.documents[id]
+	if !ok {
+		return nil, fmt.Errorf("document %s not found", id)
+	}
+	return &doc, nil
+}
+
+// DeleteDocument forgets a document and hides its chunks from this
+// VectorDB's searches. The chunks are not removed from the underlying store,
+// which has no delete operation: other clients of the store, and this
+// VectorDB after a restart, still find them. Delete them with the store's
+// own API when that matters.
+func (db *VectorDB) DeleteDocument(ctx context.Context, id string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if _, ok := db.deleted[id]; ok {
+		return nil
+	}
+	chunks := len(db.byDoc[id])
+	for _, chunkID := range db.byDoc[id] {
+		delete(db.chunks, chunkID)
+	}
+	delete(db.byDoc, id)
+	delete(db.documents, id)
+	db.deleted[id] = chunks
+	db.deletedChunks += chunks
+	return nil
+}
+
+// ListDocuments lists the documents stored through this VectorDB, newest
+// first
+func (db *VectorDB) ListDocuments(ctx context.Context, limit, offset int) ([]rag.Document, error) {
+	db.mu.RLock()
+	docs := make([]rag.Document, 0, len(db.documents))
+	for _, doc := range db.documents {
+		docs = append(docs, doc)
+	}
+	db.mu.RUnlock()
+
+	sort.Slice(docs, func(i, j int) bool { return docs[i].CreatedAt.After(docs[j].CreatedAt) })
+	if offset >= len(docs) {
+		return []rag.Document{}, nil
+	}
+	docs = docs[offset:]
+	if limit > 0 && limit < len(docs) {
+		docs = docs[:limit]
+	}
+	return docs, nil
+}
+
+// StoreChunks adds chunks to the store, with their embeddings unless the
+// store embeds itself
+func (db *VectorDB) StoreChunks(ctx context.Context, chunks []rag.DocumentChunk) error {
+	if len(chunks) == 0 {
+		return nil
+	}
+
+	db.mu.RLock()
+	docs := make([]schema.Document, len(chunks))
+	vectors := make([][]float32, len(chunks))
+	for i, chunk := range chunks {
+		docs[i] = schema.Document{PageContent: chunk.Content, Metadata: db.chunkMetadata(chunk)}
+		vectors[i] = chunk.Embedding
+		if !db.ownEmbedding && len(chunk.Embedding) == 0 {
+			db.mu.RUnlock()
+			return fmt.Errorf("chunk %s has no embedding", chunk.ID)
+		}
+	}
+	db.mu.RUnlock()
+
+	opts := db.options
+	if !db.ownEmbedding {
+		opts = append(append([]vectorstores.Option(nil), opts...), vectorstores.WithEmbedder(fixedEmbedder(vectors)))
+	}
+	if _, err := db.store.AddDocuments(ctx, docs, opts...); err != nil {
+		return fmt.Errorf("failed to add chunks to vector store: %w", err)
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	for _, chunk := range chunks {
+		if _, exists := db.chunks[chunk.ID]; !exists {
+			db.byDoc[chunk.DocumentID] = append(db.byDoc[chunk.DocumentID], chunk.ID)
+		}
+		db.chunks[chunk.ID] = chunk
+	}
+	return nil
+}
+
+// chunkMetadata adds the keys identifying a chunk and its document to the
+// chunk's metadata. Callers hold db.mu.
+func (db *VectorDB) chunkMetadata(chunk rag.DocumentChunk) map[string]any {
+	metadata := make(map[string]any, len(chunk.Metadata)+5)
+	for k, v := range chunk.Metadata {
+		metadata[k] = v
+	}
+	metadata[MetadataDocumentID] = chunk.DocumentID
+	metadata[MetadataChunkID] = chunk.ID
+	metadata[MetadataChunkIndex] = chunk.Index
+	if doc, ok := db.documents[chunk.DocumentID]; ok {
+		metadata[MetadataDocumentTitle] = doc.Title
+		metadata[MetadataSourcePath] = doc.SourcePath
+	}
+	return metadata
+}
+
+// GetChunk returns a chunk stored through this VectorDB
+func (db *VectorDB) GetChunk(ctx context.Context, id string) (*rag.DocumentChunk, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	chunk, ok := db.chunks[id]
+	if !ok {
+		return nil, fmt.Errorf("chunk %s not found", id)
+	}
+	return &chunk, nil
+}
+
+// GetDocumentChunks returns a document's chunks in order
+func (db *VectorDB) GetDocumentChunks(ctx context.Context, documentID string) ([]rag.DocumentChunk, error) {
+	db.mu.RLock()
+	chunks := make([]rag.DocumentChunk, 0, len(db.byDoc[documentID]))
+	for _, id := range db.byDoc[documentID] {
+		chunks = append(chunks, db.chunks[id])
+	}
+	db.mu.RUnlock()
+
+	sort.Slice(chunks, func(i, j int) bool { return chunks[i].Index < chunks[j].Index })
+	return chunks, nil
+}
+
+// SearchSimilar finds the chunks nearest to embedding. Filters are passed
+// to the store as is, in its own filter format.
+func (db *VectorDB) SearchSimilar(ctx context.Context, embedding []float32, limit int, filters map[string]interface{}) ([]rag.SearchResult, error) {
+	if db.ownEmbedding {
+		return nil, ErrVectorSearchUnsupported
+	}
+	opts := append(db.searchOptions(filters), vectorstores.WithEmbedder(fixedEmbedder{embedding}))
+	return db.search(ctx, "", limit, opts)
+}
+
+// SearchByText finds the chunks nearest to query, embedded by the store.
+// Unless the store embeds itself, it needs an embedder of its own.
+func (db *VectorDB) SearchByText(ctx context.Context, query string, limit int, filters map[string]interface{}) ([]rag.SearchResult, error) {
+	return db.search(ctx, query, limit, db.searchOptions(filters))
+}
+
+func (db *VectorDB) searchOptions(filters map[string]interface{}) []vectorstores.Option {
+	opts := append([]vectorstores.Option(nil), db.options...)
+	if len(filters) > 0 {
+		opts = append(opts, vectorstores.WithFilters(filters))
+	}
+	return opts
+}
+
+// search queries the store, fetching as many extra results as there are
+// deleted chunks so filtering them out still leaves limit results
+func (db *VectorDB) search(ctx context.Context, query string, limit int, opts []vectorstores.Option) ([]rag.SearchResult, error) {
+	db.mu.RLock()
+	fetch := limit + db.deletedChunks
+	db.mu.RUnlock()
+
+	docs, err := db.store.SimilaritySearch(ctx, query, fetch, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("vector store search failed: %w", err)
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	results := make([]rag.SearchResult, 0, len(docs))
+	for _, doc := range docs {
+		result := db.searchResult(doc)
+		if _, deleted := db.deleted[result.Chunk.DocumentID]; deleted {
+			continue
+		}
+		results = append(results, result)
+		if len(results) == limit {
+			break
+		}
+	}
+	return results, nil
+}
+
+// searchResult maps a store document back to its chunk and document,
+// falling back to the stored metadata for entries added by other processes.
+// Callers hold db.mu.
+func (db *VectorDB) searchResult(doc schema.Document) rag.SearchResult {
+	chunkID := metadataString(doc.Metadata, MetadataChunkID)
+	chunk, ok := db.chunks[chunkID]
+	if !ok {
+		chunk = rag.DocumentChunk{
+			ID:         chunkID,
+			DocumentID: metadataString(doc.Metadata, MetadataDocumentID),
+			Index:      metadataInt(doc.Metadata, MetadataChunkIndex),
+			Content:    doc.PageContent,
+			Metadata:   doc.Metadata,
+		}
+	}
+	document, ok := db.documents[chunk.DocumentID]
+	if !ok {
+		document = rag.Document{
+			ID:         chunk.DocumentID,
+			Title:      metadataString(doc.Metadata, MetadataDocumentTitle),
+			SourcePath: metadataString(doc.Metadata, MetadataSourcePath),
+		}
+	}
+	return rag.SearchResult{Chunk: chunk, Document: document, Score: float64(doc.Score)}
+}
+
+// CreateIndex is unsupported; indexes are managed by the store
+func (db *VectorDB) CreateIndex(ctx context.Context, indexType string) error {
+	return fmt.Errorf("create index: %w", errors.ErrUnsupported)
+}
+
+// DropIndex is unsupported; indexes are managed by the store
+func (db *VectorDB) DropIndex(ctx context.Context, indexType string) error {
+	return fmt.Errorf("drop index: %w", errors.ErrUnsupported)
+}
+
+// GetStats reports the documents and chunks stored through this VectorDB
+func (db *VectorDB) GetStats(ctx context.Context) (map[string]interface{}, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return map[string]interface{}{
+		"store":     fmt.Sprintf("%T", db.store),
+		"documents": len(db.documents),
+		"chunks":    len(db.chunks),
+	}, nil
+}
+
+// Ping always succeeds; vector stores have no health check
+func (db *VectorDB) Ping(ctx context.Context) error {
+	return nil
+}
+
+// Close closes the store if it has a Close method
+func (db *VectorDB) Close() error {
+	if closer, ok := db.store.(interface{ Close() error }); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// fixedEmbedder hands precomputed vectors to a store, in order
+type fixedEmbedder [][]float32
+
+func (e fixedEmbedder) EmbedDocuments(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) != len(e) {
+		return nil, fmt.Errorf("got %d texts for %d precomputed embeddings", len(texts), len(e))
+	}
+	return e, nil
+}
+
+func (e fixedEmbedder) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	if len(e) == 0 {
+		return nil, fmt.Errorf("no precomputed embedding")
+	}
+	return e[0], nil
+}
+
+func metadataString(metadata map[string]any, key string) string {
+	if s, ok := metadata[key].(string); ok {
+		return s
+	}
+	return ""
+}
+
+func metadataInt(metadata map[string]any, key string) int {
+	switch v := metadata[key].(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	}
+	return 0
+}
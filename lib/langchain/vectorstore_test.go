@@ -0,0 +1,122 @@
+package langchain
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/benozo/conduit/lib/rag"
+	"github.com/tmc/langchaingo/schema"
+	"github.com/tmc/langchaingo/vectorstores"
+)
+
+// memoryStore is a minimal vector store ranking by dot product with the
+// vectors its embedder returns
+type memoryStore struct {
+	docs    []schema.Document
+	vectors [][]float32
+}
+
+func (s *memoryStore) AddDocuments(ctx context.Context, docs []schema.Document, options ...vectorstores.Option) ([]string, error) {
+	opts := vectorstores.Options{}
+	for _, opt := range options {
+		opt(&opts)
+	}
+	texts := make([]string, len(docs))
+	for i, doc := range docs {
+		texts[i] = doc.PageContent
+	}
+	vectors, err := opts.Embedder.EmbedDocuments(ctx, texts)
+	if err != nil {
+		return nil, err
+	}
+	s.docs = append(s.docs, docs...)
+	s.vectors = append(s.vectors, vectors...)
+	return nil, nil
+}
+
+func (s *memoryStore) SimilaritySearch(ctx context.Context, query string, n int, options ...vectorstores.Option) ([]schema.Document, error) {
+	opts := vectorstores.Options{}
+	for _, opt := range options {
+		opt(&opts)
+	}
+	q, err := opts.Embedder.EmbedQuery(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]schema.Document, len(s.docs))
+	for i, doc := range s.docs {
+		var score float32
+		for j := range q {
+			score += q[j] * s.vectors[i][j]
+		}
+		doc.Score = score
+		results[i] = doc
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if n < len(results) {
+		results = results[:n]
+	}
+	return results, nil
+}
+
+func TestVectorDBSearchSkipsDeleted(t *testing.T) {
+	ctx := context.Background()
+	db := NewVectorDB(&memoryStore{})
+
+	// Document "a" holds the closest chunks, "b" the others
+	for _, doc := range []struct {
+		id    string
+		score float32
+	}{{"a", 10}, {"b", 1}} {
+		if err := db.StoreDocument(ctx, rag.Document{ID: doc.id, Title: "doc " + doc.id}); err != nil {
+			t.Fatal(err)
+		}
+		var chunks []rag.DocumentChunk
+		for i := 0; i < 3; i++ {
+			chunks = append(chunks, rag.DocumentChunk{
+				ID:         fmt.Sprintf("%s-%d", doc.id, i),
+				DocumentID: doc.id,
+				Index:      i,
+				Content:    fmt.Sprintf("chunk %d of %s", i, doc.id),
+				Embedding:  []float32{doc.score - float32(i)*0.1},
+			})
+		}
+		if err := db.StoreChunks(ctx, chunks); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results, err := db.SearchSimilar(ctx, []float32{1}, 2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Chunk.ID != "a-0" || results[0].Document.Title != "doc a" {
+		t.Fatalf("results before delete: %+v", results)
+	}
+
+	if err := db.DeleteDocument(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	results, err = db.SearchSimilar(ctx, []float32{1}, 2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("got %d results after delete, want the limit of 2", len(results))
+	}
+	for _, r := range results {
+		if r.Chunk.DocumentID != "b" {
+			t.Errorf("deleted document returned: %+v", r.Chunk)
+		}
+	}
+	if _, err := db.GetDocument(ctx, "a"); err == nil {
+		t.Error("deleted document still listed")
+	}
+
+	chunks, _ := db.GetDocumentChunks(ctx, "b")
+	if len(chunks) != 3 || chunks[0].Index != 0 {
+		t.Errorf("chunks of b: %+v", chunks)
+	}
+}
@@ -0,0 +1,159 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/benozo/conduit/lib/tracing"
+)
+
+// Log formats supported by Setup
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Environment variables read by OptionsFromEnv
+const (
+	EnvLevel  = "CONDUIT_LOG_LEVEL"
+	EnvFormat = "CONDUIT_LOG_FORMAT"
+)
+
+// Options configures the process-wide logger
+type Options struct {
+	// Level is the minimum level: debug, info, warn or error (default info)
+	Level string
+	// Format is text or json (default text)
+	Format string
+	// Output defaults to os.Stderr. Stdout is rejected because it carries the
+	// JSON-RPC stream in stdio mode.
+	Output io.Writer
+	// RedactKeys are extra parameter names whose values are masked, in addition
+	// to built-in names such as password, token and api_key
+	RedactKeys []string
+}
+
+// OptionsFromEnv reads level and format from CONDUIT_LOG_LEVEL and CONDUIT_LOG_FORMAT
+func OptionsFromEnv() Options {
+	return Options{
+		Level:  os.Getenv(EnvLevel),
+		Format: os.Getenv(EnvFormat),
+	}
+}
+
+// ParseLevel converts a level name to a slog.Level
+func ParseLevel(level string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "debug":
+		return slog.LevelDebug, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return slog.LevelInfo, fmt.Errorf("unknown log level: %s", level)
+	}
+}
+
+// New builds a logger from options without installing it
+func New(opts Options) (*slog.Logger, error) {
+	level, err := ParseLevel(opts.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	out := opts.Output
+	if out == nil {
+		out = os.Stderr
+	}
+	if f, ok := out.(*os.File); ok && f == os.Stdout {
+		return nil, fmt.Errorf("logging to stdout is not allowed")
+	}
+
+	AddRedactKeys(opts.RedactKeys...)
+	handlerOpts := &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redactAttr,
+	}
+
+	var handler slog.Handler
+	switch strings.ToLower(strings.TrimSpace(opts.Format)) {
+	case "", FormatText:
+		handler = slog.NewTextHandler(out, handlerOpts)
+	case FormatJSON:
+		handler = slog.NewJSONHandler(out, handlerOpts)
+	default:
+		return nil, fmt.Errorf("unknown log format: %s", opts.Format)
+	}
+
+	return slog.New(&contextHandler{Handler: handler}), nil
+}
+
+// Setup builds a logger and installs it as the slog and log package default
+func Setup(opts Options) (*slog.Logger, error) {
+	logger, err := New(opts)
+	if err != nil {
+		return nil, err
+	}
+	slog.SetDefault(logger)
+	return logger, nil
+}
+
+type requestIDKey struct{}
+type sessionIDKey struct{}
+
+// WithRequestID returns a context whose log records carry request_id
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// WithSessionID returns a context whose log records carry session_id
+func WithSessionID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, sessionIDKey{}, id)
+}
+
+// RequestID returns the request ID stored in ctx, if any
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// SessionID returns the session ID stored in ctx, if any
+func SessionID(ctx context.Context) string {
+	id, _ := ctx.Value(sessionIDKey{}).(string)
+	return id
+}
+
+// contextHandler adds request, session and trace IDs from the context to each record
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if ctx != nil {
+		if id := RequestID(ctx); id != "" {
+			r.AddAttrs(slog.String("request_id", id))
+		}
+		if id := SessionID(ctx); id != "" {
+			r.AddAttrs(slog.String("session_id", id))
+		}
+		if sc := tracing.SpanContextFromContext(ctx); sc.IsValid() {
+			r.AddAttrs(slog.String("trace_id", sc.TraceID.String()))
+		}
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
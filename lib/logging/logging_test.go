@@ -0,0 +1,151 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/benozo/conduit/lib/tracing"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    slog.Level
+		wantErr bool
+	}{
+		{"", slog.LevelInfo, false},
+		{"DEBUG", slog.LevelDebug, false},
+		{" warning ", slog.LevelWarn, false},
+		{"error", slog.LevelError, false},
+		{"verbose", slog.LevelInfo, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseLevel(tt.in)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("ParseLevel(%q) = %v, %v", tt.in, got, err)
+		}
+	}
+}
+
+func TestNewRejectsBadOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{"stdout", Options{Output: os.Stdout}},
+		{"format", Options{Format: "xml"}},
+		{"level", Options{Level: "loud"}},
+	}
+	for _, tt := range tests {
+		if _, err := New(tt.opts); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
+
+func TestJSONRecords(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(Options{Level: "info", Format: FormatJSON, Output: &buf, RedactKeys: []string{"ssn"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tracer := tracing.NewTracer(tracing.Options{})
+	defer tracer.Shutdown(context.Background())
+	ctx, span := tracer.Start(WithSessionID(WithRequestID(context.Background(), "req-1"), "sess-1"), "op")
+	defer span.End()
+
+	logger.DebugContext(ctx, "hidden")
+	logger.InfoContext(ctx, "tool call",
+		"api_key", "sk-123",
+		"params", map[string]interface{}{
+			"query":  "weather",
+			"SSN":    "000-00-0000",
+			"nested": map[string]interface{}{"github-token": "ghp", "list": []interface{}{map[string]interface{}{"password": "p"}}},
+		},
+	)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("got %d records, want the debug one filtered:\n%s", len(lines), buf.String())
+	}
+	var record map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"api_key": Redacted,
+		"params": map[string]interface{}{
+			"query":  "weather",
+			"SSN":    Redacted,
+			"nested": map[string]interface{}{"github-token": Redacted, "list": []interface{}{map[string]interface{}{"password": Redacted}}},
+		},
+		"request_id": "req-1",
+		"session_id": "sess-1",
+		"trace_id":   span.SpanContext().TraceID.String(),
+	}
+	for k, v := range want {
+		if !reflect.DeepEqual(record[k], v) {
+			t.Errorf("%s = %v, want %v", k, record[k], v)
+		}
+	}
+}
+
+func TestIsSensitive(t *testing.T) {
+	tests := map[string]bool{
+		"password":      true,
+		"Authorization": true,
+		"db_password":   true,
+		"X-Api-Key":     true,
+		"refresh-token": true,
+		"query":         false,
+		"tokens_used":   false,
+		"author":        false,
+	}
+	for key, want := range tests {
+		if got := IsSensitive(key); got != want {
+			t.Errorf("IsSensitive(%q) = %v, want %v", key, got, want)
+		}
+	}
+	if Redact(nil) != nil {
+		t.Error("Redact(nil) must stay nil")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var seen string
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestID(r.Context())
+	}))
+
+	tests := []struct {
+		name   string
+		header string
+		reuse  bool
+	}{
+		{"caller ID reused", "abc-123", true},
+		{"generated when missing", "", false},
+		{"generated when too long", strings.Repeat("x", 200), false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/health", nil)
+		if tt.header != "" {
+			req.Header.Set(RequestIDHeader, tt.header)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		echoed := rec.Header().Get(RequestIDHeader)
+		if echoed == "" || echoed != seen || (echoed == tt.header) != tt.reuse {
+			t.Errorf("%s: echoed %q, handler saw %q", tt.name, echoed, seen)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package logging
+
+import (
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// Redacted replaces the value of sensitive fields
+const Redacted = "[REDACTED]"
+
+// defaultSensitiveKeys are matched after lowercasing and stripping '_' and '-'
+var defaultSensitiveKeys = []string{
+	"password", "passwd", "secret", "token", "apikey", "authorization",
+	"auth", "bearer", "bearertoken", "accesstoken", "refreshtoken",
+	"privatekey", "credential", "credentials", "cookie",
+}
+
+// sensitiveSuffixes catch compound names such as db_password or github_token
+var sensitiveSuffixes = []string{"password", "secret", "token", "apikey", "privatekey"}
+
+var (
+	extraMu   sync.RWMutex
+	extraKeys = map[string]bool{}
+)
+
+// AddRedactKeys registers additional parameter names to mask in logs
+func AddRedactKeys(keys ...string) {
+	extraMu.Lock()
+	defer extraMu.Unlock()
+	for _, k := range keys {
+		extraKeys[normalizeKey(k)] = true
+	}
+}
+
+// IsSensitive reports whether a field name should be redacted
+func IsSensitive(key string) bool {
+	k := normalizeKey(key)
+	for _, s := range defaultSensitiveKeys {
+		if k == s {
+			return true
+		}
+	}
+	for _, s := range sensitiveSuffixes {
+		if strings.HasSuffix(k, s) {
+			return true
+		}
+	}
+
+	extraMu.RLock()
+	defer extraMu.RUnlock()
+	return extraKeys[k]
+}
+
+// Redact returns a copy of params with sensitive values masked, recursing into nested maps
+func Redact(params map[string]interface{}) map[string]interface{} {
+	if params == nil {
+		return nil
+	}
+	out := make(map[string]interface{}, len(params))
+	for k, v := range params {
+		if IsSensitive(k) {
+			out[k] = Redacted
+			continue
+		}
+		out[k] = redactValue(v)
+	}
+	return out
+}
+
+func redactValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		return Redact(val)
+	case []interface{}:
+		items := make([]interface{}, len(val))
+		for i, item := range val {
+			items[i] = redactValue(item)
+		}
+		return items
+	default:
+		return v
+	}
+}
+
+// redactAttr masks sensitive attributes as records are written
+func redactAttr(groups []string, a slog.Attr) slog.Attr {
+	if IsSensitive(a.Key) {
+		return slog.String(a.Key, Redacted)
+	}
+	if m, ok := a.Value.Any().(map[string]interface{}); ok {
+		return slog.Any(a.Key, Redact(m))
+	}
+	return a
+}
+
+func normalizeKey(key string) string {
+	key = strings.ToLower(key)
+	key = strings.ReplaceAll(key, "_", "")
+	return strings.ReplaceAll(key, "-", "")
+}
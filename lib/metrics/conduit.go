@@ -0,0 +1,150 @@
+package metrics
+
+import "time"
+
+// Outcome label values shared by the built-in metrics
+const (
+	OutcomeSuccess = "success"
+	OutcomeError   = "error"
+)
+
+// Built-in Conduit metrics, registered on the Default registry
+var (
+	ToolCalls = Default.NewCounter("conduit_tool_calls_total",
+		"Total number of tool calls by tool and outcome.", "tool", "outcome")
+	ToolCallDuration = Default.NewHistogram("conduit_tool_call_duration_seconds",
+		"Tool call latency in seconds.", nil, "tool", "outcome")
+
+	ModelCalls = Default.NewCounter("conduit_model_calls_total",
+		"Total number of model calls by provider, model and outcome.", "provider", "model", "outcome")
+	ModelCallDuration = Default.NewHistogram("conduit_model_call_duration_seconds",
+		"Model call latency in seconds.", nil, "provider", "model")
+	ModelTokens = Default.NewCounter("conduit_model_tokens_total",
+		"Tokens reported by model providers, by kind (prompt or completion).", "provider", "model", "kind")
+	ModelCost = Default.NewCounter("conduit_model_cost_total",
+		"Cost of model calls from the configured price table.", "provider", "model")
+	ModelRetries = Default.NewCounter("conduit_model_retries_total",
+		"Model HTTP requests retried after a transient failure, by provider and reason (status code or network).", "provider", "reason")
+	ModelBreakerOpens = Default.NewCounter("conduit_model_circuit_breaker_opens_total",
+		"Times a model backend's circuit breaker opened, by provider.", "provider")
+	ModelCacheRequests = Default.NewCounter("conduit_model_cache_requests_total",
+		"Model response cache lookups by provider and result (hit, semantic_hit, miss or bypass).", "provider", "result")
+	RouterAttempts = Default.NewCounter("conduit_model_router_attempts_total",
+		"Model router calls by backend and outcome.", "backend", "outcome")
+
+	RAGSearches = Default.NewCounter("conduit_rag_searches_total",
+		"Total number of RAG searches by outcome.", "outcome")
+	RAGSearchDuration = Default.NewHistogram("conduit_rag_search_duration_seconds",
+		"RAG search latency in seconds.", nil)
+	RAGDocumentsIndexed = Default.NewCounter("conduit_rag_documents_indexed_total",
+		"Total number of documents indexed by outcome.", "outcome")
+	RAGChunksIndexed = Default.NewCounter("conduit_rag_chunks_indexed_total",
+		"Total number of chunks embedded and stored.")
+	RAGIndexDuration = Default.NewHistogram("conduit_rag_index_duration_seconds",
+		"Document indexing latency in seconds.", nil)
+
+	AgentTaskTransitions = Default.NewCounter("conduit_agent_task_transitions_total",
+		"Total number of agent task state transitions by resulting status.", "status")
+	AgentTasks = Default.NewGauge("conduit_agent_tasks",
+		"Current number of agent tasks by status.", "status")
+
+	SwarmHandoffs = Default.NewCounter("conduit_swarm_handoffs_total",
+		"Total number of swarm agent handoffs.", "from", "to")
+	WorkflowNodeDuration = Default.NewHistogram("conduit_workflow_node_duration_seconds",
+		"Workflow node execution time in seconds by agent and status.", nil, "agent", "status")
+)
+
+// outcome maps an error to an outcome label
+func outcome(err error) string {
+	if err != nil {
+		return OutcomeError
+	}
+	return OutcomeSuccess
+}
+
+// ObserveToolCall records a completed tool call
+func ObserveToolCall(tool string, err error, elapsed time.Duration) {
+	o := outcome(err)
+	ToolCalls.Inc(tool, o)
+	ToolCallDuration.Observe(elapsed.Seconds(), tool, o)
+}
+
+// ObserveModelCall records a completed model call
+func ObserveModelCall(provider, model string, err error, elapsed time.Duration) {
+	ModelCalls.Inc(provider, model, outcome(err))
+	ModelCallDuration.Observe(elapsed.Seconds(), provider, model)
+}
+
+// ObserveModelTokens records token usage when a provider reports it
+func ObserveModelTokens(provider, model string, promptTokens, completionTokens int) {
+	if promptTokens > 0 {
+		ModelTokens.Add(float64(promptTokens), provider, model, "prompt")
+	}
+	if completionTokens > 0 {
+		ModelTokens.Add(float64(completionTokens), provider, model, "completion")
+	}
+}
+
+// ObserveModelCost records the priced cost of a model call
+func ObserveModelCost(provider, model string, cost float64) {
+	if cost > 0 {
+		ModelCost.Add(cost, provider, model)
+	}
+}
+
+// ObserveModelRetry records a retried model request
+func ObserveModelRetry(provider, reason string) {
+	ModelRetries.Inc(provider, reason)
+}
+
+// ObserveModelBreakerOpen records a circuit breaker opening
+func ObserveModelBreakerOpen(provider string) {
+	ModelBreakerOpens.Inc(provider)
+}
+
+// ObserveCacheRequest records a model response cache lookup
+func ObserveCacheRequest(provider, result string) {
+	ModelCacheRequests.Inc(provider, result)
+}
+
+// ObserveRouterAttempt records a model router call to one backend
+func ObserveRouterAttempt(backend string, err error) {
+	RouterAttempts.Inc(backend, outcome(err))
+}
+
+// ObserveRAGSearch records a completed RAG search
+func ObserveRAGSearch(err error, elapsed time.Duration) {
+	RAGSearches.Inc(outcome(err))
+	RAGSearchDuration.Observe(elapsed.Seconds())
+}
+
+// ObserveRAGIndex records a completed indexing run and how many chunks it stored
+func ObserveRAGIndex(chunks int, err error, elapsed time.Duration) {
+	RAGDocumentsIndexed.Inc(outcome(err))
+	if err == nil {
+		RAGChunksIndexed.Add(float64(chunks))
+	}
+	RAGIndexDuration.Observe(elapsed.Seconds())
+}
+
+// ObserveTaskTransition records an agent task moving from one status to another
+func ObserveTaskTransition(from, to string) {
+	if from == to {
+		return
+	}
+	if from != "" {
+		AgentTasks.Dec(from)
+	}
+	AgentTasks.Inc(to)
+	AgentTaskTransitions.Inc(to)
+}
+
+// ObserveHandoff records a swarm handoff between two agents
+func ObserveHandoff(from, to string) {
+	SwarmHandoffs.Inc(from, to)
+}
+
+// ObserveWorkflowNode records the duration of a workflow node execution
+func ObserveWorkflowNode(agent, status string, elapsed time.Duration) {
+	WorkflowNodeDuration.Observe(elapsed.Seconds(), agent, status)
+}
@@ -0,0 +1,395 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are histogram buckets (in seconds) suitable for tool and model latencies
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120}
+
+// Registry holds a set of metrics and renders them in Prometheus text format
+type Registry struct {
+	mu      sync.RWMutex
+	metrics []collector
+	names   map[string]bool
+}
+
+// collector is implemented by every metric type
+type collector interface {
+	name() string
+	write(w io.Writer) error
+}
+
+// NewRegistry creates an empty metrics registry
+func NewRegistry() *Registry {
+	return &Registry{names: make(map[string]bool)}
+}
+
+// Default is the registry used by the built-in Conduit instrumentation
+var Default = NewRegistry()
+
+// register adds a collector, panicking on duplicate names like a programming error should
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.names[c.name()] {
+		panic(fmt.Sprintf("metrics: duplicate metric name %q", c.name()))
+	}
+	r.names[c.name()] = true
+	r.metrics = append(r.metrics, c)
+}
+
+// WritePrometheus writes all metrics in the Prometheus text exposition format
+func (r *Registry) WritePrometheus(w io.Writer) error {
+	r.mu.RLock()
+	metrics := make([]collector, len(r.metrics))
+	copy(metrics, r.metrics)
+	r.mu.RUnlock()
+
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].name() < metrics[j].name() })
+
+	for _, m := range metrics {
+		if err := m.write(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Handler returns an HTTP handler serving the registry in Prometheus text format
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if err := r.WritePrometheus(w); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// Handler returns an HTTP handler for the default registry
+func Handler() http.Handler {
+	return Default.Handler()
+}
+
+// desc holds the common metric description
+type desc struct {
+	metricName string
+	help       string
+	labelNames []string
+}
+
+func (d *desc) name() string {
+	return d.metricName
+}
+
+// key builds a stable series key from label values
+func (d *desc) key(labelValues []string) string {
+	if len(labelValues) != len(d.labelNames) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", d.metricName, len(d.labelNames), len(labelValues)))
+	}
+	return strings.Join(labelValues, "\xff")
+}
+
+// writeHeader writes the HELP and TYPE lines
+func (d *desc) writeHeader(w io.Writer, metricType string) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.metricName, escapeHelp(d.help), d.metricName, metricType)
+	return err
+}
+
+// labels formats label pairs, optionally with an extra trailing pair
+func (d *desc) labels(labelValues []string, extraName, extraValue string) string {
+	if len(d.labelNames) == 0 && extraName == "" {
+		return ""
+	}
+
+	parts := make([]string, 0, len(d.labelNames)+1)
+	for i, name := range d.labelNames {
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, name, escapeLabel(labelValues[i])))
+	}
+	if extraName != "" {
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, extraName, escapeLabel(extraValue)))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// series is a single labelled value
+type series struct {
+	labelValues []string
+	value       float64
+}
+
+// Counter is a monotonically increasing metric with optional labels
+type Counter struct {
+	desc
+	mu     sync.Mutex
+	values map[string]*series
+}
+
+// NewCounter creates and registers a counter
+func (r *Registry) NewCounter(name, help string, labelNames ...string) *Counter {
+	c := &Counter{
+		desc:   desc{metricName: name, help: help, labelNames: labelNames},
+		values: make(map[string]*series),
+	}
+	r.register(c)
+	return c
+}
+
+// Inc increments the counter by one
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add increments the counter by v, ignoring negative values
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		return
+	}
+	key := c.key(labelValues)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.values[key]
+	if !ok {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		c.values[key] = s
+	}
+	s.value += v
+}
+
+// Value returns the current value for the given labels
+func (c *Counter) Value(labelValues ...string) float64 {
+	key := c.key(labelValues)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s, ok := c.values[key]; ok {
+		return s.value
+	}
+	return 0
+}
+
+func (c *Counter) write(w io.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return writeSimple(w, &c.desc, "counter", c.values)
+}
+
+// Gauge is a metric that can go up and down
+type Gauge struct {
+	desc
+	mu     sync.Mutex
+	values map[string]*series
+}
+
+// NewGauge creates and registers a gauge
+func (r *Registry) NewGauge(name, help string, labelNames ...string) *Gauge {
+	g := &Gauge{
+		desc:   desc{metricName: name, help: help, labelNames: labelNames},
+		values: make(map[string]*series),
+	}
+	r.register(g)
+	return g
+}
+
+// Set sets the gauge to v
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.update(labelValues, func(s *series) { s.value = v })
+}
+
+// Add adds v (which may be negative) to the gauge
+func (g *Gauge) Add(v float64, labelValues ...string) {
+	g.update(labelValues, func(s *series) { s.value += v })
+}
+
+// Inc increments the gauge by one
+func (g *Gauge) Inc(labelValues ...string) {
+	g.Add(1, labelValues...)
+}
+
+// Dec decrements the gauge by one
+func (g *Gauge) Dec(labelValues ...string) {
+	g.Add(-1, labelValues...)
+}
+
+// Value returns the current value for the given labels
+func (g *Gauge) Value(labelValues ...string) float64 {
+	key := g.key(labelValues)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if s, ok := g.values[key]; ok {
+		return s.value
+	}
+	return 0
+}
+
+func (g *Gauge) update(labelValues []string, fn func(s *series)) {
+	key := g.key(labelValues)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	s, ok := g.values[key]
+	if !ok {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		g.values[key] = s
+	}
+	fn(s)
+}
+
+func (g *Gauge) write(w io.Writer) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return writeSimple(w, &g.desc, "gauge", g.values)
+}
+
+// histogramSeries holds bucket counts for one label combination
+type histogramSeries struct {
+	labelValues []string
+	counts      []uint64
+	sum         float64
+	count       uint64
+}
+
+// Histogram samples observations into configurable buckets
+type Histogram struct {
+	desc
+	buckets []float64
+	mu      sync.Mutex
+	values  map[string]*histogramSeries
+}
+
+// NewHistogram creates and registers a histogram; nil buckets use DefaultBuckets
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labelNames ...string) *Histogram {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+
+	h := &Histogram{
+		desc:    desc{metricName: name, help: help, labelNames: labelNames},
+		buckets: sorted,
+		values:  make(map[string]*histogramSeries),
+	}
+	r.register(h)
+	return h
+}
+
+// Observe records a single observation
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	key := h.key(labelValues)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.values[key]
+	if !ok {
+		s = &histogramSeries{
+			labelValues: append([]string(nil), labelValues...),
+			counts:      make([]uint64, len(h.buckets)),
+		}
+		h.values[key] = s
+	}
+
+	for i, upper := range h.buckets {
+		if v <= upper {
+			s.counts[i]++
+		}
+	}
+	s.sum += v
+	s.count++
+}
+
+// Count returns the number of observations for the given labels
+func (h *Histogram) Count(labelValues ...string) uint64 {
+	key := h.key(labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s, ok := h.values[key]; ok {
+		return s.count
+	}
+	return 0
+}
+
+func (h *Histogram) write(w io.Writer) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := h.writeHeader(w, "histogram"); err != nil {
+		return err
+	}
+
+	for _, key := range sortedKeys(h.values) {
+		s := h.values[key]
+		for i, upper := range h.buckets {
+			if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, h.labels(s.labelValues, "le", formatFloat(upper)), s.counts[i]); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, h.labels(s.labelValues, "le", "+Inf"), s.count); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "%s_sum%s %s\n", h.metricName, h.labels(s.labelValues, "", ""), formatFloat(s.sum)); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "%s_count%s %d\n", h.metricName, h.labels(s.labelValues, "", ""), s.count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeSimple writes counter and gauge series
+func writeSimple(w io.Writer, d *desc, metricType string, values map[string]*series) error {
+	if err := d.writeHeader(w, metricType); err != nil {
+		return err
+	}
+	for _, key := range sortedKeys(values) {
+		s := values[key]
+		if _, err := fmt.Fprintf(w, "%s%s %s\n", d.metricName, d.labels(s.labelValues, "", ""), formatFloat(s.value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sortedKeys returns map keys in a deterministic order
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// formatFloat renders a float the way Prometheus expects
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// escapeLabel escapes a label value for the text format
+func escapeLabel(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return strings.ReplaceAll(s, `"`, `\"`)
+}
+
+// escapeHelp escapes a HELP string for the text format
+func escapeHelp(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return strings.ReplaceAll(s, "\n", `\n`)
+}
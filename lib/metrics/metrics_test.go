@@ -0,0 +1,107 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWritePrometheus(t *testing.T) {
+	r := NewRegistry()
+	calls := r.NewCounter("test_calls_total", "Calls by tool.\nSecond line.", "tool", "outcome")
+	inflight := r.NewGauge("test_inflight", "In-flight calls.")
+	latency := r.NewHistogram("test_latency_seconds", "Latency.", []float64{1, 0.1}, "tool")
+
+	calls.Inc("echo", OutcomeSuccess)
+	calls.Add(2, "echo", OutcomeSuccess)
+	calls.Add(-5, "echo", OutcomeSuccess)
+	calls.Inc(`say "hi"\now`, OutcomeError)
+	inflight.Inc()
+	inflight.Inc()
+	inflight.Dec()
+	latency.Observe(0.05, "echo")
+	latency.Observe(0.5, "echo")
+	latency.Observe(3, "echo")
+
+	var b strings.Builder
+	if err := r.WritePrometheus(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP test_calls_total Calls by tool.\nSecond line.
+# TYPE test_calls_total counter
+test_calls_total{tool="echo",outcome="success"} 3
+test_calls_total{tool="say \"hi\"\\now",outcome="error"} 1
+# HELP test_inflight In-flight calls.
+# TYPE test_inflight gauge
+test_inflight 1
+# HELP test_latency_seconds Latency.
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{tool="echo",le="0.1"} 1
+test_latency_seconds_bucket{tool="echo",le="1"} 2
+test_latency_seconds_bucket{tool="echo",le="+Inf"} 3
+test_latency_seconds_sum{tool="echo"} 3.55
+test_latency_seconds_count{tool="echo"} 3
+`
+	if got := b.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+	if calls.Value("echo", OutcomeSuccess) != 3 || latency.Count("echo") != 3 || latency.Count("other") != 0 {
+		t.Error("accessors disagree with the exposition")
+	}
+}
+
+func TestRegistryMisuse(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func(r *Registry)
+	}{
+		{"duplicate name", func(r *Registry) {
+			r.NewCounter("dup", "")
+			r.NewGauge("dup", "")
+		}},
+		{"wrong label count", func(r *Registry) {
+			r.NewCounter("c", "", "tool").Inc()
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("expected a panic")
+				}
+			}()
+			tt.fn(NewRegistry())
+		})
+	}
+}
+
+func TestHandler(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("served_total", "Served.").Inc()
+
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("content type %q", ct)
+	}
+	if !strings.Contains(rec.Body.String(), "served_total 1\n") {
+		t.Errorf("body:\n%s", rec.Body.String())
+	}
+}
+
+func TestObserveTaskTransition(t *testing.T) {
+	before := AgentTaskTransitions.Value("running")
+	ObserveTaskTransition("", "pending")
+	ObserveTaskTransition("pending", "running")
+	ObserveTaskTransition("running", "running")
+
+	if got := AgentTasks.Value("pending"); got != 0 {
+		t.Errorf("pending tasks %v, want 0 after the transition", got)
+	}
+	if got := AgentTasks.Value("running"); got != 1 {
+		t.Errorf("running tasks %v, want 1", got)
+	}
+	if got := AgentTaskTransitions.Value("running") - before; got != 1 {
+		t.Errorf("counted %v transitions to running, want 1", got)
+	}
+}
@@ -0,0 +1,293 @@
+// Package mockllm builds scriptable ModelFuncs for tests and offline demos.
+//
+// A Mock answers each prompt with the first rule whose matcher accepts it:
+//
+//	mock := mockllm.New().
+//		On(mockllm.Contains("weather"), mockllm.ToolCall("get_weather", map[string]interface{}{"city": "Paris"})).
+//		On(mockllm.Regex(`(?i)plan`), mockllm.Plan("Two steps", mockllm.Step{Tool: "uuid"})).
+//		Default(mockllm.Text("I don't know"))
+//
+//	server.SetModel(mock.ModelFunc())
+//	...
+//	if err := mock.AssertPrompted("weather"); err != nil {
+//		t.Fatal(err)
+//	}
+package mockllm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/benozo/conduit/mcp"
+)
+
+// ErrNoRule is returned when no rule matches a prompt and there is no default
+var ErrNoRule = errors.New("mockllm: no rule matches prompt")
+
+// Call is one prompt received by the mock
+type Call struct {
+	Prompt    string
+	Model     string
+	ContextID string
+	Inputs    map[string]interface{}
+	Time      time.Time
+}
+
+type rule struct {
+	match   Matcher
+	respond Responder
+}
+
+// Mock is a rule-driven fake model. It is safe for concurrent use.
+type Mock struct {
+	mu         sync.Mutex
+	rules      []rule
+	fallback   Responder
+	latency    time.Duration
+	tokenDelay time.Duration
+	calls      []Call
+	unmatched  []string
+}
+
+// Option configures a Mock
+type Option func(*Mock)
+
+// WithLatency delays every response by d, or until the request context is cancelled
+func WithLatency(d time.Duration) Option {
+	return func(m *Mock) { m.latency = d }
+}
+
+// WithTokenDelay waits d between streamed tokens
+func WithTokenDelay(d time.Duration) Option {
+	return func(m *Mock) { m.tokenDelay = d }
+}
+
+// New creates a mock with no rules
+func New(opts ...Option) *Mock {
+	m := &Mock{}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// On adds a rule; rules are tried in the order they were added
+func (m *Mock) On(match Matcher, respond Responder) *Mock {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rules = append(m.rules, rule{match: match, respond: respond})
+	return m
+}
+
+// Default sets the response for prompts no rule matches
+func (m *Mock) Default(respond Responder) *Mock {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.fallback = respond
+	return m
+}
+
+// ModelFunc returns the mock as a ModelFunc. When the caller passes onToken,
+// the response is streamed word by word before it is returned.
+func (m *Mock) ModelFunc() mcp.ModelFunc {
+	return func(ctx mcp.ContextInput, req mcp.MCPRequest, memory *mcp.Memory, onToken mcp.StreamCallback) (string, error) {
+		call := Call{
+			Prompt:    promptOf(ctx.Inputs),
+			Model:     req.Model,
+			ContextID: ctx.ContextID,
+			Inputs:    ctx.Inputs,
+			Time:      time.Now(),
+		}
+		respond := m.record(call)
+
+		reqCtx := ctx.Context()
+		if err := sleep(reqCtx, m.latency); err != nil {
+			return "", err
+		}
+		if respond == nil {
+			return "", fmt.Errorf("%w: %q", ErrNoRule, truncate(call.Prompt, 80))
+		}
+
+		output, err := respond(call)
+		if onToken != nil && output != "" {
+			for _, token := range splitTokens(output) {
+				onToken(ctx.ContextID, token)
+				if err := sleep(reqCtx, m.tokenDelay); err != nil {
+					return "", err
+				}
+			}
+		}
+		return output, err
+	}
+}
+
+// record stores the call and picks its responder
+func (m *Mock) record(call Call) Responder {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, call)
+	for _, r := range m.rules {
+		if r.match(call) {
+			return r.respond
+		}
+	}
+	if m.fallback == nil {
+		m.unmatched = append(m.unmatched, call.Prompt)
+	}
+	return m.fallback
+}
+
+// Calls returns every call received so far, oldest first
+func (m *Mock) Calls() []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Call(nil), m.calls...)
+}
+
+// Prompts returns the prompt of every call received so far
+func (m *Mock) Prompts() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	prompts := make([]string, len(m.calls))
+	for i, call := range m.calls {
+		prompts[i] = call.Prompt
+	}
+	return prompts
+}
+
+// CallCount returns the number of calls received
+func (m *Mock) CallCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.calls)
+}
+
+// Unmatched returns the prompts that matched no rule and had no default
+func (m *Mock) Unmatched() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.unmatched...)
+}
+
+// Reset forgets recorded calls but keeps the rules
+func (m *Mock) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = nil
+	m.unmatched = nil
+}
+
+// AssertPrompted returns an error unless some prompt contains substr
+func (m *Mock) AssertPrompted(substr string) error {
+	for _, prompt := range m.Prompts() {
+		if strings.Contains(prompt, substr) {
+			return nil
+		}
+	}
+	return fmt.Errorf("mockllm: no prompt contains %q (%d calls)", substr, m.CallCount())
+}
+
+// AssertNotPrompted returns an error if any prompt contains substr
+func (m *Mock) AssertNotPrompted(substr string) error {
+	for i, prompt := range m.Prompts() {
+		if strings.Contains(prompt, substr) {
+			return fmt.Errorf("mockllm: prompt %d contains %q", i, substr)
+		}
+	}
+	return nil
+}
+
+// AssertPromptMatches returns an error unless some prompt matches pattern
+func (m *Mock) AssertPromptMatches(pattern string) error {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("mockllm: invalid pattern: %w", err)
+	}
+	for _, prompt := range m.Prompts() {
+		if re.MatchString(prompt) {
+			return nil
+		}
+	}
+	return fmt.Errorf("mockllm: no prompt matches %q (%d calls)", pattern, m.CallCount())
+}
+
+// AssertCallCount returns an error unless exactly n calls were received
+func (m *Mock) AssertCallCount(n int) error {
+	if got := m.CallCount(); got != n {
+		return fmt.Errorf("mockllm: expected %d calls, got %d", n, got)
+	}
+	return nil
+}
+
+// AssertAllMatched returns an error if any prompt matched no rule
+func (m *Mock) AssertAllMatched() error {
+	if unmatched := m.Unmatched(); len(unmatched) > 0 {
+		return fmt.Errorf("mockllm: %d unmatched prompts, first: %q", len(unmatched), truncate(unmatched[0], 80))
+	}
+	return nil
+}
+
+// promptOf extracts the prompt from the inputs, preferring "query" as the
+// agents and swarm packages use, then "prompt", then the inputs as JSON
+func promptOf(inputs map[string]interface{}) string {
+	for _, key := range []string{"query", "prompt"} {
+		if s, ok := inputs[key].(string); ok {
+			return s
+		}
+	}
+	if len(inputs) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(inputs)
+	if err != nil {
+		return fmt.Sprint(inputs)
+	}
+	return string(data)
+}
+
+// splitTokens splits text into words, keeping each word's trailing whitespace
+// so the tokens join back into the original text
+func splitTokens(text string) []string {
+	var tokens []string
+	start := 0
+	inSpace := false
+	for i, r := range text {
+		space := r == ' ' || r == '\n' || r == '\t' || r == '\r'
+		if inSpace && !space {
+			tokens = append(tokens, text[start:i])
+			start = i
+		}
+		inSpace = space
+	}
+	if start < len(text) {
+		tokens = append(tokens, text[start:])
+	}
+	return tokens
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
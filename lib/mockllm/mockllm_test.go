@@ -0,0 +1,161 @@
+package mockllm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benozo/conduit/mcp"
+)
+
+func prompt(model mcp.ModelFunc, text string) (string, error) {
+	return model(mcp.ContextInput{Inputs: map[string]interface{}{"query": text}}, mcp.MCPRequest{Model: "mock"}, nil, nil)
+}
+
+func TestRules(t *testing.T) {
+	failure := errors.New("boom")
+	mock := New().
+		On(Contains("weather", "Paris"), Text("sunny")).
+		On(Contains("weather"), Text("which city?")).
+		On(Regex(`(?i)^fail`), Error(failure)).
+		On(All(Model("other"), Any()), Text("other model")).
+		On(Contains("count"), Sequence(Text("one"), Text("two"))).
+		Default(Text("default"))
+
+	tests := []struct {
+		prompt  string
+		want    string
+		wantErr error
+	}{
+		{prompt: "weather in Paris", want: "sunny"},
+		{prompt: "weather today", want: "which city?"},
+		{prompt: "FAIL now", wantErr: failure},
+		{prompt: "count", want: "one"},
+		{prompt: "count", want: "two"},
+		{prompt: "count", want: "two"},
+		{prompt: "anything else", want: "default"},
+	}
+	for _, tt := range tests {
+		got, err := prompt(mock.ModelFunc(), tt.prompt)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%q: got error %v, want %v", tt.prompt, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.prompt, got, tt.want)
+		}
+	}
+
+	if err := mock.AssertCallCount(len(tests)); err != nil {
+		t.Error(err)
+	}
+	if err := mock.AssertPrompted("Paris"); err != nil {
+		t.Error(err)
+	}
+	if err := mock.AssertNotPrompted("London"); err != nil {
+		t.Error(err)
+	}
+	if err := mock.AssertPromptMatches(`^count$`); err != nil {
+		t.Error(err)
+	}
+	if err := mock.AssertAllMatched(); err != nil {
+		t.Error(err)
+	}
+	if err := mock.AssertPrompted("London"); err == nil {
+		t.Error("AssertPrompted passed for a missing prompt")
+	}
+
+	mock.Reset()
+	if mock.CallCount() != 0 {
+		t.Errorf("Reset kept %d calls", mock.CallCount())
+	}
+}
+
+func TestNoRule(t *testing.T) {
+	mock := New().On(Contains("weather"), Text("ok"))
+	if _, err := prompt(mock.ModelFunc(), "unknown prompt"); !errors.Is(err, ErrNoRule) {
+		t.Fatalf("got %v, want ErrNoRule", err)
+	}
+	if got := mock.Unmatched(); len(got) != 1 || got[0] != "unknown prompt" {
+		t.Errorf("unmatched %q", got)
+	}
+	if err := mock.AssertAllMatched(); err == nil {
+		t.Error("AssertAllMatched passed with an unmatched prompt")
+	}
+}
+
+func TestStreaming(t *testing.T) {
+	const text = "The answer is\n42, probably."
+	mock := New().Default(Text(text))
+
+	var tokens []string
+	output, err := mock.ModelFunc()(mcp.ContextInput{ContextID: "c1", Inputs: map[string]interface{}{"prompt": "q"}}, mcp.MCPRequest{}, nil,
+		func(contextID, token string) {
+			if contextID != "c1" {
+				t.Errorf("token for context %q", contextID)
+			}
+			tokens = append(tokens, token)
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output != text || strings.Join(tokens, "") != text {
+		t.Errorf("output %q, streamed %q", output, tokens)
+	}
+	if len(tokens) != 5 {
+		t.Errorf("got %d tokens, want 5: %q", len(tokens), tokens)
+	}
+	if got := mock.Calls()[0].Prompt; got != "q" {
+		t.Errorf("prompt %q, want the prompt input", got)
+	}
+}
+
+func TestLatencyCancelled(t *testing.T) {
+	mock := New(WithLatency(time.Minute)).Default(Text("late"))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	input := mcp.ContextInput{Inputs: map[string]interface{}{"query": "q"}}.WithContext(ctx)
+	if _, err := mock.ModelFunc()(input, mcp.MCPRequest{}, nil, nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+}
+
+func TestDecisionResponders(t *testing.T) {
+	tests := []struct {
+		respond Responder
+		want    Decision
+	}{
+		{ToolCall("uuid", map[string]interface{}{"n": 1.0}), Decision{Action: "tool_use", ToolName: "uuid", ToolArgs: map[string]interface{}{"n": 1.0}}},
+		{Handoff("billing"), Decision{Action: "handoff", HandoffAgent: "billing"}},
+		{Respond("done"), Decision{Action: "respond", Response: "done"}},
+	}
+	for _, tt := range tests {
+		output, err := tt.respond(Call{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(output, "\n") {
+			t.Errorf("%s: want compact JSON", output)
+		}
+		var got Decision
+		if err := json.Unmarshal([]byte(output), &got); err != nil {
+			t.Fatalf("%s: %v", output, err)
+		}
+		if got.Action != tt.want.Action || got.ToolName != tt.want.ToolName || got.HandoffAgent != tt.want.HandoffAgent ||
+			got.Response != tt.want.Response || len(got.ToolArgs) != len(tt.want.ToolArgs) {
+			t.Errorf("got %+v, want %+v", got, tt.want)
+		}
+	}
+
+	output, _ := Plan("analysis", Step{Tool: "uuid"})(Call{})
+	var plan ActionPlan
+	if err := json.Unmarshal([]byte(output), &plan); err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Steps) != 1 || plan.Steps[0].Name != "uuid_1" || plan.Steps[0].Input == nil {
+		t.Errorf("plan steps %+v", plan.Steps)
+	}
+}
@@ -0,0 +1,165 @@
+package mockllm
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Matcher decides whether a rule applies to a call
+type Matcher func(call Call) bool
+
+// Responder produces the model output for a call
+type Responder func(call Call) (string, error)
+
+// Contains matches prompts containing every one of substrs
+func Contains(substrs ...string) Matcher {
+	return func(call Call) bool {
+		for _, s := range substrs {
+			if !strings.Contains(call.Prompt, s) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Regex matches prompts against pattern. It panics if pattern is invalid.
+func Regex(pattern string) Matcher {
+	re := regexp.MustCompile(pattern)
+	return func(call Call) bool {
+		return re.MatchString(call.Prompt)
+	}
+}
+
+// Model matches calls made with the given model name
+func Model(name string) Matcher {
+	return func(call Call) bool {
+		return call.Model == name
+	}
+}
+
+// All matches when every matcher does
+func All(matchers ...Matcher) Matcher {
+	return func(call Call) bool {
+		for _, match := range matchers {
+			if !match(call) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Any matches every call
+func Any() Matcher {
+	return func(Call) bool { return true }
+}
+
+// Text responds with fixed text
+func Text(text string) Responder {
+	return func(Call) (string, error) { return text, nil }
+}
+
+// Error fails the call with err
+func Error(err error) Responder {
+	return func(Call) (string, error) { return "", err }
+}
+
+// PartialError responds with partial output, streamed as usual, and then fails with err
+func PartialError(partial string, err error) Responder {
+	return func(Call) (string, error) { return partial, err }
+}
+
+// Sequence walks through responders on successive calls, repeating the last
+// one once they are used up
+func Sequence(responders ...Responder) Responder {
+	var mu sync.Mutex
+	next := 0
+	return func(call Call) (string, error) {
+		if len(responders) == 0 {
+			return "", fmt.Errorf("mockllm: empty sequence")
+		}
+		mu.Lock()
+		respond := responders[next]
+		if next < len(responders)-1 {
+			next++
+		}
+		mu.Unlock()
+		return respond(call)
+	}
+}
+
+// Decision mirrors the swarm package's LLMDecision JSON
+type Decision struct {
+	Action       string                 `json:"action"`
+	Reasoning    string                 `json:"reasoning,omitempty"`
+	ToolName     string                 `json:"tool_name,omitempty"`
+	ToolArgs     map[string]interface{} `json:"tool_args,omitempty"`
+	HandoffAgent string                 `json:"handoff_agent,omitempty"`
+	Response     string                 `json:"response,omitempty"`
+}
+
+// ToolCall responds with a swarm tool_use decision
+func ToolCall(tool string, args map[string]interface{}) Responder {
+	return JSON(Decision{Action: "tool_use", ToolName: tool, ToolArgs: args})
+}
+
+// Handoff responds with a swarm handoff decision
+func Handoff(agent string) Responder {
+	return JSON(Decision{Action: "handoff", HandoffAgent: agent})
+}
+
+// Respond responds with a swarm respond decision
+func Respond(text string) Responder {
+	return JSON(Decision{Action: "respond", Response: text})
+}
+
+// Step is one step of an agents action plan
+type Step struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Tool        string                 `json:"tool"`
+	Input       map[string]interface{} `json:"input"`
+}
+
+// ActionPlan mirrors the plan JSON the agents package parses
+type ActionPlan struct {
+	Analysis  string `json:"analysis"`
+	Steps     []Step `json:"steps"`
+	Reasoning string `json:"reasoning"`
+}
+
+// Plan responds with an agents action plan. Steps without a name are named
+// after their tool, and nil inputs become empty objects.
+func Plan(analysis string, steps ...Step) Responder {
+	plan := ActionPlan{Analysis: analysis, Steps: make([]Step, len(steps))}
+	for i, step := range steps {
+		if step.Name == "" {
+			step.Name = fmt.Sprintf("%s_%d", step.Tool, i+1)
+		}
+		if step.Input == nil {
+			step.Input = map[string]interface{}{}
+		}
+		plan.Steps[i] = step
+	}
+	return JSON(plan)
+}
+
+// JSON responds with v encoded as JSON
+func JSON(v interface{}) Responder {
+	data, err := json.Marshal(v)
+	return func(Call) (string, error) {
+		if err != nil {
+			return "", fmt.Errorf("mockllm: failed to encode response: %w", err)
+		}
+		return string(data), nil
+	}
+}
+
+// Func adapts a function of the prompt into a Responder
+func Func(fn func(prompt string) (string, error)) Responder {
+	return func(call Call) (string, error) { return fn(call.Prompt) }
+}
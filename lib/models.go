@@ -4,38 +4,121 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"strings"
 	"time"
 
+	"github.com/benozo/conduit/lib/cache"
+	"github.com/benozo/conduit/lib/httpclient"
+	"github.com/benozo/conduit/lib/logging"
+	"github.com/benozo/conduit/lib/metrics"
+	"github.com/benozo/conduit/lib/tokens"
+	"github.com/benozo/conduit/lib/tracing"
+	"github.com/benozo/conduit/lib/usage"
 	"github.com/benozo/conduit/mcp"
 )
 
 // OllamaRequest represents a request to Ollama
 type OllamaRequest struct {
-	Model  string `json:"model"`
-	Prompt string `json:"prompt"`
-	Stream bool   `json:"stream"`
+	Model  string   `json:"model"`
+	Prompt string   `json:"prompt"`
+	Images []string `json:"images,omitempty"` // base64-encoded
+	Stream bool     `json:"stream"`
+	// Format constrains the reply to a JSON Schema
+	Format map[string]interface{} `json:"format,omitempty"`
 }
 
 // OllamaChunk represents a streaming response chunk from Ollama
 type OllamaChunk struct {
-	Response string `json:"response"`
-	Done     bool   `json:"done"`
+	Response        string `json:"response"`
+	Done            bool   `json:"done"`
+	PromptEvalCount int    `json:"prompt_eval_count,omitempty"`
+	EvalCount       int    `json:"eval_count,omitempty"`
+}
+
+// instrumentModel wraps a model function so every call is recorded in the metrics registry
+// and traced as a child of the span carried by the context input.
+// modelName resolves the model actually used for a request.
+func instrumentModel(provider string, modelName func(req mcp.MCPRequest) string, fn mcp.ModelFunc) mcp.ModelFunc {
+	return func(ctx mcp.ContextInput, req mcp.MCPRequest, memory *mcp.Memory, onToken mcp.StreamCallback) (string, error) {
+		model := modelName(req)
+		spanCtx, span := tracing.StartWithKind(ctx.Context(), "model.call", tracing.SpanKindClient,
+			tracing.String("model.provider", provider),
+			tracing.String("model.name", model),
+			tracing.String("context.id", ctx.ContextID),
+		)
+		defer span.End()
+
+		scope := callScope(spanCtx, req)
+		if err := usage.Default.Check(scope, model); err != nil {
+			span.RecordError(err)
+			return "", err
+		}
+		callCtx, call := usage.StartCall(spanCtx)
+
+		start := time.Now()
+		response, err := fn(ctx.WithContext(callCtx), req, memory, onToken)
+		metrics.ObserveModelCall(provider, model, err, time.Since(start))
+		span.RecordError(err)
+		recordUsage(provider, model, scope, call, ctx, response, err)
+		return response, err
+	}
+}
+
+// requestModel resolves the model from the request, falling back to a default
+func requestModel(fallback string) func(req mcp.MCPRequest) string {
+	return func(req mcp.MCPRequest) string {
+		return modelLabel(req.Model, fallback)
+	}
+}
+
+// fixedModel always resolves to the configured model
+func fixedModel(model string) func(req mcp.MCPRequest) string {
+	return func(req mcp.MCPRequest) string {
+		return modelLabel(model, "")
+	}
+}
+
+// newModelClient creates the HTTP client for a provider, using timeout
+// unless the options set one
+func newModelClient(provider string, opts httpclient.Options, timeout time.Duration) *httpclient.Client {
+	if opts.Timeout == 0 {
+		opts.Timeout = timeout
+	}
+	return httpclient.New(provider, opts)
+}
+
+// modelLabel picks the model name used for metrics labels
+func modelLabel(requested, fallback string) string {
+	if requested != "" {
+		return requested
+	}
+	if fallback != "" {
+		return fallback
+	}
+	return "default"
 }
 
 // CreateOllamaModel creates an Ollama model function
 func CreateOllamaModel(ollamaURL string) mcp.ModelFunc {
-	return func(ctx mcp.ContextInput, req mcp.MCPRequest, memory *mcp.Memory, onToken mcp.StreamCallback) (string, error) {
+	client := newModelClient("ollama", httpclient.Options{}, 300*time.Second)
+	return instrumentModel("ollama", requestModel(""), func(ctx mcp.ContextInput, req mcp.MCPRequest, memory *mcp.Memory, onToken mcp.StreamCallback) (string, error) {
 		query := fmt.Sprintf("%v", ctx.Inputs["query"])
+		images, err := ollamaInputImages(ctx)
+		if err != nil {
+			return "", err
+		}
 
 		payload := OllamaRequest{
 			Model:  req.Model,
 			Prompt: query,
+			Images: images,
 			Stream: false,
+			Format: mcp.ResponseSchemaInput(ctx),
 		}
 
 		body, err := json.Marshal(payload)
@@ -43,23 +126,18 @@ func CreateOllamaModel(ollamaURL string) mcp.ModelFunc {
 			return "", fmt.Errorf("failed to marshal request: %w", err)
 		}
 
-		httpReq, err := http.NewRequestWithContext(context.Background(), "POST", ollamaURL+"/api/generate", bytes.NewReader(body))
+		httpReq, err := http.NewRequestWithContext(ctx.Context(), "POST", ollamaURL+"/api/generate", bytes.NewReader(body))
 		if err != nil {
 			return "", fmt.Errorf("failed to create request: %w", err)
 		}
 		httpReq.Header.Set("Content-Type", "application/json")
 
-		client := &http.Client{}
 		resp, err := client.Do(httpReq)
 		if err != nil {
 			return "", fmt.Errorf("failed to call Ollama: %w", err)
 		}
 		defer resp.Body.Close()
 
-		if resp.StatusCode != http.StatusOK {
-			return "", fmt.Errorf("Ollama returned status %d", resp.StatusCode)
-		}
-
 		var result strings.Builder
 		decoder := json.NewDecoder(resp.Body)
 
@@ -80,12 +158,13 @@ func CreateOllamaModel(ollamaURL string) mcp.ModelFunc {
 			}
 
 			if chunk.Done {
+				observeTokens(ctx.Context(), "ollama", modelLabel(req.Model, ""), chunk.PromptEvalCount, chunk.EvalCount)
 				break
 			}
 		}
 
 		return result.String(), nil
-	}
+	})
 }
 
 // OllamaChatRequest represents a chat request to Ollama with tool support
@@ -94,14 +173,19 @@ type OllamaChatRequest struct {
 	Messages []OllamaChatMessage     `json:"messages"`
 	Stream   bool                    `json:"stream"`
 	Tools    []OllamaToolDescription `json:"tools,omitempty"`
+	Options  map[string]interface{}  `json:"options,omitempty"`
+	// Format constrains the reply to a JSON Schema
+	Format map[string]interface{} `json:"format,omitempty"`
 }
 
 // OllamaChatMessage represents a chat message
 type OllamaChatMessage struct {
 	Role      string           `json:"role"`
 	Content   string           `json:"content"`
-	Name      string           `json:"name,omitempty"` // Tool name for tool messages
+	Name      string           `json:"name,omitempty"`
+	ToolName  string           `json:"tool_name,omitempty"` // Tool name for tool messages
 	ToolCalls []OllamaToolCall `json:"tool_calls,omitempty"`
+	Images    []string         `json:"images,omitempty"` // base64-encoded
 }
 
 // OllamaToolDescription represents a tool description for Ollama
@@ -119,8 +203,11 @@ type OllamaFunctionDef struct {
 
 // OllamaChatChunk represents a streaming chat response from Ollama
 type OllamaChatChunk struct {
-	Message OllamaChatMessage `json:"message"`
-	Done    bool              `json:"done"`
+	Message         OllamaChatMessage `json:"message"`
+	Done            bool              `json:"done"`
+	DoneReason      string            `json:"done_reason,omitempty"`
+	PromptEvalCount int               `json:"prompt_eval_count,omitempty"`
+	EvalCount       int               `json:"eval_count,omitempty"`
 }
 
 // OllamaToolCall represents a tool call from Ollama
@@ -134,315 +221,285 @@ type OllamaFunctionCall struct {
 	Arguments map[string]interface{} `json:"arguments"`
 }
 
-// CreateOllamaToolAwareModel creates an Ollama model function with tool support
+// CreateOllamaToolAwareModel creates an Ollama model function with tool support.
+// Tools are described with the built-in schemas; use
+// CreateOllamaToolAwareModelWithSchemas to describe custom tools correctly.
 func CreateOllamaToolAwareModel(ollamaURL string, tools *mcp.ToolRegistry) mcp.ModelFunc {
-	return func(ctx mcp.ContextInput, req mcp.MCPRequest, memory *mcp.Memory, onToken mcp.StreamCallback) (string, error) {
-		query := fmt.Sprintf("%v", ctx.Inputs["query"])
+	return CreateOllamaToolAwareModelWithSchemas(ollamaURL, tools, nil)
+}
 
-		// Use provided model or default
-		model := req.Model
-		if model == "" {
-			model = "llama3.2" // Default model
-		}
+// CreateOllamaToolAwareModelWithSchemas creates an Ollama model function that
+// describes tools with the provider's schemas and calls them over as many
+// rounds as the model needs. provider may be nil.
+func CreateOllamaToolAwareModelWithSchemas(ollamaURL string, tools *mcp.ToolRegistry, provider mcp.EnhancedSchemaProvider) mcp.ModelFunc {
+	// One chat model serves every request; the request picks the model name,
+	// defaulting to llama3.2
+	chat := NewOllamaChatModel(ollamaURL, "llama3.2")
+	modelName := requestModel(chat.Model)
+	return instrumentModel("ollama", modelName, ollamaToolModel(chat, chat, tools, provider, 0, modelName))
+}
+
+// CreateOllamaToolAwareModelWithConfig creates a tool-calling Ollama model function with configuration
+func CreateOllamaToolAwareModelWithConfig(config *ModelConfig, tools *mcp.ToolRegistry, provider mcp.EnhancedSchemaProvider) mcp.ModelFunc {
+	ollamaURL := config.URL
+	if ollamaURL == "" {
+		ollamaURL = "http://localhost:11434"
+	}
 
-		log.Printf("🔍 CreateOllamaToolAwareModel called with query: %s", query)
-		log.Printf("🔧 Ollama URL: %s", ollamaURL)
-		log.Printf("🔧 Model: %s", model)
+	chat := NewOllamaChatModel(ollamaURL, config.Model)
+	chat.Client = newModelClient("ollama", config.HTTP, 300*time.Second)
+	chat.Temperature = config.Temperature
+	chat.TopK = config.TopK
+	chat.MaxTokens = config.MaxTokens
+	configured := func(mcp.MCPRequest) string { return config.Model }
+	return instrumentModel("ollama", fixedModel(config.Model), ollamaToolModel(chat, cachedChat("ollama", config, chat), tools, provider, config.MaxToolIterations, configured))
+}
 
-		// First try with tool-aware chat API
-		result, err := tryOllamaWithTools(ollamaURL, query, model, tools, memory, onToken, ctx.ContextID)
-		if err == nil && result != "" {
-			log.Printf("✅ Ollama tool-aware request succeeded")
+// ollamaToolModel runs the native tool-call loop on loop, usually chat
+// itself, with the model modelName picks for the request. It falls back to
+// prompt-based tool calling on chat only when the first chat call is
+// rejected because the model doesn't take tools.
+func ollamaToolModel(chat *OllamaChatModel, loop mcp.ChatModel, tools *mcp.ToolRegistry, provider mcp.EnhancedSchemaProvider, maxIterations int, modelName func(req mcp.MCPRequest) string) mcp.ModelFunc {
+	return func(ctx mcp.ContextInput, req mcp.MCPRequest, memory *mcp.Memory, onToken mcp.StreamCallback) (string, error) {
+		model := modelName(req)
+		query := fmt.Sprintf("%v", ctx.Inputs["query"])
+		slog.DebugContext(ctx.Context(), "ollama tool-aware request", "url", chat.URL, "model", model, "query_length", len(query))
+
+		// Count the chat calls: tools only run after the first one succeeds
+		calls := 0
+		counted := mcp.ChatModelFunc(func(c context.Context, r mcp.ChatRequest) (*mcp.ChatResponse, error) {
+			calls++
+			if r.Model == "" && model != chat.Model {
+				r.Model = model
+			}
+			return loop.Chat(c, r)
+		})
+		result, err := toolLoopModel(counted, tools, provider, maxIterations)(ctx, req, memory, onToken)
+		if err == nil {
 			return result, nil
 		}
+		if calls > 1 || !toolsUnsupported(err) {
+			return "", err
+		}
 
-		log.Printf("⚠️ Ollama tool-aware failed, trying prompt-based approach: %v", err)
-
-		// Fallback: Use prompt engineering to simulate tool calling
-		return tryOllamaWithPromptTools(ollamaURL, query, model, tools, memory, onToken, ctx.ContextID)
-	}
-}
-
-// tryOllamaWithTools attempts to use Ollama's native tool calling
-func tryOllamaWithTools(ollamaURL, query, model string, tools *mcp.ToolRegistry, memory *mcp.Memory, onToken mcp.StreamCallback, contextID string) (string, error) {
-	// Convert tools to Ollama format
-	var ollamaTools []OllamaToolDescription
-	if tools != nil {
-		log.Printf("🔧 Available tools: %v", tools.GetRegisteredTools())
-		for _, name := range tools.GetRegisteredTools() {
-			// Use the same pattern as the working curl example
-			// All tools get "Tool: name" description and text parameter by default
-			description := fmt.Sprintf("Tool: %s", name)
-			parameters := map[string]interface{}{
-				"type": "object",
-				"properties": map[string]interface{}{
-					"text": map[string]interface{}{
-						"type":        "string",
-						"description": "Input text",
-					},
-				},
-				"required": []string{"text"},
-			}
+		slog.WarnContext(ctx.Context(), "ollama rejected native tool calling, falling back to prompt-based tools", "model", model, "error", err)
+		return tryOllamaWithPromptTools(ctx.Context(), chat, model, query, tools, provider, memory, onToken, ctx.ContextID)
+	}
+}
 
-			// Special cases for tools that need different parameters
-			switch name {
-			case "remember":
-				description = "Store a value in memory"
-				parameters = map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"key": map[string]interface{}{
-							"type":        "string",
-							"description": "Memory key",
-						},
-						"value": map[string]interface{}{
-							"type":        "string",
-							"description": "Value to store",
-						},
-					},
-					"required": []string{"key", "value"},
-				}
-			case "recall":
-				description = "Retrieve a value from memory"
-				parameters = map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"key": map[string]interface{}{
-							"type":        "string",
-							"description": "Memory key",
-						},
-					},
-					"required": []string{"key"},
-				}
-			case "forget":
-				description = "Remove a value from memory"
-				parameters = map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"key": map[string]interface{}{
-							"type":        "string",
-							"description": "Memory key",
-						},
-					},
-					"required": []string{"key"},
-				}
-			case "replace":
-				description = "Replace text patterns"
-				parameters = map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"text": map[string]interface{}{
-							"type":        "string",
-							"description": "Input text",
-						},
-						"old": map[string]interface{}{
-							"type":        "string",
-							"description": "Text to replace",
-						},
-						"new": map[string]interface{}{
-							"type":        "string",
-							"description": "Replacement text",
-						},
-					},
-					"required": []string{"text", "old", "new"},
-				}
-			case "add":
-				description = "Add two numbers together"
-				parameters = map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"a": map[string]interface{}{
-							"type":        "number",
-							"description": "First number to add",
-						},
-						"b": map[string]interface{}{
-							"type":        "number",
-							"description": "Second number to add",
-						},
-					},
-					"required": []string{"a", "b"},
-				}
-			case "timestamp":
-				description = "Get current timestamp"
-				parameters = map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"format": map[string]interface{}{
-							"type":        "string",
-							"description": "Timestamp format (iso, unix, readable)",
-						},
-					},
-					// timestamp doesn't require any parameters, making format optional
-				}
-			case "uuid":
-				description = "Generate a UUID"
-				// Keep the text parameter as in working example for consistency
-			}
+// toolsUnsupported reports whether err is the server refusing the request
+// itself, such as Ollama's 400 "does not support tools" reply. Rate limits,
+// budgets, open circuits and server errors don't qualify.
+func toolsUnsupported(err error) bool {
+	if strings.Contains(err.Error(), "does not support tools") {
+		return true
+	}
+	var httpErr *httpclient.Error
+	if !errors.As(err, &httpErr) {
+		return false
+	}
+	return httpErr.StatusCode >= 400 && httpErr.StatusCode < 500 && httpErr.StatusCode != http.StatusTooManyRequests
+}
 
-			ollamaTools = append(ollamaTools, OllamaToolDescription{
-				Type: "function",
-				Function: OllamaFunctionDef{
-					Name:        name,
-					Description: description,
-					Parameters:  parameters,
-				},
-			})
-		}
-		log.Printf("🔧 Converted %d tools to Ollama format", len(ollamaTools))
+// OllamaChatModel is a ChatModel for Ollama's /api/chat endpoint
+type OllamaChatModel struct {
+	URL   string
+	Model string
+	// Sampling defaults for requests that don't set them
+	Temperature float64
+	TopK        int
+	MaxTokens   int
+	Client      *httpclient.Client
+}
+
+// NewOllamaChatModel creates a chat model for the Ollama server at ollamaURL
+func NewOllamaChatModel(ollamaURL, model string) *OllamaChatModel {
+	return &OllamaChatModel{
+		URL:    strings.TrimSuffix(ollamaURL, "/"),
+		Model:  model,
+		Client: newModelClient("ollama", httpclient.Options{}, 300*time.Second),
+	}
+}
+
+// Chat sends the conversation, with tools when given, and returns the reply
+func (m *OllamaChatModel) Chat(ctx context.Context, req mcp.ChatRequest) (*mcp.ChatResponse, error) {
+	model := req.Model
+	if model == "" {
+		model = m.Model
 	}
 
 	payload := OllamaChatRequest{
-		Model: model,
-		Messages: []OllamaChatMessage{
-			{
-				Role:    "user",
-				Content: query,
+		Model:   model,
+		Stream:  false,
+		Options: m.options(req),
+		Format:  req.ResponseSchema,
+	}
+	for _, msg := range req.Messages {
+		images, err := ollamaImages(msg.Images)
+		if err != nil {
+			return nil, err
+		}
+		wire := OllamaChatMessage{Role: msg.Role, Content: msg.Content, Images: images}
+		if msg.Role == mcp.RoleTool {
+			wire.ToolName = msg.Name
+		}
+		for _, call := range msg.ToolCalls {
+			wire.ToolCalls = append(wire.ToolCalls, OllamaToolCall{
+				Function: OllamaFunctionCall{Name: call.Name, Arguments: call.Arguments},
+			})
+		}
+		payload.Messages = append(payload.Messages, wire)
+	}
+	for _, tool := range req.Tools {
+		payload.Tools = append(payload.Tools, OllamaToolDescription{
+			Type: "function",
+			Function: OllamaFunctionDef{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
 			},
-		},
-		Stream: false, // Try non-streaming first
-		Tools:  ollamaTools,
+		})
 	}
 
 	body, err := json.Marshal(payload)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal chat request: %w", err)
+		return nil, fmt.Errorf("failed to marshal chat request: %w", err)
 	}
 
-	log.Printf("🚀 Sending tool-aware request to Ollama")
-	log.Printf("📤 Payload: %s", string(body))
-
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+	slog.DebugContext(ctx, "sending chat request to ollama", "model", model, "messages", len(payload.Messages), "tools", len(payload.Tools), "payload_bytes", len(body))
 
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", ollamaURL+"/api/chat", bytes.NewReader(body))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", m.URL+"/api/chat", bytes.NewReader(body))
 	if err != nil {
-		return "", fmt.Errorf("failed to create chat request: %w", err)
+		return nil, fmt.Errorf("failed to create chat request: %w", err)
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{}
+	client := m.Client
+	if client == nil {
+		client = httpclient.New("ollama", httpclient.Options{})
+	}
 	resp, err := client.Do(httpReq)
 	if err != nil {
-		return "", fmt.Errorf("failed to call Ollama chat API: %w", err)
+		return nil, fmt.Errorf("failed to call Ollama chat API: %w", err)
 	}
 	defer resp.Body.Close()
 
-	log.Printf("📡 Response status: %d", resp.StatusCode)
-
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("Ollama chat API returned status %d", resp.StatusCode)
-	}
-
-	// Read the response
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("failed to read response body: %w", err)
-	}
-
-	log.Printf("📦 Raw response: %s", string(respBody))
-
 	var chatResp OllamaChatChunk
-	if err := json.Unmarshal(respBody, &chatResp); err != nil {
-		return "", fmt.Errorf("failed to decode response: %w", err)
+	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
 
-	log.Printf("🔧 Decoded response: Message.Content='%s', ToolCalls=%d", chatResp.Message.Content, len(chatResp.Message.ToolCalls))
-
-	// Check if we got tool calls
-	if len(chatResp.Message.ToolCalls) > 0 {
-		log.Printf("🔧 Ollama requested %d tool calls!", len(chatResp.Message.ToolCalls))
-
-		// Execute tools and collect results
-		var toolMessages []OllamaChatMessage
-
-		// Start with the original user message
-		toolMessages = append(toolMessages, OllamaChatMessage{
-			Role:    "user",
-			Content: query,
-		})
-
-		// Add the assistant's message with tool calls
-		toolMessages = append(toolMessages, OllamaChatMessage{
-			Role:      "assistant",
-			Content:   chatResp.Message.Content,
-			ToolCalls: chatResp.Message.ToolCalls,
-		})
-
-		// Execute each tool and create tool result messages
-		for i, toolCall := range chatResp.Message.ToolCalls {
-			log.Printf("🔧 Tool call %d: %s with args %+v", i+1, toolCall.Function.Name, toolCall.Function.Arguments)
+	slog.DebugContext(ctx, "ollama chat response", "model", model, "content_length", len(chatResp.Message.Content), "tool_calls", len(chatResp.Message.ToolCalls))
+	observeTokens(ctx, "ollama", model, chatResp.PromptEvalCount, chatResp.EvalCount)
 
-			var toolResult interface{}
-			var toolErr error
+	message := mcp.ChatMessage{Role: mcp.RoleAssistant, Content: chatResp.Message.Content}
+	for _, call := range chatResp.Message.ToolCalls {
+		args := call.Function.Arguments
+		if args == nil {
+			args = map[string]interface{}{}
+		}
+		message.ToolCalls = append(message.ToolCalls, mcp.ChatToolCall{Name: call.Function.Name, Arguments: args})
+	}
+	if req.OnToken != nil && message.Content != "" {
+		req.OnToken(message.Content)
+	}
 
-			if tools != nil {
-				toolResult, toolErr = tools.Call(toolCall.Function.Name, toolCall.Function.Arguments, memory)
-			}
+	stopReason := mcp.StopReasonEnd
+	switch {
+	case len(message.ToolCalls) > 0:
+		stopReason = mcp.StopReasonToolCalls
+	case chatResp.DoneReason == "length":
+		stopReason = mcp.StopReasonLength
+	}
 
-			// Create tool result message
-			var resultContent string
-			if toolErr != nil {
-				log.Printf("❌ Tool %s failed: %v", toolCall.Function.Name, toolErr)
-				resultContent = fmt.Sprintf("Error: %v", toolErr)
-			} else {
-				log.Printf("✅ Tool %s succeeded: %v", toolCall.Function.Name, toolResult)
-				resultContent = fmt.Sprintf("%v", toolResult)
-			}
+	return &mcp.ChatResponse{
+		Model:      model,
+		Message:    message,
+		StopReason: stopReason,
+		Usage: mcp.Usage{
+			PromptTokens:     chatResp.PromptEvalCount,
+			CompletionTokens: chatResp.EvalCount,
+			TotalTokens:      chatResp.PromptEvalCount + chatResp.EvalCount,
+		},
+	}, nil
+}
 
-			// Add tool result message
-			toolMessages = append(toolMessages, OllamaChatMessage{
-				Role:    "tool",
-				Content: resultContent,
-				Name:    toolCall.Function.Name,
-			})
+// ollamaImages encodes images for Ollama, which only takes inline data
+func ollamaImages(images []mcp.Image) ([]string, error) {
+	var encoded []string
+	for _, img := range images {
+		if img.Remote() {
+			return nil, fmt.Errorf("ollama does not fetch image URLs; pass the image as a file or base64 instead of %s", img.URL)
 		}
+		encoded = append(encoded, img.Base64())
+	}
+	return encoded, nil
+}
 
-		// Send tool results back to Ollama for final response
-		log.Printf("🔄 Sending tool results back to Ollama for final response...")
-		return sendToolResultsToOllama(ollamaURL, model, query, toolMessages, onToken, contextID)
+// ollamaInputImages encodes the images sent with a ModelFunc call
+func ollamaInputImages(ctx mcp.ContextInput) ([]string, error) {
+	images, err := mcp.ImagesInput(ctx)
+	if err != nil {
+		return nil, err
 	}
+	return ollamaImages(images)
+}
 
-	// If no tool calls but we have content, return it
-	if chatResp.Message.Content != "" {
-		log.Printf("💬 Got message content without tool calls: %s", chatResp.Message.Content)
-		return chatResp.Message.Content, nil
+// options maps sampling settings onto Ollama's options object
+func (m *OllamaChatModel) options(req mcp.ChatRequest) map[string]interface{} {
+	options := map[string]interface{}{}
+	if t := firstNonZero(req.Temperature, m.Temperature); t != 0 {
+		options["temperature"] = t
+	}
+	if k := req.TopK; k != 0 {
+		options["top_k"] = k
+	} else if m.TopK != 0 {
+		options["top_k"] = m.TopK
 	}
+	if req.TopP != 0 {
+		options["top_p"] = req.TopP
+	}
+	if n := req.MaxTokens; n != 0 {
+		options["num_predict"] = n
+	} else if m.MaxTokens != 0 {
+		options["num_predict"] = m.MaxTokens
+	}
+	if len(req.Stop) > 0 {
+		options["stop"] = req.Stop
+	}
+	if len(options) == 0 {
+		return nil
+	}
+	return options
+}
 
-	// No tool calls and no content - this is the failure case
-	return "", fmt.Errorf("ollama returned empty response")
+func firstNonZero(values ...float64) float64 {
+	for _, v := range values {
+		if v != 0 {
+			return v
+		}
+	}
+	return 0
 }
 
 // tryOllamaWithPromptTools uses prompt engineering to simulate tool calling
-func tryOllamaWithPromptTools(ollamaURL, query, model string, tools *mcp.ToolRegistry, memory *mcp.Memory, onToken mcp.StreamCallback, contextID string) (string, error) {
-	log.Printf("🔧 Using prompt-based tool calling approach")
+func tryOllamaWithPromptTools(ctx context.Context, chat *OllamaChatModel, model, query string, tools *mcp.ToolRegistry, provider mcp.EnhancedSchemaProvider, memory *mcp.Memory, onToken mcp.StreamCallback, contextID string) (string, error) {
+	slog.DebugContext(ctx, "using prompt-based tool calling", "model", model)
 
-	// Create a prompt that instructs the model to use tools
+	// Describe every tool with its full argument schema
 	var toolList strings.Builder
-	if tools != nil {
+	if defs := mcp.ToolDefinitions(tools, provider); len(defs) > 0 {
 		toolList.WriteString("Available tools:\n")
-		for _, name := range tools.GetRegisteredTools() {
-			switch name {
-			case "uuid":
-				toolList.WriteString("- uuid(): Generate a unique identifier\n")
-			case "timestamp":
-				toolList.WriteString("- timestamp(format): Get current time (formats: iso, unix, readable)\n")
-			case "uppercase":
-				toolList.WriteString("- uppercase(text): Convert text to uppercase\n")
-			case "lowercase":
-				toolList.WriteString("- lowercase(text): Convert text to lowercase\n")
-			case "base64_encode":
-				toolList.WriteString("- base64_encode(text): Encode text to base64\n")
-			case "hash_sha256":
-				toolList.WriteString("- hash_sha256(text): Generate SHA256 hash\n")
-			default:
-				toolList.WriteString(fmt.Sprintf("- %s(text): %s tool\n", name, name))
-			}
+		for _, def := range defs {
+			schema, _ := json.Marshal(def.Parameters)
+			fmt.Fprintf(&toolList, "- %s: %s\n  arguments schema: %s\n", def.Name, def.Description, schema)
 		}
 	}
 
 	prompt := fmt.Sprintf(`You are an AI assistant with access to tools. When the user asks for something, you should:
 1. Identify which tools are needed
-2. Call the tools using the format: TOOL_CALL:tool_name:parameters
+2. Call each tool on its own line using the format: TOOL_CALL:tool_name:{"argument": "value"}
+   The arguments must be a single-line JSON object matching the tool's arguments schema.
 3. Provide a helpful response
 
 %s
@@ -463,48 +520,42 @@ Please identify which tools you need and call them using the TOOL_CALL format, t
 		return "", fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	log.Printf("� Sending prompt-based request to Ollama")
-
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", ollamaURL+"/api/generate", bytes.NewReader(body))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", chat.URL+"/api/generate", bytes.NewReader(body))
 	if err != nil {
 		return "", fmt.Errorf("failed to create request: %w", err)
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{}
+	client := chat.Client
+	if client == nil {
+		client = httpclient.New("ollama", httpclient.Options{})
+	}
 	resp, err := client.Do(httpReq)
 	if err != nil {
 		return "", fmt.Errorf("failed to call Ollama: %w", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("Ollama returned status %d", resp.StatusCode)
-	}
-
-	// Read response
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
-	}
-
 	var ollamaResp OllamaChunk
-	if err := json.Unmarshal(respBody, &ollamaResp); err != nil {
+	if err := json.NewDecoder(resp.Body).Decode(&ollamaResp); err != nil {
 		return "", fmt.Errorf("failed to decode response: %w", err)
 	}
 
 	response := ollamaResp.Response
-	log.Printf("💬 Ollama response: %s", response)
+	slog.DebugContext(ctx, "ollama prompt-based response", "model", model, "response_length", len(response))
+	observeTokens(ctx, "ollama", model, ollamaResp.PromptEvalCount, ollamaResp.EvalCount)
 
 	// Parse the response for tool calls
-	return parseAndExecuteToolCalls(response, tools, memory), nil
+	result := parseAndExecuteToolCalls(ctx, response, tools, memory)
+	if onToken != nil {
+		onToken(contextID, result)
+	}
+	return result, nil
 }
 
-// parseAndExecuteToolCalls parses the LLM response for tool calls and executes them
-func parseAndExecuteToolCalls(response string, tools *mcp.ToolRegistry, memory *mcp.Memory) string {
+// parseAndExecuteToolCalls parses the LLM response for TOOL_CALL lines and executes them.
+// Arguments are a JSON object; anything else is passed as the text argument.
+func parseAndExecuteToolCalls(ctx context.Context, response string, tools *mcp.ToolRegistry, memory *mcp.Memory) string {
 	if tools == nil {
 		return response
 	}
@@ -513,32 +564,36 @@ func parseAndExecuteToolCalls(response string, tools *mcp.ToolRegistry, memory *
 	lines := strings.Split(response, "\n")
 
 	for _, line := range lines {
-		if strings.HasPrefix(line, "TOOL_CALL:") {
-			// Parse tool call: TOOL_CALL:tool_name:parameters
-			parts := strings.SplitN(line, ":", 3)
-			if len(parts) >= 2 {
-				toolName := parts[1]
-				var params map[string]interface{}
-
-				if len(parts) == 3 {
-					params = map[string]interface{}{"text": parts[2]}
-				} else {
-					params = map[string]interface{}{}
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, "TOOL_CALL:") {
+			result.WriteString(line + "\n")
+			continue
+		}
+
+		// Parse tool call: TOOL_CALL:tool_name:arguments
+		parts := strings.SplitN(trimmed, ":", 3)
+		toolName := strings.TrimSpace(parts[1])
+		params := map[string]interface{}{}
+		if len(parts) == 3 {
+			raw := strings.TrimSpace(parts[2])
+			if strings.HasPrefix(raw, "{") {
+				if err := json.Unmarshal([]byte(raw), &params); err != nil {
+					result.WriteString(fmt.Sprintf("[Tool %s error: invalid arguments: %v]\n", toolName, err))
+					continue
 				}
+			} else if raw != "" {
+				params = map[string]interface{}{"text": raw}
+			}
+		}
 
-				log.Printf("🔧 Executing parsed tool call: %s with params %+v", toolName, params)
+		slog.DebugContext(ctx, "executing parsed tool call", "tool", toolName, "params", logging.Redact(params))
 
-				toolResult, err := tools.Call(toolName, params, memory)
-				if err != nil {
-					log.Printf("❌ Tool %s failed: %v", toolName, err)
-					result.WriteString(fmt.Sprintf("[Tool %s error: %v]\n", toolName, err))
-				} else {
-					log.Printf("✅ Tool %s succeeded: %v", toolName, toolResult)
-					result.WriteString(fmt.Sprintf("[Tool %s result: %v]\n", toolName, toolResult))
-				}
-			}
+		toolResult, err := tools.CallContext(ctx, toolName, params, memory)
+		if err != nil {
+			slog.WarnContext(ctx, "parsed tool call failed", "tool", toolName, "error", err)
+			result.WriteString(fmt.Sprintf("[Tool %s error: %v]\n", toolName, err))
 		} else {
-			result.WriteString(line + "\n")
+			result.WriteString(fmt.Sprintf("[Tool %s result: %s]\n", toolName, mcp.FormatToolResult(toolResult)))
 		}
 	}
 
@@ -577,168 +632,95 @@ func CreateCustomModel(modelFunc func(query string, memory *mcp.Memory) (string,
 	}
 }
 
-// sendToolResultsToOllama sends tool results back to Ollama and gets the final response
-func sendToolResultsToOllama(ollamaURL, model, originalQuery string, messages []OllamaChatMessage, onToken mcp.StreamCallback, contextID string) (string, error) {
-	// Create a new chat request with the conversation history including tool results
-	payload := OllamaChatRequest{
-		Model:    model,
-		Messages: messages,
-		Stream:   false,
-		Tools:    nil, // Don't include tools in follow-up request to avoid infinite loops
-	}
-
-	body, err := json.Marshal(payload)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal follow-up request: %w", err)
-	}
-
-	log.Printf("🔄 Sending follow-up request to Ollama with tool results")
-	log.Printf("📤 Follow-up payload: %s", string(body))
-
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", ollamaURL+"/api/chat", bytes.NewReader(body))
-	if err != nil {
-		return "", fmt.Errorf("failed to create follow-up request: %w", err)
-	}
-	httpReq.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{}
-	resp, err := client.Do(httpReq)
-	if err != nil {
-		return "", fmt.Errorf("failed to call Ollama follow-up API: %w", err)
-	}
-	defer resp.Body.Close()
-
-	log.Printf("📡 Follow-up response status: %d", resp.StatusCode)
-
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("Ollama follow-up API returned status %d", resp.StatusCode)
-	}
-
-	// Read the response
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("failed to read follow-up response: %w", err)
-	}
-
-	log.Printf("📦 Follow-up raw response: %s", string(respBody))
-
-	var chatResp OllamaChatChunk
-	if err := json.Unmarshal(respBody, &chatResp); err != nil {
-		return "", fmt.Errorf("failed to decode follow-up response: %w", err)
-	}
-
-	// Return the final response content
-	if chatResp.Message.Content != "" {
-		log.Printf("💬 Final response from Ollama: %s", chatResp.Message.Content)
-		return chatResp.Message.Content, nil
-	}
-
-	return "", fmt.Errorf("ollama returned empty follow-up response")
-}
-
 // OpenAIRequest represents a request to OpenAI-compatible APIs (like DeepInfra)
 type OpenAIRequest struct {
 	Model       string          `json:"model"`
 	Messages    []OpenAIMessage `json:"messages"`
 	Temperature float64         `json:"temperature,omitempty"`
+	TopP        float64         `json:"top_p,omitempty"`
 	MaxTokens   int             `json:"max_tokens,omitempty"`
+	Stop        []string        `json:"stop,omitempty"`
 	Stream      bool            `json:"stream"`
+	Tools       []OpenAITool    `json:"tools,omitempty"`
+
+	StreamOptions  *OpenAIStreamOptions  `json:"stream_options,omitempty"`
+	ResponseFormat *OpenAIResponseFormat `json:"response_format,omitempty"`
 }
 
 // OpenAIMessage represents a message in OpenAI format
 type OpenAIMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role       string           `json:"role"`
+	Content    string           `json:"content"`
+	ToolCalls  []OpenAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+
+	// Parts, when set, are sent as array content in place of Content
+	Parts []OpenAIContentPart `json:"-"`
+}
+
+// OpenAIContentPart is a text or image part of array content
+type OpenAIContentPart struct {
+	Type     string          `json:"type"`
+	Text     string          `json:"text,omitempty"`
+	ImageURL *OpenAIImageURL `json:"image_url,omitempty"`
+}
+
+// OpenAIImageURL points an image_url part at an http(s) or data URL
+type OpenAIImageURL struct {
+	URL string `json:"url"`
+}
+
+// MarshalJSON sends Parts as the content array when present
+func (m OpenAIMessage) MarshalJSON() ([]byte, error) {
+	type plain OpenAIMessage
+	if len(m.Parts) == 0 {
+		return json.Marshal(plain(m))
+	}
+	return json.Marshal(struct {
+		plain
+		Content []OpenAIContentPart `json:"content"`
+	}{plain(m), m.Parts})
 }
 
 // OpenAIResponse represents a response from OpenAI-compatible APIs
 type OpenAIResponse struct {
 	Choices []OpenAIChoice `json:"choices"`
+	Usage   *OpenAIUsage   `json:"usage,omitempty"`
+}
+
+// OpenAIUsage represents token usage reported by OpenAI-compatible APIs
+type OpenAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// observeOpenAIUsage records token usage from an OpenAI-compatible response, if present
+func observeOpenAIUsage(ctx context.Context, provider, model string, reported *OpenAIUsage) {
+	if reported != nil {
+		observeTokens(ctx, provider, model, reported.PromptTokens, reported.CompletionTokens)
+	}
 }
 
 // OpenAIChoice represents a choice in the response
 type OpenAIChoice struct {
-	Message OpenAIMessage `json:"message"`
+	Message      OpenAIMessage `json:"message"`
+	FinishReason string        `json:"finish_reason,omitempty"`
 }
 
-// CreateOpenAICompatibleModel creates a model function for OpenAI-compatible APIs like DeepInfra
+// CreateOpenAICompatibleModel creates a model function for OpenAI-compatible APIs like DeepInfra.
+// Responses are streamed to onToken when it is set.
 func CreateOpenAICompatibleModel(apiURL, bearerToken string) mcp.ModelFunc {
-	return func(ctx mcp.ContextInput, req mcp.MCPRequest, memory *mcp.Memory, onToken mcp.StreamCallback) (string, error) {
-		query := fmt.Sprintf("%v", ctx.Inputs["query"])
-
-		// Use provided model or default
-		model := req.Model
-		if model == "" {
-			model = "meta-llama/Meta-Llama-3.1-8B-Instruct" // Default DeepInfra model
-		}
-
-		log.Printf("🔍 CreateOpenAICompatibleModel called with query: %s", query)
-		log.Printf("🔧 API URL: %s", apiURL)
-		log.Printf("🔧 Model: %s", model)
-
-		// Create OpenAI-compatible request
-		payload := OpenAIRequest{
-			Model: model,
-			Messages: []OpenAIMessage{
-				{
-					Role:    "user",
-					Content: query,
-				},
-			},
-			Temperature: req.Temperature,
-			MaxTokens:   1000, // Default max tokens
-			Stream:      false,
-		}
-
-		body, err := json.Marshal(payload)
-		if err != nil {
-			return "", fmt.Errorf("failed to marshal request: %w", err)
-		}
-
-		httpReq, err := http.NewRequestWithContext(context.Background(), "POST", apiURL, bytes.NewReader(body))
-		if err != nil {
-			return "", fmt.Errorf("failed to create request: %w", err)
-		}
-
-		// Set headers for OpenAI-compatible API
-		httpReq.Header.Set("Content-Type", "application/json")
-		httpReq.Header.Set("Authorization", "Bearer "+bearerToken)
-
-		client := &http.Client{Timeout: 30 * time.Second}
-		resp, err := client.Do(httpReq)
-		if err != nil {
-			return "", fmt.Errorf("failed to call API: %w", err)
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			bodyBytes, _ := io.ReadAll(resp.Body)
-			return "", fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(bodyBytes))
-		}
-
-		var result OpenAIResponse
-		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-			return "", fmt.Errorf("failed to decode response: %w", err)
-		}
-
-		if len(result.Choices) == 0 {
-			return "", fmt.Errorf("no choices in response")
-		}
-
-		response := result.Choices[0].Message.Content
-
-		// Call token callback if provided
-		if onToken != nil {
-			onToken(ctx.ContextID, response)
-		}
-
-		log.Printf("✅ OpenAI-compatible API response received: %d characters", len(response))
-		log.Printf("💬 Response content: %s", response) // Log first 100 chars for brevity
-		return response, nil
-	}
+	const defaultModel = "meta-llama/Meta-Llama-3.1-8B-Instruct" // Default DeepInfra model
+	chat := &OpenAIChatModel{
+		Provider:  "openai-compatible",
+		URL:       apiURL,
+		APIKey:    bearerToken,
+		Model:     defaultModel,
+		MaxTokens: 1000,
+		Client:    newModelClient("openai-compatible", httpclient.Options{}, 120*time.Second),
+	}
+	return instrumentModel("openai-compatible", requestModel(defaultModel), mcp.ModelFuncFromChat(chat))
 }
 
 // CreateDeepInfraModel creates a model function specifically for DeepInfra
@@ -760,21 +742,90 @@ func CreateModelFunction(config interface{}) (mcp.ModelFunc, error) {
 // ModelConfig holds configuration for individual models (defined in swarm package)
 // We need to import it to avoid circular dependency, so we'll define a local version
 type ModelConfig struct {
-	Provider    string  `json:"provider"`
-	Model       string  `json:"model"`
-	URL         string  `json:"url,omitempty"`
-	APIKey      string  `json:"api_key,omitempty"`
-	Temperature float64 `json:"temperature"`
-	MaxTokens   int     `json:"max_tokens"`
-	TopK        int     `json:"top_k"`
+	Provider    string  `json:"provider" yaml:"provider"`
+	Model       string  `json:"model" yaml:"model"`
+	URL         string  `json:"url,omitempty" yaml:"url,omitempty"`
+	APIKey      string  `json:"api_key,omitempty" yaml:"api_key,omitempty"`
+	Temperature float64 `json:"temperature" yaml:"temperature"`
+	MaxTokens   int     `json:"max_tokens" yaml:"max_tokens"`
+	TopK        int     `json:"top_k" yaml:"top_k"`
+
+	// MaxToolIterations caps the rounds of native tool calls per request
+	// for providers that run the tool-call loop (default 5)
+	MaxToolIterations int `json:"max_tool_iterations,omitempty" yaml:"max_tool_iterations,omitempty"`
+
+	// SafetySettings are passed through to providers that support them (gemini)
+	SafetySettings []SafetySetting `json:"safety_settings,omitempty" yaml:"safety_settings,omitempty"`
+
+	// HTTP configures timeouts, retries, the circuit breaker and connection
+	// pooling; zero values use the provider's defaults
+	HTTP httpclient.Options `json:"http,omitempty" yaml:"http,omitempty"`
+
+	// ContextWindow overrides the model's known context size in tokens.
+	// ContextStrategy (drop_oldest, keep_last or summarize) decides how
+	// conversation history is cut to fit; keep_last and summarize keep the
+	// last ContextKeepTurns messages verbatim.
+	ContextWindow    int    `json:"context_window,omitempty" yaml:"context_window,omitempty"`
+	ContextStrategy  string `json:"context_strategy,omitempty" yaml:"context_strategy,omitempty"`
+	ContextKeepTurns int    `json:"context_keep_turns,omitempty" yaml:"context_keep_turns,omitempty"`
+
+	// Cache serves repeated calls from a response cache; nil disables it.
+	// Tool-aware models cache each model round, so tools still run.
+	Cache *cache.Config `json:"cache,omitempty" yaml:"cache,omitempty"`
+}
+
+// ContextLimit returns the model's context window in tokens
+func (c *ModelConfig) ContextLimit() int {
+	if c.ContextWindow > 0 {
+		return c.ContextWindow
+	}
+	return tokens.ContextWindow(c.Model)
+}
+
+// ContextBudget returns the prompt tokens left after reserving MaxTokens
+// for the response
+func (c *ModelConfig) ContextBudget() int {
+	return tokens.Budget(c.ContextLimit(), c.MaxTokens)
+}
+
+// ContextTruncation returns the configured history strategy. model is used
+// by the summarize strategy.
+func (c *ModelConfig) ContextTruncation(model mcp.ModelFunc) (tokens.Strategy, error) {
+	return tokens.ParseStrategy(c.ContextStrategy, c.ContextKeepTurns, model)
 }
 
-// CreateModelFunctionFromConfig creates a model function from ModelConfig
+// LogValue implements slog.LogValuer so API keys never reach the logs
+func (c *ModelConfig) LogValue() slog.Value {
+	if c == nil {
+		return slog.Value{}
+	}
+	return slog.GroupValue(
+		slog.String("provider", c.Provider),
+		slog.String("model", c.Model),
+		slog.String("url", c.URL),
+	)
+}
+
+// CreateModelFunctionFromConfig creates a model function from ModelConfig.
+// The returned model does not call tools; see CreateToolAwareModelFromConfig.
 func CreateModelFunctionFromConfig(config *ModelConfig) (mcp.ModelFunc, error) {
 	if config == nil {
 		return nil, fmt.Errorf("model config is nil")
 	}
 
+	c, err := modelCache(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open model cache: %w", err)
+	}
+	fn, err := createModelFunction(config)
+	if err != nil {
+		return nil, err
+	}
+	return c.WrapModel(cacheTarget(strings.ToLower(config.Provider), config), fn), nil
+}
+
+// createModelFunction creates the uncached model function for a provider
+func createModelFunction(config *ModelConfig) (mcp.ModelFunc, error) {
 	switch strings.ToLower(config.Provider) {
 	case "ollama":
 		return CreateOllamaModelWithConfig(config), nil
@@ -782,11 +833,54 @@ func CreateModelFunctionFromConfig(config *ModelConfig) (mcp.ModelFunc, error) {
 		return CreateOpenAIModelWithConfig(config), nil
 	case "deepinfra":
 		return CreateDeepInfraModelWithConfig(config), nil
+	case "anthropic":
+		return CreateAnthropicModelWithConfig(config), nil
+	case "gemini":
+		return CreateGeminiModelWithConfig(config), nil
+	case "openai-compatible":
+		chat, err := NewOpenAIChatModel(config)
+		if err != nil {
+			return nil, err
+		}
+		return instrumentModel(chat.Provider, fixedModel(config.Model), mcp.ModelFuncFromChat(chat)), nil
 	default:
 		return nil, fmt.Errorf("unsupported provider: %s", config.Provider)
 	}
 }
 
+// CreateToolAwareModelFromConfig creates a model function that can call the
+// registered tools natively. Providers without native tool calling fall back
+// to CreateModelFunctionFromConfig. provider may be nil.
+func CreateToolAwareModelFromConfig(config *ModelConfig, tools *mcp.ToolRegistry, provider mcp.EnhancedSchemaProvider) (mcp.ModelFunc, error) {
+	if config == nil {
+		return nil, fmt.Errorf("model config is nil")
+	}
+	if _, err := modelCache(config); err != nil {
+		return nil, fmt.Errorf("failed to open model cache: %w", err)
+	}
+
+	switch strings.ToLower(config.Provider) {
+	case "ollama":
+		return CreateOllamaToolAwareModelWithConfig(config, tools, provider), nil
+	case "openai", "deepinfra", "openai-compatible":
+		return CreateOpenAIToolAwareModel(config, tools, provider)
+	case "anthropic":
+		chat, err := NewAnthropicChatModel(config)
+		if err != nil {
+			return nil, err
+		}
+		return instrumentModel("anthropic", fixedModel(chat.Model), toolLoopModel(cachedChat("anthropic", config, chat), tools, provider, config.MaxToolIterations)), nil
+	case "gemini":
+		chat, err := NewGeminiChatModel(config)
+		if err != nil {
+			return nil, err
+		}
+		return instrumentModel("gemini", fixedModel(chat.Model), toolLoopModel(cachedChat("gemini", config, chat), tools, provider, config.MaxToolIterations)), nil
+	default:
+		return CreateModelFunctionFromConfig(config)
+	}
+}
+
 // CreateOllamaModelWithConfig creates an Ollama model function with configuration
 func CreateOllamaModelWithConfig(config *ModelConfig) mcp.ModelFunc {
 	ollamaURL := config.URL
@@ -794,13 +888,20 @@ func CreateOllamaModelWithConfig(config *ModelConfig) mcp.ModelFunc {
 		ollamaURL = "http://localhost:11434"
 	}
 
-	return func(ctx mcp.ContextInput, req mcp.MCPRequest, memory *mcp.Memory, onToken mcp.StreamCallback) (string, error) {
+	client := newModelClient("ollama", config.HTTP, 300*time.Second)
+	return instrumentModel("ollama", fixedModel(config.Model), func(ctx mcp.ContextInput, req mcp.MCPRequest, memory *mcp.Memory, onToken mcp.StreamCallback) (string, error) {
 		query := fmt.Sprintf("%v", ctx.Inputs["query"])
+		images, err := ollamaInputImages(ctx)
+		if err != nil {
+			return "", err
+		}
 
 		payload := OllamaRequest{
 			Model:  config.Model,
 			Prompt: query,
+			Images: images,
 			Stream: false,
+			Format: mcp.ResponseSchemaInput(ctx),
 		}
 
 		body, err := json.Marshal(payload)
@@ -808,23 +909,18 @@ func CreateOllamaModelWithConfig(config *ModelConfig) mcp.ModelFunc {
 			return "", fmt.Errorf("failed to marshal request: %w", err)
 		}
 
-		httpReq, err := http.NewRequestWithContext(context.Background(), "POST", ollamaURL+"/api/generate", bytes.NewReader(body))
+		httpReq, err := http.NewRequestWithContext(ctx.Context(), "POST", ollamaURL+"/api/generate", bytes.NewReader(body))
 		if err != nil {
 			return "", fmt.Errorf("failed to create request: %w", err)
 		}
 		httpReq.Header.Set("Content-Type", "application/json")
 
-		client := &http.Client{Timeout: 300 * time.Second}
 		resp, err := client.Do(httpReq)
 		if err != nil {
 			return "", fmt.Errorf("failed to call Ollama: %w", err)
 		}
 		defer resp.Body.Close()
 
-		if resp.StatusCode != http.StatusOK {
-			return "", fmt.Errorf("Ollama returned status %d", resp.StatusCode)
-		}
-
 		respBody, err := io.ReadAll(resp.Body)
 		if err != nil {
 			return "", fmt.Errorf("failed to read response: %w", err)
@@ -834,138 +930,27 @@ func CreateOllamaModelWithConfig(config *ModelConfig) mcp.ModelFunc {
 		if err := json.Unmarshal(respBody, &ollamaResp); err != nil {
 			return "", fmt.Errorf("failed to unmarshal response: %w", err)
 		}
+		observeTokens(ctx.Context(), "ollama", modelLabel(config.Model, ""), ollamaResp.PromptEvalCount, ollamaResp.EvalCount)
 
 		return ollamaResp.Response, nil
-	}
+	})
 }
 
 // CreateOpenAIModelWithConfig creates an OpenAI model function with configuration
 func CreateOpenAIModelWithConfig(config *ModelConfig) mcp.ModelFunc {
-	return func(ctx mcp.ContextInput, req mcp.MCPRequest, memory *mcp.Memory, onToken mcp.StreamCallback) (string, error) {
-		query := fmt.Sprintf("%v", ctx.Inputs["query"])
-
-		// OpenAI API implementation
-		payload := map[string]interface{}{
-			"model": config.Model,
-			"messages": []map[string]string{
-				{"role": "user", "content": query},
-			},
-			"temperature": config.Temperature,
-			"max_tokens":  config.MaxTokens,
-		}
-
-		body, err := json.Marshal(payload)
-		if err != nil {
-			return "", fmt.Errorf("failed to marshal OpenAI request: %w", err)
-		}
-
-		httpReq, err := http.NewRequestWithContext(context.Background(), "POST", "https://api.openai.com/v1/chat/completions", bytes.NewReader(body))
-		if err != nil {
-			return "", fmt.Errorf("failed to create OpenAI request: %w", err)
-		}
-		httpReq.Header.Set("Content-Type", "application/json")
-		httpReq.Header.Set("Authorization", "Bearer "+config.APIKey)
-
-		client := &http.Client{Timeout: 60 * time.Second}
-		resp, err := client.Do(httpReq)
-		if err != nil {
-			return "", fmt.Errorf("failed to call OpenAI: %w", err)
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			return "", fmt.Errorf("OpenAI returned status %d", resp.StatusCode)
-		}
-
-		respBody, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return "", fmt.Errorf("failed to read OpenAI response: %w", err)
-		}
-
-		var openaiResp struct {
-			Choices []struct {
-				Message struct {
-					Content string `json:"content"`
-				} `json:"message"`
-			} `json:"choices"`
-		}
-
-		if err := json.Unmarshal(respBody, &openaiResp); err != nil {
-			return "", fmt.Errorf("failed to unmarshal OpenAI response: %w", err)
-		}
-
-		if len(openaiResp.Choices) == 0 {
-			return "", fmt.Errorf("no choices in OpenAI response")
-		}
-
-		return openaiResp.Choices[0].Message.Content, nil
-	}
+	return createOpenAIProviderModel("openai", config)
 }
 
 // CreateDeepInfraModelWithConfig creates a DeepInfra model function with configuration
 func CreateDeepInfraModelWithConfig(config *ModelConfig) mcp.ModelFunc {
-	return func(ctx mcp.ContextInput, req mcp.MCPRequest, memory *mcp.Memory, onToken mcp.StreamCallback) (string, error) {
-		query := fmt.Sprintf("%v", ctx.Inputs["query"])
-
-		// DeepInfra API implementation (similar to OpenAI format)
-		payload := map[string]interface{}{
-			"model": config.Model,
-			"messages": []map[string]string{
-				{"role": "user", "content": query},
-			},
-			"temperature": config.Temperature,
-			"max_tokens":  config.MaxTokens,
-		}
-
-		body, err := json.Marshal(payload)
-		if err != nil {
-			return "", fmt.Errorf("failed to marshal DeepInfra request: %w", err)
-		}
-
-		url := "https://api.deepinfra.com/v1/openai/chat/completions"
-		if config.URL != "" {
-			url = config.URL
-		}
-
-		httpReq, err := http.NewRequestWithContext(context.Background(), "POST", url, bytes.NewReader(body))
-		if err != nil {
-			return "", fmt.Errorf("failed to create DeepInfra request: %w", err)
-		}
-		httpReq.Header.Set("Content-Type", "application/json")
-		httpReq.Header.Set("Authorization", "Bearer "+config.APIKey)
-
-		client := &http.Client{Timeout: 60 * time.Second}
-		resp, err := client.Do(httpReq)
-		if err != nil {
-			return "", fmt.Errorf("failed to call DeepInfra: %w", err)
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			return "", fmt.Errorf("DeepInfra returned status %d", resp.StatusCode)
-		}
-
-		respBody, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return "", fmt.Errorf("failed to read DeepInfra response: %w", err)
-		}
-
-		var deepinfraResp struct {
-			Choices []struct {
-				Message struct {
-					Content string `json:"content"`
-				} `json:"message"`
-			} `json:"choices"`
-		}
-
-		if err := json.Unmarshal(respBody, &deepinfraResp); err != nil {
-			return "", fmt.Errorf("failed to unmarshal DeepInfra response: %w", err)
-		}
-
-		if len(deepinfraResp.Choices) == 0 {
-			return "", fmt.Errorf("no choices in DeepInfra response")
-		}
+	return createOpenAIProviderModel("deepinfra", config)
+}
 
-		return deepinfraResp.Choices[0].Message.Content, nil
-	}
+// createOpenAIProviderModel creates a chat completions model for a hosted
+// provider; the URL defaults to the provider's endpoint
+func createOpenAIProviderModel(provider string, config *ModelConfig) mcp.ModelFunc {
+	providerConfig := *config
+	providerConfig.Provider = provider
+	chat, _ := NewOpenAIChatModel(&providerConfig)
+	return instrumentModel(provider, fixedModel(config.Model), mcp.ModelFuncFromChat(chat))
 }
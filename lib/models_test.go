@@ -0,0 +1,114 @@
+package conduit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/benozo/conduit/lib/httpclient"
+	"github.com/benozo/conduit/lib/usage"
+	"github.com/benozo/conduit/mcp"
+)
+
+// ollamaStub answers /api/chat with chat(n) for the nth call and
+// /api/generate with a fixed prompt-tools reply, recording the models asked for
+func ollamaStub(t *testing.T, chat func(w http.ResponseWriter, n int)) (*httptest.Server, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var models []string
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Model string `json:"model"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		models = append(models, strings.TrimPrefix(r.URL.Path, "/api/")+":"+body.Model)
+		calls++
+		n := calls
+		mu.Unlock()
+
+		if r.URL.Path == "/api/generate" {
+			fmt.Fprint(w, `{"response":"prompted","done":true}`)
+			return
+		}
+		chat(w, n)
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), models...)
+	}
+}
+
+func ollamaCall(model mcp.ModelFunc, name string) (string, error) {
+	input := mcp.ContextInput{Inputs: map[string]interface{}{"query": "echo hi"}}
+	return model(input, mcp.MCPRequest{Model: name}, mcp.NewMemory(), nil)
+}
+
+func TestOllamaToolModelFallback(t *testing.T) {
+	tools := mcp.NewToolRegistry()
+	tools.Register("echo", func(params map[string]interface{}, memory *mcp.Memory) (interface{}, error) {
+		return "hi", nil
+	})
+
+	server, requests := ollamaStub(t, func(w http.ResponseWriter, n int) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error":"registry.ollama.ai/library/gemma:2b does not support tools"}`)
+	})
+	model := CreateOllamaToolAwareModelWithSchemas(server.URL, tools, nil)
+	for _, name := range []string{"gemma:2b", ""} {
+		got, err := ollamaCall(model, name)
+		if err != nil || strings.TrimSpace(got) != "prompted" {
+			t.Fatalf("%q: got %q, %v; want the prompt-based reply", name, got, err)
+		}
+	}
+	want := "chat:gemma:2b generate:gemma:2b chat:llama3.2 generate:llama3.2"
+	if got := strings.Join(requests(), " "); got != want {
+		t.Errorf("requests %s, want %s", got, want)
+	}
+
+	// Once a tool has run, a failed follow-up is returned as is
+	server, requests = ollamaStub(t, func(w http.ResponseWriter, n int) {
+		if n == 1 {
+			fmt.Fprint(w, `{"message":{"role":"assistant","tool_calls":[{"function":{"name":"echo","arguments":{}}}]},"done":true}`)
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error":"context too long"}`)
+	})
+	model = CreateOllamaToolAwareModelWithSchemas(server.URL, tools, nil)
+	if _, err := ollamaCall(model, "llama3.1"); err == nil || !strings.Contains(err.Error(), "context too long") {
+		t.Errorf("error %v, want the second chat call's failure", err)
+	}
+	if got := strings.Join(requests(), " "); got != "chat:llama3.1 chat:llama3.1" {
+		t.Errorf("requests %s, want no prompt-based fallback", got)
+	}
+}
+
+func TestToolsUnsupported(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&httpclient.Error{Provider: "ollama", StatusCode: http.StatusBadRequest}, true},
+		{fmt.Errorf("chat: %w", &httpclient.Error{Provider: "ollama", StatusCode: http.StatusNotFound}), true},
+		{fmt.Errorf("model does not support tools"), true},
+		{&httpclient.Error{Provider: "ollama", StatusCode: http.StatusTooManyRequests}, false},
+		{&httpclient.Error{Provider: "ollama", StatusCode: http.StatusInternalServerError}, false},
+		{&httpclient.Error{Provider: "ollama", Err: httpclient.ErrCircuitOpen}, false},
+		{&usage.BudgetError{}, false},
+		{context.DeadlineExceeded, false},
+	}
+	for _, tt := range tests {
+		if got := toolsUnsupported(tt.err); got != tt.want {
+			t.Errorf("toolsUnsupported(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,456 @@
+package conduit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/benozo/conduit/lib/httpclient"
+	"github.com/benozo/conduit/mcp"
+)
+
+// Chat completions endpoints of the hosted OpenAI-compatible providers
+const (
+	OpenAIChatURL    = "https://api.openai.com/v1/chat/completions"
+	DeepInfraChatURL = "https://api.deepinfra.com/v1/openai/chat/completions"
+)
+
+// OpenAITool describes a function the model may call
+type OpenAITool struct {
+	Type     string            `json:"type"`
+	Function OpenAIFunctionDef `json:"function"`
+}
+
+// OpenAIFunctionDef is the function part of an OpenAITool
+type OpenAIFunctionDef struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Parameters  map[string]interface{} `json:"parameters"`
+}
+
+// OpenAIToolCall is a function call requested by the model
+type OpenAIToolCall struct {
+	ID       string             `json:"id"`
+	Type     string             `json:"type"`
+	Function OpenAIFunctionCall `json:"function"`
+}
+
+// OpenAIFunctionCall holds the function name and its JSON-encoded arguments
+type OpenAIFunctionCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// OpenAIStreamOptions asks for a final usage chunk when streaming
+type OpenAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// OpenAIResponseFormat selects JSON mode. OpenAI validates against a
+// json_schema; other compatible servers get the more widely supported
+// json_object.
+type OpenAIResponseFormat struct {
+	Type       string            `json:"type"`
+	JSONSchema *OpenAIJSONSchema `json:"json_schema,omitempty"`
+}
+
+// OpenAIJSONSchema names the schema of a json_schema response format
+type OpenAIJSONSchema struct {
+	Name   string                 `json:"name"`
+	Schema map[string]interface{} `json:"schema"`
+}
+
+// OpenAIStreamChunk is one server-sent chunk of a streamed chat completion
+type OpenAIStreamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content   string `json:"content"`
+			ToolCalls []struct {
+				Index    int                `json:"index"`
+				ID       string             `json:"id"`
+				Type     string             `json:"type"`
+				Function OpenAIFunctionCall `json:"function"`
+			} `json:"tool_calls"`
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *OpenAIUsage `json:"usage,omitempty"`
+	Error *struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+	} `json:"error,omitempty"`
+}
+
+// OpenAIChatModel is a ChatModel for OpenAI-compatible chat completions APIs
+// (OpenAI, DeepInfra, vLLM, LM Studio, ...)
+type OpenAIChatModel struct {
+	// Provider labels metrics and logs
+	Provider string
+	URL      string
+	APIKey   string
+	// Model, Temperature and MaxTokens are defaults for requests that don't set them
+	Model       string
+	Temperature float64
+	MaxTokens   int
+	Client      *httpclient.Client
+}
+
+// NewOpenAIChatModel creates a chat model from config. The URL defaults to the
+// provider's hosted endpoint; the openai-compatible provider requires one.
+func NewOpenAIChatModel(config *ModelConfig) (*OpenAIChatModel, error) {
+	if config == nil {
+		return nil, fmt.Errorf("model config is nil")
+	}
+
+	provider := strings.ToLower(config.Provider)
+	url := config.URL
+	if url == "" {
+		switch provider {
+		case "openai":
+			url = OpenAIChatURL
+		case "deepinfra":
+			url = DeepInfraChatURL
+		default:
+			return nil, fmt.Errorf("url is required for provider %s", config.Provider)
+		}
+	}
+
+	return &OpenAIChatModel{
+		Provider:    provider,
+		URL:         url,
+		APIKey:      config.APIKey,
+		Model:       config.Model,
+		Temperature: config.Temperature,
+		MaxTokens:   config.MaxTokens,
+		Client:      newModelClient(provider, config.HTTP, 120*time.Second),
+	}, nil
+}
+
+// Chat sends the conversation, with tools when given, and returns the reply.
+// When req.OnToken is set the completion is streamed and content deltas are
+// passed to it as they arrive.
+func (m *OpenAIChatModel) Chat(ctx context.Context, req mcp.ChatRequest) (*mcp.ChatResponse, error) {
+	payload := m.buildRequest(req)
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s request: %w", m.Provider, err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", m.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s request: %w", m.Provider, err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if m.APIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+m.APIKey)
+	}
+	if payload.Stream {
+		httpReq.Header.Set("Accept", "text/event-stream")
+	}
+
+	slog.DebugContext(ctx, "openai chat request", "provider", m.Provider, "model", payload.Model, "messages", len(payload.Messages), "tools", len(payload.Tools), "stream", payload.Stream)
+
+	client := m.Client
+	if client == nil {
+		client = httpclient.New(m.Provider, httpclient.Options{})
+	}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call %s: %w", m.Provider, err)
+	}
+	defer resp.Body.Close()
+
+	var result OpenAIResponse
+	if payload.Stream {
+		result, err = readOpenAIStream(ctx, resp.Body, req.OnToken)
+	} else {
+		err = json.NewDecoder(resp.Body).Decode(&result)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s response: %w", m.Provider, err)
+	}
+	if len(result.Choices) == 0 {
+		return nil, fmt.Errorf("no choices in %s response", m.Provider)
+	}
+	observeOpenAIUsage(ctx, m.Provider, modelLabel(payload.Model, ""), result.Usage)
+
+	choice := result.Choices[0]
+	message := mcp.ChatMessage{Role: mcp.RoleAssistant, Content: choice.Message.Content}
+	for _, call := range choice.Message.ToolCalls {
+		message.ToolCalls = append(message.ToolCalls, mcp.ChatToolCall{
+			ID:        call.ID,
+			Name:      call.Function.Name,
+			Arguments: decodeToolArguments(ctx, call.Function.Name, call.Function.Arguments),
+		})
+	}
+
+	response := &mcp.ChatResponse{
+		Model:      payload.Model,
+		Message:    message,
+		StopReason: openAIStopReason(choice.FinishReason, len(message.ToolCalls) > 0),
+	}
+	if result.Usage != nil {
+		response.Usage = mcp.Usage{
+			PromptTokens:     result.Usage.PromptTokens,
+			CompletionTokens: result.Usage.CompletionTokens,
+			TotalTokens:      result.Usage.TotalTokens,
+		}
+	}
+	return response, nil
+}
+
+// buildRequest converts a ChatRequest to the wire format, filling in defaults
+func (m *OpenAIChatModel) buildRequest(req mcp.ChatRequest) OpenAIRequest {
+	payload := OpenAIRequest{
+		Model:       req.Model,
+		Temperature: req.Temperature,
+		TopP:        req.TopP,
+		MaxTokens:   req.MaxTokens,
+		Stop:        req.Stop,
+		Stream:      req.OnToken != nil,
+	}
+	if payload.Stream {
+		payload.StreamOptions = &OpenAIStreamOptions{IncludeUsage: true}
+	}
+	if payload.Model == "" {
+		payload.Model = m.Model
+	}
+	if payload.Temperature == 0 {
+		payload.Temperature = m.Temperature
+	}
+	if payload.MaxTokens == 0 {
+		payload.MaxTokens = m.MaxTokens
+	}
+	if req.ResponseSchema != nil {
+		if m.Provider == "openai" {
+			payload.ResponseFormat = &OpenAIResponseFormat{
+				Type:       "json_schema",
+				JSONSchema: &OpenAIJSONSchema{Name: "response", Schema: req.ResponseSchema},
+			}
+		} else {
+			payload.ResponseFormat = &OpenAIResponseFormat{Type: "json_object"}
+		}
+	}
+
+	// Tool messages only carry text, so images returned by tools follow
+	// the group of tool messages as a user message
+	var toolImages []mcp.Image
+	flushToolImages := func() {
+		if len(toolImages) > 0 {
+			payload.Messages = append(payload.Messages, OpenAIMessage{
+				Role:  mcp.RoleUser,
+				Parts: openAIContentParts("Images returned by the tools:", toolImages),
+			})
+			toolImages = nil
+		}
+	}
+	for _, msg := range req.Messages {
+		if msg.Role == mcp.RoleTool {
+			toolImages = append(toolImages, msg.Images...)
+		} else {
+			flushToolImages()
+		}
+		wire := OpenAIMessage{
+			Role:       msg.Role,
+			Content:    msg.Content,
+			ToolCallID: msg.ToolCallID,
+		}
+		if msg.Role != mcp.RoleTool && len(msg.Images) > 0 {
+			wire.Parts = openAIContentParts(msg.Content, msg.Images)
+		}
+		for _, call := range msg.ToolCalls {
+			args, _ := json.Marshal(call.Arguments)
+			wire.ToolCalls = append(wire.ToolCalls, OpenAIToolCall{
+				ID:       call.ID,
+				Type:     "function",
+				Function: OpenAIFunctionCall{Name: call.Name, Arguments: string(args)},
+			})
+		}
+		payload.Messages = append(payload.Messages, wire)
+	}
+	flushToolImages()
+
+	for _, tool := range req.Tools {
+		payload.Tools = append(payload.Tools, OpenAITool{
+			Type: "function",
+			Function: OpenAIFunctionDef{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
+			},
+		})
+	}
+	return payload
+}
+
+// openAIContentParts builds array content: the text followed by the images
+// as image_url parts, inline images as data URLs
+func openAIContentParts(text string, images []mcp.Image) []OpenAIContentPart {
+	var parts []OpenAIContentPart
+	if text != "" {
+		parts = append(parts, OpenAIContentPart{Type: "text", Text: text})
+	}
+	for _, img := range images {
+		parts = append(parts, OpenAIContentPart{Type: "image_url", ImageURL: &OpenAIImageURL{URL: img.DataURL()}})
+	}
+	return parts
+}
+
+// readOpenAIStream assembles a response from server-sent chunks, passing
+// content deltas to onToken. Tool calls arrive in fragments keyed by index
+// and are joined back together. The stream ends at [DONE].
+func readOpenAIStream(ctx context.Context, body io.Reader, onToken func(string)) (OpenAIResponse, error) {
+	var content strings.Builder
+	var calls []OpenAIToolCall
+	var finishReason string
+	var usage *OpenAIUsage
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	done := false
+	for !done && scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return OpenAIResponse{}, err
+		}
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			done = true
+			continue
+		}
+
+		var chunk OpenAIStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return OpenAIResponse{}, fmt.Errorf("invalid stream chunk: %w", err)
+		}
+		if chunk.Error != nil {
+			return OpenAIResponse{}, fmt.Errorf("stream error: %s", chunk.Error.Message)
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+
+		choice := chunk.Choices[0]
+		if choice.FinishReason != "" {
+			finishReason = choice.FinishReason
+		}
+		if choice.Delta.Content != "" {
+			content.WriteString(choice.Delta.Content)
+			if onToken != nil {
+				onToken(choice.Delta.Content)
+			}
+		}
+		for _, fragment := range choice.Delta.ToolCalls {
+			for len(calls) <= fragment.Index {
+				calls = append(calls, OpenAIToolCall{Type: "function"})
+			}
+			call := &calls[fragment.Index]
+			if fragment.ID != "" {
+				call.ID = fragment.ID
+			}
+			call.Function.Name += fragment.Function.Name
+			call.Function.Arguments += fragment.Function.Arguments
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return OpenAIResponse{}, ctxErr
+		}
+		return OpenAIResponse{}, err
+	}
+	if !done && finishReason == "" {
+		return OpenAIResponse{}, fmt.Errorf("stream ended before completion")
+	}
+
+	return OpenAIResponse{
+		Choices: []OpenAIChoice{{
+			Message:      OpenAIMessage{Role: "assistant", Content: content.String(), ToolCalls: calls},
+			FinishReason: finishReason,
+		}},
+		Usage: usage,
+	}, nil
+}
+
+// decodeToolArguments parses the JSON arguments of a tool call. Malformed
+// arguments are logged and passed on empty so the tool can report what's missing.
+func decodeToolArguments(ctx context.Context, tool, raw string) map[string]interface{} {
+	args := map[string]interface{}{}
+	if strings.TrimSpace(raw) == "" {
+		return args
+	}
+	if err := json.Unmarshal([]byte(raw), &args); err != nil {
+		slog.WarnContext(ctx, "model sent malformed tool arguments", "tool", tool, "error", err)
+		return map[string]interface{}{}
+	}
+	return args
+}
+
+// openAIStopReason maps finish_reason onto the mcp stop reasons
+func openAIStopReason(finishReason string, hasToolCalls bool) string {
+	switch finishReason {
+	case "tool_calls", "function_call":
+		return mcp.StopReasonToolCalls
+	case "length":
+		return mcp.StopReasonLength
+	}
+	if hasToolCalls {
+		return mcp.StopReasonToolCalls
+	}
+	return mcp.StopReasonEnd
+}
+
+// CreateOpenAIToolAwareModel creates a model function for OpenAI-compatible
+// APIs that offers every registered tool to the model, described by the
+// provider's schemas, and runs the tool-call loop. provider may be nil.
+func CreateOpenAIToolAwareModel(config *ModelConfig, tools *mcp.ToolRegistry, provider mcp.EnhancedSchemaProvider) (mcp.ModelFunc, error) {
+	chat, err := NewOpenAIChatModel(config)
+	if err != nil {
+		return nil, err
+	}
+	return instrumentModel(chat.Provider, fixedModel(config.Model), toolLoopModel(cachedChat(chat.Provider, config, chat), tools, provider, config.MaxToolIterations)), nil
+}
+
+// toolLoopModel adapts a ChatModel into a ModelFunc that runs the tool-call
+// loop. Tool definitions are rebuilt per call so hot-reloaded tools show up.
+func toolLoopModel(chat mcp.ChatModel, tools *mcp.ToolRegistry, provider mcp.EnhancedSchemaProvider, maxIterations int) mcp.ModelFunc {
+	return func(ctx mcp.ContextInput, req mcp.MCPRequest, memory *mcp.Memory, onToken mcp.StreamCallback) (string, error) {
+		messages, err := mcp.InputMessages(ctx)
+		if err != nil {
+			return "", err
+		}
+
+		chatReq := mcp.ChatRequest{
+			Messages:       messages,
+			Tools:          mcp.ToolDefinitions(tools, provider),
+			Temperature:    req.Temperature,
+			TopK:           req.TopK,
+			ResponseSchema: mcp.ResponseSchemaInput(ctx),
+		}
+		if onToken != nil {
+			chatReq.OnToken = func(token string) { onToken(ctx.ContextID, token) }
+		}
+
+		resp, _, err := mcp.RunToolLoop(ctx.Context(), chat, chatReq, mcp.ToolLoopOptions{
+			Tools:         tools,
+			Memory:        memory,
+			MaxIterations: maxIterations,
+		})
+		if err != nil {
+			return "", err
+		}
+		return resp.Message.Content, nil
+	}
+}
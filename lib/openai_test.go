@@ -0,0 +1,156 @@
+package conduit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/benozo/conduit/mcp"
+)
+
+// openAIStub streams the given SSE lines and records the last request
+func openAIStub(t *testing.T, lines ...string) (*httptest.Server, *OpenAIRequest) {
+	t.Helper()
+	var got OpenAIRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("invalid request body: %v", err)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, line := range lines {
+			fmt.Fprintf(w, "%s\n\n", line)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &got
+}
+
+func TestOpenAIStreamToolCalls(t *testing.T) {
+	server, got := openAIStub(t,
+		`: keep-alive`,
+		`data: {"choices":[{"delta":{"content":"Checking"}}]}`,
+		`data: {"choices":[{"delta":{"content":" both."}}]}`,
+		`data: {"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_a","type":"function","function":{"name":"word_","arguments":""}}]}}]}`,
+		`data: {"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"name":"count","arguments":"{\"text\":"}}]}}]}`,
+		`data: {"choices":[{"delta":{"tool_calls":[{"index":1,"id":"call_b","function":{"name":"uppercase","arguments":"{\"text\": \"x\"}"}}]}}]}`,
+		`data: {"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":" \"a b\"}"}}]}}]}`,
+		`data: {"choices":[{"delta":{},"finish_reason":"tool_calls"}]}`,
+		`data: {"choices":[],"usage":{"prompt_tokens":8,"completion_tokens":6,"total_tokens":14}}`,
+		`data: [DONE]`,
+	)
+
+	model, err := NewOpenAIChatModel(&ModelConfig{Provider: "openai", URL: server.URL, Model: "gpt-test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tokens []string
+	resp, err := model.Chat(context.Background(), mcp.ChatRequest{
+		Messages: []mcp.ChatMessage{{Role: mcp.RoleUser, Content: "count and shout"}},
+		OnToken:  func(token string) { tokens = append(tokens, token) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !got.Stream || got.StreamOptions == nil || !got.StreamOptions.IncludeUsage {
+		t.Errorf("request stream=%v options=%+v", got.Stream, got.StreamOptions)
+	}
+	if !reflect.DeepEqual(tokens, []string{"Checking", " both."}) || resp.Message.Content != "Checking both." {
+		t.Errorf("tokens %v, content %q", tokens, resp.Message.Content)
+	}
+	want := []mcp.ChatToolCall{
+		{ID: "call_a", Name: "word_count", Arguments: map[string]interface{}{"text": "a b"}},
+		{ID: "call_b", Name: "uppercase", Arguments: map[string]interface{}{"text": "x"}},
+	}
+	if !reflect.DeepEqual(resp.Message.ToolCalls, want) {
+		t.Errorf("tool calls %+v", resp.Message.ToolCalls)
+	}
+	if resp.StopReason != mcp.StopReasonToolCalls || resp.Usage.TotalTokens != 14 {
+		t.Errorf("stop %q, usage %+v", resp.StopReason, resp.Usage)
+	}
+}
+
+func TestOpenAIStreamErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		want  string
+	}{
+		{"mid-stream error", []string{
+			`data: {"choices":[{"delta":{"content":"par"}}]}`,
+			`data: {"error":{"message":"upstream overloaded","type":"server_error"}}`,
+		}, "stream error: upstream overloaded"},
+		{"truncated", []string{
+			`data: {"choices":[{"delta":{"content":"par"}}]}`,
+		}, "stream ended before completion"},
+		{"malformed chunk", []string{
+			`data: {"choices":`,
+		}, "invalid stream chunk"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, _ := openAIStub(t, tt.lines...)
+			model, _ := NewOpenAIChatModel(&ModelConfig{Provider: "openai-compatible", URL: server.URL})
+			_, err := model.Chat(context.Background(), mcp.ChatRequest{
+				Messages: []mcp.ChatMessage{{Role: mcp.RoleUser, Content: "hi"}},
+				OnToken:  func(string) {},
+			})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenAIStreamCancel(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"first\"}}]}\n\n")
+		w.(http.Flusher).Flush()
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	model, _ := NewOpenAIChatModel(&ModelConfig{Provider: "openai-compatible", URL: server.URL})
+	ctx, cancel := context.WithCancel(context.Background())
+	_, err := model.Chat(ctx, mcp.ChatRequest{
+		Messages: []mcp.ChatMessage{{Role: mcp.RoleUser, Content: "hi"}},
+		// Cancel once the first token has arrived, while the body is still open
+		OnToken: func(string) { cancel() },
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("error %v, want context.Canceled", err)
+	}
+}
+
+func TestOpenAIModelFuncStreams(t *testing.T) {
+	server, got := openAIStub(t,
+		`data: {"choices":[{"delta":{"content":"po"}}]}`,
+		`data: {"choices":[{"delta":{"content":"ng"},"finish_reason":"stop"}]}`,
+		`data: [DONE]`,
+	)
+
+	model := CreateOpenAIModelWithConfig(&ModelConfig{Provider: "openai", URL: server.URL, Model: "gpt-test"})
+	var tokens []string
+	input := mcp.ContextInput{ContextID: "ctx-1", Inputs: map[string]interface{}{"query": "ping"}}
+	text, err := model(input, mcp.MCPRequest{}, mcp.NewMemory(), func(contextID, token string) {
+		tokens = append(tokens, contextID+":"+token)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "pong" || !reflect.DeepEqual(tokens, []string{"ctx-1:po", "ctx-1:ng"}) || !got.Stream {
+		t.Errorf("text %q, tokens %v, stream %v", text, tokens, got.Stream)
+	}
+}
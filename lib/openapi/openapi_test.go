@@ -0,0 +1,268 @@
+package openapi
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+const petstore = `
+openapi: 3.0.3
+info:
+  title: Petstore
+servers:
+  - url: /api
+paths:
+  /pets:
+    get:
+      operationId: listPets
+      summary: List pets
+      parameters:
+        - {name: tag, in: query, schema: {type: array, items: {type: string}}}
+        - {name: limit, in: query, schema: {type: integer}}
+        - {name: session, in: cookie}
+    post:
+      operationId: createPet
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema: {$ref: '#/components/schemas/NewPet'}
+  /pets/{id}:
+    parameters:
+      - {name: id, in: path, description: Pet ID, schema: {type: string}}
+    get:
+      operationId: getPet
+      parameters:
+        - {name: X-Trace, in: header}
+    put:
+      description: Replace a pet
+      requestBody:
+        content:
+          application/json:
+            schema:
+              type: object
+              properties:
+                id: {type: string}
+                name: {type: string}
+  /blob:
+    get:
+      operationId: getBlob
+components:
+  schemas:
+    NewPet:
+      type: object
+      required: [name]
+      properties:
+        name: {type: string}
+        owner: {$ref: '#/components/schemas/Owner'}
+    Owner:
+      type: object
+      properties:
+        email: {type: string}
+`
+
+// petServer serves the document at /openapi.yaml and echoes API requests
+func petServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/openapi.yaml":
+			io.WriteString(w, petstore)
+		case r.URL.Path == "/api/blob":
+			io.WriteString(w, strings.Repeat("x", 100))
+		case r.URL.Path == "/api/pets/missing":
+			http.Error(w, `{"error": "not found"}`, http.StatusNotFound)
+		case strings.HasPrefix(r.URL.Path, "/api/"):
+			body, _ := io.ReadAll(r.Body)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"method": r.Method,
+				"path":   r.URL.EscapedPath(),
+				"query":  r.URL.RawQuery,
+				"auth":   r.Header.Get("Authorization"),
+				"trace":  r.Header.Get("X-Trace"),
+				"body":   string(body),
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func loadTools(t *testing.T, srv *httptest.Server, opts Options) map[string]Tool {
+	t.Helper()
+	spec, err := Load(context.Background(), srv.URL+"/openapi.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.Title != "Petstore" || !reflect.DeepEqual(spec.Servers, []string{srv.URL + "/api"}) {
+		t.Fatalf("spec %+v", spec)
+	}
+	tools, err := spec.Tools(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := make(map[string]Tool, len(tools))
+	for _, tool := range tools {
+		out[tool.Metadata.Name] = tool
+	}
+	return out
+}
+
+func TestToolSchemas(t *testing.T) {
+	tools := loadTools(t, petServer(t), Options{Prefix: "pets_"})
+
+	var names []string
+	for name := range tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if want := []string{"pets_createPet", "pets_getBlob", "pets_getPet", "pets_listPets", "pets_put_pets_id"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("tools %v, want %v", names, want)
+	}
+
+	tests := []struct {
+		tool        string
+		description string
+		properties  []string
+		required    []string
+	}{
+		{"pets_listPets", "List pets (GET /pets)", []string{"limit", "tag"}, []string{}},
+		{"pets_createPet", "POST /pets", []string{"name", "owner"}, []string{"name"}},
+		{"pets_getPet", "GET /pets/{id}", []string{"X-Trace", "id"}, []string{"id"}},
+		// The body's id clashes with the path parameter, so the body is one argument
+		{"pets_put_pets_id", "Replace a pet (PUT /pets/{id})", []string{"body", "id"}, []string{"id"}},
+	}
+	for _, tt := range tests {
+		meta := tools[tt.tool].Metadata
+		schema := meta.InputSchema
+		var props []string
+		for name := range schema["properties"].(map[string]interface{}) {
+			props = append(props, name)
+		}
+		sort.Strings(props)
+		if meta.Description != tt.description || !reflect.DeepEqual(props, tt.properties) {
+			t.Errorf("%s: description %q properties %v", tt.tool, meta.Description, props)
+		}
+		if got := schema["required"]; !reflect.DeepEqual(got, tt.required) {
+			t.Errorf("%s: required %v, want %v", tt.tool, got, tt.required)
+		}
+	}
+
+	owner := tools["pets_createPet"].Metadata.InputSchema["properties"].(map[string]interface{})["owner"]
+	if want := map[string]interface{}{"type": "object", "properties": map[string]interface{}{"email": map[string]interface{}{"type": "string"}}}; !reflect.DeepEqual(owner, want) {
+		t.Errorf("$ref not inlined: %v", owner)
+	}
+}
+
+func TestToolCalls(t *testing.T) {
+	srv := petServer(t)
+	t.Setenv("PETS_TOKEN", "secret")
+	tools := loadTools(t, srv, Options{
+		Headers: map[string]string{"Authorization": "Bearer ${PETS_TOKEN}"},
+	})
+
+	tests := []struct {
+		tool    string
+		params  map[string]interface{}
+		want    map[string]interface{}
+		wantErr string
+	}{
+		{
+			tool:   "listPets",
+			params: map[string]interface{}{"tag": []interface{}{"a", "b"}, "limit": float64(10)},
+			want:   map[string]interface{}{"method": "GET", "query": "limit=10&tag=a&tag=b", "auth": "Bearer secret"},
+		},
+		{
+			tool:   "getPet",
+			params: map[string]interface{}{"id": "a/b c", "X-Trace": "t1"},
+			want:   map[string]interface{}{"path": "/api/pets/a%2Fb%20c", "trace": "t1"},
+		},
+		{
+			tool:   "createPet",
+			params: map[string]interface{}{"name": "Rex", "unrelated": true},
+			want:   map[string]interface{}{"method": "POST", "body": `{"name":"Rex"}`},
+		},
+		{
+			tool:   "put_pets_id",
+			params: map[string]interface{}{"id": "7", "body": map[string]interface{}{"id": "7", "name": "Rex"}},
+			want:   map[string]interface{}{"path": "/api/pets/7", "body": `{"id":"7","name":"Rex"}`},
+		},
+		{tool: "getPet", params: map[string]interface{}{}, wantErr: "missing required parameter: id"},
+		{tool: "createPet", params: map[string]interface{}{}, wantErr: "missing request body"},
+		{tool: "getPet", params: map[string]interface{}{"id": "missing"}, wantErr: "returned 404 Not Found"},
+	}
+	for _, tt := range tests {
+		result, err := tools[tt.tool].Func(tt.params, nil)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: got %v, %v; want error %q", tt.tool, result, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.tool, err)
+			continue
+		}
+		r := result.(map[string]interface{})
+		if r["status"] != http.StatusOK {
+			t.Errorf("%s: status %v", tt.tool, r["status"])
+		}
+		echo := r["body"].(map[string]interface{})
+		for k, v := range tt.want {
+			if echo[k] != v {
+				t.Errorf("%s: %s = %v, want %v", tt.tool, k, echo[k], v)
+			}
+		}
+	}
+
+	small := loadTools(t, srv, Options{MaxResponseBytes: 64})
+	result, err := small["getBlob"].Func(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := result.(map[string]interface{}); r["truncated"] != true || r["body"] != strings.Repeat("x", 64) {
+		t.Errorf("blob result %v", r)
+	}
+}
+
+func TestOperationAllowlist(t *testing.T) {
+	tools := loadTools(t, petServer(t), Options{Operations: []string{"getPet", "post /pets"}})
+	if len(tools) != 2 || tools["getPet"].Func == nil || tools["createPet"].Func == nil {
+		t.Errorf("allowlisted tools %v", tools)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		opts Options
+		want string
+	}{
+		{"swagger 2", "swagger: '2.0'\n", Options{}, "unsupported OpenAPI version"},
+		{"no servers", "openapi: 3.0.0\npaths: {}\n", Options{}, "no base URL"},
+		{"external ref", "openapi: 3.0.0\npaths:\n  /a:\n    get:\n      parameters:\n        - $ref: 'other.yaml#/p'\n",
+			Options{BaseURL: "http://x"}, "unsupported external reference"},
+		{"dangling ref", "openapi: 3.0.0\npaths:\n  /a:\n    $ref: '#/missing'\n", Options{BaseURL: "http://x"}, "unresolved reference"},
+		{"duplicate names", "openapi: 3.0.0\npaths:\n  /a:\n    get: {operationId: same}\n  /b:\n    get: {operationId: same}\n",
+			Options{BaseURL: "http://x"}, "duplicate tool name same"},
+	}
+	for _, tt := range tests {
+		spec, err := Parse([]byte(tt.doc), "spec.yaml")
+		if err == nil {
+			_, err = spec.Tools(tt.opts)
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,324 @@
+// Package openapi registers the operations of an OpenAPI 3 document as Conduit tools.
+package openapi
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// maxSpecBytes bounds the size of a downloaded document
+const maxSpecBytes = 10 << 20
+
+// httpMethods are the operation keys of a path item, in output order
+var httpMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// Spec is a parsed OpenAPI 3 document
+type Spec struct {
+	// Location is the file path or URL the document was loaded from
+	Location string
+	Title    string
+	Servers  []string
+
+	doc map[string]interface{}
+}
+
+// Operation is one method on one path, with parameters and body schema resolved
+type Operation struct {
+	ID          string
+	Method      string
+	Path        string
+	Summary     string
+	Description string
+	Parameters  []Parameter
+	// BodySchema is the application/json request body schema, if any
+	BodySchema   map[string]interface{}
+	BodyRequired bool
+}
+
+// Parameter is a path, query or header parameter
+type Parameter struct {
+	Name        string
+	In          string
+	Description string
+	Required    bool
+	Schema      map[string]interface{}
+}
+
+// Load reads an OpenAPI document in YAML or JSON from a file path or an http(s) URL
+func Load(ctx context.Context, location string) (*Spec, error) {
+	var data []byte
+	var err error
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		data, err = fetch(ctx, location)
+	} else {
+		data, err = os.ReadFile(location)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read OpenAPI document: %w", err)
+	}
+	return Parse(data, location)
+}
+
+// Parse parses an OpenAPI document. location is used to resolve relative server URLs.
+func Parse(data []byte, location string) (*Spec, error) {
+	var doc map[string]interface{}
+	if err := yaml.NewDecoder(bytes.NewReader(data)).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to parse OpenAPI document: %w", err)
+	}
+
+	version, _ := doc["openapi"].(string)
+	if !strings.HasPrefix(version, "3.") {
+		return nil, fmt.Errorf("unsupported OpenAPI version %q (only 3.x is supported)", version)
+	}
+
+	spec := &Spec{Location: location, doc: doc}
+	if info, ok := doc["info"].(map[string]interface{}); ok {
+		spec.Title, _ = info["title"].(string)
+	}
+	for _, s := range asSlice(doc["servers"]) {
+		if server, ok := s.(map[string]interface{}); ok {
+			if u, ok := server["url"].(string); ok {
+				spec.Servers = append(spec.Servers, resolveServerURL(location, u))
+			}
+		}
+	}
+	return spec, nil
+}
+
+// Operations returns every operation in the document, sorted by path and method
+func (s *Spec) Operations() ([]Operation, error) {
+	paths, _ := s.doc["paths"].(map[string]interface{})
+	pathNames := make([]string, 0, len(paths))
+	for p := range paths {
+		pathNames = append(pathNames, p)
+	}
+	sort.Strings(pathNames)
+
+	var ops []Operation
+	for _, path := range pathNames {
+		item, err := s.resolveMap(paths[path])
+		if err != nil {
+			return nil, fmt.Errorf("path %s: %w", path, err)
+		}
+		shared := asSlice(item["parameters"])
+
+		for _, method := range httpMethods {
+			raw, ok := item[method].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			op, err := s.operation(strings.ToUpper(method), path, raw, shared)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %w", strings.ToUpper(method), path, err)
+			}
+			ops = append(ops, op)
+		}
+	}
+	return ops, nil
+}
+
+func (s *Spec) operation(method, path string, raw map[string]interface{}, shared []interface{}) (Operation, error) {
+	op := Operation{Method: method, Path: path}
+	op.ID, _ = raw["operationId"].(string)
+	op.Summary, _ = raw["summary"].(string)
+	op.Description, _ = raw["description"].(string)
+
+	// Operation-level parameters override path-level ones with the same name and location
+	byKey := make(map[string]int)
+	for _, list := range [][]interface{}{shared, asSlice(raw["parameters"])} {
+		for _, p := range list {
+			param, err := s.parameter(p)
+			if err != nil {
+				return op, err
+			}
+			if param.In == "cookie" {
+				continue
+			}
+			key := param.In + ":" + param.Name
+			if i, ok := byKey[key]; ok {
+				op.Parameters[i] = param
+				continue
+			}
+			byKey[key] = len(op.Parameters)
+			op.Parameters = append(op.Parameters, param)
+		}
+	}
+
+	if rawBody, ok := raw["requestBody"]; ok {
+		body, err := s.resolveMap(rawBody)
+		if err != nil {
+			return op, fmt.Errorf("request body: %w", err)
+		}
+		op.BodyRequired, _ = body["required"].(bool)
+		content, _ := body["content"].(map[string]interface{})
+		for mediaType, m := range content {
+			if !strings.Contains(mediaType, "json") {
+				continue
+			}
+			media, _ := m.(map[string]interface{})
+			schema, err := s.expandSchema(media["schema"], 0)
+			if err != nil {
+				return op, fmt.Errorf("request body schema: %w", err)
+			}
+			op.BodySchema, _ = schema.(map[string]interface{})
+			break
+		}
+	}
+	return op, nil
+}
+
+func (s *Spec) parameter(raw interface{}) (Parameter, error) {
+	m, err := s.resolveMap(raw)
+	if err != nil {
+		return Parameter{}, fmt.Errorf("parameter: %w", err)
+	}
+
+	param := Parameter{}
+	param.Name, _ = m["name"].(string)
+	param.In, _ = m["in"].(string)
+	param.Description, _ = m["description"].(string)
+	param.Required, _ = m["required"].(bool)
+	if param.Name == "" || param.In == "" {
+		return param, fmt.Errorf("parameter is missing name or in")
+	}
+	if param.In == "path" {
+		param.Required = true
+	}
+
+	schema, err := s.expandSchema(m["schema"], 0)
+	if err != nil {
+		return param, fmt.Errorf("parameter %s: %w", param.Name, err)
+	}
+	param.Schema, _ = schema.(map[string]interface{})
+	if param.Schema == nil {
+		param.Schema = map[string]interface{}{"type": "string"}
+	}
+	return param, nil
+}
+
+// maxRefDepth stops expansion of recursive schemas
+const maxRefDepth = 16
+
+// expandSchema returns a copy of a schema with local $refs inlined
+func (s *Spec) expandSchema(raw interface{}, depth int) (interface{}, error) {
+	if depth > maxRefDepth {
+		// Recursive schemas are cut off rather than rejected
+		return map[string]interface{}{}, nil
+	}
+
+	switch v := raw.(type) {
+	case map[string]interface{}:
+		if ref, ok := v["$ref"].(string); ok {
+			target, err := s.lookup(ref)
+			if err != nil {
+				return nil, err
+			}
+			return s.expandSchema(target, depth+1)
+		}
+		out := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			expanded, err := s.expandSchema(value, depth)
+			if err != nil {
+				return nil, err
+			}
+			out[key] = expanded
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, value := range v {
+			expanded, err := s.expandSchema(value, depth)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = expanded
+		}
+		return out, nil
+	default:
+		return v, nil
+	}
+}
+
+// resolveMap follows a $ref, if present, and returns the object it points to
+func (s *Spec) resolveMap(raw interface{}) (map[string]interface{}, error) {
+	for i := 0; i < maxRefDepth; i++ {
+		m, ok := raw.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected an object")
+		}
+		ref, ok := m["$ref"].(string)
+		if !ok {
+			return m, nil
+		}
+		target, err := s.lookup(ref)
+		if err != nil {
+			return nil, err
+		}
+		raw = target
+	}
+	return nil, fmt.Errorf("too many nested references")
+}
+
+// lookup resolves a local JSON pointer such as #/components/schemas/Pet
+func (s *Spec) lookup(ref string) (interface{}, error) {
+	if !strings.HasPrefix(ref, "#/") {
+		return nil, fmt.Errorf("unsupported external reference %q", ref)
+	}
+
+	var current interface{} = s.doc
+	for _, token := range strings.Split(ref[2:], "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unresolved reference %q", ref)
+		}
+		if current, ok = m[token]; !ok {
+			return nil, fmt.Errorf("unresolved reference %q", ref)
+		}
+	}
+	return current, nil
+}
+
+func fetch(ctx context.Context, location string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s returned %s", location, resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxSpecBytes))
+}
+
+// resolveServerURL makes a relative server URL absolute against the document URL
+func resolveServerURL(location, server string) string {
+	base, err := url.Parse(location)
+	if err != nil || base.Scheme == "" {
+		return server
+	}
+	ref, err := url.Parse(server)
+	if err != nil {
+		return server
+	}
+	return base.ResolveReference(ref).String()
+}
+
+func asSlice(v interface{}) []interface{} {
+	s, _ := v.([]interface{})
+	return s
+}
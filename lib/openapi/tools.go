@@ -0,0 +1,383 @@
+package openapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	conduit "github.com/benozo/conduit/lib"
+	"github.com/benozo/conduit/mcp"
+)
+
+// DefaultMaxResponseBytes truncates response bodies returned to the model
+const DefaultMaxResponseBytes = 16 << 10
+
+// Options controls how operations become tools
+type Options struct {
+	// BaseURL overrides the document's first server URL
+	BaseURL string
+	// Headers are added to every request, e.g. Authorization. Values are
+	// expanded with os.ExpandEnv so secrets can come from the environment.
+	Headers map[string]string
+	// Operations is an allowlist of operation IDs or "METHOD /path" entries;
+	// empty means all operations
+	Operations []string
+	// Prefix is prepended to every tool name
+	Prefix string
+	// MaxResponseBytes truncates response bodies (default 16 KiB)
+	MaxResponseBytes int
+	// Timeout bounds each request (default 30s)
+	Timeout time.Duration
+	// HTTPClient defaults to a client with Timeout
+	HTTPClient *http.Client
+}
+
+// SchemaRegistrar is implemented by conduit.EnhancedServer
+type SchemaRegistrar interface {
+	RegisterToolWithSchema(name string, tool mcp.ToolFunc, metadata conduit.ToolMetadata)
+}
+
+// Tool is an operation converted to a Conduit tool
+type Tool struct {
+	Metadata  conduit.ToolMetadata
+	Func      mcp.ToolFunc
+	Operation Operation
+}
+
+// LoadAndRegister loads a document and registers its operations, returning the tool names
+func LoadAndRegister(ctx context.Context, server SchemaRegistrar, location string, opts Options) ([]string, error) {
+	spec, err := Load(ctx, location)
+	if err != nil {
+		return nil, err
+	}
+	return Register(server, spec, opts)
+}
+
+// Register adds a tool for every allowed operation of spec
+func Register(server SchemaRegistrar, spec *Spec, opts Options) ([]string, error) {
+	tools, err := spec.Tools(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(tools))
+	for _, tool := range tools {
+		server.RegisterToolWithSchema(tool.Metadata.Name, tool.Func, tool.Metadata)
+		names = append(names, tool.Metadata.Name)
+	}
+	slog.Debug("registered OpenAPI tools", "spec", spec.Location, "count", len(names))
+	return names, nil
+}
+
+// Tools converts the allowed operations into tools
+func (s *Spec) Tools(opts Options) ([]Tool, error) {
+	baseURL := opts.BaseURL
+	if baseURL == "" && len(s.Servers) > 0 {
+		baseURL = s.Servers[0]
+	}
+	if baseURL == "" {
+		return nil, fmt.Errorf("no base URL: the document has no servers and Options.BaseURL is empty")
+	}
+	if opts.MaxResponseBytes <= 0 {
+		opts.MaxResponseBytes = DefaultMaxResponseBytes
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 30 * time.Second
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = &http.Client{Timeout: opts.Timeout}
+	}
+
+	ops, err := s.Operations()
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := make(map[string]bool, len(opts.Operations))
+	for _, name := range opts.Operations {
+		allowed[normalizeOperationKey(name)] = true
+	}
+
+	var tools []Tool
+	seen := make(map[string]bool)
+	for _, op := range ops {
+		if len(allowed) > 0 && !allowed[op.ID] && !allowed[normalizeOperationKey(op.Method+" "+op.Path)] {
+			continue
+		}
+
+		name := opts.Prefix + toolName(op)
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate tool name %s for %s %s", name, op.Method, op.Path)
+		}
+		seen[name] = true
+
+		schema, bodyFields := inputSchema(op)
+		caller := &operationCaller{op: op, baseURL: strings.TrimRight(baseURL, "/"), opts: opts, bodyFields: bodyFields}
+		tools = append(tools, Tool{
+			Metadata: conduit.ToolMetadata{
+				Name:        name,
+				Description: description(op),
+				InputSchema: schema,
+			},
+			Func:      caller.call,
+			Operation: op,
+		})
+	}
+	return tools, nil
+}
+
+var (
+	invalidNameChars    = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+	repeatedUnderscores = regexp.MustCompile(`_{2,}`)
+)
+
+// toolName uses the operation ID, or method and path when there is none
+func toolName(op Operation) string {
+	name := op.ID
+	if name == "" {
+		name = strings.ToLower(op.Method) + "_" + op.Path
+	}
+	// Path separators and braces collapse into single underscores: get_pets_id
+	name = invalidNameChars.ReplaceAllString(name, "_")
+	name = repeatedUnderscores.ReplaceAllString(name, "_")
+	return strings.Trim(name, "_")
+}
+
+func description(op Operation) string {
+	desc := op.Summary
+	if desc == "" {
+		desc = op.Description
+	}
+	if desc == "" {
+		return op.Method + " " + op.Path
+	}
+	return desc + " (" + op.Method + " " + op.Path + ")"
+}
+
+func normalizeOperationKey(key string) string {
+	method, path, ok := strings.Cut(strings.TrimSpace(key), " ")
+	if !ok {
+		return key
+	}
+	return strings.ToUpper(method) + " " + strings.TrimSpace(path)
+}
+
+// bodyField marks where a tool argument goes in the request body
+type bodyField int
+
+const (
+	// bodyWhole sends the "body" argument as the entire request body
+	bodyWhole bodyField = iota
+	// bodyProperty sends the argument as a property of a JSON object body
+	bodyProperty
+)
+
+// inputSchema merges parameters and body properties into one object schema.
+// Object bodies are flattened unless a property clashes with a parameter,
+// in which case the whole body is taken from a "body" argument instead.
+func inputSchema(op Operation) (map[string]interface{}, map[string]bodyField) {
+	properties := make(map[string]interface{})
+	required := []string{}
+
+	for _, p := range op.Parameters {
+		prop := make(map[string]interface{}, len(p.Schema)+1)
+		for k, v := range p.Schema {
+			prop[k] = v
+		}
+		desc := p.Description
+		if desc == "" {
+			desc = p.In + " parameter"
+		}
+		prop["description"] = desc
+		properties[p.Name] = prop
+		if p.Required {
+			required = append(required, p.Name)
+		}
+	}
+
+	var fields map[string]bodyField
+	if op.BodySchema != nil {
+		bodyProps, _ := op.BodySchema["properties"].(map[string]interface{})
+		flatten := len(bodyProps) > 0
+		for name := range bodyProps {
+			if _, clash := properties[name]; clash {
+				flatten = false
+			}
+		}
+
+		fields = make(map[string]bodyField)
+		if flatten {
+			for name, prop := range bodyProps {
+				properties[name] = prop
+				fields[name] = bodyProperty
+			}
+			if op.BodyRequired {
+				for _, r := range asSlice(op.BodySchema["required"]) {
+					if name, ok := r.(string); ok {
+						required = append(required, name)
+					}
+				}
+			}
+		} else {
+			properties["body"] = op.BodySchema
+			fields["body"] = bodyWhole
+			if op.BodyRequired {
+				required = append(required, "body")
+			}
+		}
+	}
+
+	return conduit.CreateObjectSchema(properties, required), fields
+}
+
+// operationCaller performs HTTP requests for one operation
+type operationCaller struct {
+	op         Operation
+	baseURL    string
+	opts       Options
+	bodyFields map[string]bodyField
+}
+
+func (c *operationCaller) call(params map[string]interface{}, memory *mcp.Memory) (interface{}, error) {
+	req, err := c.buildRequest(params)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.opts.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", c.op.Method, c.op.Path, err)
+	}
+	defer resp.Body.Close()
+
+	limit := c.opts.MaxResponseBytes
+	data, err := io.ReadAll(io.LimitReader(resp.Body, int64(limit)+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	truncated := len(data) > limit
+	if truncated {
+		data = data[:limit]
+	}
+
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("%s %s returned %s: %s", c.op.Method, c.op.Path, resp.Status, strings.TrimSpace(string(data)))
+	}
+
+	result := map[string]interface{}{
+		"status": resp.StatusCode,
+	}
+	var body interface{}
+	if !truncated && json.Unmarshal(data, &body) == nil {
+		result["body"] = body
+	} else {
+		result["body"] = string(data)
+	}
+	if truncated {
+		result["truncated"] = true
+	}
+	return result, nil
+}
+
+func (c *operationCaller) buildRequest(params map[string]interface{}) (*http.Request, error) {
+	path := c.op.Path
+	query := url.Values{}
+	headers := http.Header{}
+
+	for _, p := range c.op.Parameters {
+		value, ok := params[p.Name]
+		if !ok || value == nil {
+			if p.Required {
+				return nil, fmt.Errorf("missing required parameter: %s", p.Name)
+			}
+			continue
+		}
+		switch p.In {
+		case "path":
+			path = strings.ReplaceAll(path, "{"+p.Name+"}", url.PathEscape(formatValue(value)))
+		case "query":
+			if list, ok := value.([]interface{}); ok {
+				for _, item := range list {
+					query.Add(p.Name, formatValue(item))
+				}
+			} else {
+				query.Set(p.Name, formatValue(value))
+			}
+		case "header":
+			headers.Set(p.Name, formatValue(value))
+		}
+	}
+
+	var body io.Reader
+	if len(c.bodyFields) > 0 {
+		payload, ok := c.buildBody(params)
+		if ok {
+			data, err := json.Marshal(payload)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode request body: %w", err)
+			}
+			body = bytes.NewReader(data)
+			headers.Set("Content-Type", "application/json")
+		} else if c.op.BodyRequired {
+			return nil, fmt.Errorf("missing request body")
+		}
+	}
+
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	// Request time is bounded by the HTTP client's timeout
+	req, err := http.NewRequest(c.op.Method, target, body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header = headers
+	req.Header.Set("Accept", "application/json")
+	for k, v := range c.opts.Headers {
+		req.Header.Set(k, os.ExpandEnv(v))
+	}
+	return req, nil
+}
+
+// buildBody collects body arguments; ok is false when none were given
+func (c *operationCaller) buildBody(params map[string]interface{}) (interface{}, bool) {
+	if field, ok := c.bodyFields["body"]; ok && field == bodyWhole {
+		value, ok := params["body"]
+		return value, ok && value != nil
+	}
+
+	obj := make(map[string]interface{})
+	for name := range c.bodyFields {
+		if value, ok := params[name]; ok {
+			obj[name] = value
+		}
+	}
+	return obj, len(obj) > 0
+}
+
+func formatValue(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case float64:
+		// Integers arrive as float64 from JSON
+		if val == float64(int64(val)) {
+			return fmt.Sprintf("%d", int64(val))
+		}
+		return fmt.Sprintf("%v", val)
+	default:
+		return fmt.Sprintf("%v", val)
+	}
+}
@@ -0,0 +1,111 @@
+package plugins
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultTimeout bounds a plugin call when the manifest sets no timeout
+const DefaultTimeout = 30 * time.Second
+
+// Manifest declares external executables to expose as tools
+type Manifest struct {
+	Tools []ToolSpec `json:"tools" yaml:"tools"`
+
+	// dir is the manifest's directory; relative paths are resolved against it
+	dir string
+}
+
+// ToolSpec describes one plugin tool
+type ToolSpec struct {
+	Name        string                 `json:"name" yaml:"name"`
+	Description string                 `json:"description" yaml:"description"`
+	Command     string                 `json:"command" yaml:"command"`
+	Args        []string               `json:"args,omitempty" yaml:"args,omitempty"`
+	InputSchema map[string]interface{} `json:"input_schema,omitempty" yaml:"input_schema,omitempty"`
+	Timeout     time.Duration          `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	Env         map[string]string      `json:"env,omitempty" yaml:"env,omitempty"`
+	WorkingDir  string                 `json:"working_dir,omitempty" yaml:"working_dir,omitempty"`
+	// InheritEnv passes conduit's whole environment to the plugin. By default
+	// only PATH, HOME, LANG and TMPDIR are passed, plus Env.
+	InheritEnv bool `json:"inherit_env,omitempty" yaml:"inherit_env,omitempty"`
+	// MaxOutputBytes caps stdout (default 1 MiB). Only the end of stderr is
+	// kept, for error messages.
+	MaxOutputBytes int `json:"max_output_bytes,omitempty" yaml:"max_output_bytes,omitempty"`
+}
+
+// LoadManifest reads a YAML or JSON plugin manifest and validates it
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plugin manifest: %w", err)
+	}
+
+	var manifest Manifest
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse plugin manifest %s: %w", path, err)
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	manifest.dir = filepath.Dir(abs)
+
+	if err := manifest.Validate(); err != nil {
+		return nil, fmt.Errorf("plugin manifest %s: %w", path, err)
+	}
+	return &manifest, nil
+}
+
+// Validate checks that every tool has a unique name and a command
+func (m *Manifest) Validate() error {
+	seen := make(map[string]bool)
+	for i, spec := range m.Tools {
+		if spec.Name == "" {
+			return fmt.Errorf("tool %d: name is required", i)
+		}
+		if seen[spec.Name] {
+			return fmt.Errorf("duplicate tool name: %s", spec.Name)
+		}
+		seen[spec.Name] = true
+
+		if spec.Command == "" {
+			return fmt.Errorf("tool %s: command is required", spec.Name)
+		}
+		if spec.Timeout < 0 {
+			return fmt.Errorf("tool %s: timeout must not be negative", spec.Name)
+		}
+		if spec.InputSchema != nil {
+			if t, ok := spec.InputSchema["type"]; ok && t != "object" {
+				return fmt.Errorf("tool %s: input_schema type must be object", spec.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// resolve makes relative commands and working directories relative to the manifest
+func (m *Manifest) resolve(spec ToolSpec) ToolSpec {
+	if m.dir == "" {
+		return spec
+	}
+	// Bare names such as "python3" are looked up on PATH
+	if !filepath.IsAbs(spec.Command) && strings.ContainsRune(spec.Command, filepath.Separator) {
+		spec.Command = filepath.Join(m.dir, spec.Command)
+	}
+	if spec.WorkingDir == "" {
+		spec.WorkingDir = m.dir
+	} else if !filepath.IsAbs(spec.WorkingDir) {
+		spec.WorkingDir = filepath.Join(m.dir, spec.WorkingDir)
+	}
+	return spec
+}
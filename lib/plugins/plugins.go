@@ -0,0 +1,240 @@
+// Package plugins exposes external executables as Conduit tools.
+//
+// A plugin receives the tool arguments as a JSON object on stdin and writes its
+// result to stdout. JSON output is returned as-is; any other output is wrapped
+// as {"result": "<text>"}. A non-zero exit code becomes a tool error carrying
+// the tail of stderr. A cancelled call kills the plugin.
+package plugins
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	conduit "github.com/benozo/conduit/lib"
+	"github.com/benozo/conduit/mcp"
+)
+
+const (
+	defaultMaxOutput = 1 << 20
+	// stderrTail is how much of stderr is kept for error messages
+	stderrTail = 2048
+)
+
+// SchemaRegistrar is implemented by conduit.EnhancedServer
+type SchemaRegistrar interface {
+	RegisterContextToolWithSchema(name string, tool mcp.ContextToolFunc, metadata conduit.ToolMetadata)
+}
+
+// LoadAndRegister loads each manifest and registers its tools, returning the tool names
+func LoadAndRegister(server SchemaRegistrar, paths ...string) ([]string, error) {
+	var names []string
+	for _, path := range paths {
+		manifest, err := LoadManifest(path)
+		if err != nil {
+			return names, err
+		}
+		names = append(names, Register(server, manifest)...)
+	}
+	return names, nil
+}
+
+// Register adds every tool in the manifest to the server
+func Register(server SchemaRegistrar, manifest *Manifest) []string {
+	names := make([]string, 0, len(manifest.Tools))
+	for _, spec := range manifest.Tools {
+		spec = manifest.resolve(spec)
+
+		schema := spec.InputSchema
+		if schema == nil {
+			schema = conduit.CreateObjectSchema(map[string]interface{}{}, []string{})
+		}
+		server.RegisterContextToolWithSchema(spec.Name, NewToolFunc(spec), conduit.ToolMetadata{
+			Name:        spec.Name,
+			Description: spec.Description,
+			InputSchema: schema,
+		})
+		slog.Debug("registered plugin tool", "tool", spec.Name, "command", spec.Command)
+		names = append(names, spec.Name)
+	}
+	return names
+}
+
+// NewToolFunc returns a tool that runs the plugin once per call. The plugin
+// is killed when the call's context is done or the spec's timeout passes.
+func NewToolFunc(spec ToolSpec) mcp.ContextToolFunc {
+	timeout := spec.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	maxOutput := spec.MaxOutputBytes
+	if maxOutput <= 0 {
+		maxOutput = defaultMaxOutput
+	}
+
+	return func(parent context.Context, params map[string]interface{}, memory *mcp.Memory) (interface{}, error) {
+		if params == nil {
+			params = map[string]interface{}{}
+		}
+		input, err := json.Marshal(params)
+		if err != nil {
+			return nil, fmt.Errorf("plugin %s: failed to encode arguments: %w", spec.Name, err)
+		}
+
+		ctx, cancel := context.WithTimeout(parent, timeout)
+		defer cancel()
+
+		cmd := exec.CommandContext(ctx, spec.Command, spec.Args...)
+		cmd.Dir = spec.WorkingDir
+		cmd.Env = pluginEnv(spec)
+		cmd.Stdin = bytes.NewReader(input)
+		stdout := &limitedBuffer{limit: maxOutput}
+		stderr := newTailBuffer(stderrTail)
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
+		// Don't wait forever on pipes held open by orphaned children after a kill
+		cmd.WaitDelay = time.Second
+
+		start := time.Now()
+		err = cmd.Run()
+		duration := time.Since(start)
+
+		if err := parent.Err(); err != nil {
+			return nil, fmt.Errorf("plugin %s cancelled: %w", spec.Name, err)
+		}
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("plugin %s timed out after %s", spec.Name, timeout)
+		}
+		if err != nil {
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				if exitErr.ExitCode() < 0 {
+					return nil, fmt.Errorf("plugin %s crashed: %v%s", spec.Name, exitErr, formatStderr(stderr))
+				}
+				return nil, fmt.Errorf("plugin %s exited with code %d%s", spec.Name, exitErr.ExitCode(), formatStderr(stderr))
+			}
+			return nil, fmt.Errorf("plugin %s failed to run: %w", spec.Name, err)
+		}
+
+		if msg := stderr.String(); msg != "" {
+			slog.DebugContext(parent, "plugin stderr", "tool", spec.Name, "stderr", msg)
+		}
+		if stdout.truncated {
+			return nil, fmt.Errorf("plugin %s output exceeds %d bytes", spec.Name, maxOutput)
+		}
+		slog.DebugContext(parent, "plugin call finished", "tool", spec.Name, "duration", duration, "output_bytes", stdout.Len())
+
+		return decodeOutput(stdout.Bytes()), nil
+	}
+}
+
+// decodeOutput returns JSON output as-is and wraps anything else as text
+func decodeOutput(out []byte) interface{} {
+	trimmed := bytes.TrimSpace(out)
+	var value interface{}
+	if len(trimmed) > 0 && json.Unmarshal(trimmed, &value) == nil {
+		return value
+	}
+	return map[string]interface{}{"result": string(trimmed)}
+}
+
+// pluginEnv builds the child environment from a minimal base plus the spec's env
+func pluginEnv(spec ToolSpec) []string {
+	var env []string
+	if spec.InheritEnv {
+		env = os.Environ()
+	} else {
+		for _, key := range []string{"PATH", "HOME", "LANG", "TMPDIR"} {
+			if v, ok := os.LookupEnv(key); ok {
+				env = append(env, key+"="+v)
+			}
+		}
+	}
+	for k, v := range spec.Env {
+		env = append(env, k+"="+v)
+	}
+	return env
+}
+
+func formatStderr(stderr *tailBuffer) string {
+	msg := strings.TrimSpace(stderr.String())
+	if msg == "" {
+		return ""
+	}
+	return ": " + msg
+}
+
+// limitedBuffer keeps at most limit bytes and discards the rest. The buffer
+// is a field rather than embedded so io.Copy can't bypass Write through
+// bytes.Buffer's ReadFrom.
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	room := b.limit - b.buf.Len()
+	if room <= 0 {
+		b.truncated = b.truncated || len(p) > 0
+		return len(p), nil
+	}
+	if len(p) > room {
+		b.buf.Write(p[:room])
+		b.truncated = true
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+func (b *limitedBuffer) Len() int      { return b.buf.Len() }
+func (b *limitedBuffer) Bytes() []byte { return b.buf.Bytes() }
+
+// tailBuffer is a ring buffer keeping the last size bytes written to it
+type tailBuffer struct {
+	data  []byte // grows to size, then wraps
+	start int    // oldest byte once data is full
+	size  int
+	// dropped records that older bytes were overwritten
+	dropped bool
+}
+
+func newTailBuffer(size int) *tailBuffer {
+	return &tailBuffer{data: make([]byte, 0, size), size: size}
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	if len(p) > b.size {
+		p = p[len(p)-b.size:]
+		b.dropped = true
+	}
+	if room := b.size - len(b.data); room > 0 {
+		k := min(room, len(p))
+		b.data = append(b.data, p[:k]...)
+		p = p[k:]
+	}
+	for len(p) > 0 {
+		k := copy(b.data[b.start:], p)
+		b.start = (b.start + k) % b.size
+		p = p[k:]
+		b.dropped = true
+	}
+	return n, nil
+}
+
+// String returns the kept bytes in order, marked when earlier output was dropped
+func (b *tailBuffer) String() string {
+	s := string(b.data[b.start:]) + string(b.data[:b.start])
+	if b.dropped {
+		return "..." + s
+	}
+	return s
+}
@@ -0,0 +1,241 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	conduit "github.com/benozo/conduit/lib"
+	"github.com/benozo/conduit/mcp"
+)
+
+// shell returns a spec running script with /bin/sh
+func shell(name, script string) ToolSpec {
+	return ToolSpec{Name: name, Command: "/bin/sh", Args: []string{"-c", script}}
+}
+
+func TestNewToolFunc(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    ToolSpec
+		params  map[string]interface{}
+		want    interface{}
+		wantErr string
+	}{
+		{
+			name:   "arguments on stdin, JSON on stdout",
+			spec:   shell("echo", "cat"),
+			params: map[string]interface{}{"text": "hi", "n": 2},
+			want:   map[string]interface{}{"text": "hi", "n": float64(2)},
+		},
+		{
+			name: "nil params sent as an empty object",
+			spec: shell("empty", "cat"),
+			want: map[string]interface{}{},
+		},
+		{
+			name: "text output wrapped",
+			spec: shell("text", "echo '  plain text  '"),
+			want: map[string]interface{}{"result": "plain text"},
+		},
+		{
+			name: "env is minimal plus the spec's",
+			spec: func() ToolSpec {
+				s := shell("env", `printf '%s|%s' "$PLUGIN_MODE" "$CONDUIT_PLUGIN_SECRET"`)
+				s.Env = map[string]string{"PLUGIN_MODE": "test"}
+				return s
+			}(),
+			want: map[string]interface{}{"result": "test|"},
+		},
+		{
+			name:    "exit code with stderr",
+			spec:    shell("exit", "echo 'bad input' >&2; exit 3"),
+			wantErr: "plugin exit exited with code 3: bad input",
+		},
+		{
+			name:    "killed by a signal",
+			spec:    shell("crash", "kill -9 $$"),
+			wantErr: "plugin crash crashed",
+		},
+		{
+			name: "hung process killed",
+			spec: func() ToolSpec {
+				s := shell("hang", "sleep 10")
+				s.Timeout = 50 * time.Millisecond
+				return s
+			}(),
+			wantErr: "plugin hang timed out after 50ms",
+		},
+		{
+			name: "oversized output",
+			spec: func() ToolSpec {
+				s := shell("big", "head -c 2000 /dev/zero")
+				s.MaxOutputBytes = 1000
+				return s
+			}(),
+			wantErr: "output exceeds 1000 bytes",
+		},
+		{
+			name:    "stderr keeps only its tail",
+			spec:    shell("noisy", "head -c 5000 /dev/zero | tr '\\0' x >&2; echo ' the end' >&2; exit 1"),
+			wantErr: "xxx the end",
+		},
+		{
+			name:    "missing executable",
+			spec:    ToolSpec{Name: "missing", Command: "/nonexistent/plugin"},
+			wantErr: "plugin missing failed to run",
+		},
+	}
+
+	t.Setenv("CONDUIT_PLUGIN_SECRET", "leaked")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			got, err := NewToolFunc(tt.spec)(context.Background(), tt.params, nil)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got %v, %v; want error %q", got, err, tt.wantErr)
+				}
+				if len(err.Error()) > stderrTail+200 {
+					t.Errorf("error is %d bytes long", len(err.Error()))
+				}
+				if time.Since(start) > 5*time.Second {
+					t.Errorf("call took %s", time.Since(start))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewToolFuncCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := NewToolFunc(shell("slow", "sleep 10"))(ctx, nil, nil)
+	if !errors.Is(err, context.Canceled) || !strings.Contains(err.Error(), "plugin slow cancelled") {
+		t.Fatalf("got %v, want a cancellation error", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("cancelled call took %s", elapsed)
+	}
+}
+
+func TestTailBuffer(t *testing.T) {
+	b := newTailBuffer(4)
+	b.Write([]byte("ab"))
+	if got := b.String(); got != "ab" {
+		t.Errorf("got %q before wrapping", got)
+	}
+	b.Write([]byte("cde"))
+	b.Write([]byte("f"))
+	if got := b.String(); got != "...cdef" {
+		t.Errorf("got %q after wrapping", got)
+	}
+	b.Write([]byte("0123456789"))
+	if got := b.String(); got != "...6789" {
+		t.Errorf("got %q after an oversized write", got)
+	}
+}
+
+func TestLoadManifest(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "bin"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	script := filepath.Join(dir, "bin", "pwd.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\npwd\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	manifest := `tools:
+  - name: where
+    description: Print the working directory
+    command: ./bin/pwd.sh
+    timeout: 5s
+    input_schema:
+      type: object
+      properties:
+        verbose: {type: boolean}
+`
+	path := filepath.Join(dir, "plugins.yaml")
+	if err := os.WriteFile(path, []byte(manifest), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := LoadManifest(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec := m.resolve(m.Tools[0])
+	if spec.Command != script || spec.WorkingDir != dir || spec.Timeout != 5*time.Second {
+		t.Errorf("resolved spec %+v", spec)
+	}
+
+	registrar := &recordingRegistrar{}
+	names, err := LoadAndRegister(registrar, path)
+	if err != nil || !reflect.DeepEqual(names, []string{"where"}) {
+		t.Fatalf("registered %v, %v", names, err)
+	}
+	if registrar.metadata["where"].Description != "Print the working directory" {
+		t.Errorf("metadata %+v", registrar.metadata["where"])
+	}
+	got, err := registrar.tools["where"](context.Background(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]interface{}{"result": dir}; !reflect.DeepEqual(got, want) {
+		t.Errorf("plugin ran in %v, want %v", got, want)
+	}
+}
+
+type recordingRegistrar struct {
+	tools    map[string]mcp.ContextToolFunc
+	metadata map[string]conduit.ToolMetadata
+}
+
+func (r *recordingRegistrar) RegisterContextToolWithSchema(name string, tool mcp.ContextToolFunc, metadata conduit.ToolMetadata) {
+	if r.tools == nil {
+		r.tools = map[string]mcp.ContextToolFunc{}
+		r.metadata = map[string]conduit.ToolMetadata{}
+	}
+	r.tools[name] = tool
+	r.metadata[name] = metadata
+}
+
+func TestManifestValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		tools []ToolSpec
+		want  string
+	}{
+		{"missing name", []ToolSpec{{Command: "x"}}, "name is required"},
+		{"duplicate", []ToolSpec{{Name: "a", Command: "x"}, {Name: "a", Command: "y"}}, "duplicate tool name"},
+		{"missing command", []ToolSpec{{Name: "a"}}, "command is required"},
+		{"negative timeout", []ToolSpec{{Name: "a", Command: "x", Timeout: -time.Second}}, "timeout"},
+		{"schema type", []ToolSpec{{Name: "a", Command: "x", InputSchema: map[string]interface{}{"type": "array"}}}, "must be object"},
+	}
+	for _, tt := range tests {
+		err := (&Manifest{Tools: tt.tools}).Validate()
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got %v, want %q", tt.name, err, tt.want)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "typo.yaml")
+	os.WriteFile(path, []byte("tools:\n  - name: a\n    comand: x\n"), 0o644)
+	if _, err := LoadManifest(path); err == nil {
+		t.Error("expected unknown manifest keys to be rejected")
+	}
+}
@@ -2,61 +2,63 @@ package rag
 
 import (
 	"fmt"
+	"os"
+	"strconv"
 	"time"
 )
 
 // RAGConfig holds configuration for RAG system
 type RAGConfig struct {
 	// Database configuration
-	Database DatabaseConfig `json:"database"`
+	Database DatabaseConfig `json:"database" yaml:"database"`
 
 	// Embedding configuration
-	Embeddings EmbeddingConfig `json:"embeddings"`
+	Embeddings EmbeddingConfig `json:"embeddings" yaml:"embeddings"`
 
 	// Chunking configuration
-	Chunking ChunkingConfig `json:"chunking"`
+	Chunking ChunkingConfig `json:"chunking" yaml:"chunking"`
 
 	// Search configuration
-	Search SearchConfig `json:"search"`
+	Search SearchConfig `json:"search" yaml:"search"`
 }
 
 // DatabaseConfig for PostgreSQL with pgvector
 type DatabaseConfig struct {
-	Host         string        `json:"host"`
-	Port         int           `json:"port"`
-	Name         string        `json:"name"`
-	User         string        `json:"user"`
-	Password     string        `json:"password"`
-	SSLMode      string        `json:"ssl_mode"`
-	MaxOpenConns int           `json:"max_open_conns"`
-	MaxIdleConns int           `json:"max_idle_conns"`
-	MaxLifetime  time.Duration `json:"max_lifetime"`
+	Host         string        `json:"host" yaml:"host"`
+	Port         int           `json:"port" yaml:"port"`
+	Name         string        `json:"name" yaml:"name"`
+	User         string        `json:"user" yaml:"user"`
+	Password     string        `json:"password" yaml:"password"`
+	SSLMode      string        `json:"ssl_mode" yaml:"ssl_mode"`
+	MaxOpenConns int           `json:"max_open_conns" yaml:"max_open_conns"`
+	MaxIdleConns int           `json:"max_idle_conns" yaml:"max_idle_conns"`
+	MaxLifetime  time.Duration `json:"max_lifetime" yaml:"max_lifetime"`
 }
 
 // EmbeddingConfig for embedding providers
 type EmbeddingConfig struct {
-	Provider   string        `json:"provider"` // "openai", "ollama"
-	APIKey     string        `json:"api_key"`  // For OpenAI
-	Host       string        `json:"host"`     // For Ollama (e.g., "192.168.10.10")
-	Model      string        `json:"model"`    // "text-embedding-ada-002" or "nomic-embed-text:latest"
-	Dimensions int           `json:"dimensions"`
-	BatchSize  int           `json:"batch_size"`
-	Timeout    time.Duration `json:"timeout"`
+	Provider   string        `json:"provider" yaml:"provider"` // "openai", "ollama"
+	APIKey     string        `json:"api_key" yaml:"api_key"`   // For OpenAI
+	Host       string        `json:"host" yaml:"host"`         // For Ollama (e.g., "192.168.10.10")
+	Model      string        `json:"model" yaml:"model"`       // "text-embedding-ada-002" or "nomic-embed-text:latest"
+	Dimensions int           `json:"dimensions" yaml:"dimensions"`
+	BatchSize  int           `json:"batch_size" yaml:"batch_size"`
+	Timeout    time.Duration `json:"timeout" yaml:"timeout"`
 }
 
 // ChunkingConfig for text chunking
 type ChunkingConfig struct {
-	Size     int    `json:"size"`     // Default chunk size in characters
-	Overlap  int    `json:"overlap"`  // Overlap between chunks
-	Strategy string `json:"strategy"` // "fixed", "semantic", "paragraph"
+	Size     int    `json:"size" yaml:"size"`         // Default chunk size in characters
+	Overlap  int    `json:"overlap" yaml:"overlap"`   // Overlap between chunks
+	Strategy string `json:"strategy" yaml:"strategy"` // "fixed", "semantic", "paragraph"
 }
 
 // SearchConfig for vector search
 type SearchConfig struct {
-	DefaultLimit int     `json:"default_limit"`
-	MaxLimit     int     `json:"max_limit"`
-	Threshold    float64 `json:"threshold"` // Similarity threshold
-	Algorithm    string  `json:"algorithm"` // "cosine", "l2", "inner_product"
+	DefaultLimit int     `json:"default_limit" yaml:"default_limit"`
+	MaxLimit     int     `json:"max_limit" yaml:"max_limit"`
+	Threshold    float64 `json:"threshold" yaml:"threshold"` // Similarity threshold
+	Algorithm    string  `json:"algorithm" yaml:"algorithm"` // "cosine", "l2", "inner_product"
 }
 
 // DefaultRAGConfig returns default configuration with OpenAI
@@ -108,10 +110,42 @@ func DefaultOllamaRAGConfig() *RAGConfig {
 	return config
 }
 
-// LoadConfigFromEnv loads configuration from environment variables
+// LoadFromEnv overrides configuration from RAG_* environment variables.
+// Invalid numeric values are ignored and the current value is kept.
 func (c *RAGConfig) LoadFromEnv() {
-	// Implementation to load from environment variables
-	// This would use os.Getenv() to override defaults
+	setString(&c.Database.Host, "RAG_DB_HOST")
+	setInt(&c.Database.Port, "RAG_DB_PORT")
+	setString(&c.Database.Name, "RAG_DB_NAME")
+	setString(&c.Database.User, "RAG_DB_USER")
+	setString(&c.Database.Password, "RAG_DB_PASSWORD")
+	setString(&c.Database.SSLMode, "RAG_DB_SSLMODE")
+
+	setString(&c.Embeddings.Provider, "RAG_PROVIDER")
+	setString(&c.Embeddings.Model, "RAG_EMBEDDING_MODEL")
+	setInt(&c.Embeddings.Dimensions, "RAG_EMBEDDING_DIMENSIONS")
+	setString(&c.Embeddings.Host, "OLLAMA_HOST")
+	setString(&c.Embeddings.APIKey, "OPENAI_API_KEY")
+
+	setInt(&c.Chunking.Size, "RAG_CHUNK_SIZE")
+	setInt(&c.Chunking.Overlap, "RAG_CHUNK_OVERLAP")
+	setString(&c.Chunking.Strategy, "RAG_CHUNK_STRATEGY")
+
+	setInt(&c.Search.DefaultLimit, "RAG_SEARCH_LIMIT")
+	if v, err := strconv.ParseFloat(os.Getenv("RAG_SEARCH_THRESHOLD"), 64); err == nil {
+		c.Search.Threshold = v
+	}
+}
+
+func setString(field *string, key string) {
+	if v := os.Getenv(key); v != "" {
+		*field = v
+	}
+}
+
+func setInt(field *int, key string) {
+	if v, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		*field = v
+	}
 }
 
 // Validate checks if configuration is valid
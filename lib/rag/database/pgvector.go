@@ -6,7 +6,7 @@ import (
 	_ "embed"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"strings"
 	"time"
 
@@ -121,7 +121,7 @@ func (p *PgVectorDB) initializeSchema(ctx context.Context) error {
 
 	// If embedding column doesn't exist, we'll add it when first vector is inserted
 	if columnName == "" {
-		log.Printf("Embedding column will be created dynamically on first vector insert")
+		slog.Debug("embedding column will be created on first vector insert")
 	}
 
 	// Create indexes and triggers
@@ -156,7 +156,7 @@ func (p *PgVectorDB) initializeSchema(ctx context.Context) error {
 		return fmt.Errorf("failed to create indexes and triggers: %w", err)
 	}
 
-	log.Printf("Database schema initialized successfully")
+	slog.Info("pgvector schema initialized")
 	return nil
 }
 
@@ -681,10 +681,10 @@ func (p *PgVectorDB) ensureEmbeddingColumn(ctx context.Context, sampleChunk rag.
 			WITH (lists = 100);`
 
 		if _, err := p.db.ExecContext(ctx, indexSQL); err != nil {
-			log.Printf("Warning: failed to create vector index: %v", err)
+			slog.Warn("failed to create vector index", "error", err)
 		}
 
-		log.Printf("Created embedding column with %d dimensions", dimensions)
+		slog.Info("created embedding column", "dimensions", dimensions)
 	}
 
 	return nil
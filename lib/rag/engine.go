@@ -8,6 +8,9 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+
+	"github.com/benozo/conduit/lib/metrics"
+	"github.com/benozo/conduit/lib/tracing"
 )
 
 // RAGEngineImpl implements the RAGEngine interface
@@ -44,7 +47,18 @@ func (r *RAGEngineImpl) registerDefaultProcessors() {
 }
 
 // IndexDocument processes and indexes a document into the vector database
-func (r *RAGEngineImpl) IndexDocument(ctx context.Context, filePath string, metadata map[string]interface{}) (*Document, error) {
+func (r *RAGEngineImpl) IndexDocument(ctx context.Context, filePath string, metadata map[string]interface{}) (_ *Document, err error) {
+	start := time.Now()
+	storedChunks := 0
+	defer func() { metrics.ObserveRAGIndex(storedChunks, err, time.Since(start)) }()
+
+	ctx, span := tracing.Start(ctx, "rag.index", tracing.String("rag.source", filePath))
+	defer func() {
+		span.SetAttribute("rag.chunks", int64(storedChunks))
+		span.RecordError(err)
+		span.End()
+	}()
+
 	// Determine file type and get appropriate processor
 	ext := strings.ToLower(filepath.Ext(filePath))
 	processor, exists := r.processors[ext]
@@ -78,7 +92,7 @@ func (r *RAGEngineImpl) IndexDocument(ctx context.Context, filePath string, meta
 	}
 
 	// Generate embeddings for all chunks in batch
-	embeddings, err := r.embeddings.EmbedBatch(ctx, texts)
+	embeddings, err := r.embedBatch(ctx, texts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate embeddings: %w", err)
 	}
@@ -110,12 +124,24 @@ func (r *RAGEngineImpl) IndexDocument(ctx context.Context, filePath string, meta
 	if err := r.vectorDB.StoreChunks(ctx, documentChunks); err != nil {
 		return nil, fmt.Errorf("failed to store chunks: %w", err)
 	}
+	storedChunks = len(documentChunks)
 
 	return doc, nil
 }
 
 // IndexContent processes and indexes content directly
-func (r *RAGEngineImpl) IndexContent(ctx context.Context, content, title, contentType string, metadata map[string]interface{}) (*Document, error) {
+func (r *RAGEngineImpl) IndexContent(ctx context.Context, content, title, contentType string, metadata map[string]interface{}) (_ *Document, err error) {
+	start := time.Now()
+	storedChunks := 0
+	defer func() { metrics.ObserveRAGIndex(storedChunks, err, time.Since(start)) }()
+
+	ctx, span := tracing.Start(ctx, "rag.index", tracing.String("rag.title", title))
+	defer func() {
+		span.SetAttribute("rag.chunks", int64(storedChunks))
+		span.RecordError(err)
+		span.End()
+	}()
+
 	// Get appropriate processor
 	processor, exists := r.processors[contentType]
 	if !exists {
@@ -146,7 +172,7 @@ func (r *RAGEngineImpl) IndexContent(ctx context.Context, content, title, conten
 		texts[i] = chunk.Content
 	}
 
-	embeddings, err := r.embeddings.EmbedBatch(ctx, texts)
+	embeddings, err := r.embedBatch(ctx, texts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate embeddings: %w", err)
 	}
@@ -172,14 +198,24 @@ func (r *RAGEngineImpl) IndexContent(ctx context.Context, content, title, conten
 	if err := r.vectorDB.StoreChunks(ctx, documentChunks); err != nil {
 		return nil, fmt.Errorf("failed to store chunks: %w", err)
 	}
+	storedChunks = len(documentChunks)
 
 	return doc, nil
 }
 
 // Search performs semantic search using vector similarity
-func (r *RAGEngineImpl) Search(ctx context.Context, query string, limit int, filters map[string]interface{}) ([]SearchResult, error) {
+func (r *RAGEngineImpl) Search(ctx context.Context, query string, limit int, filters map[string]interface{}) (_ []SearchResult, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveRAGSearch(err, time.Since(start)) }()
+
+	ctx, span := tracing.Start(ctx, "rag.search", tracing.Int("rag.limit", limit))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
 	// Generate embedding for query
-	queryEmbedding, err := r.embeddings.Embed(ctx, query)
+	queryEmbedding, err := r.embed(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate query embedding: %w", err)
 	}
@@ -189,10 +225,31 @@ func (r *RAGEngineImpl) Search(ctx context.Context, query string, limit int, fil
 	if err != nil {
 		return nil, fmt.Errorf("failed to search similar: %w", err)
 	}
+	span.SetAttribute("rag.results", int64(len(results)))
 
 	return results, nil
 }
 
+// embed generates a single embedding inside a traced span
+func (r *RAGEngineImpl) embed(ctx context.Context, text string) (_ []float32, err error) {
+	ctx, span := tracing.Start(ctx, "rag.embed", tracing.Int("rag.texts", 1))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+	return r.embeddings.Embed(ctx, text)
+}
+
+// embedBatch generates embeddings for several texts inside a traced span
+func (r *RAGEngineImpl) embedBatch(ctx context.Context, texts []string) (_ [][]float32, err error) {
+	ctx, span := tracing.Start(ctx, "rag.embed", tracing.Int("rag.texts", len(texts)))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+	return r.embeddings.EmbedBatch(ctx, texts)
+}
+
 // Query performs a RAG query with context retrieval and generation
 func (r *RAGEngineImpl) Query(ctx context.Context, question string, maxSources int, filters map[string]interface{}) (*RAGResponse, error) {
 	// Search for relevant context
@@ -0,0 +1,452 @@
+package conduit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/benozo/conduit/lib/metrics"
+	"github.com/benozo/conduit/lib/tracing"
+	"github.com/benozo/conduit/mcp"
+)
+
+// Inputs the router reads from ContextInput to apply its policies
+const (
+	// InputAgent names the agent making the request (set by swarm)
+	InputAgent = "agent"
+	// InputCapabilities lists capabilities the request needs, e.g. []string{"tools"}
+	InputCapabilities = "capabilities"
+	// InputMaxCost caps the backend cost per 1K tokens for this request
+	InputMaxCost = "max_cost"
+)
+
+// Capabilities a backend can declare
+const (
+	CapabilityTools  = "tools"
+	CapabilityVision = "vision"
+)
+
+// ErrNoRoute is returned when no backend satisfies a request's policies
+var ErrNoRoute = errors.New("no model backend satisfies the request")
+
+// RouteBackend is one model the router can send requests to
+type RouteBackend struct {
+	// Name identifies the backend in rules, logs and metrics (default provider/model)
+	Name        string `json:"name,omitempty" yaml:"name,omitempty"`
+	ModelConfig `json:",inline" yaml:",inline"`
+
+	// Capabilities the backend supports, e.g. "tools" or "vision"
+	Capabilities []string `json:"capabilities,omitempty" yaml:"capabilities,omitempty"`
+	// MaxPromptChars skips the backend for longer prompts (0 = no limit)
+	MaxPromptChars int `json:"max_prompt_chars,omitempty" yaml:"max_prompt_chars,omitempty"`
+	// CostPer1KTokens is compared against cost ceilings (0 = free or unknown)
+	CostPer1KTokens float64 `json:"cost_per_1k_tokens,omitempty" yaml:"cost_per_1k_tokens,omitempty"`
+	// Timeout bounds each call so a slow backend falls through to the next (0 = none)
+	Timeout time.Duration `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+
+	// Func overrides the model built from ModelConfig, e.g. for tests
+	Func mcp.ModelFunc `json:"-" yaml:"-"`
+}
+
+// RouteRule sends matching requests to an ordered fallback chain. Zero-valued
+// conditions match anything; all set conditions must match.
+type RouteRule struct {
+	Name           string   `json:"name,omitempty" yaml:"name,omitempty"`
+	Agents         []string `json:"agents,omitempty" yaml:"agents,omitempty"`
+	MinPromptChars int      `json:"min_prompt_chars,omitempty" yaml:"min_prompt_chars,omitempty"`
+	MaxPromptChars int      `json:"max_prompt_chars,omitempty" yaml:"max_prompt_chars,omitempty"`
+	// Capabilities matches requests that need all of these
+	Capabilities []string `json:"capabilities,omitempty" yaml:"capabilities,omitempty"`
+	// Match is an extra condition for rules defined in code
+	Match func(RouteRequest) bool `json:"-" yaml:"-"`
+
+	// Backends are tried in order
+	Backends []string `json:"backends" yaml:"backends"`
+}
+
+// RouterConfig configures a Router
+type RouterConfig struct {
+	// Backends in default fallback order
+	Backends []RouteBackend `json:"backends" yaml:"backends"`
+	// Rules are checked in order; the first match picks the chain
+	Rules []RouteRule `json:"rules,omitempty" yaml:"rules,omitempty"`
+	// MaxCost is the default cost ceiling per 1K tokens (0 = none)
+	MaxCost float64 `json:"max_cost,omitempty" yaml:"max_cost,omitempty"`
+
+	// FailureThreshold consecutive failures mark a backend unhealthy (default 2).
+	// Unhealthy backends are tried last until Cooldown (default 30s) has passed.
+	FailureThreshold int           `json:"failure_threshold,omitempty" yaml:"failure_threshold,omitempty"`
+	Cooldown         time.Duration `json:"cooldown,omitempty" yaml:"cooldown,omitempty"`
+
+	// OnRoute is called after every request with the backend that served it
+	OnRoute func(ctx mcp.ContextInput, result RouteResult) `json:"-" yaml:"-"`
+}
+
+// RouteRequest is what routing policies see of a request
+type RouteRequest struct {
+	Agent        string
+	Prompt       string
+	Capabilities []string
+	MaxCost      float64
+}
+
+// RouteAttempt is one backend call made for a request
+type RouteAttempt struct {
+	Backend  string
+	Err      error
+	Duration time.Duration
+}
+
+// RouteResult reports how a request was routed. Backend is empty when every
+// attempt failed.
+type RouteResult struct {
+	Rule     string
+	Backend  string
+	Provider string
+	Model    string
+	Attempts []RouteAttempt
+}
+
+// BackendHealth is a snapshot of a backend's recent results
+type BackendHealth struct {
+	Name                string
+	Healthy             bool
+	ConsecutiveFailures int
+	Served              int
+	Failed              int
+	LastError           string
+	LastFailure         time.Time
+	LastSuccess         time.Time
+}
+
+// Router is a composite model that routes each request by policy and falls
+// back along a chain of backends when one errors or times out
+type Router struct {
+	config   RouterConfig
+	backends map[string]*routeBackend
+	order    []*routeBackend
+}
+
+type routeBackend struct {
+	RouteBackend
+	model mcp.ModelFunc
+
+	mu     sync.Mutex
+	health BackendHealth
+}
+
+// NewRouter builds a router from its backends' model configs. With a tool
+// registry the backends are tool-aware (see CreateToolAwareModelFromConfig);
+// tools and provider may be nil.
+func NewRouter(config RouterConfig, tools *mcp.ToolRegistry, provider mcp.EnhancedSchemaProvider) (*Router, error) {
+	if len(config.Backends) == 0 {
+		return nil, fmt.Errorf("router needs at least one backend")
+	}
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = 2
+	}
+	if config.Cooldown <= 0 {
+		config.Cooldown = 30 * time.Second
+	}
+
+	r := &Router{config: config, backends: make(map[string]*routeBackend)}
+	for i := range config.Backends {
+		b := &routeBackend{RouteBackend: config.Backends[i]}
+		if b.Name == "" {
+			b.Name = b.Provider + "/" + b.Model
+		}
+		if _, dup := r.backends[b.Name]; dup {
+			return nil, fmt.Errorf("duplicate router backend %q", b.Name)
+		}
+
+		b.model = b.Func
+		if b.model == nil {
+			var err error
+			if tools != nil {
+				b.model, err = CreateToolAwareModelFromConfig(&b.ModelConfig, tools, provider)
+			} else {
+				b.model, err = CreateModelFunctionFromConfig(&b.ModelConfig)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("router backend %s: %w", b.Name, err)
+			}
+		}
+		b.health = BackendHealth{Name: b.Name, Healthy: true}
+		r.backends[b.Name] = b
+		r.order = append(r.order, b)
+	}
+
+	for _, rule := range config.Rules {
+		if len(rule.Backends) == 0 {
+			return nil, fmt.Errorf("router rule %q has no backends", rule.Name)
+		}
+		for _, name := range rule.Backends {
+			if _, ok := r.backends[name]; !ok {
+				return nil, fmt.Errorf("router rule %q: unknown backend %q", rule.Name, name)
+			}
+		}
+	}
+	return r, nil
+}
+
+// ModelFunc returns the router as a model function
+func (r *Router) ModelFunc() mcp.ModelFunc {
+	return r.call
+}
+
+// Health returns a snapshot of every backend's health, in config order
+func (r *Router) Health() []BackendHealth {
+	health := make([]BackendHealth, 0, len(r.order))
+	for _, b := range r.order {
+		b.mu.Lock()
+		h := b.health
+		b.mu.Unlock()
+		h.Healthy = r.healthy(h, time.Now())
+		health = append(health, h)
+	}
+	return health
+}
+
+// Route returns the backends a request would be tried on, in order
+func (r *Router) Route(req RouteRequest) (rule string, backends []string) {
+	rule, chain := r.route(req)
+	for _, b := range chain {
+		backends = append(backends, b.Name)
+	}
+	return rule, backends
+}
+
+func (r *Router) call(ctx mcp.ContextInput, req mcp.MCPRequest, memory *mcp.Memory, onToken mcp.StreamCallback) (string, error) {
+	routeReq := r.routeRequest(ctx.Inputs)
+	rule, chain := r.route(routeReq)
+	result := RouteResult{Rule: rule}
+
+	spanCtx, span := tracing.Start(ctx.Context(), "model.route",
+		tracing.String("route.rule", rule),
+		tracing.String("context.id", ctx.ContextID),
+	)
+	defer span.End()
+	ctx = ctx.WithContext(spanCtx)
+
+	if len(chain) == 0 {
+		err := fmt.Errorf("%w (capabilities %v, prompt %d chars, max cost %g)", ErrNoRoute, routeReq.Capabilities, len(routeReq.Prompt), routeReq.MaxCost)
+		span.RecordError(err)
+		r.report(ctx, result)
+		return "", err
+	}
+
+	var errs []error
+	for _, b := range chain {
+		response, streamed, err := r.try(ctx, b, req, memory, onToken)
+		result.Attempts = append(result.Attempts, RouteAttempt{Backend: b.Name, Err: err, Duration: response.elapsed})
+		if err == nil {
+			result.Backend, result.Provider, result.Model = b.Name, b.Provider, b.Model
+			span.SetAttributes(tracing.String("model.backend", b.Name), tracing.Int("route.attempts", len(result.Attempts)))
+			slog.DebugContext(spanCtx, "model router served request", "backend", b.Name, "rule", rule, "attempts", len(result.Attempts))
+			r.report(ctx, result)
+			return response.text, nil
+		}
+
+		errs = append(errs, fmt.Errorf("%s: %w", b.Name, err))
+		if parentErr := ctx.Context().Err(); parentErr != nil {
+			// The caller gave up; there is no point trying other backends
+			break
+		}
+		if streamed {
+			// Tokens already reached the caller, so a fallback would repeat them
+			break
+		}
+		slog.WarnContext(spanCtx, "model backend failed, falling back", "backend", b.Name, "error", err)
+	}
+
+	err := fmt.Errorf("all model backends failed: %w", errors.Join(errs...))
+	span.RecordError(err)
+	r.report(ctx, result)
+	return "", err
+}
+
+type routeResponse struct {
+	text    string
+	elapsed time.Duration
+}
+
+// try calls one backend, applying its timeout and recording its health.
+// streamed reports whether any tokens were passed to onToken.
+func (r *Router) try(ctx mcp.ContextInput, b *routeBackend, req mcp.MCPRequest, memory *mcp.Memory, onToken mcp.StreamCallback) (routeResponse, bool, error) {
+	callCtx := ctx.Context()
+	if b.Timeout > 0 {
+		var cancel context.CancelFunc
+		callCtx, cancel = context.WithTimeout(callCtx, b.Timeout)
+		defer cancel()
+	}
+
+	streamed := false
+	var tokens mcp.StreamCallback
+	if onToken != nil {
+		tokens = func(contextID, token string) {
+			streamed = true
+			onToken(contextID, token)
+		}
+	}
+
+	// Each backend uses its own model, not the one the caller asked for
+	req.Model = b.Model
+
+	start := time.Now()
+	text, err := b.model(ctx.WithContext(callCtx), req, memory, tokens)
+	elapsed := time.Since(start)
+	if err == nil && callCtx.Err() != nil {
+		err = callCtx.Err()
+	}
+
+	// Don't hold the caller's cancellation against the backend
+	if ctx.Context().Err() == nil {
+		r.record(b, err)
+	}
+	metrics.ObserveRouterAttempt(b.Name, err)
+	return routeResponse{text: text, elapsed: elapsed}, streamed, err
+}
+
+// routeRequest reads the policy inputs of a request
+func (r *Router) routeRequest(inputs map[string]interface{}) RouteRequest {
+	req := RouteRequest{
+		Prompt:  fmt.Sprintf("%v", inputs["query"]),
+		MaxCost: r.config.MaxCost,
+	}
+	if agent, ok := inputs[InputAgent].(string); ok {
+		req.Agent = agent
+	}
+	switch caps := inputs[InputCapabilities].(type) {
+	case []string:
+		req.Capabilities = append(req.Capabilities, caps...)
+	case []interface{}:
+		for _, c := range caps {
+			if s, ok := c.(string); ok {
+				req.Capabilities = append(req.Capabilities, s)
+			}
+		}
+	case string:
+		for _, c := range strings.Split(caps, ",") {
+			if c = strings.TrimSpace(c); c != "" {
+				req.Capabilities = append(req.Capabilities, c)
+			}
+		}
+	}
+	switch cost := inputs[InputMaxCost].(type) {
+	case float64:
+		req.MaxCost = cost
+	case int:
+		req.MaxCost = float64(cost)
+	}
+	return req
+}
+
+// route picks the chain for a request: the first matching rule's backends,
+// or all of them, minus those that can't serve it. Unhealthy backends are
+// moved to the end rather than dropped, so they still serve as a last resort.
+func (r *Router) route(req RouteRequest) (string, []*routeBackend) {
+	rule := "default"
+	chain := r.order
+	for _, candidate := range r.config.Rules {
+		if ruleMatches(candidate, req) {
+			rule = candidate.Name
+			chain = nil
+			for _, name := range candidate.Backends {
+				chain = append(chain, r.backends[name])
+			}
+			break
+		}
+	}
+
+	now := time.Now()
+	var healthy, unhealthy []*routeBackend
+	for _, b := range chain {
+		if !b.canServe(req) {
+			continue
+		}
+		b.mu.Lock()
+		ok := r.healthy(b.health, now)
+		b.mu.Unlock()
+		if ok {
+			healthy = append(healthy, b)
+		} else {
+			unhealthy = append(unhealthy, b)
+		}
+	}
+	return rule, append(healthy, unhealthy...)
+}
+
+func ruleMatches(rule RouteRule, req RouteRequest) bool {
+	if len(rule.Agents) > 0 && !containsFold(rule.Agents, req.Agent) {
+		return false
+	}
+	if rule.MinPromptChars > 0 && len(req.Prompt) < rule.MinPromptChars {
+		return false
+	}
+	if rule.MaxPromptChars > 0 && len(req.Prompt) > rule.MaxPromptChars {
+		return false
+	}
+	for _, c := range rule.Capabilities {
+		if !containsFold(req.Capabilities, c) {
+			return false
+		}
+	}
+	return rule.Match == nil || rule.Match(req)
+}
+
+// canServe checks the backend's capabilities, prompt limit and cost
+func (b *routeBackend) canServe(req RouteRequest) bool {
+	for _, c := range req.Capabilities {
+		if !containsFold(b.Capabilities, c) {
+			return false
+		}
+	}
+	if b.MaxPromptChars > 0 && len(req.Prompt) > b.MaxPromptChars {
+		return false
+	}
+	if req.MaxCost > 0 && b.CostPer1KTokens > req.MaxCost {
+		return false
+	}
+	return true
+}
+
+func (r *Router) healthy(h BackendHealth, now time.Time) bool {
+	return h.ConsecutiveFailures < r.config.FailureThreshold || now.Sub(h.LastFailure) >= r.config.Cooldown
+}
+
+func (r *Router) record(b *routeBackend, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		b.health.Served++
+		b.health.ConsecutiveFailures = 0
+		b.health.LastSuccess = time.Now()
+		return
+	}
+	b.health.Failed++
+	b.health.ConsecutiveFailures++
+	b.health.LastError = err.Error()
+	b.health.LastFailure = time.Now()
+	if b.health.ConsecutiveFailures == r.config.FailureThreshold {
+		slog.Warn("model backend marked unhealthy", "backend", b.Name, "failures", b.health.ConsecutiveFailures, "cooldown", r.config.Cooldown)
+	}
+}
+
+func (r *Router) report(ctx mcp.ContextInput, result RouteResult) {
+	if r.config.OnRoute != nil {
+		r.config.OnRoute(ctx, result)
+	}
+}
+
+func containsFold(values []string, target string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, target) {
+			return true
+		}
+	}
+	return false
+}
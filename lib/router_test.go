@@ -0,0 +1,236 @@
+package conduit
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benozo/conduit/mcp"
+)
+
+func answer(text string) mcp.ModelFunc {
+	return func(mcp.ContextInput, mcp.MCPRequest, *mcp.Memory, mcp.StreamCallback) (string, error) {
+		return text, nil
+	}
+}
+
+func fail(msg string) mcp.ModelFunc {
+	return func(mcp.ContextInput, mcp.MCPRequest, *mcp.Memory, mcp.StreamCallback) (string, error) {
+		return "", errors.New(msg)
+	}
+}
+
+// hang blocks until the call is cancelled
+func hang(ctx mcp.ContextInput, _ mcp.MCPRequest, _ *mcp.Memory, _ mcp.StreamCallback) (string, error) {
+	<-ctx.Context().Done()
+	return "", ctx.Context().Err()
+}
+
+func routeInput(query string, extra map[string]interface{}) mcp.ContextInput {
+	inputs := map[string]interface{}{"query": query}
+	for k, v := range extra {
+		inputs[k] = v
+	}
+	return mcp.ContextInput{Inputs: inputs}
+}
+
+func TestRouterFallback(t *testing.T) {
+	tests := []struct {
+		name         string
+		backends     []RouteBackend
+		want         string
+		wantBackend  string
+		wantAttempts int
+		wantErr      bool
+	}{
+		{
+			name:         "first backend serves",
+			backends:     []RouteBackend{{Name: "a", Func: answer("from a")}, {Name: "b", Func: answer("from b")}},
+			want:         "from a",
+			wantBackend:  "a",
+			wantAttempts: 1,
+		},
+		{
+			name:         "falls back on error",
+			backends:     []RouteBackend{{Name: "a", Func: fail("down")}, {Name: "b", Func: answer("from b")}},
+			want:         "from b",
+			wantBackend:  "b",
+			wantAttempts: 2,
+		},
+		{
+			name:         "falls back on timeout",
+			backends:     []RouteBackend{{Name: "a", Func: hang, Timeout: 10 * time.Millisecond}, {Name: "b", Func: answer("from b")}},
+			want:         "from b",
+			wantBackend:  "b",
+			wantAttempts: 2,
+		},
+		{
+			name:         "all fail",
+			backends:     []RouteBackend{{Name: "a", Func: fail("down")}, {Name: "b", Func: fail("also down")}},
+			wantAttempts: 2,
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var result RouteResult
+			router, err := NewRouter(RouterConfig{
+				Backends: tt.backends,
+				OnRoute:  func(_ mcp.ContextInput, r RouteResult) { result = r },
+			}, nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := router.ModelFunc()(routeInput("hello", nil), mcp.MCPRequest{}, nil, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil && (!strings.Contains(err.Error(), "down") || !strings.Contains(err.Error(), "also down")) {
+				t.Errorf("error %q does not name every failure", err)
+			}
+			if got != tt.want || result.Backend != tt.wantBackend || len(result.Attempts) != tt.wantAttempts {
+				t.Errorf("got %q from %q after %d attempts, want %q from %q after %d",
+					got, result.Backend, len(result.Attempts), tt.want, tt.wantBackend, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestRouterNoFallbackAfterStreaming(t *testing.T) {
+	partial := func(ctx mcp.ContextInput, _ mcp.MCPRequest, _ *mcp.Memory, onToken mcp.StreamCallback) (string, error) {
+		onToken(ctx.ContextID, "partial ")
+		return "", errors.New("stream broke")
+	}
+	router, err := NewRouter(RouterConfig{Backends: []RouteBackend{
+		{Name: "a", Func: partial},
+		{Name: "b", Func: answer("from b")},
+	}}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tokens []string
+	_, err = router.ModelFunc()(routeInput("hello", nil), mcp.MCPRequest{}, nil, func(_, token string) {
+		tokens = append(tokens, token)
+	})
+	if err == nil || len(tokens) != 1 {
+		t.Errorf("got error %v and tokens %q, want the first backend's failure only", err, tokens)
+	}
+}
+
+func TestRouterHealth(t *testing.T) {
+	router, err := NewRouter(RouterConfig{
+		Backends:         []RouteBackend{{Name: "flaky", Func: fail("down")}, {Name: "stable", Func: answer("ok")}},
+		FailureThreshold: 2,
+		Cooldown:         time.Hour,
+	}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	model := router.ModelFunc()
+	for i := 0; i < 2; i++ {
+		if _, err := model(routeInput("hello", nil), mcp.MCPRequest{}, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, chain := router.Route(RouteRequest{}); !reflect.DeepEqual(chain, []string{"stable", "flaky"}) {
+		t.Errorf("chain %v, want the unhealthy backend last", chain)
+	}
+	health := router.Health()
+	if health[0].Healthy || health[0].ConsecutiveFailures != 2 || !health[1].Healthy || health[1].Served != 2 {
+		t.Errorf("health %+v", health)
+	}
+}
+
+func TestRouterPolicies(t *testing.T) {
+	router, err := NewRouter(RouterConfig{
+		Backends: []RouteBackend{
+			{Name: "small", Func: answer("small"), MaxPromptChars: 20, CostPer1KTokens: 0.1},
+			{Name: "vision", Func: answer("vision"), Capabilities: []string{CapabilityVision, CapabilityTools}, CostPer1KTokens: 5},
+			{Name: "big", Func: answer("big"), Capabilities: []string{CapabilityTools}, CostPer1KTokens: 1},
+		},
+		Rules: []RouteRule{
+			{Name: "coder", Agents: []string{"Coder"}, Backends: []string{"big", "small"}},
+			{Name: "long", MinPromptChars: 100, Backends: []string{"big"}},
+		},
+	}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	long := strings.Repeat("x", 150)
+	tests := []struct {
+		name      string
+		req       RouteRequest
+		wantRule  string
+		wantChain []string
+	}{
+		{"default order", RouteRequest{Prompt: "hi"}, "default", []string{"small", "vision", "big"}},
+		{"prompt too long for small", RouteRequest{Prompt: strings.Repeat("x", 50)}, "default", []string{"vision", "big"}},
+		{"capability", RouteRequest{Prompt: "hi", Capabilities: []string{"vision"}}, "default", []string{"vision"}},
+		{"cost ceiling", RouteRequest{Prompt: "hi", MaxCost: 1}, "default", []string{"small", "big"}},
+		{"agent rule, case-insensitive", RouteRequest{Agent: "coder", Prompt: "hi"}, "coder", []string{"big", "small"}},
+		{"prompt length rule", RouteRequest{Prompt: long}, "long", []string{"big"}},
+		{"no backend", RouteRequest{Prompt: long, Capabilities: []string{"vision"}}, "long", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, chain := router.Route(tt.req)
+			if rule != tt.wantRule || !reflect.DeepEqual(chain, tt.wantChain) {
+				t.Errorf("got rule %q chain %v, want %q %v", rule, chain, tt.wantRule, tt.wantChain)
+			}
+		})
+	}
+
+	input := routeInput(long, map[string]interface{}{InputCapabilities: "vision"})
+	if _, err := router.ModelFunc()(input, mcp.MCPRequest{}, nil, nil); !errors.Is(err, ErrNoRoute) {
+		t.Errorf("got %v, want ErrNoRoute", err)
+	}
+}
+
+func TestRouterCallerCancel(t *testing.T) {
+	router, err := NewRouter(RouterConfig{Backends: []RouteBackend{
+		{Name: "a", Func: hang},
+		{Name: "b", Func: answer("from b")},
+	}}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := router.ModelFunc()(routeInput("hello", nil).WithContext(ctx), mcp.MCPRequest{}, nil, nil); err == nil {
+		t.Fatal("a cancelled call must not fall back")
+	}
+	if h := router.Health()[0]; h.Failed != 0 {
+		t.Errorf("caller cancellation counted against the backend: %+v", h)
+	}
+}
+
+func TestNewRouterValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		config RouterConfig
+	}{
+		{"no backends", RouterConfig{}},
+		{"duplicate backend", RouterConfig{Backends: []RouteBackend{{Name: "a", Func: hang}, {Name: "a", Func: hang}}}},
+		{"unknown rule backend", RouterConfig{
+			Backends: []RouteBackend{{Name: "a", Func: hang}},
+			Rules:    []RouteRule{{Name: "r", Backends: []string{"missing"}}},
+		}},
+		{"empty rule", RouterConfig{
+			Backends: []RouteBackend{{Name: "a", Func: hang}},
+			Rules:    []RouteRule{{Name: "r"}},
+		}},
+	}
+	for _, tt := range tests {
+		if _, err := NewRouter(tt.config, nil, nil); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package scripting
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"go.starlark.net/starlark"
+
+	"github.com/benozo/conduit/mcp"
+)
+
+// toStarlark converts JSON-like Go values to Starlark values
+func toStarlark(v interface{}) (starlark.Value, error) {
+	switch val := v.(type) {
+	case nil:
+		return starlark.None, nil
+	case bool:
+		return starlark.Bool(val), nil
+	case string:
+		return starlark.String(val), nil
+	case int:
+		return starlark.MakeInt(val), nil
+	case int64:
+		return starlark.MakeInt64(val), nil
+	case float64:
+		// Whole numbers become ints so scripts can index and range with them
+		if val == math.Trunc(val) && math.Abs(val) < 1<<53 {
+			return starlark.MakeInt64(int64(val)), nil
+		}
+		return starlark.Float(val), nil
+	case []interface{}:
+		items := make([]starlark.Value, len(val))
+		for i, item := range val {
+			converted, err := toStarlark(item)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = converted
+		}
+		return starlark.NewList(items), nil
+	case []string:
+		items := make([]starlark.Value, len(val))
+		for i, item := range val {
+			items[i] = starlark.String(item)
+		}
+		return starlark.NewList(items), nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		dict := starlark.NewDict(len(val))
+		for _, k := range keys {
+			converted, err := toStarlark(val[k])
+			if err != nil {
+				return nil, err
+			}
+			if err := dict.SetKey(starlark.String(k), converted); err != nil {
+				return nil, err
+			}
+		}
+		return dict, nil
+	case map[string]string:
+		dict := starlark.NewDict(len(val))
+		for k, s := range val {
+			if err := dict.SetKey(starlark.String(k), starlark.String(s)); err != nil {
+				return nil, err
+			}
+		}
+		return dict, nil
+	default:
+		return nil, fmt.Errorf("cannot convert %T to a Starlark value", v)
+	}
+}
+
+// fromStarlark converts Starlark values to JSON-like Go values
+func fromStarlark(v starlark.Value) (interface{}, error) {
+	switch val := v.(type) {
+	case starlark.NoneType:
+		return nil, nil
+	case starlark.Bool:
+		return bool(val), nil
+	case starlark.String:
+		return string(val), nil
+	case starlark.Int:
+		if i, ok := val.Int64(); ok {
+			return float64(i), nil
+		}
+		return nil, fmt.Errorf("integer %s is too large", val)
+	case starlark.Float:
+		return float64(val), nil
+	case *starlark.List:
+		items := make([]interface{}, val.Len())
+		for i := 0; i < val.Len(); i++ {
+			converted, err := fromStarlark(val.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			items[i] = converted
+		}
+		return items, nil
+	case starlark.Tuple:
+		items := make([]interface{}, len(val))
+		for i, item := range val {
+			converted, err := fromStarlark(item)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = converted
+		}
+		return items, nil
+	case *starlark.Dict:
+		out := make(map[string]interface{}, val.Len())
+		for _, item := range val.Items() {
+			key, ok := starlark.AsString(item[0])
+			if !ok {
+				return nil, fmt.Errorf("dict keys must be strings, got %s", item[0].Type())
+			}
+			converted, err := fromStarlark(item[1])
+			if err != nil {
+				return nil, err
+			}
+			out[key] = converted
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("cannot return a value of type %s", v.Type())
+	}
+}
+
+// memoryValue exposes mcp.Memory to scripts as memory.get(key, default=None) and memory.set(key, value)
+type memoryValue struct {
+	memory *mcp.Memory
+}
+
+func newMemoryValue(memory *mcp.Memory) starlark.Value {
+	if memory == nil {
+		memory = mcp.NewMemory()
+	}
+	return &memoryValue{memory: memory}
+}
+
+func (m *memoryValue) String() string        { return "<memory>" }
+func (m *memoryValue) Type() string          { return "memory" }
+func (m *memoryValue) Freeze()               {}
+func (m *memoryValue) Truth() starlark.Bool  { return starlark.True }
+func (m *memoryValue) Hash() (uint32, error) { return 0, fmt.Errorf("unhashable type: memory") }
+func (m *memoryValue) AttrNames() []string   { return []string{"get", "set"} }
+
+func (m *memoryValue) Attr(name string) (starlark.Value, error) {
+	switch name {
+	case "get":
+		return starlark.NewBuiltin("memory.get", m.get), nil
+	case "set":
+		return starlark.NewBuiltin("memory.set", m.set), nil
+	}
+	return nil, nil
+}
+
+func (m *memoryValue) get(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var key string
+	var def starlark.Value = starlark.None
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "key", &key, "default?", &def); err != nil {
+		return nil, err
+	}
+	value := m.memory.Get(key)
+	if value == nil {
+		return def, nil
+	}
+	converted, err := toStarlark(value)
+	if err != nil {
+		// Values stored by Go tools may not be representable; expose them as text
+		return starlark.String(fmt.Sprint(value)), nil
+	}
+	return converted, nil
+}
+
+func (m *memoryValue) set(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var key string
+	var value starlark.Value
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "key", &key, "value", &value); err != nil {
+		return nil, err
+	}
+	converted, err := fromStarlark(value)
+	if err != nil {
+		return nil, err
+	}
+	m.memory.Set(key, converted)
+	return starlark.None, nil
+}
@@ -0,0 +1,191 @@
+package scripting
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	conduit "github.com/benozo/conduit/lib"
+	"github.com/benozo/conduit/mcp"
+)
+
+// Extension is the file extension of tool scripts
+const Extension = ".star"
+
+// Registrar is implemented by conduit.EnhancedServer
+type Registrar interface {
+	RegisterToolWithSchema(name string, tool mcp.ToolFunc, metadata conduit.ToolMetadata)
+	UnregisterTool(name string)
+}
+
+// Loader registers the scripts in a directory and keeps them in sync with it
+type Loader struct {
+	dir    string
+	server Registrar
+	limits Limits
+
+	mu     sync.Mutex
+	loaded map[string]loadedScript // by path
+}
+
+type loadedScript struct {
+	name    string
+	modTime time.Time
+	size    int64
+}
+
+// NewLoader creates a loader for the *.star files in dir
+func NewLoader(dir string, server Registrar, limits Limits) *Loader {
+	return &Loader{
+		dir:    dir,
+		server: server,
+		limits: limits,
+		loaded: make(map[string]loadedScript),
+	}
+}
+
+// Load registers every script in the directory. Any invalid script fails the load.
+func (l *Loader) Load() ([]string, error) {
+	paths, err := l.scan()
+	if err != nil {
+		return nil, err
+	}
+
+	var scripts []*Script
+	names := make(map[string]string)
+	for _, path := range paths {
+		script, err := LoadScript(path, l.limits)
+		if err != nil {
+			return nil, err
+		}
+		if other, ok := names[script.Metadata.Name]; ok {
+			return nil, fmt.Errorf("scripts %s and %s both define tool %s", other, path, script.Metadata.Name)
+		}
+		names[script.Metadata.Name] = path
+		scripts = append(scripts, script)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var registered []string
+	for _, script := range scripts {
+		l.register(script)
+		registered = append(registered, script.Metadata.Name)
+	}
+	return registered, nil
+}
+
+// Watch polls the directory every interval and reloads changed scripts until ctx is done
+func (l *Loader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := l.Reload(); err != nil {
+				slog.Warn("failed to scan script directory", "dir", l.dir, "error", err)
+			}
+		}
+	}
+}
+
+// Reload registers new and modified scripts and removes deleted ones.
+// A script that fails to compile is logged and its previous version kept.
+func (l *Loader) Reload() error {
+	paths, err := l.scan()
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	present := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		present[path] = true
+
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		prev, known := l.loaded[path]
+		if known && prev.modTime.Equal(info.ModTime()) && prev.size == info.Size() {
+			continue
+		}
+
+		script, err := LoadScript(path, l.limits)
+		if err != nil {
+			slog.Warn("script not reloaded", "path", path, "error", err)
+			if !known {
+				// Remember the broken file so it isn't recompiled until it changes again
+				l.loaded[path] = loadedScript{modTime: info.ModTime(), size: info.Size()}
+			} else {
+				prev.modTime, prev.size = info.ModTime(), info.Size()
+				l.loaded[path] = prev
+			}
+			continue
+		}
+		if owner := l.owner(script.Metadata.Name); owner != "" && owner != path {
+			slog.Warn("script not loaded: tool name already in use", "path", path, "tool", script.Metadata.Name, "owner", owner)
+			continue
+		}
+
+		if known && prev.name != "" && prev.name != script.Metadata.Name {
+			l.server.UnregisterTool(prev.name)
+		}
+		l.register(script)
+		slog.Info("reloaded script tool", "tool", script.Metadata.Name, "path", path)
+	}
+
+	for path, entry := range l.loaded {
+		if present[path] {
+			continue
+		}
+		if entry.name != "" {
+			l.server.UnregisterTool(entry.name)
+			slog.Info("removed script tool", "tool", entry.name, "path", path)
+		}
+		delete(l.loaded, path)
+	}
+	return nil
+}
+
+// register adds a script to the server; l.mu must be held
+func (l *Loader) register(script *Script) {
+	entry := loadedScript{name: script.Metadata.Name}
+	if info, err := os.Stat(script.Path); err == nil {
+		entry.modTime, entry.size = info.ModTime(), info.Size()
+	}
+	l.loaded[script.Path] = entry
+	l.server.RegisterToolWithSchema(script.Metadata.Name, script.ToolFunc(), script.Metadata)
+}
+
+// owner returns the path of the script registered under name; l.mu must be held
+func (l *Loader) owner(name string) string {
+	for path, entry := range l.loaded {
+		if entry.name == name {
+			return path
+		}
+	}
+	return ""
+}
+
+// scan lists the script files in the directory, sorted
+func (l *Loader) scan() ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(l.dir, "*"+Extension))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(l.dir); err != nil {
+		return nil, fmt.Errorf("script directory: %w", err)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
@@ -0,0 +1,206 @@
+// Package scripting defines Conduit tools as Starlark scripts.
+//
+// A script declares its schema in top-level variables and a run function:
+//
+//	description = "Turn text into a URL slug"
+//	parameters = {"text": {"type": "string", "description": "Text to slugify"}}
+//	required = ["text"]
+//
+//	def run(params, memory):
+//	    return {"result": params["text"].lower().replace(" ", "-")}
+//
+// The tool name is the optional top-level name variable, or the file name
+// without its .star extension. Scripts cannot load modules or touch the
+// filesystem, and every call runs with step and time limits.
+package scripting
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkjson"
+
+	conduit "github.com/benozo/conduit/lib"
+	"github.com/benozo/conduit/mcp"
+)
+
+// Default per-call limits
+const (
+	DefaultMaxSteps = 1_000_000
+	DefaultTimeout  = 5 * time.Second
+)
+
+// Limits bound a single script call
+type Limits struct {
+	// MaxSteps caps Starlark execution steps (default 1,000,000)
+	MaxSteps uint64
+	// Timeout cancels calls that run too long (default 5s)
+	Timeout time.Duration
+}
+
+func (l Limits) withDefaults() Limits {
+	if l.MaxSteps == 0 {
+		l.MaxSteps = DefaultMaxSteps
+	}
+	if l.Timeout <= 0 {
+		l.Timeout = DefaultTimeout
+	}
+	return l
+}
+
+// Script is a compiled tool script
+type Script struct {
+	Path     string
+	Metadata conduit.ToolMetadata
+
+	run    *starlark.Function
+	limits Limits
+}
+
+// LoadScript compiles a script file
+func LoadScript(path string, limits Limits) (*Script, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read script: %w", err)
+	}
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	return CompileScript(path, name, src, limits)
+}
+
+// CompileScript compiles script source; defaultName is used when the script sets no name
+func CompileScript(path, defaultName string, src []byte, limits Limits) (*Script, error) {
+	limits = limits.withDefaults()
+
+	// Top-level code gets the same limits as calls so a script can't hang loading
+	thread, cancel := newThread(path, limits)
+	defer cancel()
+
+	globals, err := starlark.ExecFile(thread, path, src, predeclared())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	globals.Freeze()
+
+	run, ok := globals["run"].(*starlark.Function)
+	if !ok {
+		return nil, fmt.Errorf("%s: script must define run(params, memory)", path)
+	}
+	if run.NumParams() != 2 {
+		return nil, fmt.Errorf("%s: run must take exactly two parameters (params, memory)", path)
+	}
+
+	metadata, err := scriptMetadata(globals, defaultName)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return &Script{Path: path, Metadata: metadata, run: run, limits: limits}, nil
+}
+
+// scriptMetadata reads name, description, parameters and required from the globals
+func scriptMetadata(globals starlark.StringDict, defaultName string) (conduit.ToolMetadata, error) {
+	metadata := conduit.ToolMetadata{Name: defaultName}
+
+	if v, ok := globals["name"]; ok {
+		s, ok := starlark.AsString(v)
+		if !ok || s == "" {
+			return metadata, fmt.Errorf("name must be a non-empty string")
+		}
+		metadata.Name = s
+	}
+	if v, ok := globals["description"]; ok {
+		s, ok := starlark.AsString(v)
+		if !ok {
+			return metadata, fmt.Errorf("description must be a string")
+		}
+		metadata.Description = s
+	}
+
+	properties := map[string]interface{}{}
+	if v, ok := globals["parameters"]; ok {
+		converted, err := fromStarlark(v)
+		if err != nil {
+			return metadata, fmt.Errorf("parameters: %w", err)
+		}
+		if properties, ok = converted.(map[string]interface{}); !ok {
+			return metadata, fmt.Errorf("parameters must be a dict")
+		}
+	}
+
+	required := []string{}
+	if v, ok := globals["required"]; ok {
+		converted, err := fromStarlark(v)
+		if err != nil {
+			return metadata, fmt.Errorf("required: %w", err)
+		}
+		list, ok := converted.([]interface{})
+		if !ok {
+			return metadata, fmt.Errorf("required must be a list")
+		}
+		for _, item := range list {
+			s, ok := item.(string)
+			if !ok {
+				return metadata, fmt.Errorf("required must contain strings")
+			}
+			required = append(required, s)
+		}
+	}
+
+	metadata.InputSchema = conduit.CreateObjectSchema(properties, required)
+	return metadata, nil
+}
+
+// ToolFunc returns a ToolFunc that calls the script's run function
+func (s *Script) ToolFunc() mcp.ToolFunc {
+	return func(params map[string]interface{}, memory *mcp.Memory) (interface{}, error) {
+		return s.Call(context.Background(), params, memory)
+	}
+}
+
+// Call runs the script with params and memory under its limits
+func (s *Script) Call(ctx context.Context, params map[string]interface{}, memory *mcp.Memory) (interface{}, error) {
+	args, err := toStarlark(params)
+	if err != nil {
+		return nil, fmt.Errorf("script %s: %w", s.Metadata.Name, err)
+	}
+
+	thread, cancel := newThread(s.Metadata.Name, s.limits)
+	defer cancel()
+	stop := context.AfterFunc(ctx, func() { thread.Cancel(ctx.Err().Error()) })
+	defer stop()
+
+	result, err := starlark.Call(thread, s.run, starlark.Tuple{args, newMemoryValue(memory)}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("script %s: %w", s.Metadata.Name, err)
+	}
+	return fromStarlark(result)
+}
+
+// newThread creates a thread with the step limit and a timer enforcing the timeout.
+// Load is left nil, so scripts cannot import other files.
+func newThread(name string, limits Limits) (*starlark.Thread, func()) {
+	thread := &starlark.Thread{
+		Name: name,
+		Print: func(t *starlark.Thread, msg string) {
+			slog.Debug("script print", "script", t.Name, "message", msg)
+		},
+	}
+	thread.SetMaxExecutionSteps(limits.MaxSteps)
+	timer := time.AfterFunc(limits.Timeout, func() {
+		thread.Cancel(fmt.Sprintf("timed out after %s", limits.Timeout))
+	})
+	return thread, func() { timer.Stop() }
+}
+
+// predeclared are the globals available to every script
+func predeclared() starlark.StringDict {
+	return starlark.StringDict{
+		"json": starlarkjson.Module,
+	}
+}
@@ -0,0 +1,250 @@
+package scripting
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	conduit "github.com/benozo/conduit/lib"
+	"github.com/benozo/conduit/mcp"
+)
+
+const slugScript = `
+description = "Turn text into a URL slug"
+parameters = {"text": {"type": "string"}}
+required = ["text"]
+
+def run(params, memory):
+    memory.set("calls", memory.get("calls", 0) + 1)
+    return {"slug": params["text"].lower().replace(" ", "-"), "n": params.get("n", 1) * 2}
+`
+
+func TestCompileAndCall(t *testing.T) {
+	script, err := CompileScript("slug.star", "slug", []byte(slugScript), Limits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantSchema := map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{"text": map[string]interface{}{"type": "string"}},
+		"required":   []string{"text"},
+	}
+	if script.Metadata.Name != "slug" || script.Metadata.Description != "Turn text into a URL slug" ||
+		!reflect.DeepEqual(script.Metadata.InputSchema, wantSchema) {
+		t.Errorf("metadata %+v", script.Metadata)
+	}
+
+	memory := mcp.NewMemory()
+	for i := 0; i < 2; i++ {
+		got, err := script.ToolFunc()(map[string]interface{}{"text": "Hello World", "n": float64(3)}, memory)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := map[string]interface{}{"slug": "hello-world", "n": float64(6)}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+	if calls := memory.Get("calls"); calls != float64(2) {
+		t.Errorf("memory holds %v calls, want 2", calls)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	loop := "def run(params, memory):\n    n = 0\n    for i in range(100000000):\n        n += i\n    return n\n"
+	tests := []struct {
+		name    string
+		src     string
+		limits  Limits
+		ctx     func() (context.Context, context.CancelFunc)
+		wantErr string
+	}{
+		{name: "step limit", src: loop, limits: Limits{MaxSteps: 1000}, wantErr: "too many steps"},
+		{name: "timeout", src: loop, limits: Limits{MaxSteps: 1 << 40, Timeout: 20 * time.Millisecond}, wantErr: "timed out after 20ms"},
+		{name: "caller cancel", src: loop, limits: Limits{MaxSteps: 1 << 40, Timeout: time.Minute},
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 20*time.Millisecond)
+			}, wantErr: "deadline exceeded"},
+		{name: "no filesystem", src: "def run(params, memory):\n    return open('/etc/passwd')\n", wantErr: "undefined: open"},
+		{name: "unrepresentable result", src: "def run(params, memory):\n    return run\n", wantErr: "cannot return a value of type function"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			script, err := CompileScript("t.star", "t", []byte(tt.src), tt.limits)
+			if err == nil {
+				ctx, cancel := context.Background(), func() {}
+				if tt.ctx != nil {
+					ctx, cancel = tt.ctx()
+				}
+				defer cancel()
+				start := time.Now()
+				_, err = script.Call(ctx, nil, nil)
+				if time.Since(start) > 5*time.Second {
+					t.Errorf("call took %s", time.Since(start))
+				}
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("got %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"syntax", "def run(:\n", "t.star"},
+		{"no run", "x = 1\n", "must define run"},
+		{"run arity", "def run(params):\n    return 1\n", "exactly two parameters"},
+		{"load", "load('other.star', 'x')\ndef run(p, m):\n    return 1\n", "load"},
+		{"bad name", "name = ''\ndef run(p, m):\n    return 1\n", "name must be a non-empty string"},
+		{"bad parameters", "parameters = [1]\ndef run(p, m):\n    return 1\n", "parameters must be a dict"},
+		{"bad required", "required = [1]\ndef run(p, m):\n    return 1\n", "required must contain strings"},
+		{"top-level loop", "n = [i for i in range(100000000)]\ndef run(p, m):\n    return 1\n", "too many steps"},
+	}
+	for _, tt := range tests {
+		_, err := CompileScript("t.star", "t", []byte(tt.src), Limits{MaxSteps: 10000})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+// fakeServer records registered tools like EnhancedServer
+type fakeServer struct {
+	mu    sync.Mutex
+	tools map[string]conduit.ToolMetadata
+	funcs map[string]mcp.ToolFunc
+}
+
+func (s *fakeServer) RegisterToolWithSchema(name string, tool mcp.ToolFunc, metadata conduit.ToolMetadata) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tools[name] = metadata
+	s.funcs[name] = tool
+}
+
+func (s *fakeServer) UnregisterTool(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tools, name)
+	delete(s.funcs, name)
+}
+
+func (s *fakeServer) names() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var names []string
+	for name := range s.tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *fakeServer) call(t *testing.T, name string) interface{} {
+	t.Helper()
+	s.mu.Lock()
+	fn := s.funcs[name]
+	s.mu.Unlock()
+	got, err := fn(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+func TestLoaderReload(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, src string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	returns := func(v string) string { return "def run(params, memory):\n    return " + v + "\n" }
+
+	write("one.star", returns("1"))
+	write("two.star", "name = 'second'\n"+returns("2"))
+	write("notes.txt", "not a script")
+
+	server := &fakeServer{tools: map[string]conduit.ToolMetadata{}, funcs: map[string]mcp.ToolFunc{}}
+	loader := NewLoader(dir, server, Limits{})
+	names, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(names, []string{"one", "second"}) || !reflect.DeepEqual(server.names(), names) {
+		t.Fatalf("loaded %v, server has %v", names, server.names())
+	}
+
+	// Changed, added, broken and deleted scripts
+	write("one.star", returns("'updated'"))
+	write("three.star", returns("3"))
+	write("two.star", "def run(:\n")
+	if err := loader.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if got := server.call(t, "one"); got != "updated" {
+		t.Errorf("one returned %v after reload", got)
+	}
+	if got := server.call(t, "second"); got != float64(2) {
+		t.Errorf("a broken edit replaced the working script: %v", got)
+	}
+	if !reflect.DeepEqual(server.names(), []string{"one", "second", "three"}) {
+		t.Errorf("tools after reload: %v", server.names())
+	}
+
+	// Renaming a tool and deleting a file unregister the old names
+	write("one.star", "name = 'first'\n"+returns("1"))
+	os.Remove(filepath.Join(dir, "three.star"))
+	write("zdup.star", "name = 'first'\n"+returns("'dup'"))
+	if err := loader.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(server.names(), []string{"first", "second"}) {
+		t.Errorf("tools after rename and delete: %v", server.names())
+	}
+	if got := server.call(t, "first"); got != float64(1) {
+		t.Errorf("duplicate name took over the tool: %v", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		loader.Watch(ctx, time.Millisecond)
+		close(done)
+	}()
+	write("four.star", returns("4"))
+	deadline := time.Now().Add(5 * time.Second)
+	for !reflect.DeepEqual(server.names(), []string{"first", "four", "second"}) && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	<-done
+	if !reflect.DeepEqual(server.names(), []string{"first", "four", "second"}) {
+		t.Errorf("Watch did not pick up the new script: %v", server.names())
+	}
+}
+
+func TestLoaderRejectsDuplicates(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.star", "b.star"} {
+		os.WriteFile(filepath.Join(dir, name), []byte("name = 'same'\ndef run(p, m):\n    return 1\n"), 0o644)
+	}
+	server := &fakeServer{tools: map[string]conduit.ToolMetadata{}, funcs: map[string]mcp.ToolFunc{}}
+	if _, err := NewLoader(dir, server, Limits{}).Load(); err == nil || !strings.Contains(err.Error(), "both define tool same") {
+		t.Errorf("got %v", err)
+	}
+	if _, err := NewLoader(filepath.Join(dir, "missing"), server, Limits{}).Load(); err == nil {
+		t.Error("expected an error for a missing directory")
+	}
+}
@@ -0,0 +1,20 @@
+// Package offline embeds tiktoken's BPE rank files, so lib/tokens can count
+// OpenAI models exactly without downloading them. Importing it adds several
+// megabytes to the binary; nothing is installed until Install is called.
+package offline
+
+import (
+	"github.com/benozo/conduit/lib/tokens"
+	"github.com/pkoukk/tiktoken-go"
+	tiktoken_loader "github.com/pkoukk/tiktoken-go-loader"
+)
+
+// Loader returns a BPE loader serving the embedded rank files
+func Loader() tiktoken.BpeLoader {
+	return tiktoken_loader.NewOfflineLoader()
+}
+
+// Install makes lib/tokens count OpenAI models with the embedded rank files
+func Install() {
+	tokens.SetBPELoader(Loader())
+}
@@ -0,0 +1,18 @@
+package offline
+
+import (
+	"testing"
+
+	"github.com/benozo/conduit/lib/tokens"
+)
+
+func TestInstall(t *testing.T) {
+	if got := tokens.Count("gpt-4o", "hello world"); got != 3 {
+		t.Fatalf("before Install: got %d, want the heuristic count 3", got)
+	}
+	Install()
+	t.Cleanup(func() { tokens.SetBPELoader(nil) })
+	if got := tokens.Count("gpt-4o", "hello world"); got != 2 {
+		t.Errorf("after Install: got %d, want the BPE count 2", got)
+	}
+}
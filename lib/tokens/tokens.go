@@ -0,0 +1,248 @@
+// Package tokens counts prompt tokens and keeps conversations inside a
+// model's context window. Once a BPE loader is installed with SetBPELoader,
+// OpenAI models are counted with their BPE encoding; everything else uses a
+// character heuristic that errs on the high side.
+package tokens
+
+import (
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/benozo/conduit/mcp"
+	"github.com/pkoukk/tiktoken-go"
+)
+
+// DefaultContextWindow is used for models missing from the known-model table
+const DefaultContextWindow = 8192
+
+// messageOverhead approximates the role and separator tokens chat formats
+// add around each message
+const messageOverhead = 4
+
+// Counter counts the tokens in a piece of text
+type Counter interface {
+	Count(text string) int
+}
+
+// Heuristic estimates tokens as one per four characters, rounded up
+type Heuristic struct{}
+
+// Count implements Counter
+func (Heuristic) Count(text string) int {
+	n := len([]rune(text))
+	return (n + 3) / 4
+}
+
+// bpe counts with a tiktoken encoding
+type bpe struct {
+	enc *tiktoken.Tiktoken
+}
+
+func (b bpe) Count(text string) int {
+	return len(b.enc.EncodeOrdinary(text))
+}
+
+// SetBPELoader makes OpenAI models count with their BPE encoding, reading
+// the rank files through loader. It replaces tiktoken's global loader. Until
+// it is called every model uses the heuristic, so counting never fetches
+// rank files from the network; lib/tokens/offline installs a loader with the
+// files embedded. A nil loader goes back to the heuristic.
+func SetBPELoader(loader tiktoken.BpeLoader) {
+	bpeMu.Lock()
+	defer bpeMu.Unlock()
+	if loader != nil {
+		tiktoken.SetBpeLoader(loader)
+	}
+	bpeEnabled = loader != nil
+	// Encodings that failed to load under the previous loader get another try
+	encodings = sync.Map{}
+}
+
+var (
+	bpeMu      sync.RWMutex
+	bpeEnabled bool
+)
+
+// ForModel returns the counter for a model name
+func ForModel(model string) Counter {
+	bpeMu.RLock()
+	defer bpeMu.RUnlock()
+	if !bpeEnabled {
+		return Heuristic{}
+	}
+	name := normalize(model)
+	if encoding := encodingFor(name); encoding != "" {
+		if enc := loadEncoding(encoding); enc != nil {
+			return bpe{enc: enc}
+		}
+	}
+	return Heuristic{}
+}
+
+// Count counts text's tokens for model
+func Count(model, text string) int {
+	return ForModel(model).Count(text)
+}
+
+// CountMessages counts a conversation, including per-message overhead and
+// any tool calls
+func CountMessages(counter Counter, messages []mcp.ChatMessage) int {
+	total := 0
+	for _, msg := range messages {
+		total += countMessage(counter, msg)
+	}
+	return total
+}
+
+func countMessage(counter Counter, msg mcp.ChatMessage) int {
+	n := messageOverhead + counter.Count(msg.Content)
+	if msg.Name != "" {
+		n += counter.Count(msg.Name)
+	}
+	for _, call := range msg.ToolCalls {
+		args, _ := json.Marshal(call.Arguments)
+		n += messageOverhead + counter.Count(call.Name) + counter.Count(string(args))
+	}
+	return n
+}
+
+// normalize lowercases a model name and drops provider or organisation
+// prefixes such as "openai/" or "models/"
+func normalize(model string) string {
+	model = strings.ToLower(strings.TrimSpace(model))
+	if i := strings.LastIndex(model, "/"); i >= 0 {
+		model = model[i+1:]
+	}
+	return model
+}
+
+// encodingFor returns the tiktoken encoding of an OpenAI model, or "" for
+// models from other families
+func encodingFor(model string) string {
+	switch {
+	case strings.HasPrefix(model, "gpt-3.5"), strings.HasPrefix(model, "text-embedding"),
+		model == "gpt-4", strings.HasPrefix(model, "gpt-4-"):
+		return tiktoken.MODEL_CL100K_BASE
+	case strings.HasPrefix(model, "gpt-"), strings.HasPrefix(model, "chatgpt-"),
+		strings.HasPrefix(model, "o1"), strings.HasPrefix(model, "o3"), strings.HasPrefix(model, "o4"):
+		return tiktoken.MODEL_O200K_BASE
+	}
+	return ""
+}
+
+// encoding is a tiktoken encoding, parsed from the loader's rank files on
+// first use
+type encoding struct {
+	once sync.Once
+	enc  *tiktoken.Tiktoken
+}
+
+var encodings sync.Map
+
+// loadEncoding returns the named encoding, or nil when it could not be
+// loaded. Each encoding is loaded once.
+func loadEncoding(name string) *tiktoken.Tiktoken {
+	value, _ := encodings.LoadOrStore(name, &encoding{})
+	e := value.(*encoding)
+	e.once.Do(func() {
+		enc, err := tiktoken.GetEncoding(name)
+		if err != nil {
+			slog.Warn("token encoding unavailable, using heuristic counts", "encoding", name, "error", err)
+			return
+		}
+		e.enc = enc
+	})
+	return e.enc
+}
+
+// Truncate shortens text to at most max tokens, keeping the beginning and
+// marking the cut
+func Truncate(counter Counter, text string, max int) string {
+	if counter.Count(text) <= max {
+		return text
+	}
+	const marker = "\n...[truncated]"
+	max -= counter.Count(marker)
+	if max <= 0 {
+		return ""
+	}
+
+	runes := []rune(text)
+	lo, hi := 0, len(runes)
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if counter.Count(string(runes[:mid])) <= max {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	return string(runes[:lo]) + marker
+}
+
+// contextWindows maps model name fragments to context sizes in tokens. More
+// specific entries come first.
+var contextWindows = []struct {
+	match  string
+	tokens int
+}{
+	{"gpt-4.1", 1047576},
+	{"gpt-5", 400000},
+	{"gpt-4o", 128000},
+	{"gpt-4-turbo", 128000},
+	{"gpt-4-32k", 32768},
+	{"gpt-4", 8192},
+	{"gpt-3.5-turbo", 16385},
+	{"o1-mini", 128000},
+	{"o1", 200000},
+	{"o3", 200000},
+	{"o4", 200000},
+	{"claude", 200000},
+	{"gemini-1.5-pro", 2097152},
+	{"gemini", 1048576},
+	{"llama-3.1", 131072},
+	{"llama-3.2", 131072},
+	{"llama-3.3", 131072},
+	{"llama3.1", 131072},
+	{"llama3.2", 131072},
+	{"llama3.3", 131072},
+	{"llama3", 8192},
+	{"llama-3", 8192},
+	{"mistral-large", 131072},
+	{"mistral-nemo", 131072},
+	{"mistral", 32768},
+	{"mixtral", 32768},
+	{"qwen3", 40960},
+	{"qwen2.5", 32768},
+	{"deepseek", 65536},
+	{"phi3", 4096},
+	{"gemma2", 8192},
+	{"gemma3", 131072},
+}
+
+// ContextWindow returns the context size of a known model, or
+// DefaultContextWindow
+func ContextWindow(model string) int {
+	name := normalize(model)
+	for _, w := range contextWindows {
+		if strings.HasPrefix(name, w.match) || strings.Contains(name, "-"+w.match) {
+			return w.tokens
+		}
+	}
+	return DefaultContextWindow
+}
+
+// Budget returns the prompt tokens available in a context window after
+// reserving room for the response. Reservations larger than half the
+// window are capped at a quarter of it.
+func Budget(window, maxOutput int) int {
+	if window <= 0 {
+		window = DefaultContextWindow
+	}
+	if maxOutput <= 0 || maxOutput > window/2 {
+		maxOutput = window / 4
+	}
+	return window - maxOutput
+}
@@ -0,0 +1,259 @@
+package tokens
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/benozo/conduit/mcp"
+	tiktoken_loader "github.com/pkoukk/tiktoken-go-loader"
+)
+
+// words counts one token per space-separated word, which keeps the
+// arithmetic in these tests readable
+type words struct{}
+
+func (words) Count(text string) int { return len(strings.Fields(text)) }
+
+func TestHeuristic(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"a", 1},
+		{"abcd", 1},
+		{"abcde", 2},
+		{"héllo wörld", 3},
+	}
+	for _, tt := range tests {
+		if got := (Heuristic{}).Count(tt.text); got != tt.want {
+			t.Errorf("Count(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+	if _, ok := ForModel("llama3.2").(Heuristic); !ok {
+		t.Error("non-OpenAI models should use the heuristic")
+	}
+}
+
+func TestBPE(t *testing.T) {
+	if _, ok := ForModel("gpt-4o").(Heuristic); !ok {
+		t.Fatal("OpenAI models should use the heuristic until a loader is installed")
+	}
+	SetBPELoader(tiktoken_loader.NewOfflineLoader())
+	t.Cleanup(func() { SetBPELoader(nil) })
+
+	tests := []struct {
+		model string
+		text  string
+		want  int
+	}{
+		{"gpt-4o", "hello world", 2},
+		{"openai/gpt-4", "hello world", 2},
+		{"gpt-4o-mini", "", 0},
+	}
+	for _, tt := range tests {
+		counter := ForModel(tt.model)
+		if _, ok := counter.(bpe); !ok {
+			t.Fatalf("ForModel(%q) = %T, want the BPE counter", tt.model, counter)
+		}
+		if got := counter.Count(tt.text); got != tt.want {
+			t.Errorf("%s: Count(%q) = %d, want %d", tt.model, tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestEncodingFor(t *testing.T) {
+	tests := map[string]string{
+		"gpt-4":         "cl100k_base",
+		"gpt-4-turbo":   "cl100k_base",
+		"gpt-3.5-turbo": "cl100k_base",
+		"gpt-4o-mini":   "o200k_base",
+		"o3-mini":       "o200k_base",
+		"claude-3-opus": "",
+		"llama3.2":      "",
+	}
+	for model, want := range tests {
+		if got := encodingFor(normalize(model)); got != want {
+			t.Errorf("encodingFor(%q) = %q, want %q", model, got, want)
+		}
+	}
+	if got := normalize(" OpenAI/GPT-4o "); got != "gpt-4o" {
+		t.Errorf("normalize = %q", got)
+	}
+}
+
+func TestContextWindow(t *testing.T) {
+	tests := map[string]int{
+		"gpt-4o-mini":                        128000,
+		"gpt-4":                              8192,
+		"gpt-4-32k":                          32768,
+		"models/gemini-1.5-pro":              2097152,
+		"meta-llama/Meta-Llama-3.1-8B":       131072,
+		"llama3.2:latest":                    131072,
+		"mistralai/Mistral-Nemo-Instruct":    131072,
+		"claude-sonnet-4-5":                  200000,
+		"some-unknown-model":                 DefaultContextWindow,
+		"accounts/fireworks/models/qwen3-8b": 40960,
+	}
+	for model, want := range tests {
+		if got := ContextWindow(model); got != want {
+			t.Errorf("ContextWindow(%q) = %d, want %d", model, got, want)
+		}
+	}
+
+	if got := Budget(1000, 100); got != 900 {
+		t.Errorf("Budget(1000, 100) = %d", got)
+	}
+	if got := Budget(1000, 800); got != 750 {
+		t.Errorf("oversized reservation: Budget = %d, want 750", got)
+	}
+	if got := Budget(0, 0); got != DefaultContextWindow-DefaultContextWindow/4 {
+		t.Errorf("Budget(0, 0) = %d", got)
+	}
+}
+
+func TestCountMessages(t *testing.T) {
+	messages := []mcp.ChatMessage{
+		{Role: mcp.RoleUser, Content: "one two three"},
+		{Role: mcp.RoleAssistant, ToolCalls: []mcp.ChatToolCall{{Name: "search", Arguments: map[string]interface{}{"q": "x"}}}},
+		{Role: mcp.RoleTool, Name: "search", Content: "found"},
+	}
+	// 4+3, 4+0 + (4+1+1), 4+1+1
+	if got := CountMessages(words{}, messages); got != 23 {
+		t.Errorf("CountMessages = %d, want 23", got)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	text := "a b c d e f g h i j"
+	if got := Truncate(words{}, text, 10); got != text {
+		t.Errorf("text within the limit changed: %q", got)
+	}
+	got := Truncate(words{}, text, 5)
+	if !strings.HasSuffix(got, "...[truncated]") || words.Count(words{}, got) > 5 {
+		t.Errorf("Truncate = %q", got)
+	}
+	if !strings.HasPrefix(got, "a b c d") {
+		t.Errorf("Truncate should keep the beginning, got %q", got)
+	}
+	if got := Truncate(words{}, text, 1); got != "" {
+		t.Errorf("no room for the marker: %q", got)
+	}
+}
+
+// conversation has a system prompt and alternating turns, with a tool call
+// in the middle. Every message costs 4 + len(words).
+func conversation() []mcp.ChatMessage {
+	return []mcp.ChatMessage{
+		{Role: mcp.RoleSystem, Content: "be helpful"},
+		{Role: mcp.RoleUser, Content: "first question here"},
+		{Role: mcp.RoleAssistant, Content: "first answer", ToolCalls: []mcp.ChatToolCall{{Name: "t"}}},
+		{Role: mcp.RoleTool, Content: "tool output"},
+		{Role: mcp.RoleAssistant, Content: "second answer"},
+		{Role: mcp.RoleUser, Content: "latest question"},
+	}
+}
+
+func contents(messages []mcp.ChatMessage) []string {
+	var out []string
+	for _, msg := range messages {
+		out = append(out, msg.Content)
+	}
+	return out
+}
+
+func TestFitUnderBudget(t *testing.T) {
+	messages := conversation()
+	got, err := Fit(context.Background(), messages, 1000, words{}, nil)
+	if err != nil || !reflect.DeepEqual(got, messages) {
+		t.Errorf("Fit changed a conversation within budget: %v, %v", contents(got), err)
+	}
+}
+
+func TestDropOldest(t *testing.T) {
+	// system 6, latest 6, second answer 6, tool 6 (orphaned once its call is dropped)
+	got, err := Fit(context.Background(), conversation(), 24, words{}, DropOldest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"be helpful", "second answer", "latest question"}
+	if !reflect.DeepEqual(contents(got), want) {
+		t.Errorf("DropOldest kept %q, want %q", contents(got), want)
+	}
+
+	// Only the system prompt and a truncated latest message fit
+	long := []mcp.ChatMessage{
+		{Role: mcp.RoleSystem, Content: "sys"},
+		{Role: mcp.RoleUser, Content: strings.Repeat("word ", 50)},
+	}
+	got, _ = Fit(context.Background(), long, 20, words{}, DropOldest())
+	if len(got) != 2 || !strings.HasSuffix(got[1].Content, "...[truncated]") || CountMessages(words{}, got) > 20 {
+		t.Errorf("oversized latest message: %q (%d tokens)", contents(got), CountMessages(words{}, got))
+	}
+}
+
+func TestKeepLast(t *testing.T) {
+	got, _ := Fit(context.Background(), conversation(), 40, words{}, KeepLast(3))
+	// The last three are tool, second answer, latest; the orphaned tool result goes
+	want := []string{"be helpful", "second answer", "latest question"}
+	if !reflect.DeepEqual(contents(got), want) {
+		t.Errorf("KeepLast kept %q, want %q", contents(got), want)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	var prompt string
+	model := func(ctx mcp.ContextInput, req mcp.MCPRequest, memory *mcp.Memory, onToken mcp.StreamCallback) (string, error) {
+		prompt = ctx.Inputs["query"].(string)
+		return " they asked a question ", nil
+	}
+
+	got, err := Fit(context.Background(), conversation(), 32, words{}, Summarize(model, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"be helpful", "Summary of the earlier conversation: they asked a question", "second answer", "latest question"}
+	if !reflect.DeepEqual(contents(got), want) {
+		t.Errorf("Summarize kept %q, want %q", contents(got), want)
+	}
+	if !strings.Contains(prompt, "first question here") || strings.Contains(prompt, "latest question") {
+		t.Errorf("summarizer prompt should cover only the older turns:\n%s", prompt)
+	}
+
+	failing := func(mcp.ContextInput, mcp.MCPRequest, *mcp.Memory, mcp.StreamCallback) (string, error) {
+		return "", errors.New("model down")
+	}
+	got, err = Fit(context.Background(), conversation(), 32, words{}, Summarize(failing, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"be helpful", "second answer", "latest question"}; !reflect.DeepEqual(contents(got), want) {
+		t.Errorf("failed summary kept %q, want %q", contents(got), want)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Fit(ctx, conversation(), 32, words{}, Summarize(failing, 2)); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled summary returned %v", err)
+	}
+}
+
+func TestParseStrategy(t *testing.T) {
+	model := func(mcp.ContextInput, mcp.MCPRequest, *mcp.Memory, mcp.StreamCallback) (string, error) {
+		return "", nil
+	}
+	for _, name := range []string{"", "drop_oldest", "KEEP_LAST", "summarize"} {
+		if s, err := ParseStrategy(name, 0, model); err != nil || s == nil {
+			t.Errorf("ParseStrategy(%q) = %v", name, err)
+		}
+	}
+	if _, err := ParseStrategy("summarize", 0, nil); err == nil {
+		t.Error("summarize without a model should fail")
+	}
+	if _, err := ParseStrategy("newest", 0, model); err == nil || !strings.Contains(err.Error(), "unknown context strategy") {
+		t.Errorf("unknown strategy error %v", err)
+	}
+}
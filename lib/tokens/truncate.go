@@ -0,0 +1,168 @@
+package tokens
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/benozo/conduit/mcp"
+)
+
+// Strategy names accepted by ParseStrategy
+const (
+	StrategyDropOldest = "drop_oldest"
+	StrategyKeepLast   = "keep_last"
+	StrategySummarize  = "summarize"
+)
+
+// DefaultKeepTurns is the number of recent messages KeepLast and Summarize
+// keep when no count is given
+const DefaultKeepTurns = 6
+
+// Strategy shortens a conversation to fit within budget tokens. System
+// messages and the latest message are kept whenever they fit.
+type Strategy func(ctx context.Context, messages []mcp.ChatMessage, budget int, counter Counter) ([]mcp.ChatMessage, error)
+
+// Fit returns messages unchanged when they fit within budget, otherwise the
+// result of strategy (DropOldest when nil)
+func Fit(ctx context.Context, messages []mcp.ChatMessage, budget int, counter Counter, strategy Strategy) ([]mcp.ChatMessage, error) {
+	if CountMessages(counter, messages) <= budget {
+		return messages, nil
+	}
+	if strategy == nil {
+		strategy = DropOldest()
+	}
+	return strategy(ctx, messages, budget, counter)
+}
+
+// ParseStrategy returns the named strategy. keepTurns applies to keep_last
+// and summarize; summarize calls model to condense older turns.
+func ParseStrategy(name string, keepTurns int, model mcp.ModelFunc) (Strategy, error) {
+	switch strings.ToLower(name) {
+	case "", StrategyDropOldest:
+		return DropOldest(), nil
+	case StrategyKeepLast:
+		return KeepLast(keepTurns), nil
+	case StrategySummarize:
+		if model == nil {
+			return nil, fmt.Errorf("context strategy %q needs a model", name)
+		}
+		return Summarize(model, keepTurns), nil
+	default:
+		return nil, fmt.Errorf("unknown context strategy %q (want %s, %s or %s)", name, StrategyDropOldest, StrategyKeepLast, StrategySummarize)
+	}
+}
+
+// DropOldest removes the oldest non-system messages until the conversation
+// fits. Tool results whose call was dropped go with it. If the latest
+// message alone is too long, its content is truncated.
+func DropOldest() Strategy {
+	return func(ctx context.Context, messages []mcp.ChatMessage, budget int, counter Counter) ([]mcp.ChatMessage, error) {
+		return dropOldest(messages, budget, counter), nil
+	}
+}
+
+// KeepLast keeps the system messages and the last n other messages, then
+// drops older ones if that is still over budget
+func KeepLast(n int) Strategy {
+	if n <= 0 {
+		n = DefaultKeepTurns
+	}
+	return func(ctx context.Context, messages []mcp.ChatMessage, budget int, counter Counter) ([]mcp.ChatMessage, error) {
+		system, rest := splitSystem(messages)
+		if len(rest) > n {
+			rest = trimOrphans(rest[len(rest)-n:])
+		}
+		return dropOldest(append(system, rest...), budget, counter), nil
+	}
+}
+
+// Summarize asks model to condense all but the last keepLast messages into
+// a summary, which replaces them as a system message. If the model call
+// fails the older turns are dropped instead.
+func Summarize(model mcp.ModelFunc, keepLast int) Strategy {
+	if keepLast <= 0 {
+		keepLast = DefaultKeepTurns
+	}
+	return func(ctx context.Context, messages []mcp.ChatMessage, budget int, counter Counter) ([]mcp.ChatMessage, error) {
+		system, rest := splitSystem(messages)
+		if len(rest) <= keepLast {
+			return dropOldest(messages, budget, counter), nil
+		}
+		older, recent := rest[:len(rest)-keepLast], trimOrphans(rest[len(rest)-keepLast:])
+
+		// The transcript given to the summarizer is itself bounded by the budget
+		transcript := Truncate(counter, mcp.FlattenMessages(older, nil), budget/2)
+		prompt := "Summarize the following conversation in a few sentences. Keep facts, decisions, names and open questions; omit pleasantries.\n\n" + transcript
+
+		input := mcp.ContextInput{Inputs: map[string]interface{}{"query": prompt}}.WithContext(ctx)
+		summary, err := model(input, mcp.MCPRequest{Contexts: []mcp.ContextInput{input}}, nil, nil)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			slog.WarnContext(ctx, "summarizing conversation failed, dropping older turns", "error", err)
+			return dropOldest(append(system, recent...), budget, counter), nil
+		}
+
+		result := append(system, mcp.ChatMessage{
+			Role:    mcp.RoleSystem,
+			Content: "Summary of the earlier conversation: " + strings.TrimSpace(summary),
+		})
+		return dropOldest(append(result, recent...), budget, counter), nil
+	}
+}
+
+// dropOldest implements DropOldest
+func dropOldest(messages []mcp.ChatMessage, budget int, counter Counter) []mcp.ChatMessage {
+	system, rest := splitSystem(messages)
+	used := CountMessages(counter, system)
+	if used+CountMessages(counter, rest) <= budget {
+		return messages
+	}
+
+	// Walk back from the newest message, keeping as many as fit
+	start := len(rest)
+	for start > 0 {
+		n := countMessage(counter, rest[start-1])
+		if used+n > budget {
+			break
+		}
+		used += n
+		start--
+	}
+	kept := trimOrphans(rest[start:])
+
+	if len(kept) == 0 && len(rest) > 0 {
+		// Not even the latest message fits; keep a truncated copy of it
+		last := rest[len(rest)-1]
+		room := budget - CountMessages(counter, system) - messageOverhead
+		last.Content = Truncate(counter, last.Content, room)
+		kept = []mcp.ChatMessage{last}
+	}
+
+	result := make([]mcp.ChatMessage, 0, len(system)+len(kept))
+	result = append(result, system...)
+	return append(result, kept...)
+}
+
+// splitSystem separates the system messages from the rest, preserving order
+func splitSystem(messages []mcp.ChatMessage) (system, rest []mcp.ChatMessage) {
+	for _, msg := range messages {
+		if msg.Role == mcp.RoleSystem {
+			system = append(system, msg)
+		} else {
+			rest = append(rest, msg)
+		}
+	}
+	return system, rest
+}
+
+// trimOrphans drops leading tool results whose assistant call was cut off
+func trimOrphans(messages []mcp.ChatMessage) []mcp.ChatMessage {
+	for len(messages) > 0 && messages[0].Role == mcp.RoleTool {
+		messages = messages[1:]
+	}
+	return messages
+}
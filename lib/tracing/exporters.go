@@ -0,0 +1,199 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OTLPExporter sends spans to an OpenTelemetry collector using OTLP/HTTP with JSON encoding
+type OTLPExporter struct {
+	endpoint string
+	headers  map[string]string
+	client   *http.Client
+}
+
+// NewOTLPExporter creates an OTLP/HTTP exporter. The endpoint may be a collector
+// base URL (e.g. http://localhost:4318) or the full /v1/traces URL.
+func NewOTLPExporter(endpoint string, headers map[string]string) *OTLPExporter {
+	endpoint = strings.TrimRight(endpoint, "/")
+	if !strings.HasSuffix(endpoint, "/v1/traces") {
+		endpoint += "/v1/traces"
+	}
+	return &OTLPExporter{
+		endpoint: endpoint,
+		headers:  headers,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// ExportSpans posts a batch of spans to the collector
+func (e *OTLPExporter) ExportSpans(ctx context.Context, spans []SpanData) error {
+	if len(spans) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(otlpPayload(spans))
+	if err != nil {
+		return fmt.Errorf("failed to encode spans: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send spans: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("collector returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// Shutdown is a no-op; the exporter holds no resources
+func (e *OTLPExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// otlpPayload converts spans to the OTLP JSON request shape, grouped by service
+func otlpPayload(spans []SpanData) map[string]interface{} {
+	byService := make(map[string][]map[string]interface{})
+	var services []string
+	for _, s := range spans {
+		if _, ok := byService[s.Service]; !ok {
+			services = append(services, s.Service)
+		}
+		byService[s.Service] = append(byService[s.Service], otlpSpan(s))
+	}
+
+	resourceSpans := make([]map[string]interface{}, 0, len(services))
+	for _, service := range services {
+		resourceSpans = append(resourceSpans, map[string]interface{}{
+			"resource": map[string]interface{}{
+				"attributes": otlpAttributes(map[string]interface{}{"service.name": service}),
+			},
+			"scopeSpans": []map[string]interface{}{{
+				"scope": map[string]interface{}{"name": "github.com/benozo/conduit"},
+				"spans": byService[service],
+			}},
+		})
+	}
+	return map[string]interface{}{"resourceSpans": resourceSpans}
+}
+
+// otlpSpan converts a single span to OTLP JSON
+func otlpSpan(s SpanData) map[string]interface{} {
+	span := map[string]interface{}{
+		"traceId":           s.TraceID,
+		"spanId":            s.SpanID,
+		"name":              s.Name,
+		"kind":              otlpKind(s.Kind),
+		"startTimeUnixNano": strconv.FormatInt(s.StartTime.UnixNano(), 10),
+		"endTimeUnixNano":   strconv.FormatInt(s.EndTime.UnixNano(), 10),
+		"attributes":        otlpAttributes(s.Attributes),
+		"status":            map[string]interface{}{"code": int(s.StatusCode), "message": s.StatusMessage},
+	}
+	if s.ParentSpanID != "" {
+		span["parentSpanId"] = s.ParentSpanID
+	}
+	return span
+}
+
+// otlpKind maps span kinds to OTLP enum values (internal=1, server=2, client=3)
+func otlpKind(kind SpanKind) int {
+	switch kind {
+	case SpanKindServer:
+		return 2
+	case SpanKindClient:
+		return 3
+	default:
+		return 1
+	}
+}
+
+// otlpAttributes converts an attribute map to OTLP key/value pairs in key order
+func otlpAttributes(attrs map[string]interface{}) []map[string]interface{} {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	out := make([]map[string]interface{}, 0, len(keys))
+	for _, k := range keys {
+		out = append(out, map[string]interface{}{"key": k, "value": otlpValue(attrs[k])})
+	}
+	return out
+}
+
+// otlpValue wraps a Go value in the OTLP AnyValue encoding
+func otlpValue(v interface{}) map[string]interface{} {
+	switch val := v.(type) {
+	case string:
+		return map[string]interface{}{"stringValue": val}
+	case bool:
+		return map[string]interface{}{"boolValue": val}
+	case int:
+		return map[string]interface{}{"intValue": strconv.Itoa(val)}
+	case int64:
+		return map[string]interface{}{"intValue": strconv.FormatInt(val, 10)}
+	case float64:
+		return map[string]interface{}{"doubleValue": val}
+	default:
+		return map[string]interface{}{"stringValue": fmt.Sprint(val)}
+	}
+}
+
+// FileExporter writes spans as JSON lines to a file for local debugging
+type FileExporter struct {
+	mu   sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+}
+
+// NewFileExporter creates an exporter appending one JSON span per line to path
+func NewFileExporter(path string) (*FileExporter, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open trace file: %w", err)
+	}
+	return &FileExporter{file: file, enc: json.NewEncoder(file)}, nil
+}
+
+// ExportSpans appends the spans to the file
+func (e *FileExporter) ExportSpans(ctx context.Context, spans []SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, s := range spans {
+		if err := e.enc.Encode(s); err != nil {
+			return fmt.Errorf("failed to write span: %w", err)
+		}
+	}
+	return nil
+}
+
+// Shutdown closes the underlying file
+func (e *FileExporter) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.file.Close()
+}
@@ -0,0 +1,122 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// TraceparentHeader is the W3C trace-context header name
+const TraceparentHeader = "traceparent"
+
+// ParseTraceparent parses a W3C traceparent header value
+// (version-traceid-parentid-flags, e.g. 00-4bf9...-00f0...-01)
+func ParseTraceparent(value string) (SpanContext, error) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 {
+		return SpanContext{}, fmt.Errorf("invalid traceparent: %q", value)
+	}
+	if len(parts[0]) != 2 || parts[0] == "ff" {
+		return SpanContext{}, fmt.Errorf("unsupported traceparent version: %q", parts[0])
+	}
+	if parts[0] == "00" && len(parts) != 4 {
+		return SpanContext{}, fmt.Errorf("invalid traceparent: %q", value)
+	}
+
+	var sc SpanContext
+	if len(parts[1]) != 32 || !decodeHex(sc.TraceID[:], parts[1]) || !sc.TraceID.IsValid() {
+		return SpanContext{}, fmt.Errorf("invalid trace id in traceparent: %q", parts[1])
+	}
+	if len(parts[2]) != 16 || !decodeHex(sc.SpanID[:], parts[2]) || !sc.SpanID.IsValid() {
+		return SpanContext{}, fmt.Errorf("invalid parent id in traceparent: %q", parts[2])
+	}
+
+	var flags [1]byte
+	if len(parts[3]) != 2 || !decodeHex(flags[:], parts[3]) {
+		return SpanContext{}, fmt.Errorf("invalid flags in traceparent: %q", parts[3])
+	}
+	sc.Sampled = flags[0]&0x01 == 0x01
+	sc.Remote = true
+	return sc, nil
+}
+
+// FormatTraceparent renders a span context as a W3C traceparent header value
+func FormatTraceparent(sc SpanContext) string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", sc.TraceID, sc.SpanID, flags)
+}
+
+// Extract returns ctx carrying the caller's span context from the headers, if present and valid
+func Extract(ctx context.Context, header http.Header) context.Context {
+	value := header.Get(TraceparentHeader)
+	if value == "" {
+		return ctx
+	}
+	sc, err := ParseTraceparent(value)
+	if err != nil {
+		return ctx
+	}
+	return ContextWithRemoteSpanContext(ctx, sc)
+}
+
+// Inject writes the active span context in ctx to the headers
+func Inject(ctx context.Context, header http.Header) {
+	if sc := SpanContextFromContext(ctx); sc.IsValid() {
+		header.Set(TraceparentHeader, FormatTraceparent(sc))
+	}
+}
+
+// Middleware extracts incoming traceparent headers and wraps each request in a server span
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := Extract(r.Context(), r.Header)
+		ctx, span := StartWithKind(ctx, r.Method+" "+r.URL.Path, SpanKindServer,
+			String("http.method", r.Method),
+			String("http.target", r.URL.Path),
+		)
+		defer span.End()
+
+		if span != nil {
+			w.Header().Set(TraceparentHeader, FormatTraceparent(span.SpanContext()))
+		}
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		span.SetAttribute("http.status_code", int64(rec.status))
+		if rec.status >= 500 {
+			span.SetStatus(StatusError, http.StatusText(rec.status))
+		}
+	})
+}
+
+// statusRecorder captures the response status code for the server span
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.status = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// Flush forwards to the underlying writer so streaming handlers keep working
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func decodeHex(dst []byte, s string) bool {
+	if s != strings.ToLower(s) {
+		return false
+	}
+	_, err := hex.Decode(dst, []byte(s))
+	return err == nil
+}
@@ -0,0 +1,60 @@
+package tracing
+
+import (
+	"os"
+	"strings"
+)
+
+// Environment variables read by SetupFromEnv
+const (
+	EnvOTLPEndpoint = "OTEL_EXPORTER_OTLP_ENDPOINT"
+	EnvOTLPHeaders  = "OTEL_EXPORTER_OTLP_HEADERS"
+	EnvServiceName  = "OTEL_SERVICE_NAME"
+	EnvTraceFile    = "CONDUIT_TRACE_FILE"
+)
+
+// SetupFromEnv builds a tracer from the standard OTLP environment variables and
+// CONDUIT_TRACE_FILE, installs it globally and returns it. It returns a nil
+// tracer when no exporter is configured, leaving tracing disabled.
+func SetupFromEnv(serviceName string) (*Tracer, error) {
+	var exporters []Exporter
+
+	if endpoint := os.Getenv(EnvOTLPEndpoint); endpoint != "" {
+		exporters = append(exporters, NewOTLPExporter(endpoint, parseHeaders(os.Getenv(EnvOTLPHeaders))))
+	}
+
+	if path := os.Getenv(EnvTraceFile); path != "" {
+		fileExporter, err := NewFileExporter(path)
+		if err != nil {
+			return nil, err
+		}
+		exporters = append(exporters, fileExporter)
+	}
+
+	if len(exporters) == 0 {
+		return nil, nil
+	}
+
+	if name := os.Getenv(EnvServiceName); name != "" {
+		serviceName = name
+	}
+
+	tracer := NewTracer(Options{ServiceName: serviceName}, exporters...)
+	SetTracer(tracer)
+	return tracer, nil
+}
+
+// parseHeaders parses the OTLP "key1=value1,key2=value2" header format
+func parseHeaders(value string) map[string]string {
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		key, val, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		if key = strings.TrimSpace(key); key != "" {
+			headers[key] = strings.TrimSpace(val)
+		}
+	}
+	return headers
+}
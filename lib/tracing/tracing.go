@@ -0,0 +1,467 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TraceID identifies a trace (16 bytes, W3C trace-context compatible)
+type TraceID [16]byte
+
+// SpanID identifies a span within a trace (8 bytes)
+type SpanID [8]byte
+
+// String returns the lowercase hex encoding of the trace ID
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+
+// IsValid reports whether the trace ID is non-zero
+func (t TraceID) IsValid() bool { return t != TraceID{} }
+
+// String returns the lowercase hex encoding of the span ID
+func (s SpanID) String() string { return hex.EncodeToString(s[:]) }
+
+// IsValid reports whether the span ID is non-zero
+func (s SpanID) IsValid() bool { return s != SpanID{} }
+
+// SpanContext carries the identity of a span across process boundaries
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+	Remote  bool
+}
+
+// IsValid reports whether both IDs are set
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// SpanKind describes the relationship of a span to its callers
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = iota
+	SpanKindServer
+	SpanKindClient
+)
+
+// StatusCode is the final status of a span
+type StatusCode int
+
+const (
+	StatusUnset StatusCode = iota
+	StatusOK
+	StatusError
+)
+
+// Attribute is a key/value pair attached to a span
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// String creates a string attribute
+func String(key, value string) Attribute { return Attribute{Key: key, Value: value} }
+
+// Int creates an integer attribute
+func Int(key string, value int) Attribute { return Attribute{Key: key, Value: int64(value)} }
+
+// Float64 creates a float attribute
+func Float64(key string, value float64) Attribute { return Attribute{Key: key, Value: value} }
+
+// Bool creates a boolean attribute
+func Bool(key string, value bool) Attribute { return Attribute{Key: key, Value: value} }
+
+// SpanData is the immutable snapshot of a finished span handed to exporters
+type SpanData struct {
+	Name          string                 `json:"name"`
+	TraceID       string                 `json:"trace_id"`
+	SpanID        string                 `json:"span_id"`
+	ParentSpanID  string                 `json:"parent_span_id,omitempty"`
+	Kind          SpanKind               `json:"kind"`
+	StartTime     time.Time              `json:"start_time"`
+	EndTime       time.Time              `json:"end_time"`
+	Attributes    map[string]interface{} `json:"attributes,omitempty"`
+	StatusCode    StatusCode             `json:"status_code"`
+	StatusMessage string                 `json:"status_message,omitempty"`
+	Service       string                 `json:"service"`
+}
+
+// Span is an in-flight operation. A nil *Span is a valid no-op span.
+type Span struct {
+	tracer     *Tracer
+	name       string
+	kind       SpanKind
+	sc         SpanContext
+	parent     SpanID
+	start      time.Time
+	mu         sync.Mutex
+	attributes map[string]interface{}
+	status     StatusCode
+	statusMsg  string
+	ended      bool
+}
+
+// SpanContext returns the span's identity
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.sc
+}
+
+// SetAttribute attaches a key/value pair to the span
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attributes[key] = value
+}
+
+// SetAttributes attaches several attributes at once
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	for _, attr := range attrs {
+		s.SetAttribute(attr.Key, attr.Value)
+	}
+}
+
+// SetStatus sets the final status of the span
+func (s *Span) SetStatus(code StatusCode, message string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = code
+	s.statusMsg = message
+}
+
+// RecordError marks the span as failed when err is non-nil
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.SetAttribute("error", true)
+	s.SetStatus(StatusError, err.Error())
+}
+
+// End finishes the span and hands it to the tracer's exporters
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+
+	attrs := make(map[string]interface{}, len(s.attributes))
+	for k, v := range s.attributes {
+		attrs[k] = v
+	}
+	data := SpanData{
+		Name:          s.name,
+		TraceID:       s.sc.TraceID.String(),
+		SpanID:        s.sc.SpanID.String(),
+		Kind:          s.kind,
+		StartTime:     s.start,
+		EndTime:       time.Now(),
+		Attributes:    attrs,
+		StatusCode:    s.status,
+		StatusMessage: s.statusMsg,
+		Service:       s.tracer.serviceName,
+	}
+	if s.parent.IsValid() {
+		data.ParentSpanID = s.parent.String()
+	}
+	s.mu.Unlock()
+
+	if s.sc.Sampled {
+		s.tracer.enqueue(data)
+	}
+}
+
+// Exporter receives batches of finished spans
+type Exporter interface {
+	ExportSpans(ctx context.Context, spans []SpanData) error
+	Shutdown(ctx context.Context) error
+}
+
+// Options configures a Tracer
+type Options struct {
+	ServiceName   string
+	BatchSize     int
+	FlushInterval time.Duration
+	QueueSize     int
+	// OnError is called when an exporter fails; defaults to dropping the error
+	OnError func(err error)
+}
+
+// Tracer creates spans and exports them in the background
+type Tracer struct {
+	serviceName string
+	exporters   []Exporter
+	batchSize   int
+	interval    time.Duration
+	queue       chan SpanData
+	flushReq    chan chan struct{}
+	done        chan struct{}
+	onError     func(err error)
+	closeOnce   sync.Once
+	closed      atomic.Bool
+}
+
+// NewTracer creates a tracer that exports finished spans to the given exporters
+func NewTracer(opts Options, exporters ...Exporter) *Tracer {
+	if opts.ServiceName == "" {
+		opts.ServiceName = "conduit"
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 128
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = 5 * time.Second
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 2048
+	}
+	if opts.OnError == nil {
+		opts.OnError = func(error) {}
+	}
+
+	t := &Tracer{
+		serviceName: opts.ServiceName,
+		exporters:   exporters,
+		batchSize:   opts.BatchSize,
+		interval:    opts.FlushInterval,
+		queue:       make(chan SpanData, opts.QueueSize),
+		flushReq:    make(chan chan struct{}),
+		done:        make(chan struct{}),
+		onError:     opts.OnError,
+	}
+	go t.loop()
+	return t
+}
+
+// Start begins a new span as a child of the span in ctx (local or remote)
+func (t *Tracer) Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, *Span) {
+	return t.StartWithKind(ctx, name, SpanKindInternal, attrs...)
+}
+
+// StartWithKind begins a new span with an explicit kind
+func (t *Tracer) StartWithKind(ctx context.Context, name string, kind SpanKind, attrs ...Attribute) (context.Context, *Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	span := &Span{
+		tracer:     t,
+		name:       name,
+		kind:       kind,
+		start:      time.Now(),
+		attributes: make(map[string]interface{}, len(attrs)),
+	}
+
+	if parent := SpanContextFromContext(ctx); parent.IsValid() {
+		span.sc = SpanContext{TraceID: parent.TraceID, Sampled: parent.Sampled}
+		span.parent = parent.SpanID
+	} else {
+		span.sc = SpanContext{TraceID: newTraceID(), Sampled: true}
+	}
+	span.sc.SpanID = newSpanID()
+
+	for _, attr := range attrs {
+		span.attributes[attr.Key] = attr.Value
+	}
+
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// Flush exports all queued spans and waits for completion
+func (t *Tracer) Flush(ctx context.Context) error {
+	if t.closed.Load() {
+		return nil
+	}
+	ack := make(chan struct{})
+	select {
+	case t.flushReq <- ack:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-ack:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown flushes pending spans and shuts down all exporters
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	var err error
+	t.closeOnce.Do(func() {
+		flushErr := t.Flush(ctx)
+		t.closed.Store(true)
+		close(t.done)
+		for _, exp := range t.exporters {
+			if e := exp.Shutdown(ctx); e != nil && err == nil {
+				err = e
+			}
+		}
+		if err == nil {
+			err = flushErr
+		}
+	})
+	return err
+}
+
+// enqueue hands a finished span to the export loop, dropping it if the queue is full
+func (t *Tracer) enqueue(data SpanData) {
+	if t.closed.Load() {
+		return
+	}
+	select {
+	case t.queue <- data:
+	default:
+	}
+}
+
+// loop batches spans and exports them on size or interval
+func (t *Tracer) loop() {
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	batch := make([]SpanData, 0, t.batchSize)
+	export := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		for _, exp := range t.exporters {
+			if err := exp.ExportSpans(ctx, batch); err != nil {
+				t.onError(fmt.Errorf("tracing export failed: %w", err))
+			}
+		}
+		batch = make([]SpanData, 0, t.batchSize)
+	}
+	drain := func() {
+		for {
+			select {
+			case data := <-t.queue:
+				batch = append(batch, data)
+			default:
+				return
+			}
+		}
+	}
+
+	for {
+		select {
+		case data := <-t.queue:
+			batch = append(batch, data)
+			if len(batch) >= t.batchSize {
+				export()
+			}
+		case <-ticker.C:
+			export()
+		case ack := <-t.flushReq:
+			drain()
+			export()
+			close(ack)
+		case <-t.done:
+			return
+		}
+	}
+}
+
+// spanKey is the context key for the active span
+type spanKey struct{}
+
+// remoteKey is the context key for a span context extracted from a carrier
+type remoteKey struct{}
+
+// SpanFromContext returns the active span in ctx, or nil
+func SpanFromContext(ctx context.Context) *Span {
+	if ctx == nil {
+		return nil
+	}
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// SpanContextFromContext returns the active span context, local or remote
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if ctx == nil {
+		return SpanContext{}
+	}
+	if span := SpanFromContext(ctx); span != nil {
+		return span.sc
+	}
+	if sc, ok := ctx.Value(remoteKey{}).(SpanContext); ok {
+		return sc
+	}
+	return SpanContext{}
+}
+
+// ContextWithRemoteSpanContext returns a context carrying a span context received from a caller
+func ContextWithRemoteSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	sc.Remote = true
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+var globalTracer atomic.Pointer[Tracer]
+
+// SetTracer installs the tracer used by the package-level Start function
+func SetTracer(t *Tracer) {
+	globalTracer.Store(t)
+}
+
+// GetTracer returns the installed tracer, or nil when tracing is disabled
+func GetTracer() *Tracer {
+	return globalTracer.Load()
+}
+
+// Start begins a span using the global tracer. When tracing is disabled it
+// returns ctx unchanged and a nil span, whose methods are all no-ops.
+func Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, *Span) {
+	return StartWithKind(ctx, name, SpanKindInternal, attrs...)
+}
+
+// StartWithKind begins a span of the given kind using the global tracer
+func StartWithKind(ctx context.Context, name string, kind SpanKind, attrs ...Attribute) (context.Context, *Span) {
+	t := globalTracer.Load()
+	if t == nil {
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		return ctx, nil
+	}
+	return t.StartWithKind(ctx, name, kind, attrs...)
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		_, _ = rand.Read(id[:])
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		_, _ = rand.Read(id[:])
+	}
+	return id
+}
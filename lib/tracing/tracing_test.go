@@ -0,0 +1,224 @@
+package tracing
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// recorder is an exporter that keeps every span it receives
+type recorder struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+func (r *recorder) ExportSpans(ctx context.Context, spans []SpanData) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, spans...)
+	return nil
+}
+
+func (r *recorder) Shutdown(ctx context.Context) error { return nil }
+
+func (r *recorder) byName() map[string]SpanData {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make(map[string]SpanData, len(r.spans))
+	for _, s := range r.spans {
+		out[s.Name] = s
+	}
+	return out
+}
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		value   string
+		wantErr bool
+		sampled bool
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false, true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", false, false},
+		{" 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01 ", false, true},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false, true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", true, false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true, false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", true, false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", true, false},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", true, false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-zz", true, false},
+		{"garbage", true, false},
+	}
+	for _, tt := range tests {
+		sc, err := ParseTraceparent(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: got error %v, want error %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if sc.Sampled != tt.sampled || !sc.Remote || sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Errorf("%q: got %+v", tt.value, sc)
+		}
+		if tt.value == "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01" && FormatTraceparent(sc) != tt.value {
+			t.Errorf("round trip gave %q", FormatTraceparent(sc))
+		}
+	}
+}
+
+func TestSpanParenting(t *testing.T) {
+	rec := &recorder{}
+	tracer := NewTracer(Options{ServiceName: "test"}, rec)
+	defer tracer.Shutdown(context.Background())
+
+	ctx, root := tracer.Start(context.Background(), "root", String("kind", "run"))
+	_, child := tracer.Start(ctx, "child", Int("n", 3))
+	child.RecordError(errors.New("tool failed"))
+	child.End()
+	child.End()
+	root.End()
+
+	if err := tracer.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	spans := rec.byName()
+	if len(rec.spans) != 2 {
+		t.Fatalf("exported %d spans, want 2 (End is idempotent)", len(rec.spans))
+	}
+	r, c := spans["root"], spans["child"]
+	if r.ParentSpanID != "" || c.ParentSpanID != r.SpanID || c.TraceID != r.TraceID {
+		t.Errorf("child %+v is not linked to root %+v", c, r)
+	}
+	if c.StatusCode != StatusError || c.StatusMessage != "tool failed" || c.Attributes["n"] != int64(3) || c.Service != "test" {
+		t.Errorf("child span %+v", c)
+	}
+
+	// A remote parent continues the caller's trace
+	remote, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	_, span := tracer.Start(ContextWithRemoteSpanContext(context.Background(), remote), "remote child")
+	if sc := span.SpanContext(); sc.TraceID != remote.TraceID || sc.Sampled || span.parent != remote.SpanID {
+		t.Errorf("remote child context %+v", sc)
+	}
+}
+
+func TestGlobalStartDisabled(t *testing.T) {
+	SetTracer(nil)
+	ctx, span := Start(context.Background(), "noop")
+	if span != nil || SpanFromContext(ctx) != nil {
+		t.Fatal("expected a nil span without a tracer")
+	}
+	// Every method must be safe on the nil span
+	span.SetAttributes(String("a", "b"))
+	span.RecordError(errors.New("x"))
+	span.End()
+}
+
+func TestMiddleware(t *testing.T) {
+	rec := &recorder{}
+	tracer := NewTracer(Options{}, rec)
+	SetTracer(tracer)
+	defer SetTracer(nil)
+
+	var inner SpanContext
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inner = SpanContextFromContext(r.Context())
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+
+	req := httptest.NewRequest("POST", "/mcp", nil)
+	req.Header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+
+	if inner.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("handler did not see the caller's trace: %+v", inner)
+	}
+	if got, _ := ParseTraceparent(resp.Header().Get(TraceparentHeader)); got.SpanID != inner.SpanID {
+		t.Errorf("response traceparent %q does not name the server span", resp.Header().Get(TraceparentHeader))
+	}
+
+	tracer.Flush(context.Background())
+	span := rec.byName()["POST /mcp"]
+	if span.ParentSpanID != "00f067aa0ba902b7" || span.Kind != SpanKindServer ||
+		span.StatusCode != StatusError || span.Attributes["http.status_code"] != int64(502) {
+		t.Errorf("server span %+v", span)
+	}
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var got map[string]interface{}
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" {
+			http.NotFound(w, r)
+			return
+		}
+		auth = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	exp := NewOTLPExporter(srv.URL+"/", parseHeaders("Authorization = Bearer x, broken"))
+	span := SpanData{Name: "tool", TraceID: "t", SpanID: "s", ParentSpanID: "p", Kind: SpanKindClient,
+		Service: "svc", Attributes: map[string]interface{}{"count": int64(2), "ok": true}}
+	if err := exp.ExportSpans(context.Background(), []SpanData{span}); err != nil {
+		t.Fatal(err)
+	}
+	if auth != "Bearer x" {
+		t.Errorf("authorization header %q", auth)
+	}
+
+	rs := got["resourceSpans"].([]interface{})[0].(map[string]interface{})
+	exported := rs["scopeSpans"].([]interface{})[0].(map[string]interface{})["spans"].([]interface{})[0].(map[string]interface{})
+	wantAttrs := []interface{}{
+		map[string]interface{}{"key": "count", "value": map[string]interface{}{"intValue": "2"}},
+		map[string]interface{}{"key": "ok", "value": map[string]interface{}{"boolValue": true}},
+	}
+	if exported["parentSpanId"] != "p" || exported["kind"] != float64(3) || !reflect.DeepEqual(exported["attributes"], wantAttrs) {
+		t.Errorf("exported span %v", exported)
+	}
+
+	failing := NewOTLPExporter(srv.URL+"/elsewhere", nil)
+	if err := failing.ExportSpans(context.Background(), []SpanData{span}); err == nil {
+		t.Error("expected an error for a non-2xx collector response")
+	}
+}
+
+func TestFileExporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spans.jsonl")
+	exp, err := NewFileExporter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp.ExportSpans(context.Background(), []SpanData{{Name: "a"}, {Name: "b"}})
+	if err := exp.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var names []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var s SpanData
+		if err := json.Unmarshal(scanner.Bytes(), &s); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, s.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"a", "b"}) {
+		t.Errorf("file holds spans %v", names)
+	}
+}
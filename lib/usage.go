@@ -0,0 +1,57 @@
+package conduit
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/benozo/conduit/lib/metrics"
+	"github.com/benozo/conduit/lib/tokens"
+	"github.com/benozo/conduit/lib/usage"
+	"github.com/benozo/conduit/mcp"
+)
+
+// callScope returns the usage scope of a model call, defaulting the session
+// to the request's
+func callScope(ctx context.Context, req mcp.MCPRequest) usage.Scope {
+	scope := usage.ScopeFrom(ctx)
+	if scope.Session == "" {
+		scope.Session = req.SessionID
+	}
+	return scope
+}
+
+// observeTokens records provider-reported token usage in the metrics and
+// in the usage accounting of the call in ctx
+func observeTokens(ctx context.Context, provider, model string, promptTokens, completionTokens int) {
+	metrics.ObserveModelTokens(provider, model, promptTokens, completionTokens)
+	usage.Report(ctx, promptTokens, completionTokens)
+}
+
+// recordUsage accounts a finished model call. When the provider reported no
+// usage, successful calls are estimated by counting the prompt and response;
+// calls answered entirely from the response cache cost nothing.
+func recordUsage(provider, model string, scope usage.Scope, call *usage.Call, input mcp.ContextInput, response string, err error) {
+	promptTokens, completionTokens, reported := call.Tokens()
+	if !reported {
+		if err != nil || call.Cached() {
+			return
+		}
+		counter := tokens.ForModel(model)
+		for _, key := range []string{"system", "query"} {
+			if v, ok := input.Inputs[key]; ok && v != nil {
+				promptTokens += counter.Count(fmt.Sprint(v))
+			}
+		}
+		completionTokens = counter.Count(response)
+	}
+
+	record := usage.Default.Record(usage.Record{
+		Provider:         provider,
+		Model:            model,
+		Scope:            scope,
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+		Estimated:        !reported,
+	})
+	metrics.ObserveModelCost(provider, model, record.Cost)
+}
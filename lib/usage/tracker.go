@@ -0,0 +1,373 @@
+package usage
+
+import (
+	"container/list"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrBudgetExceeded matches every *BudgetError
+var ErrBudgetExceeded = errors.New("usage budget exceeded")
+
+// Budget caps the cost and tokens spent under one key. Zero fields are
+// unlimited.
+type Budget struct {
+	MaxCost   float64 `json:"max_cost,omitempty" yaml:"max_cost,omitempty"`
+	MaxTokens int     `json:"max_tokens,omitempty" yaml:"max_tokens,omitempty"`
+}
+
+func (b Budget) exceeded(t Totals) bool {
+	return (b.MaxCost > 0 && t.Cost >= b.MaxCost) || (b.MaxTokens > 0 && t.Tokens() >= b.MaxTokens)
+}
+
+// BudgetError is returned instead of calling the model once a budget is spent
+type BudgetError struct {
+	Dimension string
+	Key       string
+	Spent     Totals
+	Budget    Budget
+}
+
+func (e *BudgetError) Error() string {
+	if e.Budget.MaxCost > 0 && e.Spent.Cost >= e.Budget.MaxCost {
+		return fmt.Sprintf("%s %q reached its cost budget: spent %.4f of %.4f", e.Dimension, e.Key, e.Spent.Cost, e.Budget.MaxCost)
+	}
+	return fmt.Sprintf("%s %q reached its token budget: used %d of %d tokens", e.Dimension, e.Key, e.Spent.Tokens(), e.Budget.MaxTokens)
+}
+
+// Is makes errors.Is(err, ErrBudgetExceeded) true
+func (e *BudgetError) Is(target error) bool {
+	return target == ErrBudgetExceeded
+}
+
+// DefaultMaxKeys is how many keys a tracker keeps per dimension unless
+// SetMaxKeys changes it
+const DefaultMaxKeys = 10000
+
+// Tracker aggregates records by dimension and enforces budgets. Each
+// dimension keeps its most recently used keys, up to a limit, so run and
+// session IDs don't grow it forever. It is safe for concurrent use.
+type Tracker struct {
+	mu      sync.RWMutex
+	prices  Prices
+	totals  map[string]map[string]*Totals
+	budgets map[string]map[string]Budget
+	maxKeys int
+	recent  map[string]*keyList
+}
+
+// keyList orders a dimension's keys from most to least recently used
+type keyList struct {
+	order *list.List
+	elems map[string]*list.Element
+}
+
+// NewTracker creates a tracker pricing calls with prices
+func NewTracker(prices Prices) *Tracker {
+	return &Tracker{
+		prices:  prices,
+		totals:  make(map[string]map[string]*Totals),
+		budgets: make(map[string]map[string]Budget),
+		maxKeys: DefaultMaxKeys,
+		recent:  make(map[string]*keyList),
+	}
+}
+
+// SetMaxKeys limits how many keys each dimension keeps. Beyond it, the
+// totals and budget of the least recently recorded or budgeted key are
+// dropped. n <= 0 restores DefaultMaxKeys.
+func (t *Tracker) SetMaxKeys(n int) {
+	if n <= 0 {
+		n = DefaultMaxKeys
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.maxKeys = n
+	for dim := range t.recent {
+		t.evict(dim)
+	}
+}
+
+// touch marks a key as just used and evicts the dimension's oldest keys
+// beyond the limit. The caller holds the write lock.
+func (t *Tracker) touch(dimension, key string) {
+	keys := t.recent[dimension]
+	if keys == nil {
+		keys = &keyList{order: list.New(), elems: make(map[string]*list.Element)}
+		t.recent[dimension] = keys
+	}
+	if elem, ok := keys.elems[key]; ok {
+		keys.order.MoveToFront(elem)
+		return
+	}
+	keys.elems[key] = keys.order.PushFront(key)
+	t.evict(dimension)
+}
+
+func (t *Tracker) evict(dimension string) {
+	keys := t.recent[dimension]
+	for keys.order.Len() > t.maxKeys {
+		key := keys.order.Remove(keys.order.Back()).(string)
+		delete(keys.elems, key)
+		delete(t.totals[dimension], key)
+		delete(t.budgets[dimension], key)
+	}
+}
+
+// untrack removes a key from the recency list. The caller holds the write lock.
+func (t *Tracker) untrack(dimension, key string) {
+	if keys := t.recent[dimension]; keys != nil {
+		if elem, ok := keys.elems[key]; ok {
+			keys.order.Remove(elem)
+			delete(keys.elems, key)
+		}
+	}
+}
+
+// Default is the tracker model calls are recorded in
+var Default = NewTracker(nil)
+
+// SetPrices replaces the price table
+func (t *Tracker) SetPrices(prices Prices) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.prices = prices
+}
+
+// Price returns the price of model's tokens, if known
+func (t *Tracker) Price(model string) (Price, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.prices.Lookup(model)
+}
+
+// SetBudget sets the budget for one key of a dimension, e.g. a run ID. An
+// empty key sets the default for every key of the dimension; a zero Budget
+// removes it.
+func (t *Tracker) SetBudget(dimension, key string, budget Budget) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if budget == (Budget{}) {
+		delete(t.budgets[dimension], key)
+		return
+	}
+	if t.budgets[dimension] == nil {
+		t.budgets[dimension] = make(map[string]Budget)
+	}
+	t.budgets[dimension][key] = budget
+	if key != "" {
+		t.touch(dimension, key)
+	}
+}
+
+// budget returns the budget applying to a key, falling back to the
+// dimension's default
+func (t *Tracker) budget(dimension, key string) (Budget, bool) {
+	if b, ok := t.budgets[dimension][key]; ok {
+		return b, true
+	}
+	b, ok := t.budgets[dimension][""]
+	return b, ok
+}
+
+// Record prices r when it has no cost yet, adds it to the totals of every
+// dimension in its scope and returns it
+func (t *Tracker) Record(r Record) Record {
+	if r.Time.IsZero() {
+		r.Time = time.Now()
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if r.Cost == 0 {
+		if price, ok := t.prices.Lookup(r.Model); ok {
+			r.Cost = price.Cost(r.PromptTokens, r.CompletionTokens)
+		}
+	}
+	for dim, key := range r.keys() {
+		if t.totals[dim] == nil {
+			t.totals[dim] = make(map[string]*Totals)
+		}
+		totals := t.totals[dim][key]
+		if totals == nil {
+			totals = &Totals{}
+			t.totals[dim][key] = totals
+		}
+		totals.add(r)
+		t.touch(dim, key)
+	}
+	return r
+}
+
+// Check returns a *BudgetError when any key of scope, or model, has spent
+// its budget
+func (t *Tracker) Check(scope Scope, model string) error {
+	keys := Record{Scope: scope, Model: model}.keys()
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for _, dim := range Dimensions {
+		key := keys[dim]
+		if key == "" {
+			continue
+		}
+		budget, ok := t.budget(dim, key)
+		if !ok {
+			continue
+		}
+		var spent Totals
+		if totals := t.totals[dim][key]; totals != nil {
+			spent = *totals
+		}
+		if budget.exceeded(spent) {
+			return &BudgetError{Dimension: dim, Key: key, Spent: spent, Budget: budget}
+		}
+	}
+	return nil
+}
+
+// Totals returns what one key of a dimension has spent
+func (t *Tracker) Totals(dimension, key string) Totals {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if totals := t.totals[dimension][key]; totals != nil {
+		return *totals
+	}
+	return Totals{}
+}
+
+// Report returns the totals of every key, by dimension
+func (t *Tracker) Report() map[string]map[string]Totals {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	report := make(map[string]map[string]Totals, len(t.totals))
+	for dim, keys := range t.totals {
+		report[dim] = make(map[string]Totals, len(keys))
+		for key, totals := range keys {
+			report[dim][key] = *totals
+		}
+	}
+	return report
+}
+
+// Forget drops the totals and budget of a key right away, rather than
+// waiting for it to age out
+func (t *Tracker) Forget(dimension, key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.totals[dimension], key)
+	if key != "" {
+		delete(t.budgets[dimension], key)
+		t.untrack(dimension, key)
+	}
+}
+
+// Reset drops all totals, keeping prices and budgets
+func (t *Tracker) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.totals = make(map[string]map[string]*Totals)
+	// Keys with a budget stay tracked so they can still be evicted
+	for dim, keys := range t.recent {
+		for key, elem := range keys.elems {
+			if _, ok := t.budgets[dim][key]; !ok {
+				keys.order.Remove(elem)
+				delete(keys.elems, key)
+			}
+		}
+	}
+}
+
+// Handler serves the totals as JSON. ?dimension= limits the report to one
+// dimension, and ?dimension=&key= returns one key's totals and budget.
+func (t *Tracker) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		dim, key := r.URL.Query().Get("dimension"), r.URL.Query().Get("key")
+
+		var body interface{}
+		switch {
+		case dim != "" && key != "":
+			t.mu.RLock()
+			budget, _ := t.budget(dim, key)
+			t.mu.RUnlock()
+			body = map[string]interface{}{
+				"dimension": dim,
+				"key":       key,
+				"totals":    t.Totals(dim, key),
+				"budget":    budget,
+			}
+		case dim != "":
+			report := t.Report()[dim]
+			if report == nil {
+				report = map[string]Totals{}
+			}
+			body = map[string]interface{}{dim: report}
+		default:
+			body = t.Report()
+		}
+		json.NewEncoder(w).Encode(body)
+	})
+}
+
+// Config is the usage section of a config file
+type Config struct {
+	Prices Prices `json:"prices,omitempty" yaml:"prices,omitempty"`
+	// Budgets maps a dimension (session, agent, run, workflow, api_key or
+	// model) to the budget each of its keys gets
+	Budgets map[string]Budget `json:"budgets,omitempty" yaml:"budgets,omitempty"`
+	// MaxKeys caps the keys kept per dimension; 0 means DefaultMaxKeys
+	MaxKeys int `json:"max_keys,omitempty" yaml:"max_keys,omitempty"`
+}
+
+// Validate reports unknown dimensions and negative prices or budgets
+func (c *Config) Validate() error {
+	var problems []string
+	for model, price := range c.Prices {
+		if price.PromptPer1K < 0 || price.CompletionPer1K < 0 {
+			problems = append(problems, fmt.Sprintf("price for %q must not be negative", model))
+		}
+	}
+	for dim, budget := range c.Budgets {
+		if !isDimension(dim) {
+			problems = append(problems, fmt.Sprintf("unknown budget dimension %q (want %s)", dim, strings.Join(Dimensions, ", ")))
+		}
+		if budget.MaxCost < 0 || budget.MaxTokens < 0 {
+			problems = append(problems, fmt.Sprintf("budget for %s must not be negative", dim))
+		}
+	}
+	if c.MaxKeys < 0 {
+		problems = append(problems, "max_keys must not be negative")
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Apply installs the prices and default budgets on t
+func (c *Config) Apply(t *Tracker) {
+	if c.Prices != nil {
+		t.SetPrices(c.Prices)
+	}
+	for dim, budget := range c.Budgets {
+		t.SetBudget(dim, "", budget)
+	}
+	if c.MaxKeys > 0 {
+		t.SetMaxKeys(c.MaxKeys)
+	}
+}
+
+func isDimension(name string) bool {
+	for _, dim := range Dimensions {
+		if dim == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,261 @@
+// Package usage accounts for model token usage and cost. Each model call is
+// recorded against the session, agent, run, workflow and API key found in
+// its context, priced from a per-model table, and checked against budgets.
+package usage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Price is the cost of a model's tokens, in the currency of your choice
+// (usually USD) per 1,000 tokens
+type Price struct {
+	PromptPer1K     float64 `json:"prompt_per_1k" yaml:"prompt_per_1k"`
+	CompletionPer1K float64 `json:"completion_per_1k" yaml:"completion_per_1k"`
+}
+
+// Cost returns the price of a call's tokens
+func (p Price) Cost(promptTokens, completionTokens int) float64 {
+	return float64(promptTokens)/1000*p.PromptPer1K + float64(completionTokens)/1000*p.CompletionPer1K
+}
+
+// Prices maps model names to prices. A key ending in "*" matches every
+// model with that prefix; the longest match wins, and "*" alone matches
+// any model.
+type Prices map[string]Price
+
+// Lookup returns the price for model
+func (p Prices) Lookup(model string) (Price, bool) {
+	model = strings.ToLower(model)
+	best, bestLen, found := Price{}, -1, false
+	for key, price := range p {
+		key = strings.ToLower(key)
+		switch {
+		case key == model:
+			return price, true
+		case strings.HasSuffix(key, "*") && strings.HasPrefix(model, strings.TrimSuffix(key, "*")):
+			if len(key) > bestLen {
+				best, bestLen, found = price, len(key), true
+			}
+		}
+	}
+	return best, found
+}
+
+// Scope identifies who a model call is accounted to. Empty fields are not
+// aggregated.
+type Scope struct {
+	Session  string `json:"session,omitempty"`
+	Agent    string `json:"agent,omitempty"`
+	Run      string `json:"run,omitempty"`
+	Workflow string `json:"workflow,omitempty"`
+	APIKey   string `json:"api_key,omitempty"`
+}
+
+type scopeKey struct{}
+
+// WithScope returns a context whose model calls are accounted to s. Fields
+// left empty keep the values of any scope already in ctx.
+func WithScope(ctx context.Context, s Scope) context.Context {
+	parent := ScopeFrom(ctx)
+	if s.Session == "" {
+		s.Session = parent.Session
+	}
+	if s.Agent == "" {
+		s.Agent = parent.Agent
+	}
+	if s.Run == "" {
+		s.Run = parent.Run
+	}
+	if s.Workflow == "" {
+		s.Workflow = parent.Workflow
+	}
+	if s.APIKey == "" {
+		s.APIKey = parent.APIKey
+	}
+	return context.WithValue(ctx, scopeKey{}, s)
+}
+
+// ScopeFrom returns the scope stored in ctx, if any
+func ScopeFrom(ctx context.Context) Scope {
+	if ctx == nil {
+		return Scope{}
+	}
+	s, _ := ctx.Value(scopeKey{}).(Scope)
+	return s
+}
+
+// KeyID returns a stable, non-reversible identifier for an API key, so keys
+// can be aggregated and reported without being stored
+func KeyID(key string) string {
+	if key == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(key))
+	return "key-" + hex.EncodeToString(sum[:6])
+}
+
+// Middleware accounts HTTP API requests to the caller's API key, taken from
+// the X-API-Key header or a bearer token
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("X-API-Key")
+		if key == "" {
+			if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+				key = strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+			}
+		}
+		if key != "" {
+			r = r.WithContext(WithScope(r.Context(), Scope{APIKey: KeyID(key)}))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Call collects the token counts providers report while serving one model
+// call, which may span several requests (tool-call rounds)
+type Call struct {
+	mu               sync.Mutex
+	promptTokens     int
+	completionTokens int
+	reported         bool
+	cached           bool
+}
+
+type callKey struct{}
+
+// StartCall returns a context that collects the token counts reported by
+// providers via Report
+func StartCall(ctx context.Context) (context.Context, *Call) {
+	call := &Call{}
+	return context.WithValue(ctx, callKey{}, call), call
+}
+
+// Report adds provider-reported token counts to the call in ctx, if any
+func Report(ctx context.Context, promptTokens, completionTokens int) {
+	if ctx == nil {
+		return
+	}
+	call, _ := ctx.Value(callKey{}).(*Call)
+	if call == nil || (promptTokens <= 0 && completionTokens <= 0) {
+		return
+	}
+	call.mu.Lock()
+	defer call.mu.Unlock()
+	call.promptTokens += promptTokens
+	call.completionTokens += completionTokens
+	call.reported = true
+}
+
+// Cached marks the call in ctx, if any, as served from a response cache,
+// so it is not estimated when no provider reports usage
+func Cached(ctx context.Context) {
+	if ctx == nil {
+		return
+	}
+	if call, _ := ctx.Value(callKey{}).(*Call); call != nil {
+		call.mu.Lock()
+		defer call.mu.Unlock()
+		call.cached = true
+	}
+}
+
+// Cached reports whether any part of the call was served from a cache
+func (c *Call) Cached() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cached
+}
+
+// Tokens returns the reported counts and whether any provider reported them
+func (c *Call) Tokens() (promptTokens, completionTokens int, reported bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.promptTokens, c.completionTokens, c.reported
+}
+
+// Record is one accounted model call
+type Record struct {
+	Time             time.Time `json:"time"`
+	Provider         string    `json:"provider"`
+	Model            string    `json:"model"`
+	Scope            Scope     `json:"scope"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	Cost             float64   `json:"cost"`
+	// Estimated is set when the provider did not report usage and the
+	// tokens were counted locally
+	Estimated bool `json:"estimated,omitempty"`
+}
+
+// Totals aggregates records
+type Totals struct {
+	Calls            int     `json:"calls"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
+	EstimatedCalls   int     `json:"estimated_calls,omitempty"`
+}
+
+// Tokens returns prompt plus completion tokens
+func (t Totals) Tokens() int {
+	return t.PromptTokens + t.CompletionTokens
+}
+
+// Sub returns the spend between an earlier snapshot o and t
+func (t Totals) Sub(o Totals) Totals {
+	return Totals{
+		Calls:            t.Calls - o.Calls,
+		PromptTokens:     t.PromptTokens - o.PromptTokens,
+		CompletionTokens: t.CompletionTokens - o.CompletionTokens,
+		Cost:             t.Cost - o.Cost,
+		EstimatedCalls:   t.EstimatedCalls - o.EstimatedCalls,
+	}
+}
+
+func (t *Totals) add(r Record) {
+	t.Calls++
+	t.PromptTokens += r.PromptTokens
+	t.CompletionTokens += r.CompletionTokens
+	t.Cost += r.Cost
+	if r.Estimated {
+		t.EstimatedCalls++
+	}
+}
+
+// Dimensions usage is aggregated by
+const (
+	DimensionSession  = "session"
+	DimensionAgent    = "agent"
+	DimensionRun      = "run"
+	DimensionWorkflow = "workflow"
+	DimensionAPIKey   = "api_key"
+	DimensionModel    = "model"
+)
+
+// Dimensions lists every dimension in report order
+var Dimensions = []string{DimensionSession, DimensionAgent, DimensionRun, DimensionWorkflow, DimensionAPIKey, DimensionModel}
+
+// keys returns the key a record is aggregated under for each dimension it has
+func (r Record) keys() map[string]string {
+	keys := map[string]string{
+		DimensionSession:  r.Scope.Session,
+		DimensionAgent:    r.Scope.Agent,
+		DimensionRun:      r.Scope.Run,
+		DimensionWorkflow: r.Scope.Workflow,
+		DimensionAPIKey:   r.Scope.APIKey,
+		DimensionModel:    r.Model,
+	}
+	for dim, key := range keys {
+		if key == "" {
+			delete(keys, dim)
+		}
+	}
+	return keys
+}
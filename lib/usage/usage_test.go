@@ -0,0 +1,280 @@
+package usage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPricesLookup(t *testing.T) {
+	prices := Prices{
+		"gpt-4o":      {PromptPer1K: 2.5, CompletionPer1K: 10},
+		"gpt-4o*":     {PromptPer1K: 1, CompletionPer1K: 1},
+		"gpt-*":       {PromptPer1K: 3, CompletionPer1K: 3},
+		"*":           {PromptPer1K: 9, CompletionPer1K: 9},
+		"Claude-Opus": {PromptPer1K: 15, CompletionPer1K: 75},
+	}
+	tests := []struct {
+		model string
+		want  float64
+	}{
+		{"gpt-4o", 2.5},
+		{"gpt-4o-mini", 1},
+		{"gpt-3.5-turbo", 3},
+		{"llama3", 9},
+		{"claude-opus", 15},
+	}
+	for _, tt := range tests {
+		price, ok := prices.Lookup(tt.model)
+		if !ok || price.PromptPer1K != tt.want {
+			t.Errorf("Lookup(%q) = %+v, %v; want prompt price %v", tt.model, price, ok, tt.want)
+		}
+	}
+	if _, ok := (Prices{"gpt-*": {}}).Lookup("llama3"); ok {
+		t.Error("unmatched model should have no price")
+	}
+
+	if cost := (Price{PromptPer1K: 2, CompletionPer1K: 6}).Cost(1500, 500); cost != 6 {
+		t.Errorf("Cost = %v, want 6", cost)
+	}
+}
+
+func TestScope(t *testing.T) {
+	ctx := WithScope(context.Background(), Scope{Session: "s1", Agent: "planner"})
+	ctx = WithScope(ctx, Scope{Agent: "coder", Run: "r1"})
+	want := Scope{Session: "s1", Agent: "coder", Run: "r1"}
+	if got := ScopeFrom(ctx); got != want {
+		t.Errorf("ScopeFrom = %+v, want %+v", got, want)
+	}
+	if got := ScopeFrom(nil); got != (Scope{}) {
+		t.Errorf("ScopeFrom(nil) = %+v", got)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var got Scope
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = ScopeFrom(r.Context())
+	}))
+
+	for _, header := range []string{"X-API-Key", "Authorization"} {
+		req := httptest.NewRequest("GET", "/", nil)
+		value := "secret-key"
+		if header == "Authorization" {
+			value = "Bearer secret-key"
+		}
+		req.Header.Set(header, value)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		if got.APIKey != KeyID("secret-key") || strings.Contains(got.APIKey, "secret") {
+			t.Errorf("%s: api key scope %q", header, got.APIKey)
+		}
+	}
+	if KeyID("secret-key") == KeyID("other-key") || KeyID("") != "" {
+		t.Error("KeyID must distinguish keys and leave empty keys empty")
+	}
+}
+
+func TestCallReport(t *testing.T) {
+	Report(context.Background(), 5, 5) // no call in context: ignored
+
+	ctx, call := StartCall(context.Background())
+	if _, _, reported := call.Tokens(); reported {
+		t.Error("a new call has no reported usage")
+	}
+	Report(ctx, 10, 2)
+	Report(ctx, 0, 0)
+	Report(ctx, 7, 3)
+	if p, c, reported := call.Tokens(); p != 17 || c != 5 || !reported {
+		t.Errorf("Tokens = %d, %d, %v", p, c, reported)
+	}
+}
+
+func TestTrackerRecord(t *testing.T) {
+	tracker := NewTracker(Prices{"m": {PromptPer1K: 1, CompletionPer1K: 2}})
+	scope := Scope{Session: "s1", Agent: "a1", APIKey: "k1"}
+
+	r := tracker.Record(Record{Model: "m", Scope: scope, PromptTokens: 1000, CompletionTokens: 500})
+	if r.Cost != 2 || r.Time.IsZero() {
+		t.Errorf("record %+v", r)
+	}
+	tracker.Record(Record{Model: "m", Scope: Scope{Session: "s1"}, PromptTokens: 100, Estimated: true})
+	tracker.Record(Record{Model: "free", Scope: scope, PromptTokens: 10, Cost: 0.5})
+
+	session := tracker.Totals(DimensionSession, "s1")
+	if session.Calls != 3 || session.PromptTokens != 1110 || session.EstimatedCalls != 1 || math.Abs(session.Cost-2.6) > 1e-9 {
+		t.Errorf("session totals %+v", session)
+	}
+	if agent := tracker.Totals(DimensionAgent, "a1"); agent.Calls != 2 || agent.Tokens() != 1510 {
+		t.Errorf("agent totals %+v", agent)
+	}
+	report := tracker.Report()
+	if _, ok := report[DimensionRun]; ok {
+		t.Error("empty scope fields must not be aggregated")
+	}
+	if len(report[DimensionModel]) != 2 {
+		t.Errorf("model report %+v", report[DimensionModel])
+	}
+
+	before := tracker.Totals(DimensionAgent, "a1")
+	tracker.Record(Record{Model: "m", Scope: scope, CompletionTokens: 1000})
+	if spent := tracker.Totals(DimensionAgent, "a1").Sub(before); spent.Calls != 1 || spent.Cost != 2 {
+		t.Errorf("spent since snapshot %+v", spent)
+	}
+
+	tracker.Reset()
+	if got := tracker.Totals(DimensionSession, "s1"); got != (Totals{}) {
+		t.Errorf("totals after reset %+v", got)
+	}
+	if _, ok := tracker.Price("m"); !ok {
+		t.Error("Reset must keep prices")
+	}
+}
+
+func TestTrackerBudgets(t *testing.T) {
+	tracker := NewTracker(Prices{"m": {PromptPer1K: 1}})
+	tracker.SetBudget(DimensionRun, "", Budget{MaxTokens: 1000})
+	tracker.SetBudget(DimensionRun, "big", Budget{MaxTokens: 5000})
+	tracker.SetBudget(DimensionAgent, "coder", Budget{MaxCost: 0.5})
+
+	small := Scope{Run: "small", Agent: "coder"}
+	if err := tracker.Check(small, "m"); err != nil {
+		t.Fatalf("fresh budget: %v", err)
+	}
+
+	tracker.Record(Record{Model: "m", Scope: Scope{Run: "small"}, PromptTokens: 1000})
+	err := tracker.Check(small, "m")
+	var budgetErr *BudgetError
+	if !errors.As(err, &budgetErr) || !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("Check = %v, want a BudgetError", err)
+	}
+	if budgetErr.Dimension != DimensionRun || budgetErr.Key != "small" || !strings.Contains(err.Error(), "token budget: used 1000 of 1000") {
+		t.Errorf("budget error %+v: %v", budgetErr, err)
+	}
+
+	big := Scope{Run: "big"}
+	tracker.Record(Record{Model: "m", Scope: big, PromptTokens: 1000})
+	if err := tracker.Check(big, "m"); err != nil {
+		t.Errorf("a key's own budget overrides the default: %v", err)
+	}
+
+	tracker.Record(Record{Model: "m", Scope: Scope{Agent: "coder"}, PromptTokens: 600})
+	if err := tracker.Check(Scope{Agent: "coder"}, "m"); err == nil || !strings.Contains(err.Error(), "cost budget") {
+		t.Errorf("cost budget: %v", err)
+	}
+
+	tracker.SetBudget(DimensionAgent, "coder", Budget{})
+	if err := tracker.Check(Scope{Agent: "coder"}, "m"); err != nil {
+		t.Errorf("removed budget still enforced: %v", err)
+	}
+}
+
+func TestTrackerMaxKeys(t *testing.T) {
+	tracker := NewTracker(nil)
+	tracker.SetMaxKeys(2)
+	tracker.SetBudget(DimensionRun, "", Budget{MaxTokens: 100})
+	tracker.SetBudget(DimensionRun, "r1", Budget{MaxTokens: 5})
+	tracker.Record(Record{Model: "m", Scope: Scope{Run: "r1", Session: "s"}, PromptTokens: 5})
+	tracker.Record(Record{Model: "m", Scope: Scope{Run: "r2", Session: "s"}, PromptTokens: 1})
+	// Using r1 again makes r2 the oldest
+	tracker.Record(Record{Model: "m", Scope: Scope{Run: "r1"}, PromptTokens: 1})
+	tracker.Record(Record{Model: "m", Scope: Scope{Run: "r3"}, PromptTokens: 1})
+
+	runs := tracker.Report()[DimensionRun]
+	if len(runs) != 2 || runs["r2"] != (Totals{}) || runs["r1"].PromptTokens != 6 {
+		t.Errorf("runs after eviction %+v", runs)
+	}
+	if tracker.Totals(DimensionSession, "s").Calls != 2 {
+		t.Error("other dimensions are limited separately")
+	}
+	if err := tracker.Check(Scope{Run: "r1"}, "m"); err == nil {
+		t.Error("a kept key lost its budget")
+	}
+
+	// An evicted key's own budget goes with it, leaving the default
+	tracker.Record(Record{Model: "m", Scope: Scope{Run: "r4"}, PromptTokens: 1})
+	tracker.Record(Record{Model: "m", Scope: Scope{Run: "r5"}, PromptTokens: 1})
+	tracker.Record(Record{Model: "m", Scope: Scope{Run: "r1"}, PromptTokens: 6})
+	if err := tracker.Check(Scope{Run: "r1"}, "m"); err != nil {
+		t.Errorf("evicted budget still enforced: %v", err)
+	}
+
+	tracker.Forget(DimensionRun, "r1")
+	tracker.Record(Record{Model: "m", Scope: Scope{Run: "r6"}, PromptTokens: 1})
+	if runs := tracker.Report()[DimensionRun]; len(runs) != 2 || runs["r5"].Calls != 1 {
+		t.Errorf("runs after Forget %+v", runs)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	tracker := NewTracker(nil)
+	tracker.Record(Record{Model: "m", Scope: Scope{Agent: "a1"}, PromptTokens: 3})
+	tracker.SetBudget(DimensionAgent, "a1", Budget{MaxTokens: 10})
+
+	get := func(query string) map[string]json.RawMessage {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		tracker.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/usage"+query, nil))
+		if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("content type %q", ct)
+		}
+		var body map[string]json.RawMessage
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		return body
+	}
+
+	if body := get(""); body[DimensionAgent] == nil || body[DimensionModel] == nil {
+		t.Errorf("full report %v", body)
+	}
+	if body := get("?dimension=run"); string(body["run"]) != "{}" {
+		t.Errorf("empty dimension %s", body["run"])
+	}
+	body := get("?dimension=agent&key=a1")
+	var totals Totals
+	var budget Budget
+	json.Unmarshal(body["totals"], &totals)
+	json.Unmarshal(body["budget"], &budget)
+	if totals.PromptTokens != 3 || budget.MaxTokens != 10 {
+		t.Errorf("key report totals %+v budget %+v", totals, budget)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	bad := Config{
+		Prices:  Prices{"m": {PromptPer1K: -1}},
+		Budgets: map[string]Budget{"team": {MaxCost: 1}, DimensionRun: {MaxTokens: -5}},
+		MaxKeys: -1,
+	}
+	err := bad.Validate()
+	for _, want := range []string{`price for "m"`, `unknown budget dimension "team"`, "budget for run", "max_keys"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate = %v, want it to mention %q", err, want)
+		}
+	}
+
+	config := Config{
+		Prices:  Prices{"m": {PromptPer1K: 1}},
+		Budgets: map[string]Budget{DimensionSession: {MaxTokens: 100}},
+		MaxKeys: 1,
+	}
+	if err := config.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	tracker := NewTracker(nil)
+	config.Apply(tracker)
+	tracker.Record(Record{Model: "m", Scope: Scope{Session: "s"}, PromptTokens: 100})
+	if err := tracker.Check(Scope{Session: "s"}, "m"); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("applied session budget not enforced: %v", err)
+	}
+	tracker.Record(Record{Model: "m", Scope: Scope{Session: "t"}, PromptTokens: 1})
+	if sessions := tracker.Report()[DimensionSession]; len(sessions) != 1 {
+		t.Errorf("applied max_keys not enforced: %+v", sessions)
+	}
+}
@@ -1,45 +1,142 @@
 package main
 
 import (
-	"log"
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/benozo/conduit/agents"
 	conduit "github.com/benozo/conduit/lib"
+	"github.com/benozo/conduit/lib/logging"
+	"github.com/benozo/conduit/lib/openapi"
+	"github.com/benozo/conduit/lib/plugins"
+	"github.com/benozo/conduit/lib/scripting"
+	"github.com/benozo/conduit/lib/tokens/offline"
 	"github.com/benozo/conduit/lib/tools"
+	"github.com/benozo/conduit/lib/tracing"
 	"github.com/benozo/conduit/mcp"
 )
 
 func main() {
-	// Create server configuration
-	config := conduit.DefaultConfig()
+	// Count OpenAI tokens exactly, from rank files built into the binary
+	offline.Install()
 
-	// Parse command line arguments to set mode
-	mode := mcp.ModeBoth // Default to both protocols
-	if len(os.Args) > 1 {
-		switch os.Args[1] {
-		case "--stdio":
-			mode = mcp.ModeStdio
-		case "--http":
-			mode = mcp.ModeHTTP
-		case "--both":
-			mode = mcp.ModeBoth
-		case "--agents":
-			// Demo AI Agents functionality
-			demoAgents()
-			return
-		}
+	args := os.Args[1:]
+	command := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	switch command {
+	case "serve":
+		os.Exit(runServe(args))
+	case "validate":
+		os.Exit(runValidate(args))
+	case "tools", "prompts", "resources":
+		os.Exit(runClient(command, args))
+	case "repl":
+		os.Exit(runREPL(args))
+	case "help":
+		usage()
+	default:
+		fmt.Fprintf(os.Stderr, "conduit: unknown command %q\n", command)
+		usage()
+		os.Exit(2)
+	}
+}
+
+// runServe starts the MCP server with the effective configuration
+func runServe(args []string) int {
+	opts, err := parseCommand("serve", args, os.Stderr)
+	if err == flag.ErrHelp {
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "conduit: %v\n", err)
+		return 2
+	}
+	if opts.agents {
+		// Demo AI Agents functionality
+		demoAgents()
+		return 0
+	}
+
+	config := opts.config
+	if err := config.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	// Structured logs go to stderr so stdio mode keeps stdout for JSON-RPC
+	if _, err := logging.Setup(logging.Options{Level: config.LogLevel, Format: config.LogFormat}); err != nil {
+		fmt.Fprintf(os.Stderr, "conduit: invalid logging configuration: %v\n", err)
+		return 1
+	}
+
+	// Enable tracing when an OTLP endpoint or trace file is configured
+	tracer, err := tracing.SetupFromEnv("conduit")
+	if err != nil {
+		slog.Warn("tracing disabled", "error", err)
+	} else if tracer != nil {
+		defer tracer.Shutdown(context.Background())
 	}
-	config.Mode = mode
 
 	// Create the server
-	// server := conduit.NewServer(config)
 	server := conduit.NewEnhancedServer(config)
 
-	// Register all tool packages
-	tools.RegisterTextTools(server)
-	tools.RegisterMemoryTools(server)
-	tools.RegisterUtilityTools(server)
+	// Register the enabled tool packages
+	if config.ToolEnabled(conduit.ToolPackageText) {
+		tools.RegisterTextTools(server)
+	}
+	if config.ToolEnabled(conduit.ToolPackageMemory) {
+		tools.RegisterMemoryTools(server)
+	}
+	if config.ToolEnabled(conduit.ToolPackageUtility) {
+		tools.RegisterUtilityTools(server)
+	}
+	if config.ToolEnabled(conduit.ToolPackageRAG) {
+		if err := setupRAG(context.Background(), config.RAG); err != nil {
+			slog.Error("failed to initialize RAG", "error", err)
+			return 1
+		}
+		tools.RegisterRAGTools(server)
+	}
+
+	// Register external plugin tools
+	if len(config.Plugins) > 0 {
+		names, err := plugins.LoadAndRegister(server, config.Plugins...)
+		if err != nil {
+			slog.Error("failed to load plugins", "error", err)
+			return 1
+		}
+		slog.Info("loaded plugin tools", "tools", names)
+	}
+
+	// Import HTTP APIs described by OpenAPI documents
+	for _, src := range config.OpenAPI {
+		names, err := openapi.LoadAndRegister(context.Background(), server, src.Spec, openAPIOptions(src))
+		if err != nil {
+			slog.Error("failed to import OpenAPI tools", "spec", src.Spec, "error", err)
+			return 1
+		}
+		slog.Info("loaded OpenAPI tools", "spec", src.Spec, "tools", names)
+	}
+
+	// Load Starlark tool scripts and reload them when they change
+	if config.ScriptsDir != "" {
+		loader := scripting.NewLoader(config.ScriptsDir, server, scripting.Limits{})
+		names, err := loader.Load()
+		if err != nil {
+			slog.Error("failed to load scripts", "dir", config.ScriptsDir, "error", err)
+			return 1
+		}
+		slog.Info("loaded script tools", "dir", config.ScriptsDir, "tools", names)
+		go loader.Watch(context.Background(), 2*time.Second)
+	}
 
 	// Use Custom tools
 	server.RegisterToolWithSchema("add",
@@ -75,16 +172,17 @@ func main() {
 		}, []string{"a", "b"}))
 
 	// Start the server
-	log.Printf("Starting Conduit server (mode: %v)...", mode)
+	slog.Info("starting conduit server", "mode", config.Mode.String(), "port", config.Port, "tools", config.Tools)
 	if err := server.Start(); err != nil {
-		log.Fatal(err)
+		slog.Error("server stopped", "error", err)
+		return 1
 	}
+	return 0
 }
 
 // demoAgents demonstrates the AI Agents functionality
 func demoAgents() {
-	log.Println("🤖 AI Agents Demo Mode")
-	log.Println("======================")
+	slog.Info("starting AI agents demo")
 
 	// Create MCP server for agents
 	config := conduit.DefaultConfig()
@@ -105,7 +203,7 @@ func demoAgents() {
 			a := params["a"].(float64)
 			b := params["b"].(float64)
 			result := a + b
-			log.Printf("🧮 Math: %.1f + %.1f = %.1f", a, b, result)
+			slog.Info("math tool", "operation", "add", "a", a, "b", b, "result", result)
 			return map[string]interface{}{
 				"result":    result,
 				"operation": "addition",
@@ -120,27 +218,28 @@ func demoAgents() {
 	agentManager := agents.NewMCPAgentManager(server)
 
 	// Create specialized agents
-	log.Println("📝 Creating AI agents...")
+	slog.Info("creating AI agents")
 	if err := agentManager.CreateSpecializedAgents(); err != nil {
-		log.Fatalf("Failed to create agents: %v", err)
+		slog.Error("failed to create agents", "error", err)
+		os.Exit(1)
 	}
 
 	// Start server
 	go func() {
 		if err := server.Start(); err != nil {
-			log.Printf("Server error: %v", err)
+			slog.Error("server stopped", "error", err)
 		}
 	}()
 
 	// Wait and create a sample task
-	log.Println("⏳ Starting server...")
+	slog.Info("starting server", "port", config.Port)
 	// In a real scenario, you would wait for proper startup
 	// For demo purposes, we'll show the concept
 
-	log.Println("✅ AI Agents ready!")
-	log.Println("🔗 Server running on http://localhost:8080")
-	log.Println("📚 See examples/ai_agents/ for complete usage examples")
-	log.Println("📖 See agents/README.md for full documentation")
+	slog.Info("AI agents ready",
+		"url", fmt.Sprintf("http://localhost:%d", config.Port),
+		"examples", "examples/ai_agents/",
+		"docs", "agents/README.md")
 
 	// Keep running
 	select {}
@@ -0,0 +1,267 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Chat message roles
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	RoleTool      = "tool"
+)
+
+// Stop reasons reported in ChatResponse
+const (
+	StopReasonEnd       = "stop"
+	StopReasonToolCalls = "tool_calls"
+	StopReasonLength    = "length"
+)
+
+// ChatMessage is one role-tagged message in a conversation
+type ChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+
+	// Images are sent alongside Content to models that accept them
+	Images []Image `json:"images,omitempty"`
+
+	// ToolCalls are the calls requested by an assistant message
+	ToolCalls []ChatToolCall `json:"tool_calls,omitempty"`
+
+	// ToolCallID and Name identify the call a tool message answers
+	ToolCallID string `json:"tool_call_id,omitempty"`
+	Name       string `json:"name,omitempty"`
+}
+
+// ChatToolCall is a tool invocation requested by the model
+type ChatToolCall struct {
+	ID        string                 `json:"id"`
+	Name      string                 `json:"name"`
+	Arguments map[string]interface{} `json:"arguments"`
+}
+
+// ToolDefinition describes a tool the model may call
+type ToolDefinition struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Parameters  map[string]interface{} `json:"parameters"`
+}
+
+// ChatRequest is a model call with full conversation history
+type ChatRequest struct {
+	Model    string           `json:"model,omitempty"`
+	Messages []ChatMessage    `json:"messages"`
+	Tools    []ToolDefinition `json:"tools,omitempty"`
+
+	// Sampling options; zero values leave the provider's defaults in place
+	Temperature float64  `json:"temperature,omitempty"`
+	TopP        float64  `json:"top_p,omitempty"`
+	TopK        int      `json:"top_k,omitempty"`
+	MaxTokens   int      `json:"max_tokens,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+
+	// ResponseSchema asks for a JSON reply matching this JSON Schema, using
+	// the provider's JSON mode where it has one
+	ResponseSchema map[string]interface{} `json:"response_schema,omitempty"`
+
+	// OnToken receives content as it is generated, when the provider streams
+	OnToken func(token string) `json:"-"`
+}
+
+// Usage reports the tokens a call consumed
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// ChatResponse is the model's reply
+type ChatResponse struct {
+	Model      string      `json:"model,omitempty"`
+	Message    ChatMessage `json:"message"`
+	StopReason string      `json:"stop_reason,omitempty"`
+	Usage      Usage       `json:"usage"`
+}
+
+// ChatModel is a message-based model. Unlike ModelFunc it sees the whole
+// conversation, tool definitions and tool results as structured data.
+type ChatModel interface {
+	Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error)
+}
+
+// ChatModelFunc adapts a function into a ChatModel
+type ChatModelFunc func(ctx context.Context, req ChatRequest) (*ChatResponse, error)
+
+// Chat calls f
+func (f ChatModelFunc) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	return f(ctx, req)
+}
+
+// ChatModelFromFunc wraps a ModelFunc as a ChatModel. The conversation and
+// tool definitions are flattened into the "query" input, and the reply is
+// always plain text, so the model cannot make structured tool calls. Images
+// of the last user message are passed in the InputImages input.
+func ChatModelFromFunc(model ModelFunc, memory *Memory) ChatModel {
+	return ChatModelFunc(func(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+		input := ContextInput{
+			Inputs: map[string]interface{}{"query": FlattenMessages(req.Messages, req.Tools)},
+		}.WithContext(ctx)
+		if req.ResponseSchema != nil {
+			input.Inputs[InputResponseSchema] = req.ResponseSchema
+		}
+		if images := lastUserImages(req.Messages); len(images) > 0 {
+			input.Inputs[InputImages] = images
+		}
+		mcpReq := MCPRequest{
+			Model:       req.Model,
+			Contexts:    []ContextInput{input},
+			Temperature: req.Temperature,
+			TopK:        req.TopK,
+		}
+
+		var onToken StreamCallback
+		if req.OnToken != nil {
+			onToken = func(_, token string) { req.OnToken(token) }
+			mcpReq.Stream = true
+		}
+
+		text, err := model(input, mcpReq, memory, onToken)
+		if err != nil {
+			return nil, err
+		}
+		return &ChatResponse{
+			Model:      req.Model,
+			Message:    ChatMessage{Role: RoleAssistant, Content: text},
+			StopReason: StopReasonEnd,
+		}, nil
+	})
+}
+
+// ModelFuncFromChat wraps a ChatModel as a ModelFunc. The "query" input
+// becomes a user message, carrying the InputImages images and preceded by
+// a system message when a "system" input is set.
+func ModelFuncFromChat(model ChatModel) ModelFunc {
+	return func(ctx ContextInput, req MCPRequest, memory *Memory, onToken StreamCallback) (string, error) {
+		messages, err := InputMessages(ctx)
+		if err != nil {
+			return "", err
+		}
+
+		chatReq := ChatRequest{
+			Model:          req.Model,
+			Messages:       messages,
+			Temperature:    req.Temperature,
+			TopK:           req.TopK,
+			ResponseSchema: ResponseSchemaInput(ctx),
+		}
+		if onToken != nil {
+			chatReq.OnToken = func(token string) { onToken(ctx.ContextID, token) }
+		}
+
+		resp, err := model.Chat(ctx.Context(), chatReq)
+		if err != nil {
+			return "", err
+		}
+		return resp.Message.Content, nil
+	}
+}
+
+// InputMessages builds the messages of a single-turn call from ctx: an
+// optional system message from the "system" input, then the "query" input
+// as a user message with the InputImages images
+func InputMessages(ctx ContextInput) ([]ChatMessage, error) {
+	images, err := ImagesInput(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var messages []ChatMessage
+	if system, ok := ctx.Inputs["system"].(string); ok && system != "" {
+		messages = append(messages, ChatMessage{Role: RoleSystem, Content: system})
+	}
+	messages = append(messages, ChatMessage{Role: RoleUser, Content: fmt.Sprintf("%v", ctx.Inputs["query"]), Images: images})
+	return messages, nil
+}
+
+// lastUserImages returns the images of the last user message
+func lastUserImages(messages []ChatMessage) []Image {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == RoleUser {
+			return messages[i].Images
+		}
+	}
+	return nil
+}
+
+// FlattenMessages renders a conversation as a single prompt for models that
+// only take text; images are replaced by a note. A lone user message
+// without tools is returned unchanged.
+func FlattenMessages(messages []ChatMessage, tools []ToolDefinition) string {
+	if len(messages) == 1 && messages[0].Role == RoleUser && len(tools) == 0 {
+		return messages[0].Content
+	}
+
+	var b strings.Builder
+	if len(tools) > 0 {
+		b.WriteString("Available tools:\n")
+		for _, tool := range tools {
+			params, _ := json.Marshal(tool.Parameters)
+			fmt.Fprintf(&b, "- %s: %s\n  parameters: %s\n", tool.Name, tool.Description, params)
+		}
+		b.WriteString("\n")
+	}
+
+	for _, msg := range messages {
+		switch msg.Role {
+		case RoleSystem:
+			fmt.Fprintf(&b, "System: %s\n\n", msg.Content)
+		case RoleUser:
+			fmt.Fprintf(&b, "User: %s\n\n", WithImageNote(msg.Content, len(msg.Images)))
+		case RoleAssistant:
+			b.WriteString("Assistant:")
+			if msg.Content != "" {
+				b.WriteString(" " + msg.Content)
+			}
+			b.WriteString("\n")
+			for _, call := range msg.ToolCalls {
+				args, _ := json.Marshal(call.Arguments)
+				fmt.Fprintf(&b, "[called %s with %s]\n", call.Name, args)
+			}
+			b.WriteString("\n")
+		case RoleTool:
+			fmt.Fprintf(&b, "Tool %s returned: %s\n\n", msg.Name, WithImageNote(msg.Content, len(msg.Images)))
+		default:
+			fmt.Fprintf(&b, "%s: %s\n\n", msg.Role, msg.Content)
+		}
+	}
+	b.WriteString("Assistant:")
+	return b.String()
+}
+
+// ToolDefinitions describes every registered tool, using the provider's
+// schemas when available and the same built-in fallbacks as tools/list.
+// provider may be nil.
+func ToolDefinitions(tools *ToolRegistry, provider EnhancedSchemaProvider) []ToolDefinition {
+	if tools == nil {
+		return nil
+	}
+	var defs []ToolDefinition
+	for _, tool := range toolSchemas(tools, provider) {
+		params, _ := tool.InputSchema.(map[string]interface{})
+		if params == nil {
+			params = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+		}
+		defs = append(defs, ToolDefinition{
+			Name:        tool.Name,
+			Description: tool.Description,
+			Parameters:  params,
+		})
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Name < defs[j].Name })
+	return defs
+}
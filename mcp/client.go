@@ -0,0 +1,550 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Transport carries raw JSON-RPC messages to an MCP server
+type Transport interface {
+	// RoundTrip sends a message and, when expectReply is set, returns the response
+	RoundTrip(ctx context.Context, message []byte, expectReply bool) ([]byte, error)
+	Close() error
+}
+
+// Client speaks MCP JSON-RPC to a server over any Transport
+type Client struct {
+	transport Transport
+	nextID    int64
+
+	// Trace receives every raw message sent and received when set
+	Trace io.Writer
+}
+
+// NewClient creates a client for the given transport
+func NewClient(transport Transport) *Client {
+	return &Client{transport: transport}
+}
+
+// Close releases the underlying transport
+func (c *Client) Close() error {
+	return c.transport.Close()
+}
+
+// Initialize performs the MCP handshake and returns the server's capabilities
+func (c *Client) Initialize(ctx context.Context) (*MCPInitializeResult, error) {
+	params := MCPInitializeParams{
+		ProtocolVersion: "2024-11-05",
+		Capabilities:    map[string]interface{}{},
+		ClientInfo: map[string]interface{}{
+			"name":    "conduit-client",
+			"version": "1.0.0",
+		},
+	}
+
+	var result MCPInitializeResult
+	if err := c.Call(ctx, "initialize", params, &result); err != nil {
+		return nil, err
+	}
+	if err := c.Notify(ctx, "notifications/initialized", nil); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ListTools returns the tools exposed by the server
+func (c *Client) ListTools(ctx context.Context) ([]MCPTool, error) {
+	var result MCPToolsListResult
+	if err := c.Call(ctx, "tools/list", map[string]interface{}{}, &result); err != nil {
+		return nil, err
+	}
+	return result.Tools, nil
+}
+
+// CallTool invokes a tool with the given arguments
+func (c *Client) CallTool(ctx context.Context, name string, args map[string]interface{}) (*MCPToolCallResult, error) {
+	if args == nil {
+		args = map[string]interface{}{}
+	}
+	var result MCPToolCallResult
+	if err := c.Call(ctx, "tools/call", MCPToolCallParams{Name: name, Arguments: args}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ListPrompts returns the prompts exposed by the server
+func (c *Client) ListPrompts(ctx context.Context) ([]MCPPrompt, error) {
+	var result MCPPromptsListResult
+	if err := c.Call(ctx, "prompts/list", map[string]interface{}{}, &result); err != nil {
+		return nil, err
+	}
+	return result.Prompts, nil
+}
+
+// ListResources returns the resources exposed by the server
+func (c *Client) ListResources(ctx context.Context) ([]MCPResource, error) {
+	var result MCPResourcesListResult
+	if err := c.Call(ctx, "resources/list", map[string]interface{}{}, &result); err != nil {
+		return nil, err
+	}
+	return result.Resources, nil
+}
+
+// ReadResource returns the contents of the resource at uri
+func (c *Client) ReadResource(ctx context.Context, uri string) (*MCPReadResourceResult, error) {
+	var result MCPReadResourceResult
+	if err := c.Call(ctx, "resources/read", MCPReadResourceParams{URI: uri}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Call sends a request and decodes its result into result, which may be nil.
+// Server errors are returned as *JSONRPCError.
+func (c *Client) Call(ctx context.Context, method string, params interface{}, result interface{}) error {
+	id := atomic.AddInt64(&c.nextID, 1)
+	message, err := c.encode(id, method, params)
+	if err != nil {
+		return err
+	}
+
+	c.trace("-->", message)
+	data, err := c.transport.RoundTrip(ctx, message, true)
+	if err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	c.trace("<--", data)
+
+	var response struct {
+		Result json.RawMessage `json:"result"`
+		Error  *JSONRPCError   `json:"error"`
+	}
+	if err := json.Unmarshal(data, &response); err != nil {
+		return fmt.Errorf("%s: invalid response: %w", method, err)
+	}
+	if response.Error != nil {
+		return response.Error
+	}
+	if result == nil || len(response.Result) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(response.Result, result); err != nil {
+		return fmt.Errorf("%s: failed to decode result: %w", method, err)
+	}
+	return nil
+}
+
+// Notify sends a notification, which has no response
+func (c *Client) Notify(ctx context.Context, method string, params interface{}) error {
+	message, err := c.encode(nil, method, params)
+	if err != nil {
+		return err
+	}
+	c.trace("-->", message)
+	_, err = c.transport.RoundTrip(ctx, message, false)
+	return err
+}
+
+func (c *Client) encode(id interface{}, method string, params interface{}) ([]byte, error) {
+	req := JSONRPCRequest{Jsonrpc: "2.0", ID: id, Method: method}
+	if params != nil {
+		raw, err := json.Marshal(params)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s params: %w", method, err)
+		}
+		req.Params = raw
+	}
+	return json.Marshal(req)
+}
+
+func (c *Client) trace(direction string, message []byte) {
+	if c.Trace != nil {
+		fmt.Fprintf(c.Trace, "%s %s\n", direction, bytes.TrimSpace(message))
+	}
+}
+
+// StdioTransport runs an MCP server as a child process and talks to it over
+// stdin/stdout. Requests may be in flight concurrently: one reader goroutine
+// routes each response to its caller by JSON-RPC id.
+type StdioTransport struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	reader *bufio.Reader
+
+	writeMu sync.Mutex
+	start   sync.Once
+	mu      sync.Mutex
+	pending map[string]chan []byte
+	err     error // why the reader stopped
+}
+
+// NewStdioTransport starts command with args. The server's stderr is passed through.
+func NewStdioTransport(command string, args ...string) (*StdioTransport, error) {
+	cmd := exec.Command(command, args...)
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open server stdin: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open server stdout: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %w", command, err)
+	}
+
+	t := NewIOTransport(stdout, stdin)
+	t.cmd = cmd
+	return t, nil
+}
+
+// NewIOTransport talks to a server over an existing reader and writer, such as
+// pipes connected to an in-process StdioServer
+func NewIOTransport(r io.Reader, w io.WriteCloser) *StdioTransport {
+	return &StdioTransport{stdin: w, reader: bufio.NewReader(r), pending: make(map[string]chan []byte)}
+}
+
+// RoundTrip writes one line and, when expectReply is set, waits for the
+// response carrying the message's id. A cancelled caller stops waiting; a
+// late response to it is dropped by the reader.
+func (t *StdioTransport) RoundTrip(ctx context.Context, message []byte, expectReply bool) ([]byte, error) {
+	if !expectReply {
+		return nil, t.write(message)
+	}
+
+	id, _ := messageID(message)
+	if id == "" {
+		return nil, fmt.Errorf("request has no id")
+	}
+	reply := make(chan []byte, 1)
+	t.mu.Lock()
+	if t.err != nil {
+		t.mu.Unlock()
+		return nil, t.err
+	}
+	if _, busy := t.pending[id]; busy {
+		t.mu.Unlock()
+		return nil, fmt.Errorf("request id %s is already in flight", id)
+	}
+	t.pending[id] = reply
+	t.mu.Unlock()
+	// Start reading with the first request so no response can arrive unclaimed
+	t.start.Do(func() { go t.readLoop() })
+
+	if err := t.write(message); err != nil {
+		t.forget(id)
+		return nil, err
+	}
+
+	select {
+	case data, ok := <-reply:
+		if !ok {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			return nil, t.err
+		}
+		return data, nil
+	case <-ctx.Done():
+		t.forget(id)
+		return nil, ctx.Err()
+	}
+}
+
+func (t *StdioTransport) write(message []byte) error {
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	if _, err := t.stdin.Write(append(message, '\n')); err != nil {
+		return fmt.Errorf("failed to write to server: %w", err)
+	}
+	return nil
+}
+
+func (t *StdioTransport) forget(id string) {
+	t.mu.Lock()
+	delete(t.pending, id)
+	t.mu.Unlock()
+}
+
+// readLoop hands each response line to the request with the same id.
+// Server-initiated notifications and requests are skipped. When the server
+// goes away every waiting request fails with the read error.
+func (t *StdioTransport) readLoop() {
+	for {
+		line, err := t.reader.ReadBytes('\n')
+		if err != nil {
+			if err == io.EOF {
+				err = fmt.Errorf("server closed the connection")
+			}
+			t.mu.Lock()
+			t.err = err
+			for id, reply := range t.pending {
+				close(reply)
+				delete(t.pending, id)
+			}
+			t.mu.Unlock()
+			return
+		}
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		id, response := messageID(line)
+		if !response {
+			continue
+		}
+
+		t.mu.Lock()
+		reply, waiting := t.pending[id]
+		delete(t.pending, id)
+		t.mu.Unlock()
+		if !waiting {
+			slog.Debug("dropping MCP response nobody is waiting for", "id", id)
+			continue
+		}
+		reply <- line
+	}
+}
+
+// messageID returns the id of a JSON-RPC message, empty for notifications,
+// and whether the message is a response: one with an id and no method, as
+// opposed to a request
+func messageID(message []byte) (id string, response bool) {
+	var probe struct {
+		ID     json.RawMessage `json:"id"`
+		Method string          `json:"method"`
+	}
+	if err := json.Unmarshal(message, &probe); err != nil {
+		return "", false
+	}
+	id = string(bytes.TrimSpace(probe.ID))
+	if id == "null" {
+		id = ""
+	}
+	return id, id != "" && probe.Method == ""
+}
+
+// Close closes the server's stdin and waits briefly for it to exit
+func (t *StdioTransport) Close() error {
+	err := t.stdin.Close()
+	if t.cmd == nil {
+		return err
+	}
+
+	exited := make(chan error, 1)
+	go func() { exited <- t.cmd.Wait() }()
+	select {
+	case <-exited:
+	case <-time.After(2 * time.Second):
+		t.cmd.Process.Kill()
+		<-exited
+	}
+	return err
+}
+
+// HTTPTransport speaks MCP's streamable HTTP transport: it posts each message
+// and reads the reply from either a JSON body or a text/event-stream, and
+// sends back the Mcp-Session-Id the server assigns. It does not open the
+// optional GET stream for server-initiated messages.
+type HTTPTransport struct {
+	URL    string
+	Client *http.Client
+	Header http.Header
+
+	mu        sync.Mutex
+	sessionID string
+}
+
+// NewHTTPTransport creates a transport posting to url
+func NewHTTPTransport(url string) *HTTPTransport {
+	return &HTTPTransport{
+		URL:    url,
+		Client: &http.Client{Timeout: 60 * time.Second},
+		Header: make(http.Header),
+	}
+}
+
+// RoundTrip posts the message and returns the response
+func (t *HTTPTransport) RoundTrip(ctx context.Context, message []byte, expectReply bool) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.URL, bytes.NewReader(message))
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range t.Header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	if session := t.session(); session != "" {
+		req.Header.Set("Mcp-Session-Id", session)
+	}
+
+	resp, err := t.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if session := resp.Header.Get("Mcp-Session-Id"); session != "" {
+		t.mu.Lock()
+		t.sessionID = session
+		t.mu.Unlock()
+	}
+
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("server returned %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	if !expectReply {
+		return nil, nil
+	}
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		id, _ := messageID(message)
+		return readEventReply(resp.Body, id)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	return body, nil
+}
+
+func (t *HTTPTransport) session() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.sessionID
+}
+
+// readEventReply reads server-sent events until the response with id
+// arrives, skipping notifications and requests the server sends first. An
+// empty id accepts the first response.
+func readEventReply(body io.Reader, id string) ([]byte, error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	var data []byte
+	for scanner.Scan() {
+		line := scanner.Text()
+		if rest, ok := strings.CutPrefix(line, "data:"); ok {
+			if len(data) > 0 {
+				data = append(data, '\n')
+			}
+			data = append(data, strings.TrimPrefix(rest, " ")...)
+			continue
+		}
+		if line != "" || len(data) == 0 {
+			continue
+		}
+		// A blank line ends the event
+		event := bytes.TrimSpace(data)
+		data = nil
+		if got, response := messageID(event); response && (id == "" || got == id) {
+			return event, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read event stream: %w", err)
+	}
+	if event := bytes.TrimSpace(data); len(event) > 0 {
+		if got, response := messageID(event); response && (id == "" || got == id) {
+			return event, nil
+		}
+	}
+	return nil, fmt.Errorf("event stream ended without a response")
+}
+
+// Close ends the server session, if the server assigned one
+func (t *HTTPTransport) Close() error {
+	session := t.session()
+	if session == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, t.URL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Mcp-Session-Id", session)
+	resp, err := t.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// ParseToolArguments converts key=value pairs into tool arguments, using the
+// tool's JSON schema to pick each value's type. Keys missing from the schema
+// are parsed as JSON when possible and kept as strings otherwise.
+func ParseToolArguments(schema interface{}, pairs []string) (map[string]interface{}, error) {
+	properties := map[string]interface{}{}
+	if s, ok := schema.(map[string]interface{}); ok {
+		if p, ok := s["properties"].(map[string]interface{}); ok {
+			properties = p
+		}
+	}
+
+	args := make(map[string]interface{}, len(pairs))
+	for _, pair := range pairs {
+		key, raw, ok := strings.Cut(pair, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid argument %q, expected key=value", pair)
+		}
+
+		var typ string
+		if prop, ok := properties[key].(map[string]interface{}); ok {
+			typ, _ = prop["type"].(string)
+		}
+		value, err := parseArgumentValue(typ, raw)
+		if err != nil {
+			return nil, fmt.Errorf("argument %s: %w", key, err)
+		}
+		args[key] = value
+	}
+	return args, nil
+}
+
+func parseArgumentValue(typ, raw string) (interface{}, error) {
+	switch typ {
+	case "string":
+		return raw, nil
+	case "number":
+		return strconv.ParseFloat(raw, 64)
+	case "integer":
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		// JSON decoding yields float64, which is what tools expect
+		return float64(n), nil
+	case "boolean":
+		return strconv.ParseBool(raw)
+	case "array", "object":
+		var value interface{}
+		if err := json.Unmarshal([]byte(raw), &value); err != nil {
+			return nil, fmt.Errorf("expected JSON %s: %w", typ, err)
+		}
+		return value, nil
+	default:
+		var value interface{}
+		if err := json.Unmarshal([]byte(raw), &value); err == nil {
+			return value, nil
+		}
+		return raw, nil
+	}
+}
@@ -0,0 +1,335 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func clientTestTools() *ToolRegistry {
+	tools := NewToolRegistry()
+	tools.Register("echo", func(params map[string]interface{}, memory *Memory) (interface{}, error) {
+		return params["text"], nil
+	})
+	tools.Register("fail", func(map[string]interface{}, *Memory) (interface{}, error) {
+		return nil, errors.New("always fails")
+	})
+	return tools
+}
+
+// pipeClient connects a client to an in-process stdio server
+func pipeClient(t *testing.T) *Client {
+	t.Helper()
+	clientIn, serverOut := io.Pipe()
+	serverIn, clientOut := io.Pipe()
+
+	server := NewStdioServer(clientTestTools(), NewMemory())
+	server.SetIO(serverIn, serverOut)
+	go func() {
+		server.Run()
+		serverOut.Close()
+	}()
+
+	c := NewClient(NewIOTransport(clientIn, clientOut))
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestClientOverStdio(t *testing.T) {
+	ctx := context.Background()
+	c := pipeClient(t)
+	var trace bytes.Buffer
+	c.Trace = &trace
+
+	init, err := c.Initialize(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if init.ProtocolVersion == "" || init.Capabilities["tools"] == nil {
+		t.Errorf("initialize result %+v", init)
+	}
+
+	tools, err := c.ListTools(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tools) != 2 {
+		t.Errorf("listed %d tools, want 2", len(tools))
+	}
+
+	result, err := c.CallTool(ctx, "echo", map[string]interface{}{"text": "hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Content) != 1 || result.Content[0].Text != "hello" {
+		t.Errorf("echo result %+v", result)
+	}
+
+	result, err = c.CallTool(ctx, "fail", nil)
+	if err != nil || !result.IsError || !strings.Contains(result.Content[0].Text, "always fails") {
+		t.Errorf("got %+v, %v; want an isError result", result, err)
+	}
+	_, err = c.CallTool(ctx, "missing", nil)
+	var rpcErr *JSONRPCError
+	if !errors.As(err, &rpcErr) || rpcErr.Code != -32602 {
+		t.Errorf("got %v, want invalid params for an unknown tool", err)
+	}
+
+	if prompts, err := c.ListPrompts(ctx); err != nil || len(prompts) != 0 {
+		t.Errorf("prompts %v, %v", prompts, err)
+	}
+	if err := c.Call(ctx, "no/such/method", nil, nil); !errors.As(err, &rpcErr) || rpcErr.Code != -32601 {
+		t.Errorf("got %v, want method not found", err)
+	}
+
+	if !strings.Contains(trace.String(), `--> {"jsonrpc":"2.0","id":1,"method":"initialize"`) ||
+		!strings.Contains(trace.String(), `--> {"jsonrpc":"2.0","method":"notifications/initialized"}`) ||
+		!strings.Contains(trace.String(), "<-- ") {
+		t.Errorf("trace:\n%s", trace.String())
+	}
+}
+
+func TestStdioTransportSkipsNotifications(t *testing.T) {
+	server := "{\"jsonrpc\":\"2.0\",\"method\":\"notifications/progress\"}\n\n{\"jsonrpc\":\"2.0\",\"id\":1,\"result\":{}}\n"
+	transport := NewIOTransport(strings.NewReader(server), nopWriteCloser{io.Discard})
+	reply, err := transport.RoundTrip(context.Background(), []byte(`{"id":1}`), true)
+	if err != nil || string(reply) != `{"jsonrpc":"2.0","id":1,"result":{}}` {
+		t.Errorf("got %q, %v", reply, err)
+	}
+	if _, err := transport.RoundTrip(context.Background(), []byte(`{"id":2}`), true); err == nil {
+		t.Error("expected an error once the server closed the connection")
+	}
+
+	blocked, _ := io.Pipe()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := NewIOTransport(blocked, nopWriteCloser{io.Discard}).RoundTrip(ctx, []byte(`{"id":1}`), true); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want the context error", err)
+	}
+}
+
+func TestStdioTransportRoutesByID(t *testing.T) {
+	clientIn, serverOut := io.Pipe()
+	serverIn, clientOut := io.Pipe()
+	transport := NewIOTransport(clientIn, clientOut)
+	defer transport.Close()
+
+	requests := bufio.NewReader(serverIn)
+	received := make(chan string, 4)
+	go func() {
+		for {
+			line, err := requests.ReadString('\n')
+			if err != nil {
+				return
+			}
+			id, _ := messageID([]byte(line))
+			received <- id
+		}
+	}()
+	reply := func(lines ...string) {
+		for _, line := range lines {
+			io.WriteString(serverOut, line+"\n")
+		}
+	}
+
+	// The first caller gives up; its late reply must not reach the next caller
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-received
+		cancel()
+	}()
+	if _, err := transport.RoundTrip(ctx, []byte(`{"id":1,"method":"slow"}`), true); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+	go func() {
+		<-received
+		reply(`{"jsonrpc":"2.0","id":1,"result":"stale"}`,
+			`{"jsonrpc":"2.0","id":2,"method":"sampling/createMessage"}`,
+			`{"jsonrpc":"2.0","id":2,"result":"fresh"}`)
+	}()
+	got, err := transport.RoundTrip(context.Background(), []byte(`{"id":2,"method":"next"}`), true)
+	if err != nil || string(got) != `{"jsonrpc":"2.0","id":2,"result":"fresh"}` {
+		t.Fatalf("got %s, %v; want the reply to id 2", got, err)
+	}
+
+	// Concurrent calls answered out of order each get their own reply
+	go func() {
+		<-received
+		<-received
+		reply(`{"jsonrpc":"2.0","id":"b","result":"B"}`, `{"jsonrpc":"2.0","id":"a","result":"A"}`)
+	}()
+	results := make(chan string, 2)
+	for _, id := range []string{"a", "b"} {
+		go func(id string) {
+			data, err := transport.RoundTrip(context.Background(), []byte(`{"id":"`+id+`","method":"m"}`), true)
+			if err != nil {
+				t.Error(err)
+			}
+			var resp struct{ ID, Result string }
+			json.Unmarshal(data, &resp)
+			results <- resp.ID + "=" + resp.Result
+		}(id)
+	}
+	for i := 0; i < 2; i++ {
+		if r := <-results; r != "a=A" && r != "b=B" {
+			t.Errorf("mismatched reply %s", r)
+		}
+	}
+
+	serverOut.Close()
+	if _, err := transport.RoundTrip(context.Background(), []byte(`{"id":3,"method":"m"}`), true); err == nil {
+		t.Error("expected an error once the server closed the connection")
+	}
+}
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+func TestClientOverHTTP(t *testing.T) {
+	server := NewUnifiedServer(nil, clientTestTools())
+	srv := httptest.NewServer(http.HandlerFunc(server.handleJSONRPCHTTP))
+	defer srv.Close()
+
+	ctx := context.Background()
+	c := NewClient(NewHTTPTransport(srv.URL))
+	if _, err := c.Initialize(ctx); err != nil {
+		t.Fatal(err)
+	}
+	result, err := c.CallTool(ctx, "echo", map[string]interface{}{"text": "over http"})
+	if err != nil || result.Content[0].Text != "over http" {
+		t.Fatalf("got %+v, %v", result, err)
+	}
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET returned %d", resp.StatusCode)
+	}
+	reply, err := NewHTTPTransport(srv.URL).RoundTrip(ctx, []byte("{"), true)
+	if err != nil || !strings.Contains(string(reply), "-32700") {
+		t.Errorf("malformed request: got %s, %v; want a parse error", reply, err)
+	}
+}
+
+func TestHTTPTransportEventStream(t *testing.T) {
+	var deleted string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			deleted = r.Header.Get("Mcp-Session-Id")
+			return
+		}
+		if !strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+			t.Errorf("Accept %q", r.Header.Get("Accept"))
+		}
+		id, _ := messageID(mustRead(t, r.Body))
+		switch session := r.Header.Get("Mcp-Session-Id"); {
+		case id == "1":
+			w.Header().Set("Mcp-Session-Id", "s-42")
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"jsonrpc":"2.0","id":1,"result":{}}`)
+		case session != "s-42":
+			http.Error(w, "missing session", http.StatusBadRequest)
+		default:
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, "event: message\ndata: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/progress\"}\n\n")
+			io.WriteString(w, "data: {\"jsonrpc\":\"2.0\",\"id\":"+id+",\n")
+			io.WriteString(w, "data: \"result\":{\"content\":[{\"type\":\"text\",\"text\":\"streamed\"}]}}\n\n")
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	transport := NewHTTPTransport(srv.URL)
+	c := NewClient(transport)
+	if err := c.Call(ctx, "initialize", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	result, err := c.CallTool(ctx, "echo", nil)
+	if err != nil || result.Content[0].Text != "streamed" {
+		t.Fatalf("got %+v, %v", result, err)
+	}
+	if err := c.Close(); err != nil || deleted != "s-42" {
+		t.Errorf("close: %v, deleted session %q", err, deleted)
+	}
+
+	if _, err := readEventReply(strings.NewReader("data: {\"jsonrpc\":\"2.0\",\"id\":9,\"result\":1}\n\n"), "2"); err == nil {
+		t.Error("a stream without the awaited id should fail")
+	}
+}
+
+func mustRead(t *testing.T, r io.Reader) []byte {
+	t.Helper()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestJSONRPCCORS(t *testing.T) {
+	server := NewUnifiedServer(nil, clientTestTools())
+	preflight := httptest.NewRequest(http.MethodOptions, "/rpc", nil)
+	w := httptest.NewRecorder()
+	server.handleJSONRPCHTTP(w, preflight)
+	if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Origin") != "*" ||
+		!strings.Contains(w.Header().Get("Access-Control-Allow-Headers"), "Content-Type") {
+		t.Errorf("preflight %d %v", w.Code, w.Header())
+	}
+
+	server.SetCORS(false)
+	w = httptest.NewRecorder()
+	server.handleJSONRPCHTTP(w, httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"ping"}`)))
+	if w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("CORS disabled but got %v", w.Header())
+	}
+	w = httptest.NewRecorder()
+	server.handleJSONRPCHTTP(w, preflight)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("preflight with CORS disabled returned %d", w.Code)
+	}
+}
+
+func TestParseToolArguments(t *testing.T) {
+	schema := map[string]interface{}{
+		"properties": map[string]interface{}{
+			"name":  map[string]interface{}{"type": "string"},
+			"count": map[string]interface{}{"type": "integer"},
+			"ratio": map[string]interface{}{"type": "number"},
+			"on":    map[string]interface{}{"type": "boolean"},
+			"tags":  map[string]interface{}{"type": "array"},
+		},
+	}
+	tests := []struct {
+		pairs   []string
+		want    map[string]interface{}
+		wantErr bool
+	}{
+		{[]string{"name=42", "count=3", "ratio=0.5", "on=true", `tags=["a"]`},
+			map[string]interface{}{"name": "42", "count": float64(3), "ratio": 0.5, "on": true, "tags": []interface{}{"a"}}, false},
+		{[]string{"extra=7", "note=plain text", "eq=a=b"},
+			map[string]interface{}{"extra": float64(7), "note": "plain text", "eq": "a=b"}, false},
+		{[]string{"count=1.5"}, nil, true},
+		{[]string{"tags=a,b"}, nil, true},
+		{[]string{"novalue"}, nil, true},
+		{[]string{"=x"}, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseToolArguments(schema, tt.pairs)
+		if (err != nil) != tt.wantErr || (!tt.wantErr && !reflect.DeepEqual(got, tt.want)) {
+			t.Errorf("%v: got %v, %v", tt.pairs, got, err)
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package mcp
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// InputImages is the ContextInput key carrying images sent with the query.
+// Its value may be an Image, a []Image, or strings (a single one or a list)
+// holding data URLs, http(s) URLs or raw base64. Inputs can come from remote
+// clients, so strings are never read as file paths; load local files with
+// ImageFromFile and pass the Image.
+const InputImages = "images"
+
+// Image is an image sent to or returned by a model. Data holds the image
+// bytes; URL is set instead for remote images the provider fetches itself.
+type Image struct {
+	MIMEType string `json:"mime_type,omitempty"`
+	Data     []byte `json:"data,omitempty"`
+	URL      string `json:"url,omitempty"`
+}
+
+// ImageFromFile reads an image from disk, detecting its MIME type
+func ImageFromFile(path string) (Image, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Image{}, fmt.Errorf("failed to read image: %w", err)
+	}
+	return ImageFromBytes(data, mimeFromExtension(path)), nil
+}
+
+// ImageFromBytes wraps image bytes. An empty mimeType is detected from the
+// data.
+func ImageFromBytes(data []byte, mimeType string) Image {
+	if mimeType == "" {
+		mimeType = http.DetectContentType(data)
+	}
+	return Image{MIMEType: mimeType, Data: data}
+}
+
+// ImageFromBase64 decodes a base64 image or a data: URL
+func ImageFromBase64(encoded string) (Image, error) {
+	var mimeType string
+	if rest, ok := strings.CutPrefix(encoded, "data:"); ok {
+		header, payload, found := strings.Cut(rest, ",")
+		if !found || !strings.HasSuffix(header, ";base64") {
+			return Image{}, fmt.Errorf("unsupported data URL: expected base64 encoding")
+		}
+		mimeType, encoded = strings.TrimSuffix(header, ";base64"), payload
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return Image{}, fmt.Errorf("invalid base64 image: %w", err)
+	}
+	return ImageFromBytes(data, mimeType), nil
+}
+
+// ImageFromURL refers to a remote image. Providers that only accept inline
+// data reject it.
+func ImageFromURL(url string) Image {
+	return Image{URL: url, MIMEType: mimeFromExtension(url)}
+}
+
+// ParseImage reads an image reference: an http(s) URL, a data URL or raw
+// base64. It never touches the filesystem, since references may come from
+// request inputs.
+func ParseImage(ref string) (Image, error) {
+	ref = strings.TrimSpace(ref)
+	switch {
+	case ref == "":
+		return Image{}, fmt.Errorf("empty image reference")
+	case strings.HasPrefix(ref, "http://"), strings.HasPrefix(ref, "https://"):
+		return ImageFromURL(ref), nil
+	case strings.HasPrefix(ref, "data:"):
+		return ImageFromBase64(ref)
+	}
+	img, err := ImageFromBase64(ref)
+	if err != nil {
+		return Image{}, fmt.Errorf("image %q is not a URL or base64", truncateRef(ref))
+	}
+	return img, nil
+}
+
+// Base64 returns the image data base64-encoded
+func (i Image) Base64() string {
+	return base64.StdEncoding.EncodeToString(i.Data)
+}
+
+// DataURL returns the image as a data: URL, or its URL when it is remote
+func (i Image) DataURL() string {
+	if len(i.Data) == 0 {
+		return i.URL
+	}
+	return fmt.Sprintf("data:%s;base64,%s", i.MIMEType, i.Base64())
+}
+
+// Remote reports whether the image is only available by URL
+func (i Image) Remote() bool {
+	return len(i.Data) == 0 && i.URL != ""
+}
+
+// ImagesInput returns the images sent with ctx, if any
+func ImagesInput(ctx ContextInput) ([]Image, error) {
+	value, ok := ctx.Inputs[InputImages]
+	if !ok || value == nil {
+		return nil, nil
+	}
+	images, err := parseImages(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s input: %w", InputImages, err)
+	}
+	return images, nil
+}
+
+// parseImages accepts the forms documented on InputImages, including the
+// []interface{} and map values of decoded JSON
+func parseImages(value interface{}) ([]Image, error) {
+	switch v := value.(type) {
+	case Image:
+		return []Image{v}, nil
+	case *Image:
+		return []Image{*v}, nil
+	case []Image:
+		return v, nil
+	case string:
+		img, err := ParseImage(v)
+		if err != nil {
+			return nil, err
+		}
+		return []Image{img}, nil
+	case []string:
+		images := make([]Image, 0, len(v))
+		for _, ref := range v {
+			img, err := ParseImage(ref)
+			if err != nil {
+				return nil, err
+			}
+			images = append(images, img)
+		}
+		return images, nil
+	case map[string]interface{}:
+		return imageFromMap(v)
+	case []interface{}:
+		var images []Image
+		for _, item := range v {
+			parsed, err := parseImages(item)
+			if err != nil {
+				return nil, err
+			}
+			images = append(images, parsed...)
+		}
+		return images, nil
+	}
+	return nil, fmt.Errorf("unsupported image value of type %T", value)
+}
+
+// imageFromMap reads a decoded Image, whose data is base64 in JSON
+func imageFromMap(m map[string]interface{}) ([]Image, error) {
+	mimeType, _ := m["mime_type"].(string)
+	if url, _ := m["url"].(string); url != "" {
+		img := ImageFromURL(url)
+		if mimeType != "" {
+			img.MIMEType = mimeType
+		}
+		return []Image{img}, nil
+	}
+	data, _ := m["data"].(string)
+	if data == "" {
+		return nil, fmt.Errorf("image has neither data nor url")
+	}
+	img, err := ImageFromBase64(data)
+	if err != nil {
+		return nil, err
+	}
+	if mimeType != "" {
+		img.MIMEType = mimeType
+	}
+	return []Image{img}, nil
+}
+
+// ToolContent is a tool result carrying images for the model, e.g. a
+// screenshot or a rendered chart. Tools may also return an Image or []Image.
+type ToolContent struct {
+	Text   string  `json:"text,omitempty"`
+	Images []Image `json:"images,omitempty"`
+}
+
+// toolResultContent splits a tool result into message text and images
+func toolResultContent(result interface{}) (string, []Image) {
+	if text, images, ok := SplitToolContent(result); ok {
+		return text, images
+	}
+	return FormatToolResult(result), nil
+}
+
+// SplitToolContent returns the text and images of a ToolContent, Image or
+// []Image tool result; ok is false for other results
+func SplitToolContent(result interface{}) (text string, images []Image, ok bool) {
+	switch v := result.(type) {
+	case ToolContent:
+		return v.Text, v.Images, true
+	case *ToolContent:
+		return v.Text, v.Images, true
+	case Image:
+		return "", []Image{v}, true
+	case []Image:
+		return "", v, true
+	}
+	return "", nil, false
+}
+
+// WithImageNote appends a note for images to text rendered for text-only
+// models
+func WithImageNote(text string, images int) string {
+	if images == 0 {
+		return text
+	}
+	if text == "" {
+		return imageNote(images)
+	}
+	return text + " " + imageNote(images)
+}
+
+// imageNote stands in for n images when a message is rendered as text
+func imageNote(n int) string {
+	if n == 1 {
+		return "[1 image]"
+	}
+	return fmt.Sprintf("[%d images]", n)
+}
+
+func mimeFromExtension(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".png":
+		return "image/png"
+	case ".jpg", ".jpeg":
+		return "image/jpeg"
+	case ".gif":
+		return "image/gif"
+	case ".webp":
+		return "image/webp"
+	}
+	return ""
+}
+
+func truncateRef(ref string) string {
+	if len(ref) > 40 {
+		return ref[:40] + "..."
+	}
+	return ref
+}
@@ -0,0 +1,77 @@
+package mcp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseImage(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n")
+	path := filepath.Join(t.TempDir(), "secret.png")
+	if err := os.WriteFile(path, png, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		ref     string
+		want    Image
+		wantErr bool
+	}{
+		{name: "https URL", ref: "https://example.com/cat.jpg", want: Image{URL: "https://example.com/cat.jpg", MIMEType: "image/jpeg"}},
+		{name: "data URL", ref: "data:image/gif;base64,R0lG", want: Image{MIMEType: "image/gif", Data: []byte("GIF")}},
+		{name: "raw base64", ref: "iVBORw0KGgo=", want: Image{MIMEType: "image/png", Data: png}},
+		{name: "file path is not read", ref: path, wantErr: true},
+		{name: "relative path is not read", ref: "../../etc/passwd", wantErr: true},
+		{name: "non-base64 data URL", ref: "data:image/png,abc", wantErr: true},
+		{name: "empty", ref: " ", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseImage(tt.ref)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && (got.URL != tt.want.URL || got.MIMEType != tt.want.MIMEType || string(got.Data) != string(tt.want.Data)) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	// Local files are only read when asked for explicitly
+	img, err := ImageFromFile(path)
+	if err != nil || string(img.Data) != string(png) || img.MIMEType != "image/png" {
+		t.Errorf("ImageFromFile: %+v, %v", img, err)
+	}
+}
+
+func TestImagesInput(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   interface{}
+		want    int
+		wantErr bool
+	}{
+		{name: "unset", value: nil, want: 0},
+		{name: "Image", value: Image{URL: "https://example.com/a.png"}, want: 1},
+		{name: "strings", value: []string{"https://example.com/a.png", "data:image/png;base64,AQID"}, want: 2},
+		{name: "decoded JSON", value: []interface{}{
+			map[string]interface{}{"url": "https://example.com/a.png"},
+			map[string]interface{}{"mime_type": "image/png", "data": "AQID"},
+		}, want: 2},
+		{name: "path from a request", value: "/etc/hostname", wantErr: true},
+		{name: "unsupported type", value: 42, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			images, err := ImagesInput(ContextInput{Inputs: map[string]interface{}{InputImages: tt.value}})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if len(images) != tt.want {
+				t.Errorf("got %d images, want %d", len(images), tt.want)
+			}
+		})
+	}
+}
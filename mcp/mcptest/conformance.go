@@ -0,0 +1,242 @@
+package mcptest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/benozo/conduit/mcp"
+)
+
+// rawResponse is a JSON-RPC response decoded without assuming it is well formed
+type rawResponse struct {
+	Jsonrpc string            `json:"jsonrpc"`
+	ID      json.RawMessage   `json:"id"`
+	Result  json.RawMessage   `json:"result"`
+	Error   *mcp.JSONRPCError `json:"error"`
+}
+
+// AssertConformance runs Check and reports every violation as a test error
+func (c *Client) AssertConformance(tb testing.TB) {
+	tb.Helper()
+	ctx, cancel := c.context()
+	defer cancel()
+	for _, err := range Check(ctx, c.transport) {
+		tb.Errorf("mcptest: %v", err)
+	}
+}
+
+// Check sends raw JSON-RPC messages through transport and reports where the
+// server's responses break the MCP 2024-11-05 spec: the initialize handshake,
+// ID echoing, ping, tools/list shape, how failed tool calls are reported and
+// the standard error codes.
+func Check(ctx context.Context, transport mcp.Transport) []error {
+	var problems []error
+	fail := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Errorf(format, args...))
+	}
+
+	// initialize, with a string ID
+	resp, err := roundTrip(ctx, transport, "init-1", "initialize", mcp.MCPInitializeParams{
+		ProtocolVersion: "2024-11-05",
+		Capabilities:    map[string]interface{}{},
+		ClientInfo:      map[string]interface{}{"name": "mcptest", "version": "1.0.0"},
+	})
+	if err != nil {
+		fail("initialize: %v", err)
+		return problems
+	}
+	checkEnvelope(resp, `"init-1"`, "initialize", fail)
+	var init struct {
+		ProtocolVersion string                     `json:"protocolVersion"`
+		Capabilities    map[string]json.RawMessage `json:"capabilities"`
+		ServerInfo      struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		} `json:"serverInfo"`
+	}
+	if decodeResult(resp, &init, "initialize", fail) {
+		if init.ProtocolVersion == "" {
+			fail("initialize: result has no protocolVersion")
+		}
+		if init.Capabilities == nil {
+			fail("initialize: result has no capabilities object")
+		}
+		if init.ServerInfo.Name == "" {
+			fail("initialize: serverInfo.name is empty")
+		}
+	}
+
+	if err := notify(ctx, transport, "notifications/initialized"); err != nil {
+		fail("notifications/initialized: %v", err)
+	}
+
+	// ping, with a numeric ID. A stray reply to the notification above would
+	// arrive here instead and fail the ID check.
+	if resp, err := roundTrip(ctx, transport, 2, "ping", nil); err != nil {
+		fail("ping: %v", err)
+	} else {
+		checkEnvelope(resp, "2", "ping", fail)
+		var result map[string]interface{}
+		decodeResult(resp, &result, "ping", fail)
+	}
+
+	// tools/list
+	if resp, err := roundTrip(ctx, transport, 3, "tools/list", map[string]interface{}{}); err != nil {
+		fail("tools/list: %v", err)
+	} else {
+		checkEnvelope(resp, "3", "tools/list", fail)
+		var result struct {
+			Tools []struct {
+				Name        string          `json:"name"`
+				InputSchema json.RawMessage `json:"inputSchema"`
+			} `json:"tools"`
+		}
+		if decodeResult(resp, &result, "tools/list", fail) {
+			if result.Tools == nil {
+				fail("tools/list: result.tools must be an array")
+			}
+			seen := make(map[string]bool)
+			for i, tool := range result.Tools {
+				if tool.Name == "" {
+					fail("tools/list: tool %d has no name", i)
+					continue
+				}
+				if seen[tool.Name] {
+					fail("tools/list: duplicate tool %q", tool.Name)
+				}
+				seen[tool.Name] = true
+
+				var schema struct {
+					Type string `json:"type"`
+				}
+				if len(tool.InputSchema) == 0 || json.Unmarshal(tool.InputSchema, &schema) != nil {
+					fail("tools/list: tool %q inputSchema must be an object", tool.Name)
+				} else if schema.Type != "object" {
+					fail("tools/list: tool %q inputSchema type is %q, want \"object\"", tool.Name, schema.Type)
+				}
+			}
+		}
+	}
+
+	// Unknown methods must get Method not found
+	if resp, err := roundTrip(ctx, transport, 4, "mcptest/no_such_method", nil); err != nil {
+		fail("unknown method: %v", err)
+	} else {
+		checkEnvelope(resp, "4", "unknown method", fail)
+		if resp.Error == nil {
+			fail("unknown method: expected error -32601, got a result")
+		} else if resp.Error.Code != -32601 {
+			fail("unknown method: error code %d, want -32601", resp.Error.Code)
+		}
+	}
+
+	// Calling a missing tool must fail, either as an isError result or as
+	// Invalid params or Internal error
+	if resp, err := roundTrip(ctx, transport, 5, "tools/call", mcp.MCPToolCallParams{
+		Name:      "mcptest_no_such_tool",
+		Arguments: map[string]interface{}{},
+	}); err != nil {
+		fail("tools/call unknown tool: %v", err)
+	} else {
+		checkEnvelope(resp, "5", "tools/call unknown tool", fail)
+		checkToolFailure(resp, "tools/call unknown tool", fail)
+	}
+
+	// Malformed params must get Invalid params
+	if resp, err := roundTrip(ctx, transport, 6, "tools/call", "not an object"); err != nil {
+		fail("tools/call invalid params: %v", err)
+	} else {
+		checkEnvelope(resp, "6", "tools/call invalid params", fail)
+		if resp.Error == nil {
+			fail("tools/call invalid params: expected error -32602, got a result")
+		} else if resp.Error.Code != -32602 {
+			fail("tools/call invalid params: error code %d, want -32602", resp.Error.Code)
+		}
+	}
+
+	return problems
+}
+
+// checkToolFailure verifies a failed tool call is reported the way the spec
+// allows: an isError result, or error -32602 or -32603
+func checkToolFailure(resp *rawResponse, method string, fail func(string, ...interface{})) {
+	if resp.Error != nil {
+		if resp.Error.Code != -32602 && resp.Error.Code != -32603 {
+			fail("%s: error code %d, want -32602, -32603 or an isError result", method, resp.Error.Code)
+		}
+		return
+	}
+	var result struct {
+		IsError bool `json:"isError"`
+	}
+	if json.Unmarshal(resp.Result, &result) != nil || !result.IsError {
+		fail("%s: expected an isError result or a JSON-RPC error", method)
+	}
+}
+
+// checkEnvelope verifies the jsonrpc version, the echoed ID and that exactly
+// one of result and error is set
+func checkEnvelope(resp *rawResponse, wantID, method string, fail func(string, ...interface{})) {
+	if resp.Jsonrpc != "2.0" {
+		fail("%s: jsonrpc is %q, want \"2.0\"", method, resp.Jsonrpc)
+	}
+	if string(resp.ID) != wantID {
+		fail("%s: response id %s, want %s", method, resp.ID, wantID)
+	}
+	hasResult := len(resp.Result) > 0 && string(resp.Result) != "null"
+	if hasResult == (resp.Error != nil) {
+		fail("%s: response must have exactly one of result and error", method)
+	}
+}
+
+// decodeResult unmarshals a successful result, reporting errors and bad shapes
+func decodeResult(resp *rawResponse, v interface{}, method string, fail func(string, ...interface{})) bool {
+	if resp.Error != nil {
+		fail("%s: unexpected error: %v", method, resp.Error)
+		return false
+	}
+	if err := json.Unmarshal(resp.Result, v); err != nil {
+		fail("%s: malformed result: %v", method, err)
+		return false
+	}
+	return true
+}
+
+func roundTrip(ctx context.Context, transport mcp.Transport, id interface{}, method string, params interface{}) (*rawResponse, error) {
+	message, err := encode(id, method, params)
+	if err != nil {
+		return nil, err
+	}
+	data, err := transport.RoundTrip(ctx, message, true)
+	if err != nil {
+		return nil, err
+	}
+	var resp rawResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("response is not valid JSON-RPC: %w", err)
+	}
+	return &resp, nil
+}
+
+func notify(ctx context.Context, transport mcp.Transport, method string) error {
+	message, err := encode(nil, method, nil)
+	if err != nil {
+		return err
+	}
+	_, err = transport.RoundTrip(ctx, message, false)
+	return err
+}
+
+func encode(id interface{}, method string, params interface{}) ([]byte, error) {
+	req := mcp.JSONRPCRequest{Jsonrpc: "2.0", ID: id, Method: method}
+	if params != nil {
+		raw, err := json.Marshal(params)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s params: %w", method, err)
+		}
+		req.Params = raw
+	}
+	return json.Marshal(req)
+}
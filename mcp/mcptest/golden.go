@@ -0,0 +1,79 @@
+package mcptest
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// update rewrites golden files instead of comparing against them:
+//
+//	go test ./... -mcptest.update
+var update = flag.Bool("mcptest.update", false, "rewrite mcptest golden files")
+
+// AssertToolsGolden compares the server's tools/list output, sorted by name,
+// with the golden file at path
+func (c *Client) AssertToolsGolden(tb testing.TB, path string) {
+	tb.Helper()
+
+	tools := c.ListTools(tb)
+	sort.Slice(tools, func(i, j int) bool { return tools[i].Name < tools[j].Name })
+	data, err := json.MarshalIndent(tools, "", "  ")
+	if err != nil {
+		tb.Fatalf("mcptest: failed to encode tools: %v", err)
+	}
+	AssertGolden(tb, path, append(data, '\n'))
+}
+
+// AssertGolden compares got with the file at path. Run tests with
+// -mcptest.update to create or rewrite the file.
+func AssertGolden(tb testing.TB, path string, got []byte) {
+	tb.Helper()
+
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			tb.Fatalf("mcptest: failed to create golden directory: %v", err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			tb.Fatalf("mcptest: failed to write golden file: %v", err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		tb.Fatalf("mcptest: failed to read golden file (run with -mcptest.update to create it): %v", err)
+	}
+	if !bytes.Equal(normalizeNewlines(want), normalizeNewlines(got)) {
+		tb.Errorf("mcptest: %s does not match (run with -mcptest.update to accept):\n%s", path, firstDifference(string(want), string(got)))
+	}
+}
+
+func normalizeNewlines(b []byte) []byte {
+	return bytes.ReplaceAll(b, []byte("\r\n"), []byte("\n"))
+}
+
+// firstDifference describes the first line that differs between want and got
+func firstDifference(want, got string) string {
+	wantLines := strings.Split(want, "\n")
+	gotLines := strings.Split(got, "\n")
+	for i := 0; i < len(wantLines) || i < len(gotLines); i++ {
+		var w, g string
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if w != g {
+			return fmt.Sprintf("line %d:\n  want: %s\n  got:  %s", i+1, w, g)
+		}
+	}
+	return "files differ"
+}
@@ -0,0 +1,197 @@
+// Package mcptest runs MCP servers in-process for tests and talks to them
+// over the real JSON-RPC protocol.
+//
+//	func TestTools(t *testing.T) {
+//		tools := mcp.NewToolRegistry()
+//		tools.Register("echo", echo)
+//		c := mcptest.NewStdio(t, mcp.NewStdioServer(tools, mcp.NewMemory()))
+//
+//		result := c.CallTool(t, "echo", map[string]interface{}{"text": "hi"})
+//		c.AssertToolsGolden(t, "testdata/tools.golden.json")
+//		c.AssertConformance(t)
+//	}
+package mcptest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benozo/conduit/mcp"
+)
+
+// DefaultTimeout bounds each helper call
+const DefaultTimeout = 10 * time.Second
+
+// Client is an initialized MCP client connected to an in-process server
+type Client struct {
+	// RPC is the underlying client, for calls the helpers don't cover
+	RPC *mcp.Client
+	// Init is the server's initialize result
+	Init *mcp.MCPInitializeResult
+	// Timeout bounds each helper call (default DefaultTimeout)
+	Timeout time.Duration
+
+	transport mcp.Transport
+	cleanup   []func()
+}
+
+// NewStdio connects to server through in-memory pipes. The server runs until
+// the test ends.
+func NewStdio(tb testing.TB, server *mcp.StdioServer) *Client {
+	tb.Helper()
+
+	requestsR, requestsW := io.Pipe()
+	responsesR, responsesW := io.Pipe()
+	server.SetIO(requestsR, responsesW)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		server.Run()
+		responsesW.Close()
+	}()
+
+	transport := mcp.NewIOTransport(responsesR, requestsW)
+	return connect(tb, transport, func() {
+		// Closing the request pipe ends Run's scan loop
+		requestsW.Close()
+		responsesR.Close()
+		<-done
+	})
+}
+
+// NewHTTP serves handler from an httptest server and posts JSON-RPC to its /rpc
+// path. handler may be a UnifiedServer's Handler() or any JSON-RPC handler.
+func NewHTTP(tb testing.TB, handler http.Handler) *Client {
+	tb.Helper()
+
+	srv := httptest.NewServer(handler)
+	transport := mcp.NewHTTPTransport(strings.TrimSuffix(srv.URL, "/") + "/rpc")
+	return connect(tb, transport, srv.Close)
+}
+
+// NewClient initializes a client over any transport, closing it when the test ends
+func NewClient(tb testing.TB, transport mcp.Transport) *Client {
+	tb.Helper()
+	return connect(tb, transport, nil)
+}
+
+func connect(tb testing.TB, transport mcp.Transport, stop func()) *Client {
+	tb.Helper()
+
+	c := &Client{RPC: mcp.NewClient(transport), Timeout: DefaultTimeout, transport: transport}
+	c.cleanup = append(c.cleanup, func() { transport.Close() })
+	if stop != nil {
+		c.cleanup = append(c.cleanup, stop)
+	}
+	tb.Cleanup(c.Close)
+
+	ctx, cancel := c.context()
+	defer cancel()
+	init, err := c.RPC.Initialize(ctx)
+	if err != nil {
+		tb.Fatalf("mcptest: initialize failed: %v", err)
+	}
+	c.Init = init
+	return c
+}
+
+// Close shuts the connection and server down. It runs automatically at test cleanup.
+func (c *Client) Close() {
+	cleanup := c.cleanup
+	c.cleanup = nil
+	for _, fn := range cleanup {
+		fn()
+	}
+}
+
+// ListTools returns the server's tools, failing the test on error
+func (c *Client) ListTools(tb testing.TB) []mcp.MCPTool {
+	tb.Helper()
+	ctx, cancel := c.context()
+	defer cancel()
+	tools, err := c.RPC.ListTools(ctx)
+	if err != nil {
+		tb.Fatalf("mcptest: tools/list failed: %v", err)
+	}
+	return tools
+}
+
+// CallTool calls a tool, failing the test on error
+func (c *Client) CallTool(tb testing.TB, name string, args map[string]interface{}) *mcp.MCPToolCallResult {
+	tb.Helper()
+	ctx, cancel := c.context()
+	defer cancel()
+	result, err := c.RPC.CallTool(ctx, name, args)
+	if err != nil {
+		tb.Fatalf("mcptest: tools/call %s failed: %v", name, err)
+	}
+	return result
+}
+
+// CallToolText calls a tool and returns its text content joined by newlines
+func (c *Client) CallToolText(tb testing.TB, name string, args map[string]interface{}) string {
+	tb.Helper()
+	return contentText(c.CallTool(tb, name, args))
+}
+
+// contentText joins a result's text content with newlines
+func contentText(result *mcp.MCPToolCallResult) string {
+	texts := make([]string, 0, len(result.Content))
+	for _, content := range result.Content {
+		if content.Type == "text" {
+			texts = append(texts, content.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// CallToolError calls a tool that is expected to fail and returns the
+// failure message. The failure must come back as an isError result, or as a
+// JSON-RPC error with code -32602 or -32603.
+func (c *Client) CallToolError(tb testing.TB, name string, args map[string]interface{}) string {
+	tb.Helper()
+	ctx, cancel := c.context()
+	defer cancel()
+	result, err := c.RPC.CallTool(ctx, name, args)
+	if err != nil {
+		var rpcErr *mcp.JSONRPCError
+		if !errors.As(err, &rpcErr) {
+			tb.Fatalf("mcptest: tools/call %s failed without a JSON-RPC error: %v", name, err)
+		}
+		if rpcErr.Code != -32602 && rpcErr.Code != -32603 {
+			tb.Fatalf("mcptest: tools/call %s failed with error code %d, want -32602, -32603 or an isError result", name, rpcErr.Code)
+		}
+		return rpcErr.Message
+	}
+	if !result.IsError {
+		tb.Fatalf("mcptest: tools/call %s succeeded, expected an error", name)
+	}
+	return contentText(result)
+}
+
+// ReadResource reads a resource, failing the test on error
+func (c *Client) ReadResource(tb testing.TB, uri string) *mcp.MCPReadResourceResult {
+	tb.Helper()
+	ctx, cancel := c.context()
+	defer cancel()
+	result, err := c.RPC.ReadResource(ctx, uri)
+	if err != nil {
+		tb.Fatalf("mcptest: resources/read %s failed: %v", uri, err)
+	}
+	return result
+}
+
+func (c *Client) context() (context.Context, context.CancelFunc) {
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return context.WithTimeout(context.Background(), timeout)
+}
@@ -0,0 +1,61 @@
+package mcptest_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/benozo/conduit/mcp"
+	"github.com/benozo/conduit/mcp/mcptest"
+)
+
+func testTools() *mcp.ToolRegistry {
+	tools := mcp.NewToolRegistry()
+	tools.Register("echo", func(params map[string]interface{}, memory *mcp.Memory) (interface{}, error) {
+		return fmt.Sprint(params["text"]), nil
+	})
+	tools.Register("fail", func(map[string]interface{}, *mcp.Memory) (interface{}, error) {
+		return nil, errors.New("always fails")
+	})
+	tools.Register("screenshot", func(map[string]interface{}, *mcp.Memory) (interface{}, error) {
+		return mcp.ToolContent{Text: "the screen", Images: []mcp.Image{{MIMEType: "image/png", Data: []byte{1, 2, 3}}}}, nil
+	})
+	return tools
+}
+
+func TestStdioConformance(t *testing.T) {
+	c := mcptest.NewStdio(t, mcp.NewStdioServer(testTools(), mcp.NewMemory()))
+	c.AssertConformance(t)
+
+	if got := c.CallToolText(t, "echo", map[string]interface{}{"text": "hi"}); got != "hi" {
+		t.Errorf("echo returned %q", got)
+	}
+	if got := c.CallToolError(t, "fail", nil); !strings.Contains(got, "always fails") {
+		t.Errorf("tool error %q", got)
+	}
+	if got := c.CallToolError(t, "missing", nil); !strings.Contains(got, "missing") {
+		t.Errorf("unknown tool error %q", got)
+	}
+
+	result := c.CallTool(t, "screenshot", nil)
+	if len(result.Content) != 2 || result.Content[0].Text != "the screen" ||
+		result.Content[1].Type != "image" || result.Content[1].Data != "AQID" || result.Content[1].MimeType != "image/png" {
+		t.Errorf("screenshot content %+v", result.Content)
+	}
+
+	c.AssertToolsGolden(t, "testdata/tools.golden.json")
+}
+
+func TestHTTPConformance(t *testing.T) {
+	server := mcp.NewUnifiedServer(nil, testTools())
+	c := mcptest.NewHTTP(t, server.Handler())
+	c.AssertConformance(t)
+
+	if got := c.CallToolText(t, "echo", map[string]interface{}{"text": "over http"}); got != "over http" {
+		t.Errorf("echo returned %q", got)
+	}
+	if n := len(c.ListTools(t)); n != 3 {
+		t.Errorf("listed %d tools, want 3", n)
+	}
+}
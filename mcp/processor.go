@@ -1,6 +1,11 @@
 package mcp
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+
+	"github.com/benozo/conduit/lib/tracing"
+)
 
 type ModelFunc func(ctx ContextInput, req MCPRequest, memory *Memory, onToken StreamCallback) (string, error)
 
@@ -28,14 +33,26 @@ func (p *MCPProcessor) EnableStreaming(cb StreamCallback) {
 }
 
 func (p *MCPProcessor) Run(req MCPRequest) (map[string]interface{}, error) {
+	return p.RunContext(context.Background(), req)
+}
+
+// RunContext processes a request, propagating ctx to tools and models for tracing
+func (p *MCPProcessor) RunContext(parent context.Context, req MCPRequest) (map[string]interface{}, error) {
+	parent, span := tracing.Start(parent, "mcp.process",
+		tracing.String("session.id", req.SessionID),
+		tracing.Int("contexts", len(req.Contexts)),
+	)
+	defer span.End()
+
 	results := make(map[string]interface{})
 
 	for _, ctx := range req.Contexts {
 		var out interface{}
 		var err error
+		ctx = ctx.WithContext(parent)
 
 		if req.ToolChoice != nil {
-			out, err = p.Tools.Call(req.ToolChoice.Name, req.ToolChoice.Parameters, p.Memory)
+			out, err = p.Tools.CallContext(parent, req.ToolChoice.Name, req.ToolChoice.Parameters, p.Memory)
 		} else {
 			onToken := func(contextID, token string) {}
 			if p.StreamTokens && p.OnToken != nil {
@@ -47,6 +64,7 @@ func (p *MCPProcessor) Run(req MCPRequest) (map[string]interface{}, error) {
 		}
 
 		if err != nil {
+			span.RecordError(err)
 			return nil, fmt.Errorf("context %s error: %w", ctx.ContextID, err)
 		}
 		results[ctx.ContextID] = out
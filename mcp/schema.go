@@ -0,0 +1,274 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ValidateSchema checks a decoded JSON value against a JSON Schema and
+// returns one problem per violation, prefixed with the value's path ($ is
+// the root). It supports the keywords models are asked to follow: type,
+// enum, const, properties, required, additionalProperties, items, minItems,
+// maxItems, minimum, maximum, exclusiveMinimum, exclusiveMaximum, minLength,
+// maxLength, pattern, allOf, anyOf and oneOf. Other keywords are ignored.
+func ValidateSchema(schema map[string]interface{}, value interface{}) []string {
+	// Round-trip both through JSON so schemas and values written as Go
+	// literals (ints, typed slices and maps) compare like decoded JSON
+	normalized, _ := normalizeJSON(schema).(map[string]interface{})
+	var problems []string
+	validate(normalized, normalizeJSON(value), "$", &problems)
+	return problems
+}
+
+func validate(schema map[string]interface{}, value interface{}, path string, problems *[]string) {
+	if schema == nil {
+		return
+	}
+	add := func(format string, args ...interface{}) {
+		*problems = append(*problems, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	if t, ok := schema["type"]; ok {
+		types := schemaTypes(t)
+		if len(types) > 0 && !matchesAnyType(types, value) {
+			add("expected %s, got %s", strings.Join(types, " or "), jsonType(value))
+			return
+		}
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok && !containsValue(enum, value) {
+		add("must be one of %s", compactJSON(enum))
+	}
+	if c, ok := schema["const"]; ok && !reflect.DeepEqual(c, value) {
+		add("must be %s", compactJSON(c))
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		validateObject(schema, v, path, problems)
+	case []interface{}:
+		if n, ok := toFloat(schema["minItems"]); ok && float64(len(v)) < n {
+			add("must have at least %v items", n)
+		}
+		if n, ok := toFloat(schema["maxItems"]); ok && float64(len(v)) > n {
+			add("must have at most %v items", n)
+		}
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				validate(items, item, fmt.Sprintf("%s[%d]", path, i), problems)
+			}
+		}
+	case string:
+		length := float64(len([]rune(v)))
+		if n, ok := toFloat(schema["minLength"]); ok && length < n {
+			add("must be at least %v characters", n)
+		}
+		if n, ok := toFloat(schema["maxLength"]); ok && length > n {
+			add("must be at most %v characters", n)
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			if re, err := regexp.Compile(pattern); err == nil && !re.MatchString(v) {
+				add("must match %q", pattern)
+			}
+		}
+	case float64:
+		if n, ok := toFloat(schema["minimum"]); ok && v < n {
+			add("must be >= %v", n)
+		}
+		if n, ok := toFloat(schema["maximum"]); ok && v > n {
+			add("must be <= %v", n)
+		}
+		if n, ok := toFloat(schema["exclusiveMinimum"]); ok && v <= n {
+			add("must be > %v", n)
+		}
+		if n, ok := toFloat(schema["exclusiveMaximum"]); ok && v >= n {
+			add("must be < %v", n)
+		}
+	}
+
+	for _, sub := range subschemas(schema["allOf"]) {
+		validate(sub, value, path, problems)
+	}
+	if subs := subschemas(schema["anyOf"]); len(subs) > 0 {
+		if n, misses := countMatches(subs, value, path); n == 0 {
+			add("must match at least one of the anyOf schemas (%s)", strings.Join(misses, "; "))
+		}
+	}
+	if subs := subschemas(schema["oneOf"]); len(subs) > 0 {
+		if n, misses := countMatches(subs, value, path); n == 0 {
+			add("must match exactly one of the oneOf schemas, matched 0 (%s)", strings.Join(misses, "; "))
+		} else if n > 1 {
+			add("must match exactly one of the oneOf schemas, matched %d", n)
+		}
+	}
+}
+
+func validateObject(schema map[string]interface{}, obj map[string]interface{}, path string, problems *[]string) {
+	for _, name := range stringList(schema["required"]) {
+		if _, ok := obj[name]; !ok {
+			*problems = append(*problems, fmt.Sprintf("%s: missing required property %q", path, name))
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]interface{})
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		child := path + "." + name
+		if prop, ok := properties[name].(map[string]interface{}); ok {
+			validate(prop, obj[name], child, problems)
+			continue
+		}
+		if _, declared := properties[name]; declared {
+			continue
+		}
+		switch extra := schema["additionalProperties"].(type) {
+		case bool:
+			if !extra {
+				*problems = append(*problems, fmt.Sprintf("%s: unexpected property %q", path, name))
+			}
+		case map[string]interface{}:
+			validate(extra, obj[name], child, problems)
+		}
+	}
+}
+
+// schemaTypes reads "type" given as a string or a list of strings
+func schemaTypes(t interface{}) []string {
+	if s, ok := t.(string); ok {
+		return []string{s}
+	}
+	return stringList(t)
+}
+
+func stringList(v interface{}) []string {
+	list, _ := v.([]interface{})
+	out := make([]string, 0, len(list))
+	for _, item := range list {
+		if s, ok := item.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func subschemas(v interface{}) []map[string]interface{} {
+	list, _ := v.([]interface{})
+	var out []map[string]interface{}
+	for _, item := range list {
+		if m, ok := item.(map[string]interface{}); ok {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// countMatches counts the schemas value matches. misses describes why each
+// of the others failed, so a reply matching none can be corrected.
+func countMatches(schemas []map[string]interface{}, value interface{}, path string) (n int, misses []string) {
+	for i, sub := range schemas {
+		var problems []string
+		if validate(sub, value, path, &problems); len(problems) == 0 {
+			n++
+		} else {
+			misses = append(misses, fmt.Sprintf("option %d: %s", i+1, strings.Join(problems, ", ")))
+		}
+	}
+	return n, misses
+}
+
+func matchesAnyType(types []string, value interface{}) bool {
+	for _, t := range types {
+		if matchesType(t, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesType(t string, value interface{}) bool {
+	switch t {
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		f, ok := value.(float64)
+		return ok && f == math.Trunc(f)
+	case "null":
+		return value == nil
+	}
+	return true
+}
+
+// jsonType names the JSON type of a decoded value
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	f, ok := v.(float64)
+	return f, ok
+}
+
+func containsValue(values []interface{}, value interface{}) bool {
+	for _, v := range values {
+		if reflect.DeepEqual(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeJSON(v interface{}) interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var out interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return v
+	}
+	return out
+}
+
+func compactJSON(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
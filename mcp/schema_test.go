@@ -0,0 +1,167 @@
+package mcp
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateSchema(t *testing.T) {
+	person := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"name": map[string]interface{}{"type": "string", "minLength": 1, "maxLength": 10},
+			"age":  map[string]interface{}{"type": "integer", "minimum": 0, "exclusiveMaximum": 150},
+			"role": map[string]interface{}{"enum": []string{"admin", "user"}},
+			"tags": map[string]interface{}{
+				"type":     "array",
+				"items":    map[string]interface{}{"type": "string", "pattern": "^[a-z]+$"},
+				"maxItems": 2,
+			},
+		},
+		"required":             []string{"name"},
+		"additionalProperties": false,
+	}
+
+	tests := []struct {
+		name   string
+		schema map[string]interface{}
+		value  interface{}
+		// want holds a substring of each expected problem; none means valid
+		want []string
+	}{
+		{"valid", person, map[string]interface{}{"name": "ann", "age": 30, "role": "admin", "tags": []string{"a"}}, nil},
+		{"Go literals normalize", person, struct {
+			Name string `json:"name"`
+			Age  int64  `json:"age"`
+		}{"bob", 4}, nil},
+		{"missing required", person, map[string]interface{}{}, []string{`$: missing required property "name"`}},
+		{"wrong type", person, map[string]interface{}{"name": 5}, []string{"$.name: expected string, got integer"}},
+		{"not an integer", person, map[string]interface{}{"name": "a", "age": 1.5}, []string{"$.age: expected integer"}},
+		{"bounds", person, map[string]interface{}{"name": "", "age": 150}, []string{"$.age: must be < 150", "$.name: must be at least 1 characters"}},
+		{"enum", person, map[string]interface{}{"name": "a", "role": "root"}, []string{`$.role: must be one of ["admin","user"]`}},
+		{"items", person, map[string]interface{}{"name": "a", "tags": []interface{}{"ok", "NO", "x"}}, []string{"$.tags: must have at most 2 items", `$.tags[1]: must match "^[a-z]+$"`}},
+		{"additional property", person, map[string]interface{}{"name": "a", "extra": true}, []string{`$: unexpected property "extra"`}},
+		{"type list", map[string]interface{}{"type": []string{"string", "null"}}, nil, nil},
+		{"const", map[string]interface{}{"const": "x"}, "y", []string{`$: must be "x"`}},
+		{"anyOf", map[string]interface{}{"anyOf": []interface{}{
+			map[string]interface{}{"type": "string"},
+			map[string]interface{}{"type": "number"},
+		}}, true, []string{"must match at least one of the anyOf schemas (option 1: $: expected string, got boolean"}},
+		{"oneOf matches two", map[string]interface{}{"oneOf": []interface{}{
+			map[string]interface{}{"type": "number"},
+			map[string]interface{}{"type": "integer"},
+		}}, 3, []string{"matched 2"}},
+		{"allOf", map[string]interface{}{"allOf": []interface{}{
+			map[string]interface{}{"type": "string"},
+			map[string]interface{}{"maxLength": 2},
+		}}, "abc", []string{"must be at most 2 characters"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := ValidateSchema(tt.schema, tt.value)
+			if len(problems) != len(tt.want) {
+				t.Fatalf("got problems %q, want %d matching %q", problems, len(tt.want), tt.want)
+			}
+			for i, want := range tt.want {
+				if !strings.Contains(problems[i], want) {
+					t.Errorf("problem %d is %q, want it to contain %q", i, problems[i], want)
+				}
+			}
+		})
+	}
+}
+
+// TestValidateSchemaActionBranches checks the pattern the swarm decision
+// schema uses: oneOf branches keyed on a const, each requiring its field
+func TestValidateSchemaActionBranches(t *testing.T) {
+	branch := func(action, field string) map[string]interface{} {
+		properties := map[string]interface{}{"action": map[string]interface{}{"const": action}}
+		required := []string{"action"}
+		if field != "" {
+			properties[field] = map[string]interface{}{"type": "string", "minLength": 1}
+			required = append(required, field)
+		}
+		return map[string]interface{}{"properties": properties, "required": required}
+	}
+	schema := map[string]interface{}{
+		"type":  "object",
+		"oneOf": []interface{}{branch("tool_use", "tool_name"), branch("handoff", "handoff_agent"), branch("respond", "")},
+	}
+
+	tests := []struct {
+		value map[string]interface{}
+		valid bool
+	}{
+		{map[string]interface{}{"action": "tool_use", "tool_name": "search"}, true},
+		{map[string]interface{}{"action": "tool_use", "tool_name": ""}, false},
+		{map[string]interface{}{"action": "tool_use"}, false},
+		{map[string]interface{}{"action": "handoff", "handoff_agent": "billing"}, true},
+		{map[string]interface{}{"action": "handoff", "handoff_agent": nil}, false},
+		{map[string]interface{}{"action": "respond", "response": "hi"}, true},
+		{map[string]interface{}{"action": "dance"}, false},
+	}
+	for _, tt := range tests {
+		if problems := ValidateSchema(schema, tt.value); (len(problems) == 0) != tt.valid {
+			t.Errorf("%v: problems %q, want valid %v", tt.value, problems, tt.valid)
+		}
+	}
+}
+
+func TestExtractJSON(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+		ok   bool
+	}{
+		{`{"a":1}`, `{"a":1}`, true},
+		{"Sure:\n```json\n{\"a\": 1}\n```", `{"a": 1}`, true},
+		{`<think>{"draft": true}</think> The answer is {"a": "}"} ok`, `{"a": "}"}`, true},
+		{`list: [1, 2] done`, `[1, 2]`, true},
+		{`no json here`, "", false},
+		{`{"broken": `, "", false},
+	}
+	for _, tt := range tests {
+		got, ok := ExtractJSON(tt.text)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("ExtractJSON(%q) = %q, %v; want %q, %v", tt.text, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestGenerateStructuredRepairs(t *testing.T) {
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{"n": map[string]interface{}{"type": "integer"}},
+		"required":   []string{"n"},
+	}
+	replies := []string{"not json", `{"n": "three"}`, "```json\n{\"n\": 3}\n```"}
+	var prompts []string
+	model := func(ctx ContextInput, req MCPRequest, memory *Memory, onToken StreamCallback) (string, error) {
+		prompts = append(prompts, ctx.Inputs["query"].(string))
+		if ctx.Inputs[InputResponseSchema] == nil {
+			t.Error("response schema input not set")
+		}
+		reply := replies[0]
+		replies = replies[1:]
+		return reply, nil
+	}
+
+	var out struct{ N int }
+	if err := GenerateStructured(ContextInput{Inputs: map[string]interface{}{}}, model, schema, "count", &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.N != 3 || len(prompts) != 3 {
+		t.Fatalf("got %+v after %d attempts", out, len(prompts))
+	}
+	if !strings.Contains(prompts[2], "$.n: expected integer, got string") {
+		t.Errorf("repair prompt does not list the problem:\n%s", prompts[2])
+	}
+
+	replies = []string{"nope", "nope"}
+	err := GenerateStructured(ContextInput{}, model, schema, "count", &out, MaxAttempts(2))
+	var structuredErr *StructuredOutputError
+	if !errors.Is(err, ErrStructuredOutput) || !errors.As(err, &structuredErr) || structuredErr.Attempts != 2 {
+		t.Errorf("got %v, want a StructuredOutputError after 2 attempts", err)
+	}
+}
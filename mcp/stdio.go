@@ -2,17 +2,22 @@ package mcp
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"os"
+	"strings"
+
+	"github.com/benozo/conduit/lib/logging"
+	"github.com/benozo/conduit/lib/tracing"
 )
 
 // JSONRPCRequest represents a JSON-RPC 2.0 request
 type JSONRPCRequest struct {
 	Jsonrpc string          `json:"jsonrpc"`
-	ID      interface{}     `json:"id"`
+	ID      interface{}     `json:"id,omitempty"`
 	Method  string          `json:"method"`
 	Params  json.RawMessage `json:"params,omitempty"`
 }
@@ -31,6 +36,11 @@ type JSONRPCError struct {
 	Message string `json:"message"`
 }
 
+// Error implements the error interface
+func (e *JSONRPCError) Error() string {
+	return fmt.Sprintf("JSON-RPC error %d: %s", e.Code, e.Message)
+}
+
 // MCPTool represents an MCP tool definition
 type MCPTool struct {
 	Name        string      `json:"name"`
@@ -52,12 +62,67 @@ type MCPToolCallParams struct {
 // MCPToolCallResult represents the result of tools/call
 type MCPToolCallResult struct {
 	Content []MCPContent `json:"content"`
+	// IsError marks a tool that ran and failed; Content describes the failure
+	IsError bool `json:"isError,omitempty"`
 }
 
-// MCPContent represents content in MCP responses
+// MCPContent represents content in MCP responses. Text content sets Text;
+// image content sets Data (base64) and MimeType.
 type MCPContent struct {
-	Type string `json:"type"`
-	Text string `json:"text"`
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	Data     string `json:"data,omitempty"`
+	MimeType string `json:"mimeType,omitempty"`
+}
+
+// MCPPrompt represents an MCP prompt definition
+type MCPPrompt struct {
+	Name        string              `json:"name"`
+	Description string              `json:"description,omitempty"`
+	Arguments   []MCPPromptArgument `json:"arguments,omitempty"`
+}
+
+// MCPPromptArgument describes an argument accepted by a prompt
+type MCPPromptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+// MCPPromptsListResult represents the result of prompts/list
+type MCPPromptsListResult struct {
+	Prompts []MCPPrompt `json:"prompts"`
+}
+
+// MCPResource represents an MCP resource definition
+type MCPResource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// MCPResourcesListResult represents the result of resources/list
+type MCPResourcesListResult struct {
+	Resources []MCPResource `json:"resources"`
+}
+
+// MCPReadResourceParams represents parameters for resources/read
+type MCPReadResourceParams struct {
+	URI string `json:"uri"`
+}
+
+// MCPResourceContents holds the text or base64 blob of a resource
+type MCPResourceContents struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Blob     string `json:"blob,omitempty"`
+}
+
+// MCPReadResourceResult represents the result of resources/read
+type MCPReadResourceResult struct {
+	Contents []MCPResourceContents `json:"contents"`
 }
 
 // MCPInitializeParams represents parameters for initialize
@@ -86,7 +151,6 @@ type StdioServer struct {
 	memory         *Memory
 	input          io.Reader
 	output         io.Writer
-	logger         *log.Logger
 	schemaProvider EnhancedSchemaProvider // Optional enhanced schema provider
 }
 
@@ -97,7 +161,6 @@ func NewStdioServer(tools *ToolRegistry, memory *Memory) *StdioServer {
 		memory: memory,
 		input:  os.Stdin,
 		output: os.Stdout,
-		logger: log.New(os.Stderr, "[MCP] ", log.LstdFlags),
 	}
 }
 
@@ -108,7 +171,6 @@ func NewStdioServerWithSchemaProvider(tools *ToolRegistry, memory *Memory, provi
 		memory:         memory,
 		input:          os.Stdin,
 		output:         os.Stdout,
-		logger:         log.New(os.Stderr, "[MCP] ", log.LstdFlags),
 		schemaProvider: provider,
 	}
 }
@@ -121,7 +183,7 @@ func (s *StdioServer) SetIO(input io.Reader, output io.Writer) {
 
 // Run starts the stdio server
 func (s *StdioServer) Run() error {
-	s.logger.Println("MCP Stdio Server starting...")
+	slog.Info("MCP stdio server starting")
 
 	scanner := bufio.NewScanner(s.input)
 	for scanner.Scan() {
@@ -132,18 +194,38 @@ func (s *StdioServer) Run() error {
 
 		var req JSONRPCRequest
 		if err := json.Unmarshal([]byte(line), &req); err != nil {
+			slog.Warn("invalid JSON-RPC message", "error", err)
 			s.sendError(req.ID, -32700, "Parse error")
 			continue
 		}
 
-		s.handleRequest(req)
+		s.handleRequest(context.Background(), req)
 	}
 
 	return scanner.Err()
 }
 
+// HandleJSONRPC processes a single request and writes the response, if any, to w.
+// It is safe for concurrent use and lets other transports reuse the stdio handlers.
+func (s *StdioServer) HandleJSONRPC(ctx context.Context, req JSONRPCRequest, w io.Writer) {
+	handler := *s
+	handler.output = w
+	handler.handleRequest(ctx, req)
+}
+
 // handleRequest processes individual JSON-RPC requests
-func (s *StdioServer) handleRequest(req JSONRPCRequest) {
+func (s *StdioServer) handleRequest(ctx context.Context, req JSONRPCRequest) {
+	ctx, span := tracing.StartWithKind(requestContext(ctx, req), "jsonrpc "+req.Method, tracing.SpanKindServer,
+		tracing.String("rpc.system", "jsonrpc"),
+		tracing.String("rpc.method", req.Method),
+	)
+	defer span.End()
+
+	if req.ID != nil {
+		ctx = logging.WithRequestID(ctx, fmt.Sprint(req.ID))
+	}
+	slog.DebugContext(ctx, "handling JSON-RPC request", "method", req.Method)
+
 	switch req.Method {
 	case "initialize":
 		s.handleInitialize(req)
@@ -152,12 +234,41 @@ func (s *StdioServer) handleRequest(req JSONRPCRequest) {
 	case "tools/list":
 		s.handleToolsList(req)
 	case "tools/call":
-		s.handleToolCall(req)
+		s.handleToolCall(ctx, req)
+	case "prompts/list":
+		s.sendResult(req.ID, MCPPromptsListResult{Prompts: []MCPPrompt{}})
+	case "resources/list":
+		s.sendResult(req.ID, MCPResourcesListResult{Resources: []MCPResource{}})
+	case "resources/read":
+		s.handleResourceRead(req)
+	case "ping":
+		s.sendResult(req.ID, map[string]interface{}{})
 	default:
+		slog.WarnContext(ctx, "unknown JSON-RPC method", "method", req.Method)
 		s.sendError(req.ID, -32601, "Method not found")
 	}
 }
 
+// requestContext extracts a caller's traceparent from params._meta, if present
+func requestContext(ctx context.Context, req JSONRPCRequest) context.Context {
+	if len(req.Params) == 0 {
+		return ctx
+	}
+
+	var params struct {
+		Meta struct {
+			Traceparent string `json:"traceparent"`
+		} `json:"_meta"`
+	}
+	if err := json.Unmarshal(req.Params, &params); err != nil || params.Meta.Traceparent == "" {
+		return ctx
+	}
+	if sc, err := tracing.ParseTraceparent(params.Meta.Traceparent); err == nil {
+		ctx = tracing.ContextWithRemoteSpanContext(ctx, sc)
+	}
+	return ctx
+}
+
 // handleInitialize processes initialize requests
 func (s *StdioServer) handleInitialize(req JSONRPCRequest) {
 	var params MCPInitializeParams
@@ -169,7 +280,9 @@ func (s *StdioServer) handleInitialize(req JSONRPCRequest) {
 	result := MCPInitializeResult{
 		ProtocolVersion: "2024-11-05",
 		Capabilities: map[string]interface{}{
-			"tools": map[string]interface{}{},
+			"tools":     map[string]interface{}{},
+			"prompts":   map[string]interface{}{},
+			"resources": map[string]interface{}{},
 		},
 		ServerInfo: map[string]interface{}{
 			"name":    "conduit-server",
@@ -189,46 +302,86 @@ func (s *StdioServer) handleToolsList(req JSONRPCRequest) {
 }
 
 // handleToolCall processes tools/call requests
-func (s *StdioServer) handleToolCall(req JSONRPCRequest) {
+func (s *StdioServer) handleToolCall(ctx context.Context, req JSONRPCRequest) {
 	var params MCPToolCallParams
 	if err := json.Unmarshal(req.Params, &params); err != nil {
 		s.sendError(req.ID, -32602, "Invalid params")
 		return
 	}
 
-	result, err := s.tools.Call(params.Name, params.Arguments, s.memory)
+	if !s.tools.has(params.Name) {
+		s.sendError(req.ID, -32602, "Unknown tool: "+params.Name)
+		return
+	}
+
+	slog.DebugContext(ctx, "calling tool", "tool", params.Name, "params", logging.Redact(params.Arguments))
+	result, err := s.tools.CallContext(ctx, params.Name, params.Arguments, s.memory)
 	if err != nil {
-		s.sendError(req.ID, -32601, fmt.Sprintf("Tool error: %v", err))
+		// Failures are results, not protocol errors, so the client's model sees them
+		slog.WarnContext(ctx, "tool call failed", "tool", params.Name, "error", err)
+		s.sendResult(req.ID, MCPToolCallResult{
+			Content: []MCPContent{{Type: "text", Text: fmt.Sprintf("Tool error: %v", err)}},
+			IsError: true,
+		})
 		return
 	}
 
-	// Convert result to MCP format
-	resultText := s.formatToolResult(result)
+	s.sendResult(req.ID, MCPToolCallResult{Content: s.toolResultContent(result)})
+}
 
-	mcpResult := MCPToolCallResult{
-		Content: []MCPContent{
-			{
-				Type: "text",
-				Text: resultText,
-			},
-		},
+// toolResultContent converts a tool result to MCP content. Image results
+// become image items; remote images, which MCP can't carry, are linked in
+// the text.
+func (s *StdioServer) toolResultContent(result interface{}) []MCPContent {
+	text, images, ok := SplitToolContent(result)
+	if !ok {
+		return []MCPContent{{Type: "text", Text: s.formatToolResult(result)}}
 	}
-	s.sendResult(req.ID, mcpResult)
+
+	var content []MCPContent
+	for _, img := range images {
+		if img.Remote() {
+			text = strings.TrimSpace(text + "\n" + img.URL)
+			continue
+		}
+		content = append(content, MCPContent{Type: "image", Data: img.Base64(), MimeType: img.MIMEType})
+	}
+	if text != "" || len(content) == 0 {
+		content = append([]MCPContent{{Type: "text", Text: text}}, content...)
+	}
+	return content
+}
+
+// handleResourceRead processes resources/read requests. No resources are
+// exposed yet, so every valid request gets the spec's not-found error.
+func (s *StdioServer) handleResourceRead(req JSONRPCRequest) {
+	var params MCPReadResourceParams
+	if err := json.Unmarshal(req.Params, &params); err != nil || params.URI == "" {
+		s.sendError(req.ID, -32602, "Invalid params")
+		return
+	}
+	s.sendError(req.ID, -32002, "Resource not found: "+params.URI)
 }
 
 // getToolSchemas dynamically generates tool schemas from registered tools
 func (s *StdioServer) getToolSchemas() []MCPTool {
+	return toolSchemas(s.tools, s.schemaProvider)
+}
+
+// toolSchemas describes every tool in the registry, preferring the
+// provider's schemas over the built-in fallbacks. provider may be nil.
+func toolSchemas(tools *ToolRegistry, provider EnhancedSchemaProvider) []MCPTool {
 	var mcpTools []MCPTool
 
 	// Get all registered tool names
-	toolNames := s.tools.GetRegisteredTools()
+	toolNames := tools.GetRegisteredTools()
 
 	for _, name := range toolNames {
 		// Create a basic schema for each tool
 		tool := MCPTool{
 			Name:        name,
-			Description: s.getToolDescription(name),
-			InputSchema: s.getToolInputSchema(name),
+			Description: toolDescription(provider, name),
+			InputSchema: toolInputSchema(provider, name),
 		}
 		mcpTools = append(mcpTools, tool)
 	}
@@ -236,11 +389,11 @@ func (s *StdioServer) getToolSchemas() []MCPTool {
 	return mcpTools
 }
 
-// getToolDescription returns a description for a tool
-func (s *StdioServer) getToolDescription(name string) string {
+// toolDescription returns a description for a tool
+func toolDescription(provider EnhancedSchemaProvider, name string) string {
 	// Check if enhanced schema provider has custom description
-	if s.schemaProvider != nil {
-		if schema, exists := s.schemaProvider.GetToolSchema(name); exists {
+	if provider != nil {
+		if schema, exists := provider.GetToolSchema(name); exists {
 			if schemaMap, ok := schema.(map[string]interface{}); ok {
 				if desc, ok := schemaMap["description"].(string); ok {
 					return desc
@@ -284,11 +437,11 @@ func (s *StdioServer) getToolDescription(name string) string {
 	return fmt.Sprintf("Tool: %s", name)
 }
 
-// getToolInputSchema returns input schema for a tool
-func (s *StdioServer) getToolInputSchema(name string) interface{} {
+// toolInputSchema returns input schema for a tool
+func toolInputSchema(provider EnhancedSchemaProvider, name string) interface{} {
 	// Check if enhanced schema provider has custom schema
-	if s.schemaProvider != nil {
-		if schema, exists := s.schemaProvider.GetToolSchema(name); exists {
+	if provider != nil {
+		if schema, exists := provider.GetToolSchema(name); exists {
 			if schemaMap, ok := schema.(map[string]interface{}); ok {
 				if inputSchema, ok := schemaMap["inputSchema"]; ok {
 					return inputSchema
@@ -0,0 +1,208 @@
+package mcp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// InputResponseSchema is the ContextInput key carrying the JSON Schema a
+// reply must match. Providers with a JSON mode enable it when it is set.
+const InputResponseSchema = "response_schema"
+
+// ResponseSchemaInput returns the response schema requested by ctx, if any
+func ResponseSchemaInput(ctx ContextInput) map[string]interface{} {
+	schema, _ := ctx.Inputs[InputResponseSchema].(map[string]interface{})
+	return schema
+}
+
+// DefaultStructuredAttempts is how many replies GenerateStructured asks for
+// before giving up
+const DefaultStructuredAttempts = 3
+
+// ErrStructuredOutput matches every *StructuredOutputError
+var ErrStructuredOutput = errors.New("model output does not match schema")
+
+// StructuredOutputError is returned when no reply matched the schema
+type StructuredOutputError struct {
+	Attempts int
+	// Problems are the validation errors of the last reply
+	Problems []string
+	// Output is the last reply, for callers that fall back to plain text
+	Output string
+}
+
+func (e *StructuredOutputError) Error() string {
+	return fmt.Sprintf("model output did not match the schema after %d attempts: %s", e.Attempts, strings.Join(e.Problems, "; "))
+}
+
+// Is makes errors.Is(err, ErrStructuredOutput) true
+func (e *StructuredOutputError) Is(target error) bool {
+	return target == ErrStructuredOutput
+}
+
+// StructuredOption configures GenerateStructured
+type StructuredOption func(*structuredOptions)
+
+type structuredOptions struct {
+	attempts int
+	request  MCPRequest
+	memory   *Memory
+}
+
+// MaxAttempts sets how many replies are requested, including re-prompts
+func MaxAttempts(n int) StructuredOption {
+	return func(o *structuredOptions) {
+		if n > 0 {
+			o.attempts = n
+		}
+	}
+}
+
+// WithRequest sets the request, e.g. the model name and session, each
+// attempt is made with
+func WithRequest(req MCPRequest) StructuredOption {
+	return func(o *structuredOptions) { o.request = req }
+}
+
+// WithMemory sets the memory passed to the model
+func WithMemory(memory *Memory) StructuredOption {
+	return func(o *structuredOptions) { o.memory = memory }
+}
+
+// GenerateStructured asks model for a JSON reply matching schema and decodes
+// it into out. The schema is added to the prompt and passed in the
+// InputResponseSchema input so providers can use their JSON mode. Replies
+// that aren't valid JSON or don't match the schema are sent back with the
+// validation errors until one passes or the attempts run out, which
+// returns a *StructuredOutputError. Other inputs of ctx, such as "system",
+// are passed through.
+func GenerateStructured(ctx ContextInput, model ModelFunc, schema map[string]interface{}, prompt string, out interface{}, opts ...StructuredOption) error {
+	o := structuredOptions{attempts: DefaultStructuredAttempts}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	schemaJSON, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return fmt.Errorf("invalid schema: %w", err)
+	}
+	base := fmt.Sprintf("%s\n\nRespond with only a JSON value matching this JSON Schema, with no other text:\n%s", prompt, schemaJSON)
+	query := base
+
+	var problems []string
+	var output string
+	for attempt := 1; attempt <= o.attempts; attempt++ {
+		inputs := make(map[string]interface{}, len(ctx.Inputs)+2)
+		for k, v := range ctx.Inputs {
+			inputs[k] = v
+		}
+		inputs["query"] = query
+		inputs[InputResponseSchema] = schema
+		input := ctx
+		input.Inputs = inputs
+
+		output, err = model(input, o.request, o.memory, nil)
+		if err != nil {
+			return err
+		}
+
+		var raw string
+		raw, problems = checkStructured(schema, output)
+		if len(problems) == 0 {
+			err := json.Unmarshal([]byte(raw), out)
+			if err == nil {
+				return nil
+			}
+			problems = []string{err.Error()}
+		}
+		if err := ctx.Context().Err(); err != nil {
+			return err
+		}
+		query = repairPrompt(base, output, problems)
+	}
+	return &StructuredOutputError{Attempts: o.attempts, Problems: problems, Output: output}
+}
+
+// checkStructured extracts the JSON in a reply and validates it
+func checkStructured(schema map[string]interface{}, output string) (string, []string) {
+	raw, ok := ExtractJSON(output)
+	if !ok {
+		return "", []string{"the response contains no valid JSON value"}
+	}
+	var value interface{}
+	if err := json.Unmarshal([]byte(raw), &value); err != nil {
+		return "", []string{err.Error()}
+	}
+	return raw, ValidateSchema(schema, value)
+}
+
+// maxEchoedOutput caps how much of an invalid reply is sent back
+const maxEchoedOutput = 4000
+
+func repairPrompt(base, output string, problems []string) string {
+	if runes := []rune(output); len(runes) > maxEchoedOutput {
+		output = string(runes[:maxEchoedOutput]) + "\n...[truncated]"
+	}
+	return fmt.Sprintf("%s\n\nYour previous response was:\n%s\n\nIt was rejected because:\n- %s\n\nRespond again with only the corrected JSON.",
+		base, output, strings.Join(problems, "\n- "))
+}
+
+var (
+	thinkBlock = regexp.MustCompile(`(?s)<think>.*?</think>`)
+	codeFence  = regexp.MustCompile("(?s)```(?:json|JSON)?\\s*(.*?)```")
+)
+
+// ExtractJSON finds the JSON value in a model reply. It accepts a bare
+// value, one wrapped in a Markdown code fence, or the first complete object
+// or array embedded in prose, and skips <think> blocks.
+func ExtractJSON(text string) (string, bool) {
+	text = strings.TrimSpace(thinkBlock.ReplaceAllString(text, ""))
+	if json.Valid([]byte(text)) && text != "" {
+		return text, true
+	}
+	for _, m := range codeFence.FindAllStringSubmatch(text, -1) {
+		if body := strings.TrimSpace(m[1]); json.Valid([]byte(body)) {
+			return body, true
+		}
+	}
+	for start := 0; start < len(text); start++ {
+		if text[start] != '{' && text[start] != '[' {
+			continue
+		}
+		if end := matchingBracket(text, start); end > start {
+			if candidate := text[start : end+1]; json.Valid([]byte(candidate)) {
+				return candidate, true
+			}
+		}
+	}
+	return "", false
+}
+
+// matchingBracket returns the index closing the object or array opened at
+// start, skipping brackets inside strings, or -1
+func matchingBracket(text string, start int) int {
+	depth, inString, escaped := 0, false, false
+	for i := start; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case escaped:
+			escaped = false
+		case inString && c == '\\':
+			escaped = true
+		case c == '"':
+			inString = !inString
+		case inString:
+		case c == '{' || c == '[':
+			depth++
+		case c == '}' || c == ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,121 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/benozo/conduit/lib/logging"
+)
+
+// DefaultMaxToolIterations bounds RunToolLoop when no limit is set
+const DefaultMaxToolIterations = 5
+
+// ToolLoopOptions configures RunToolLoop
+type ToolLoopOptions struct {
+	Tools  *ToolRegistry
+	Memory *Memory
+	// MaxIterations caps the rounds of tool calls (default DefaultMaxToolIterations)
+	MaxIterations int
+}
+
+// RunToolLoop calls model and executes the tool calls it requests against the
+// registry, feeding results back as tool messages until the model answers
+// without calling tools. Parallel calls in one reply run concurrently. After
+// MaxIterations rounds the model is called once more without tools so it has
+// to answer. It returns the final response, with usage summed over every
+// call, and the conversation including all tool messages.
+func RunToolLoop(ctx context.Context, model ChatModel, req ChatRequest, opts ToolLoopOptions) (*ChatResponse, []ChatMessage, error) {
+	maxIterations := opts.MaxIterations
+	if maxIterations <= 0 {
+		maxIterations = DefaultMaxToolIterations
+	}
+
+	messages := append([]ChatMessage(nil), req.Messages...)
+	var usage Usage
+	for iteration := 0; ; iteration++ {
+		req.Messages = messages
+		if iteration == maxIterations {
+			slog.WarnContext(ctx, "tool loop reached max iterations", "iterations", maxIterations)
+			req.Tools = nil
+		}
+
+		resp, err := model.Chat(ctx, req)
+		if err != nil {
+			return nil, messages, err
+		}
+		usage.PromptTokens += resp.Usage.PromptTokens
+		usage.CompletionTokens += resp.Usage.CompletionTokens
+		usage.TotalTokens += resp.Usage.TotalTokens
+
+		reply := resp.Message
+		reply.Role = RoleAssistant
+		for i := range reply.ToolCalls {
+			if reply.ToolCalls[i].ID == "" {
+				reply.ToolCalls[i].ID = fmt.Sprintf("call_%d_%d", iteration+1, i+1)
+			}
+		}
+		messages = append(messages, reply)
+		if len(reply.ToolCalls) == 0 || len(req.Tools) == 0 {
+			resp.Usage = usage
+			return resp, messages, nil
+		}
+
+		messages = append(messages, executeToolCalls(ctx, reply.ToolCalls, opts.Tools, opts.Memory)...)
+	}
+}
+
+// executeToolCalls runs calls concurrently and returns their tool messages in call order.
+// Failures and panics are reported to the model as the message content rather than
+// aborting the loop.
+func executeToolCalls(ctx context.Context, calls []ChatToolCall, tools *ToolRegistry, memory *Memory) []ChatMessage {
+	results := make([]ChatMessage, len(calls))
+	var wg sync.WaitGroup
+	for i, call := range calls {
+		wg.Add(1)
+		go func(i int, call ChatToolCall) {
+			defer wg.Done()
+			// A panicking tool fails its own call instead of the whole process
+			defer func() {
+				if r := recover(); r != nil {
+					slog.ErrorContext(ctx, "model tool call panicked", "tool", call.Name, "panic", r)
+					results[i] = ChatMessage{Role: RoleTool, Content: fmt.Sprintf("Error: tool %s panicked: %v", call.Name, r), ToolCallID: call.ID, Name: call.Name}
+				}
+			}()
+			slog.DebugContext(ctx, "executing model tool call", "tool", call.Name, "params", logging.Redact(call.Arguments))
+
+			var content string
+			var images []Image
+			if tools == nil {
+				content = "Error: no tools available"
+			} else if result, err := tools.CallContext(ctx, call.Name, call.Arguments, memory); err != nil {
+				slog.WarnContext(ctx, "model tool call failed", "tool", call.Name, "error", err)
+				content = fmt.Sprintf("Error: %v", err)
+			} else {
+				content, images = toolResultContent(result)
+			}
+			results[i] = ChatMessage{Role: RoleTool, Content: content, Images: images, ToolCallID: call.ID, Name: call.Name}
+		}(i, call)
+	}
+	wg.Wait()
+	return results
+}
+
+// FormatToolResult renders a tool result as message content: strings as-is,
+// image results as their text with a note for the images, anything else as
+// JSON
+func FormatToolResult(result interface{}) string {
+	if s, ok := result.(string); ok {
+		return s
+	}
+	if text, images, ok := SplitToolContent(result); ok {
+		return WithImageNote(text, len(images))
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Sprintf("%v", result)
+	}
+	return string(data)
+}
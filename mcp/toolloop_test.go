@@ -0,0 +1,69 @@
+package mcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestRunToolLoop(t *testing.T) {
+	tools := NewToolRegistry()
+	tools.Register("echo", func(params map[string]interface{}, memory *Memory) (interface{}, error) {
+		return params["text"], nil
+	})
+	tools.Register("explode", func(map[string]interface{}, *Memory) (interface{}, error) {
+		panic("kaboom")
+	})
+
+	var seen []ChatMessage
+	model := ChatModelFunc(func(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+		if len(req.Messages) == 1 {
+			return &ChatResponse{
+				Message: ChatMessage{ToolCalls: []ChatToolCall{
+					{Name: "echo", Arguments: map[string]interface{}{"text": "hi"}},
+					{Name: "explode"},
+				}},
+				Usage: Usage{TotalTokens: 3},
+			}, nil
+		}
+		seen = req.Messages
+		return &ChatResponse{Message: ChatMessage{Content: "done"}, Usage: Usage{TotalTokens: 4}}, nil
+	})
+
+	req := ChatRequest{
+		Messages: []ChatMessage{{Role: RoleUser, Content: "go"}},
+		Tools:    ToolDefinitions(tools, nil),
+	}
+	resp, messages, err := RunToolLoop(context.Background(), model, req, ToolLoopOptions{Tools: tools})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Message.Content != "done" || resp.Usage.TotalTokens != 7 || len(messages) != 5 {
+		t.Fatalf("response %+v after %d messages", resp, len(messages))
+	}
+
+	echo, explode := seen[2], seen[3]
+	if echo.Role != RoleTool || echo.Content != "hi" || echo.ToolCallID != "call_1_1" {
+		t.Errorf("echo result %+v", echo)
+	}
+	if explode.ToolCallID != "call_1_2" || !strings.Contains(explode.Content, "panicked: kaboom") {
+		t.Errorf("panicking tool result %+v", explode)
+	}
+}
+
+func TestToolDefinitions(t *testing.T) {
+	tools := NewToolRegistry()
+	tools.Register("zeta", func(map[string]interface{}, *Memory) (interface{}, error) { return nil, nil })
+	tools.Register("remember", func(map[string]interface{}, *Memory) (interface{}, error) { return nil, nil })
+
+	defs := ToolDefinitions(tools, nil)
+	if len(defs) != 2 || defs[0].Name != "remember" || defs[1].Name != "zeta" {
+		t.Fatalf("definitions %+v", defs)
+	}
+	if defs[0].Description != "Store a value in memory" || defs[0].Parameters["required"] == nil {
+		t.Errorf("built-in fallback not used: %+v", defs[0])
+	}
+	if ToolDefinitions(nil, nil) != nil {
+		t.Error("nil registry should have no definitions")
+	}
+}
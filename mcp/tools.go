@@ -1,29 +1,115 @@
 package mcp
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/benozo/conduit/lib/metrics"
+	"github.com/benozo/conduit/lib/tracing"
+)
 
 type ToolFunc func(params map[string]interface{}, memory *Memory) (interface{}, error)
 
+// ContextToolFunc is a tool that receives the caller's context, so it can
+// stop its work when the call is cancelled or times out
+type ContextToolFunc func(ctx context.Context, params map[string]interface{}, memory *Memory) (interface{}, error)
+
+// ToolMiddleware wraps a tool at call time, e.g. to record or replay calls
+type ToolMiddleware func(name string, next ToolFunc) ToolFunc
+
 type ToolRegistry struct {
-	tools map[string]ToolFunc
+	mu           sync.RWMutex
+	tools        map[string]ToolFunc
+	contextTools map[string]ContextToolFunc
+	middleware   []ToolMiddleware
 }
 
 func NewToolRegistry() *ToolRegistry {
-	return &ToolRegistry{tools: make(map[string]ToolFunc)}
+	return &ToolRegistry{tools: make(map[string]ToolFunc), contextTools: make(map[string]ContextToolFunc)}
 }
 
 func (r *ToolRegistry) Register(name string, fn ToolFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	r.tools[name] = fn
+	delete(r.contextTools, name)
+}
+
+// RegisterContext adds a tool that receives the context passed to
+// CallContext; Call runs it with context.Background
+func (r *ToolRegistry) RegisterContext(name string, fn ContextToolFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tools[name] = func(params map[string]interface{}, memory *Memory) (interface{}, error) {
+		return fn(context.Background(), params, memory)
+	}
+	r.contextTools[name] = fn
+}
+
+// Use adds middleware around every tool call. The first middleware added is outermost.
+func (r *ToolRegistry) Use(mw ToolMiddleware) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.middleware = append(r.middleware, mw)
+}
+
+// Unregister removes a tool; unknown names are ignored
+func (r *ToolRegistry) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.tools, name)
+	delete(r.contextTools, name)
+}
+
+// has reports whether a tool is registered under name
+func (r *ToolRegistry) has(name string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.tools[name]
+	return ok
 }
 
 func (r *ToolRegistry) Call(name string, params map[string]interface{}, memory *Memory) (interface{}, error) {
-	if tool, ok := r.tools[name]; ok {
-		return tool(params, memory)
+	return r.CallContext(context.Background(), name, params, memory)
+}
+
+// CallContext calls a tool as a child of the span carried by ctx. Tools
+// added with RegisterContext receive ctx and can stop when it is cancelled.
+func (r *ToolRegistry) CallContext(ctx context.Context, name string, params map[string]interface{}, memory *Memory) (interface{}, error) {
+	ctx, span := tracing.Start(ctx, "tool.call", tracing.String("tool.name", name))
+	defer span.End()
+
+	r.mu.RLock()
+	tool, ok := r.tools[name]
+	if fn, withContext := r.contextTools[name]; withContext {
+		tool = func(params map[string]interface{}, memory *Memory) (interface{}, error) {
+			return fn(ctx, params, memory)
+		}
+	}
+	for i := len(r.middleware) - 1; ok && i >= 0; i-- {
+		tool = r.middleware[i](name, tool)
 	}
-	return nil, ErrToolNotFound(name)
+	r.mu.RUnlock()
+	if !ok {
+		err := ErrToolNotFound(name)
+		// Unknown names are not used as label values to keep cardinality bounded
+		metrics.ObserveToolCall("unknown", err, 0)
+		span.RecordError(err)
+		return nil, err
+	}
+
+	start := time.Now()
+	result, err := tool(params, memory)
+	metrics.ObserveToolCall(name, err, time.Since(start))
+	span.RecordError(err)
+	return result, err
 }
 
 func (r *ToolRegistry) GetRegisteredTools() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	var names []string
 	for name := range r.tools {
 		names = append(names, name)
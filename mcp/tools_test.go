@@ -0,0 +1,70 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/benozo/conduit/lib/metrics"
+)
+
+func TestToolRegistryMetrics(t *testing.T) {
+	tools := NewToolRegistry()
+	tools.Register("metrics_ok", func(map[string]interface{}, *Memory) (interface{}, error) { return "ok", nil })
+	tools.Register("metrics_fail", func(map[string]interface{}, *Memory) (interface{}, error) { return nil, errors.New("boom") })
+
+	unknown := metrics.ToolCalls.Value("unknown", metrics.OutcomeError)
+	tools.Call("metrics_ok", nil, nil)
+	tools.Call("metrics_ok", nil, nil)
+	tools.Call("metrics_fail", nil, nil)
+	tools.Call("no_such_tool", nil, nil)
+
+	tests := []struct {
+		tool, outcome string
+		want          float64
+	}{
+		{"metrics_ok", metrics.OutcomeSuccess, 2},
+		{"metrics_fail", metrics.OutcomeError, 1},
+		{"no_such_tool", metrics.OutcomeError, 0},
+		{"unknown", metrics.OutcomeError, unknown + 1},
+	}
+	for _, tt := range tests {
+		if got := metrics.ToolCalls.Value(tt.tool, tt.outcome); got != tt.want {
+			t.Errorf("%s/%s: counted %v calls, want %v", tt.tool, tt.outcome, got, tt.want)
+		}
+	}
+	if n := metrics.ToolCallDuration.Count("metrics_ok", metrics.OutcomeSuccess); n != 2 {
+		t.Errorf("observed %d latencies, want 2", n)
+	}
+}
+
+func TestRegisterContext(t *testing.T) {
+	type key struct{}
+	tools := NewToolRegistry()
+	tools.Use(func(name string, next ToolFunc) ToolFunc {
+		return func(params map[string]interface{}, memory *Memory) (interface{}, error) {
+			result, err := next(params, memory)
+			return []interface{}{"wrapped", result}, err
+		}
+	})
+	tools.RegisterContext("ctx", func(ctx context.Context, params map[string]interface{}, memory *Memory) (interface{}, error) {
+		return ctx.Value(key{}), ctx.Err()
+	})
+
+	ctx := context.WithValue(context.Background(), key{}, "value")
+	got, err := tools.CallContext(ctx, "ctx", nil, nil)
+	if err != nil || got.([]interface{})[1] != "value" {
+		t.Fatalf("got %v, %v; want the caller's context through the middleware", got, err)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := tools.CallContext(cancelled, "ctx", nil, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+
+	tools.Register("ctx", func(map[string]interface{}, *Memory) (interface{}, error) { return "plain", nil })
+	if got, _ := tools.CallContext(ctx, "ctx", nil, nil); got.([]interface{})[1] != "plain" {
+		t.Errorf("got %v after re-registering a plain tool", got)
+	}
+}
@@ -1,8 +1,26 @@
 package mcp
 
+import "context"
+
 type ContextInput struct {
 	ContextID string                 `json:"context_id"`
 	Inputs    map[string]interface{} `json:"inputs"`
+
+	ctx context.Context
+}
+
+// Context returns the request-scoped context, defaulting to context.Background
+func (c ContextInput) Context() context.Context {
+	if c.ctx != nil {
+		return c.ctx
+	}
+	return context.Background()
+}
+
+// WithContext returns a copy of the input carrying ctx, for cancellation and tracing
+func (c ContextInput) WithContext(ctx context.Context) ContextInput {
+	c.ctx = ctx
+	return c
 }
 
 type ToolCall struct {
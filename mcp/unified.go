@@ -1,12 +1,19 @@
 package mcp
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"strings"
+
+	"github.com/benozo/conduit/lib/logging"
+	"github.com/benozo/conduit/lib/metrics"
+	"github.com/benozo/conduit/lib/tracing"
+	"github.com/benozo/conduit/lib/usage"
 )
 
 // ServerMode defines the server operating mode
@@ -21,6 +28,49 @@ const (
 	ModeBoth
 )
 
+// String returns the mode name used in config files and flags
+func (m ServerMode) String() string {
+	switch m {
+	case ModeStdio:
+		return "stdio"
+	case ModeHTTP:
+		return "http"
+	case ModeBoth:
+		return "both"
+	default:
+		return fmt.Sprintf("ServerMode(%d)", int(m))
+	}
+}
+
+// ParseServerMode converts a mode name ("stdio", "http", "both") to a ServerMode
+func ParseServerMode(name string) (ServerMode, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "stdio", "0":
+		return ModeStdio, nil
+	case "http", "1":
+		return ModeHTTP, nil
+	case "both", "2":
+		return ModeBoth, nil
+	default:
+		return ModeBoth, fmt.Errorf("unknown server mode: %q (want stdio, http or both)", name)
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (m ServerMode) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (m *ServerMode) UnmarshalText(text []byte) error {
+	mode, err := ParseServerMode(string(text))
+	if err != nil {
+		return err
+	}
+	*m = mode
+	return nil
+}
+
 // UnifiedServer supports both stdio and HTTP protocols
 type UnifiedServer struct {
 	tools       *ToolRegistry
@@ -30,6 +80,9 @@ type UnifiedServer struct {
 	httpServer  *http.Server
 	mode        ServerMode
 	port        string
+	certFile    string
+	keyFile     string
+	cors        bool
 }
 
 // NewUnifiedServer creates a new unified MCP server
@@ -45,6 +98,7 @@ func NewUnifiedServer(model ModelFunc, tools *ToolRegistry) *UnifiedServer {
 		stdioServer: stdioServer,
 		mode:        ModeBoth,
 		port:        ":8080",
+		cors:        true,
 	}
 }
 
@@ -61,6 +115,26 @@ func NewUnifiedServerWithSchemaProvider(model ModelFunc, tools *ToolRegistry, sc
 		stdioServer: stdioServer,
 		mode:        ModeBoth,
 		port:        ":8080",
+		cors:        true,
+	}
+}
+
+// SetTLS serves HTTP over TLS using the given certificate and key files
+func (s *UnifiedServer) SetTLS(certFile, keyFile string) {
+	s.certFile = certFile
+	s.keyFile = keyFile
+}
+
+// SetCORS controls whether HTTP endpoints accept cross-origin browser
+// requests. CORS is enabled by default.
+func (s *UnifiedServer) SetCORS(enabled bool) {
+	s.cors = enabled
+}
+
+// allowCORS marks the response as readable from any origin when CORS is enabled
+func (s *UnifiedServer) allowCORS(w http.ResponseWriter) {
+	if s.cors {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
 	}
 }
 
@@ -90,14 +164,18 @@ func (s *UnifiedServer) Run() error {
 
 // runStdio runs only the stdio server
 func (s *UnifiedServer) runStdio() error {
-	log.Println("Starting MCP server in stdio mode...")
+	slog.Info("starting MCP server", "mode", "stdio")
 	return s.stdioServer.Run()
 }
 
 // runHTTP runs only the HTTP server
 func (s *UnifiedServer) runHTTP() error {
-	log.Printf("Starting MCP server in HTTP mode on %s...", s.port)
 	s.setupHTTPRoutes()
+	if s.certFile != "" && s.keyFile != "" {
+		slog.Info("starting MCP server", "mode", "https", "addr", s.port)
+		return s.httpServer.ListenAndServeTLS(s.certFile, s.keyFile)
+	}
+	slog.Info("starting MCP server", "mode", "http", "addr", s.port)
 	return s.httpServer.ListenAndServe()
 }
 
@@ -107,12 +185,12 @@ func (s *UnifiedServer) runBoth() error {
 	stat, _ := os.Stdin.Stat()
 	if (stat.Mode() & os.ModeCharDevice) == 0 {
 		// No TTY, run stdio mode
-		log.Println("Detected stdio mode (no TTY)")
+		slog.Info("no TTY detected, using stdio mode")
 		return s.runStdio()
 	}
 
 	// TTY detected, run HTTP mode
-	log.Println("Detected TTY, running HTTP mode")
+	slog.Info("TTY detected, using HTTP mode")
 	return s.runHTTP()
 }
 
@@ -123,6 +201,9 @@ func (s *UnifiedServer) setupHTTPRoutes() {
 	// MCP endpoint (SSE)
 	mux.HandleFunc("/mcp", s.handleMCPHTTP)
 
+	// JSON-RPC endpoint speaking the same protocol as stdio
+	mux.HandleFunc("/rpc", s.handleJSONRPCHTTP)
+
 	// Direct tool call endpoint (simpler for testing)
 	mux.HandleFunc("/tool", s.handleToolCallHTTP)
 
@@ -138,18 +219,31 @@ func (s *UnifiedServer) setupHTTPRoutes() {
 	// Health check
 	mux.HandleFunc("/health", s.handleHealthHTTP)
 
+	// Prometheus metrics
+	mux.Handle("/metrics", metrics.Handler())
+
+	// Token usage and cost totals
+	mux.Handle("/usage", usage.Default.Handler())
+
 	s.httpServer = &http.Server{
 		Addr:    s.port,
-		Handler: mux,
+		Handler: logging.Middleware(tracing.Middleware(usage.Middleware(mux))),
 	}
 }
 
+// Handler returns the HTTP routes without starting a listener, for mounting
+// in another server or serving from httptest
+func (s *UnifiedServer) Handler() http.Handler {
+	if s.httpServer == nil {
+		s.setupHTTPRoutes()
+	}
+	return s.httpServer.Handler
+}
+
 // handleToolCallHTTP handles direct tool calls (simpler than full MCP)
 func (s *UnifiedServer) handleToolCallHTTP(w http.ResponseWriter, r *http.Request) {
-	log.Printf("Tool HTTP request received: %s %s", r.Method, r.URL.Path)
-
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
+	s.allowCORS(w)
 	w.Header().Set("Connection", "close")
 
 	var req struct {
@@ -157,79 +251,104 @@ func (s *UnifiedServer) handleToolCallHTTP(w http.ResponseWriter, r *http.Reques
 		Params map[string]interface{} `json:"params"`
 	}
 
-	log.Printf("Reading request body...")
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		log.Printf("Error decoding tool request: %v", err)
+		slog.WarnContext(r.Context(), "invalid tool request", "error", err)
 		http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	log.Printf("Decoded tool request: name=%s, params=%+v", req.Name, req.Params)
-
-	log.Printf("Calling tool %s...", req.Name)
-	result, err := s.tools.Call(req.Name, req.Params, s.memory)
+	slog.DebugContext(r.Context(), "calling tool", "tool", req.Name, "params", logging.Redact(req.Params))
+	result, err := s.tools.CallContext(r.Context(), req.Name, req.Params, s.memory)
 	if err != nil {
-		log.Printf("Tool error: %v", err)
+		slog.WarnContext(r.Context(), "tool call failed", "tool", req.Name, "error", err)
 		http.Error(w, "tool error: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	log.Printf("Tool result: %+v", result)
-
 	response := map[string]interface{}{
 		"tool":   req.Name,
 		"result": result,
 	}
 
-	log.Printf("Sending response...")
 	if err := json.NewEncoder(w).Encode(response); err != nil {
-		log.Printf("Error encoding response: %v", err)
+		slog.ErrorContext(r.Context(), "failed to encode tool response", "tool", req.Name, "error", err)
 		http.Error(w, "encoding error", http.StatusInternalServerError)
 		return
 	}
-	log.Printf("Response sent successfully")
+}
+
+// handleJSONRPCHTTP handles MCP JSON-RPC requests over HTTP POST
+func (s *UnifiedServer) handleJSONRPCHTTP(w http.ResponseWriter, r *http.Request) {
+	s.allowCORS(w)
+	if r.Method == http.MethodOptions && s.cors {
+		// Browser preflight for the JSON POST below
+		w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Mcp-Session-Id")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req JSONRPCRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.WarnContext(r.Context(), "invalid JSON-RPC request", "error", err)
+		w.Header().Set("Content-Type", "application/json")
+		data, _ := json.Marshal(JSONRPCResponse{Jsonrpc: "2.0", Error: &JSONRPCError{Code: -32700, Message: "Parse error"}})
+		w.Write(data)
+		return
+	}
+
+	var buf bytes.Buffer
+	s.stdioServer.HandleJSONRPC(r.Context(), req, &buf)
+	if buf.Len() == 0 {
+		// Notifications have no response
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(buf.Bytes())
 }
 
 // handleMCPHTTP handles the SSE MCP endpoint
 func (s *UnifiedServer) handleMCPHTTP(w http.ResponseWriter, r *http.Request) {
-	log.Printf("MCP HTTP request received: %s %s", r.Method, r.URL.Path)
-
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
+	s.allowCORS(w)
 
 	var req MCPRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		log.Printf("Error decoding MCP request: %v", err)
+		slog.WarnContext(r.Context(), "invalid MCP request", "error", err)
 		http.Error(w, "invalid request", http.StatusBadRequest)
 		return
 	}
 
-	log.Printf("Decoded MCP request: %+v", req)
+	ctx := logging.WithSessionID(r.Context(), req.SessionID)
+	slog.DebugContext(ctx, "processing MCP request", "contexts", len(req.Contexts), "model", req.Model)
 
 	flusher, ok := w.(http.Flusher)
 	if !ok {
-		log.Printf("Streaming not supported")
+		slog.ErrorContext(ctx, "streaming not supported by response writer")
 		http.Error(w, "streaming not supported", http.StatusInternalServerError)
 		return
 	}
 
 	s.processor.EnableStreaming(func(ctxID, token string) {
-		log.Printf("Streaming token for context %s: %s", ctxID, token)
 		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ctxID, token)
 		flusher.Flush()
 	})
 
-	log.Printf("Running processor...")
-	result, err := s.processor.Run(req)
+	result, err := s.processor.RunContext(ctx, req)
 	if err != nil {
-		log.Printf("Processor error: %v", err)
+		slog.WarnContext(ctx, "MCP request failed", "error", err)
 		fmt.Fprintf(w, "event: error\ndata: %s\n\n", err.Error())
 		return
 	}
 
-	log.Printf("Processor result: %+v", result)
 	out, _ := json.Marshal(result)
 	fmt.Fprintf(w, "event: done\ndata: %s\n\n", out)
 	flusher.Flush()
@@ -238,7 +357,7 @@ func (s *UnifiedServer) handleMCPHTTP(w http.ResponseWriter, r *http.Request) {
 // handleReActHTTP handles the ReAct demonstration endpoint
 func (s *UnifiedServer) handleReActHTTP(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
+	s.allowCORS(w)
 
 	// Set initial memory
 	s.processor.Memory.Set("latest", "hello world")
@@ -260,7 +379,7 @@ func (s *UnifiedServer) handleReActHTTP(w http.ResponseWriter, r *http.Request)
 // handleSchemaHTTP handles the schema endpoint
 func (s *UnifiedServer) handleSchemaHTTP(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
+	s.allowCORS(w)
 
 	tools := s.stdioServer.getToolSchemas()
 	response := map[string]interface{}{"tools": tools}
@@ -270,7 +389,7 @@ func (s *UnifiedServer) handleSchemaHTTP(w http.ResponseWriter, r *http.Request)
 // handleHealthHTTP handles health checks
 func (s *UnifiedServer) handleHealthHTTP(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
+	s.allowCORS(w)
 
 	response := map[string]interface{}{
 		"status":    "healthy",
@@ -282,10 +401,8 @@ func (s *UnifiedServer) handleHealthHTTP(w http.ResponseWriter, r *http.Request)
 
 // handleChatHTTP handles natural language chat with tool selection
 func (s *UnifiedServer) handleChatHTTP(w http.ResponseWriter, r *http.Request) {
-	log.Printf("Chat HTTP request received: %s %s", r.Method, r.URL.Path)
-
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
+	s.allowCORS(w)
 	w.Header().Set("Connection", "close")
 
 	var req struct {
@@ -295,14 +412,13 @@ func (s *UnifiedServer) handleChatHTTP(w http.ResponseWriter, r *http.Request) {
 		Stream      bool    `json:"stream,omitempty"`
 	}
 
-	log.Printf("Reading chat request body...")
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		log.Printf("Error decoding chat request: %v", err)
+		slog.WarnContext(r.Context(), "invalid chat request", "error", err)
 		http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	log.Printf("Decoded chat request: message=%s, model=%s, stream=%v", req.Message, req.Model, req.Stream)
+	slog.DebugContext(r.Context(), "processing chat request", "model", req.Model, "message_length", len(req.Message))
 
 	// Force non-streaming for REST API
 	req.Stream = false
@@ -323,31 +439,25 @@ func (s *UnifiedServer) handleChatHTTP(w http.ResponseWriter, r *http.Request) {
 		Stream:      false, // Force non-streaming
 	}
 
-	log.Printf("Running processor with request: %+v", mcpReq)
-
 	// Always use regular JSON response for REST API
-	result, err := s.processor.Run(mcpReq)
+	result, err := s.processor.RunContext(r.Context(), mcpReq)
 	if err != nil {
-		log.Printf("Processor error: %v", err)
+		slog.WarnContext(r.Context(), "chat request failed", "error", err)
 		http.Error(w, "processing error: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	log.Printf("Processor result: %+v", result)
-
 	response := map[string]interface{}{
 		"message": req.Message,
 		"result":  result,
 		"status":  "success",
 	}
 
-	log.Printf("Sending chat response...")
 	if err := json.NewEncoder(w).Encode(response); err != nil {
-		log.Printf("Error encoding chat response: %v", err)
+		slog.ErrorContext(r.Context(), "failed to encode chat response", "error", err)
 		http.Error(w, "encoding error", http.StatusInternalServerError)
 		return
 	}
-	log.Printf("Chat response sent successfully")
 }
 
 // Shutdown gracefully shuts down the server
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/benozo/conduit/lib/rag"
+	"github.com/benozo/conduit/lib/rag/database"
+	"github.com/benozo/conduit/lib/rag/embeddings"
+	"github.com/benozo/conduit/lib/rag/processors"
+	ragtools "github.com/benozo/conduit/lib/rag/tools"
+)
+
+// setupRAG connects the RAG engine described by config and installs it for the rag tools
+func setupRAG(ctx context.Context, config *rag.RAGConfig) error {
+	vectorDB, err := database.NewPgVectorDB(config.Database)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	var embeddingProvider rag.EmbeddingProvider
+	switch config.Embeddings.Provider {
+	case "openai":
+		embeddingProvider = embeddings.NewOpenAIEmbeddings(
+			config.Embeddings.APIKey,
+			config.Embeddings.Model,
+			config.Embeddings.Dimensions,
+			config.Embeddings.Timeout,
+		)
+	case "ollama":
+		embeddingProvider = embeddings.NewOllamaEmbeddings(
+			config.Embeddings.Host,
+			config.Embeddings.Model,
+			config.Embeddings.Dimensions,
+			config.Embeddings.Timeout,
+		)
+	default:
+		return fmt.Errorf("unsupported embedding provider: %s", config.Embeddings.Provider)
+	}
+
+	pingCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if err := embeddingProvider.Ping(pingCtx); err != nil {
+		return fmt.Errorf("failed to reach %s embeddings: %w", config.Embeddings.Provider, err)
+	}
+
+	chunker := processors.NewTextChunker(
+		processors.ChunkingStrategy(config.Chunking.Strategy),
+		config.Chunking.Size,
+		config.Chunking.Overlap,
+	)
+
+	ragtools.SetRAGEngine(rag.NewRAGEngine(config, vectorDB, embeddingProvider, chunker))
+	return nil
+}
@@ -2,12 +2,19 @@ package swarm
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"sort"
 	"strings"
 	"time"
 
 	conduit "github.com/benozo/conduit/lib"
+	"github.com/benozo/conduit/lib/logging"
+	"github.com/benozo/conduit/lib/metrics"
+	"github.com/benozo/conduit/lib/tokens"
+	"github.com/benozo/conduit/lib/tracing"
+	"github.com/benozo/conduit/lib/usage"
 	"github.com/benozo/conduit/mcp"
 )
 
@@ -24,20 +31,20 @@ type swarmClient struct {
 	modelName    string        // Model name for LLM requests
 }
 
-// defaultLogger provides basic logging functionality
+// defaultLogger writes structured records through slog
 type defaultLogger struct{}
 
 func (l *defaultLogger) Info(msg string, fields ...interface{}) {
-	log.Printf("[INFO] %s %v", msg, fields)
+	slog.Info(msg, fields...)
 }
 func (l *defaultLogger) Error(msg string, fields ...interface{}) {
-	log.Printf("[ERROR] %s %v", msg, fields)
+	slog.Error(msg, fields...)
 }
 func (l *defaultLogger) Debug(msg string, fields ...interface{}) {
-	log.Printf("[DEBUG] %s %v", msg, fields)
+	slog.Debug(msg, fields...)
 }
 func (l *defaultLogger) Warn(msg string, fields ...interface{}) {
-	log.Printf("[WARN] %s %v", msg, fields)
+	slog.Warn(msg, fields...)
 }
 
 // NewSwarmClient creates a new swarm client with MCP integration
@@ -101,25 +108,27 @@ func (sc *swarmClient) CreateAgent(name, instructions string, tools []string) *A
 		}
 
 		// Create agent function from MCP tool
-		agentFunc := AgentFunction{
-			Name:        toolName,
-			Description: fmt.Sprintf("MCP tool: %s", toolName),
-			Parameters:  map[string]interface{}{},
-			Function: func(args map[string]interface{}, contextVars map[string]interface{}) Result {
-				result, err := sc.toolRegistry.Call(toolName, args, sc.memory)
-				if err != nil {
-					return Result{
-						Value:   fmt.Sprintf("Error calling tool %s: %v", toolName, err),
-						Success: false,
-						Error:   err,
-					}
-				}
-
+		callTool := func(ctx context.Context, args map[string]interface{}, contextVars map[string]interface{}) Result {
+			result, err := sc.toolRegistry.CallContext(ctx, toolName, args, sc.memory)
+			if err != nil {
 				return Result{
-					Value:   fmt.Sprintf("%v", result),
-					Success: true,
+					Value:   fmt.Sprintf("Error calling tool %s: %v", toolName, err),
+					Success: false,
+					Error:   err,
 				}
-			},
+			}
+
+			return Result{
+				Value:   fmt.Sprintf("%v", result),
+				Success: true,
+			}
+		}
+		agentFunc := AgentFunction{
+			Name:            toolName,
+			Description:     fmt.Sprintf("MCP tool: %s", toolName),
+			Parameters:      map[string]interface{}{},
+			Function:        withBackground(callTool),
+			ContextFunction: callTool,
 		}
 
 		agent.Functions = append(agent.Functions, agentFunc)
@@ -146,7 +155,7 @@ func (sc *swarmClient) CreateAgentWithModel(name, instructions string, tools []s
 	// Create model function from configuration
 	modelFunc, err := conduit.CreateModelFunction(modelConfig)
 	if err != nil {
-		sc.logger.Error("Failed to create model function", "error", err, "config", modelConfig)
+		sc.logger.Error("Failed to create model function", "error", err, "provider", modelConfig.Provider, "model", modelConfig.Model)
 		// Fallback to swarm default model
 		return sc.CreateAgent(name, instructions, tools)
 	}
@@ -168,6 +177,25 @@ func (sc *swarmClient) CreateAgentWithModel(name, instructions string, tools []s
 		}
 
 		// Create MCP tool function
+		callTool := func(ctx context.Context, args map[string]interface{}, contextVars map[string]interface{}) Result {
+			params, ok := args["params"].(map[string]interface{})
+			if !ok {
+				params = args
+			}
+
+			result, err := sc.toolRegistry.CallContext(ctx, toolName, params, sc.memory)
+			if err != nil {
+				return Result{
+					Error:   err,
+					Success: false,
+				}
+			}
+
+			return Result{
+				Value:   fmt.Sprintf("Tool %s executed successfully: %v", toolName, result),
+				Success: true,
+			}
+		}
 		mcpToolFunc := AgentFunction{
 			Name:        toolName,
 			Description: fmt.Sprintf("MCP tool: %s", toolName),
@@ -179,25 +207,8 @@ func (sc *swarmClient) CreateAgentWithModel(name, instructions string, tools []s
 					},
 				},
 			},
-			Function: func(args map[string]interface{}, contextVars map[string]interface{}) Result {
-				params, ok := args["params"].(map[string]interface{})
-				if !ok {
-					params = args
-				}
-
-				result, err := sc.toolRegistry.Call(toolName, params, sc.memory)
-				if err != nil {
-					return Result{
-						Error:   err,
-						Success: false,
-					}
-				}
-
-				return Result{
-					Value:   fmt.Sprintf("Tool %s executed successfully: %v", toolName, result),
-					Success: true,
-				}
-			},
+			Function:        withBackground(callTool),
+			ContextFunction: callTool,
 		}
 
 		agent.Functions = append(agent.Functions, mcpToolFunc)
@@ -226,6 +237,25 @@ func (sc *swarmClient) CreateAgentWithLLM(name, instructions string, tools []str
 		}
 
 		// Create MCP tool function
+		callTool := func(ctx context.Context, args map[string]interface{}, contextVars map[string]interface{}) Result {
+			params, ok := args["params"].(map[string]interface{})
+			if !ok {
+				params = args
+			}
+
+			result, err := sc.toolRegistry.CallContext(ctx, toolName, params, sc.memory)
+			if err != nil {
+				return Result{
+					Error:   err,
+					Success: false,
+				}
+			}
+
+			return Result{
+				Value:   fmt.Sprintf("Tool %s executed successfully: %v", toolName, result),
+				Success: true,
+			}
+		}
 		mcpToolFunc := AgentFunction{
 			Name:        toolName,
 			Description: fmt.Sprintf("MCP tool: %s", toolName),
@@ -237,25 +267,8 @@ func (sc *swarmClient) CreateAgentWithLLM(name, instructions string, tools []str
 					},
 				},
 			},
-			Function: func(args map[string]interface{}, contextVars map[string]interface{}) Result {
-				params, ok := args["params"].(map[string]interface{})
-				if !ok {
-					params = args
-				}
-
-				result, err := sc.toolRegistry.Call(toolName, params, sc.memory)
-				if err != nil {
-					return Result{
-						Error:   err,
-						Success: false,
-					}
-				}
-
-				return Result{
-					Value:   fmt.Sprintf("Tool %s executed successfully: %v", toolName, result),
-					Success: true,
-				}
-			},
+			Function:        withBackground(callTool),
+			ContextFunction: callTool,
 		}
 
 		agent.Functions = append(agent.Functions, mcpToolFunc)
@@ -287,16 +300,36 @@ func (sc *swarmClient) Run(agent *Agent, messages []Message, contextVars map[str
 	return sc.RunWithContext(ctx, agent, messages, contextVars)
 }
 
-// RunWithContext executes the swarm with context
+// RunWithContext executes the swarm with context. Model calls are accounted
+// to the run, which stops with a usage.BudgetError once SwarmConfig.Budget
+// is spent.
 func (sc *swarmClient) RunWithContext(ctx context.Context, agent *Agent, messages []Message, contextVars map[string]interface{}) *Response {
+	runID := fmt.Sprintf("run_%d", time.Now().UnixNano())
+	ctx = usage.WithScope(ctx, usage.Scope{Run: runID})
+	if sc.config.Budget != (usage.Budget{}) {
+		usage.Default.SetBudget(usage.DimensionRun, runID, sc.config.Budget)
+	}
+
+	response := sc.run(ctx, runID, agent, messages, contextVars)
+	// The run's totals stay in /usage until the tracker evicts them
+	response.Usage = usage.Default.Totals(usage.DimensionRun, runID)
+	return response
+}
+
+// run executes the turns of a swarm run
+func (sc *swarmClient) run(ctx context.Context, runID string, agent *Agent, messages []Message, contextVars map[string]interface{}) *Response {
 	startTime := time.Now()
 
 	if contextVars == nil {
 		contextVars = make(map[string]interface{})
 	}
 
+	ctx, runSpan := tracing.Start(ctx, "swarm.run", tracing.String("agent.name", agent.Name))
+	defer runSpan.End()
+
 	execCtx := &ExecutionContext{
-		SessionID:     fmt.Sprintf("session_%d", time.Now().UnixNano()),
+		Context:       ctx,
+		SessionID:     runID,
 		CurrentAgent:  agent,
 		MessageCount:  len(messages),
 		ToolCallCount: 0,
@@ -348,9 +381,21 @@ func (sc *swarmClient) RunWithContext(ctx context.Context, agent *Agent, message
 		}
 
 		// Process the current conversation turn
+		turnCtx, turnSpan := tracing.Start(ctx, "swarm.turn",
+			tracing.String("agent.name", currentAgent.Name),
+			tracing.Int("turn", turnCount),
+		)
+		execCtx.Context = turnCtx
 		turnResult := sc.processTurn(execCtx, currentAgent, response.Messages, contextVars)
+		execCtx.Context = ctx
+		if turnResult.Agent != nil && turnResult.Agent != currentAgent {
+			turnSpan.SetAttribute("handoff.to", turnResult.Agent.Name)
+		}
+		turnSpan.RecordError(turnResult.Error)
+		turnSpan.End()
 
 		if turnResult.Error != nil {
+			runSpan.RecordError(turnResult.Error)
 			response.Error = turnResult.Error
 			response.ExecutionTime = time.Since(startTime)
 			return response
@@ -363,6 +408,7 @@ func (sc *swarmClient) RunWithContext(ctx context.Context, agent *Agent, message
 
 		// Handle agent handoff
 		if turnResult.Agent != nil && turnResult.Agent != currentAgent {
+			metrics.ObserveHandoff(currentAgent.Name, turnResult.Agent.Name)
 			execCtx.HandoffCount++
 			response.HandoffsCount++
 			currentAgent = turnResult.Agent
@@ -389,6 +435,11 @@ func (sc *swarmClient) RunWithContext(ctx context.Context, agent *Agent, message
 		}
 	}
 
+	runSpan.SetAttributes(
+		tracing.Int("swarm.turns", turnCount),
+		tracing.Int("swarm.handoffs", response.HandoffsCount),
+	)
+
 	response.TotalTurns = turnCount
 	response.ContextVars = contextVars
 	response.ExecutionTime = time.Since(startTime)
@@ -400,10 +451,10 @@ func (sc *swarmClient) RunWithContext(ctx context.Context, agent *Agent, message
 // processTurn handles a single conversation turn with LLM reasoning
 func (sc *swarmClient) processTurn(ctx *ExecutionContext, agent *Agent, messages []Message, contextVars map[string]interface{}) Result {
 	// Get the last user message
-	lastMessage := ""
+	var lastMessage Message
 	for i := len(messages) - 1; i >= 0; i-- {
 		if messages[i].Role == "user" {
-			lastMessage = messages[i].Content
+			lastMessage = messages[i]
 			break
 		}
 	}
@@ -419,16 +470,15 @@ func (sc *swarmClient) processTurn(ctx *ExecutionContext, agent *Agent, messages
 	return sc.processWithLLM(ctx, agent, lastMessage, messages, contextVars)
 }
 
-// processWithLLM uses LLM for intelligent agent reasoning and decision-making
-func (sc *swarmClient) processWithLLM(ctx *ExecutionContext, agent *Agent, message string, messages []Message, contextVars map[string]interface{}) Result {
+// processWithLLM uses LLM for intelligent agent reasoning and decision-making.
+// Images of the user message are sent to the model with the prompt.
+func (sc *swarmClient) processWithLLM(ctx *ExecutionContext, agent *Agent, message Message, messages []Message, contextVars map[string]interface{}) Result {
 	// Create comprehensive system prompt for the agent
 	systemPrompt := sc.buildAgentSystemPrompt(agent, contextVars)
 
-	// Create conversation history
-	conversationHistory := sc.buildConversationHistory(messages)
-
 	// Build LLM prompt for agent reasoning
-	prompt := fmt.Sprintf(`%s
+	buildPrompt := func(conversationHistory string) string {
+		return fmt.Sprintf(`%s
 
 CONVERSATION HISTORY:
 %s
@@ -443,18 +493,22 @@ Please analyze this message and decide what action to take. You can:
 Your available tools: %s
 Available agents for handoff: %s
 
-Respond with a JSON object containing your decision:
-{
-  "action": "tool_use|handoff|respond",
-  "reasoning": "explain your decision",
-  "tool_name": "tool to use (if action=tool_use)",
-  "tool_args": {"param": "value"},
-  "handoff_agent": "agent name (if action=handoff)",
-  "response": "direct response (if action=respond)"
-}`, systemPrompt, conversationHistory, message, sc.getAvailableToolsForAgent(agent), sc.getAvailableAgentsForHandoff(agent))
+Reply with your decision: set "action" to tool_use, handoff or respond, and fill in tool_name and tool_args, handoff_agent, or response to match.`, systemPrompt, conversationHistory, mcp.WithImageNote(message.Content, len(message.Images)), sc.getAvailableToolsForAgent(agent), sc.getAvailableAgentsForHandoff(agent))
+	}
+
+	// Fit the conversation history into what the rest of the prompt leaves
+	// of the model's context window
+	conversationHistory, err := sc.buildConversationHistory(ctx.Context, agent, messages, buildPrompt(""))
+	if err != nil {
+		return Result{
+			Error:   fmt.Errorf("building conversation history: %w", err),
+			Success: false,
+		}
+	}
+	prompt := buildPrompt(conversationHistory)
 
 	// Call LLM for reasoning - use agent-specific model if available
-	llmResponse, err := sc.callAgentLLM(agent, prompt, ctx.SessionID)
+	decision, err := sc.decideWithLLM(ctx.Context, agent, prompt, message.Images, ctx.SessionID)
 	if err != nil {
 		return Result{
 			Error:   fmt.Errorf("LLM reasoning failed: %w", err),
@@ -462,8 +516,7 @@ Respond with a JSON object containing your decision:
 		}
 	}
 
-	// Parse LLM response and execute decision
-	return sc.executeLLMDecision(ctx, agent, llmResponse, contextVars)
+	return sc.executeLLMDecision(ctx, agent, decision, contextVars)
 }
 
 // callLLM makes a call to the configured LLM model
@@ -493,8 +546,11 @@ func (sc *swarmClient) callLLM(prompt string, sessionID string) (string, error)
 	return response, nil
 }
 
-// callAgentLLM makes a call to the agent-specific LLM model or fallback to swarm model
-func (sc *swarmClient) callAgentLLM(agent *Agent, prompt string, sessionID string) (string, error) {
+// decideWithLLM asks the agent-specific model, or the swarm model, for a
+// decision matching decisionSchema, sending images with the prompt. Replies
+// that still don't match after the configured attempts are treated as a
+// direct response.
+func (sc *swarmClient) decideWithLLM(parent context.Context, agent *Agent, prompt string, images []mcp.Image, sessionID string) (*LLMDecision, error) {
 	// Try agent-specific model first
 	modelFunc := agent.ModelFunc
 	modelName := agent.Model
@@ -507,15 +563,19 @@ func (sc *swarmClient) callAgentLLM(agent *Agent, prompt string, sessionID strin
 
 	// If still no model, return error
 	if modelFunc == nil {
-		return "", fmt.Errorf("no LLM configured for agent %s or swarm", agent.Name)
+		return nil, fmt.Errorf("no LLM configured for agent %s or swarm", agent.Name)
 	}
 
-	// Create context for LLM call
+	// Create context for LLM call. The agent name lets a model router pick a
+	// backend per agent.
 	ctx := mcp.ContextInput{
 		ContextID: sessionID,
 		Inputs: map[string]interface{}{
-			"query": prompt,
+			conduit.InputAgent: agent.Name,
 		},
+	}.WithContext(usage.WithScope(parent, usage.Scope{Agent: agent.Name}))
+	if len(images) > 0 {
+		ctx.Inputs[mcp.InputImages] = images
 	}
 
 	// Create request
@@ -525,13 +585,19 @@ func (sc *swarmClient) callAgentLLM(agent *Agent, prompt string, sessionID strin
 		Stream:    false,
 	}
 
-	// Call the model function
-	response, err := modelFunc(ctx, req, sc.memory, nil)
+	var decision LLMDecision
+	err := mcp.GenerateStructured(ctx, modelFunc, sc.decisionSchema(agent), prompt, &decision,
+		mcp.WithRequest(req), mcp.WithMemory(sc.memory), mcp.MaxAttempts(sc.config.DecisionAttempts))
+	var invalid *mcp.StructuredOutputError
+	if errors.As(err, &invalid) {
+		slog.WarnContext(parent, "agent decision did not match the schema, treating it as a response",
+			"agent", agent.Name, "attempts", invalid.Attempts, "problems", invalid.Problems)
+		return &LLMDecision{Action: "respond", Response: invalid.Output}, nil
+	}
 	if err != nil {
-		return "", fmt.Errorf("agent LLM call failed: %w", err)
+		return nil, fmt.Errorf("agent LLM call failed: %w", err)
 	}
-
-	return response, nil
+	return &decision, nil
 }
 
 // buildAgentSystemPrompt creates a comprehensive system prompt for the agent
@@ -548,26 +614,68 @@ func (sc *swarmClient) buildAgentSystemPrompt(agent *Agent, contextVars map[stri
 	return prompt
 }
 
-// buildConversationHistory formats conversation history for LLM context
-func (sc *swarmClient) buildConversationHistory(messages []Message) string {
+// buildConversationHistory formats conversation history for LLM context,
+// cut to fit the agent model's context window alongside the rest of prompt
+func (sc *swarmClient) buildConversationHistory(ctx context.Context, agent *Agent, messages []Message, prompt string) (string, error) {
 	if len(messages) == 0 {
-		return "No previous conversation."
+		return "No previous conversation.", nil
+	}
+
+	history := make([]mcp.ChatMessage, len(messages))
+	for i, msg := range messages {
+		history[i] = mcp.ChatMessage{Role: msg.Role, Content: msg.Content, Name: msg.Name, Images: msg.Images}
+	}
+
+	counter, budget, strategy, err := sc.contextWindow(agent)
+	if err != nil {
+		return "", err
+	}
+	history, err = tokens.Fit(ctx, history, budget-counter.Count(prompt), counter, strategy)
+	if err != nil {
+		return "", err
 	}
 
-	history := ""
-	// Limit to last 5 messages to prevent prompt overflow
-	start := len(messages) - 5
-	if start < 0 {
-		start = 0
+	var b strings.Builder
+	for _, msg := range history {
+		fmt.Fprintf(&b, "%s: %s\n", strings.ToUpper(msg.Role), mcp.WithImageNote(msg.Content, len(msg.Images)))
 	}
+	return strings.TrimSpace(b.String()), nil
+}
 
-	for _, msg := range messages[start:] {
-		history += fmt.Sprintf("%s: %s\n", strings.ToUpper(msg.Role), msg.Content)
+// contextWindow returns the token counter, prompt budget and history
+// strategy for the model that will answer for agent
+func (sc *swarmClient) contextWindow(agent *Agent) (tokens.Counter, int, tokens.Strategy, error) {
+	if agent.ModelFunc != nil && agent.ModelConfig != nil {
+		cfg := agent.ModelConfig
+		strategy := defaultHistoryStrategy
+		if cfg.ContextStrategy != "" {
+			var err error
+			if strategy, err = cfg.ContextTruncation(agent.ModelFunc); err != nil {
+				return nil, 0, nil, fmt.Errorf("agent %s: %w", agent.Name, err)
+			}
+		}
+		return tokens.ForModel(cfg.Model), cfg.ContextBudget(), strategy, nil
 	}
 
-	return strings.TrimSpace(history)
+	modelName := sc.modelName
+	if agent.ModelFunc != nil {
+		modelName = agent.Model
+	}
+	window := sc.config.ContextWindow
+	if window <= 0 {
+		window = tokens.ContextWindow(modelName)
+	}
+	strategy := sc.config.HistoryStrategy
+	if strategy == nil {
+		strategy = defaultHistoryStrategy
+	}
+	return tokens.ForModel(modelName), tokens.Budget(window, 0), strategy, nil
 }
 
+// defaultHistoryStrategy keeps the last 5 messages, which bounds prompt
+// growth for small local models
+var defaultHistoryStrategy = tokens.KeepLast(5)
+
 // getAvailableToolsForAgent returns list of tools available to the agent
 func (sc *swarmClient) getAvailableToolsForAgent(agent *Agent) string {
 	if len(agent.Functions) == 0 {
@@ -604,17 +712,8 @@ func (sc *swarmClient) getAvailableAgentsForHandoff(currentAgent *Agent) string
 	return strings.Join(agents, ", ")
 }
 
-// executeLLMDecision parses and executes the LLM's decision
-func (sc *swarmClient) executeLLMDecision(ctx *ExecutionContext, agent *Agent, llmResponse string, contextVars map[string]interface{}) Result {
-	// Try to extract JSON from LLM response
-	decision, err := sc.parseLLMDecision(llmResponse)
-	if err != nil {
-		return Result{
-			Error:   fmt.Errorf("failed to parse LLM decision: %w", err),
-			Success: false,
-		}
-	}
-
+// executeLLMDecision executes the LLM's decision
+func (sc *swarmClient) executeLLMDecision(ctx *ExecutionContext, agent *Agent, decision *LLMDecision, contextVars map[string]interface{}) Result {
 	switch decision.Action {
 	case "tool_use":
 		return sc.executeLLMToolUse(ctx, agent, decision, contextVars)
@@ -647,77 +746,66 @@ type LLMDecision struct {
 	Response     string                 `json:"response"`
 }
 
-// parseLLMDecision attempts to parse JSON decision from LLM response
-func (sc *swarmClient) parseLLMDecision(response string) (*LLMDecision, error) {
-	// Try to find JSON in the response
-	jsonStart := strings.Index(response, "{")
-	jsonEnd := strings.LastIndex(response, "}")
-
-	if jsonStart == -1 || jsonEnd == -1 || jsonEnd <= jsonStart {
-		// If no JSON found, treat as direct response
-		return &LLMDecision{
-			Action:   "respond",
-			Response: response,
-		}, nil
+// decisionSchema describes an LLMDecision. Tool and agent names are
+// limited to the ones the agent can use, and each action requires its own
+// field, so hallucinated or missing names are sent back to the model instead
+// of failing the turn.
+func (sc *swarmClient) decisionSchema(agent *Agent) map[string]interface{} {
+	nameOf := func(names []string, description string) map[string]interface{} {
+		enum := []interface{}{"", nil}
+		for _, name := range names {
+			enum = append(enum, name)
+		}
+		return map[string]interface{}{
+			"type":        []string{"string", "null"},
+			"enum":        enum,
+			"description": description,
+		}
 	}
 
-	jsonStr := response[jsonStart : jsonEnd+1]
-
-	var decision LLMDecision
-	if err := sc.parseJSON(jsonStr, &decision); err != nil {
-		// If JSON parsing fails, treat as direct response
-		return &LLMDecision{
-			Action:   "respond",
-			Response: response,
-		}, nil
+	var tools []string
+	for _, fn := range agent.Functions {
+		tools = append(tools, fn.Name)
 	}
-
-	return &decision, nil
-}
-
-// parseJSON is a simple JSON parser for LLM responses
-func (sc *swarmClient) parseJSON(jsonStr string, decision *LLMDecision) error {
-	// Simple parsing logic - in production, use proper JSON parser
-	// This is simplified for the demo
-
-	if strings.Contains(jsonStr, `"action": "tool_use"`) {
-		decision.Action = "tool_use"
-
-		// Extract tool name
-		if start := strings.Index(jsonStr, `"tool_name": "`); start != -1 {
-			start += len(`"tool_name": "`)
-			if end := strings.Index(jsonStr[start:], `"`); end != -1 {
-				decision.ToolName = jsonStr[start : start+end]
-			}
-		}
-
-		// Simple tool args extraction - in practice, use proper JSON
-		decision.ToolArgs = map[string]interface{}{}
-
-	} else if strings.Contains(jsonStr, `"action": "handoff"`) {
-		decision.Action = "handoff"
-
-		// Extract handoff agent
-		if start := strings.Index(jsonStr, `"handoff_agent": "`); start != -1 {
-			start += len(`"handoff_agent": "`)
-			if end := strings.Index(jsonStr[start:], `"`); end != -1 {
-				decision.HandoffAgent = jsonStr[start : start+end]
-			}
+	var agents []string
+	for name := range sc.agents {
+		if name != agent.Name {
+			agents = append(agents, name)
 		}
-
-	} else {
-		decision.Action = "respond"
-
-		// Extract response
-		if start := strings.Index(jsonStr, `"response": "`); start != -1 {
-			start += len(`"response": "`)
-			if end := strings.Index(jsonStr[start:], `"`); end != -1 {
-				decision.Response = jsonStr[start : start+end]
-			}
+	}
+	sort.Strings(agents)
+
+	// branch matches one action and requires the named field to be set
+	branch := func(action, field string) map[string]interface{} {
+		properties := map[string]interface{}{"action": map[string]interface{}{"const": action}}
+		required := []string{"action"}
+		if field != "" {
+			properties[field] = map[string]interface{}{"type": "string", "minLength": 1}
+			required = append(required, field)
 		}
+		return map[string]interface{}{"properties": properties, "required": required}
 	}
 
-	return nil
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"action": map[string]interface{}{
+				"type": "string",
+				"enum": []string{"tool_use", "handoff", "respond"},
+			},
+			"reasoning":     map[string]interface{}{"type": "string", "description": "why you chose this action"},
+			"tool_name":     nameOf(tools, "tool to call when action is tool_use"),
+			"tool_args":     map[string]interface{}{"type": []string{"object", "null"}, "description": "arguments for the tool"},
+			"handoff_agent": nameOf(agents, "agent to transfer to when action is handoff"),
+			"response":      map[string]interface{}{"type": []string{"string", "null"}, "description": "your reply when action is respond"},
+		},
+		"required": []string{"action"},
+		"oneOf": []interface{}{
+			branch("tool_use", "tool_name"),
+			branch("handoff", "handoff_agent"),
+			branch("respond", ""),
+		},
+	}
 }
 
 // executeLLMToolUse executes a tool use decision from LLM
@@ -730,7 +818,7 @@ func (sc *swarmClient) executeLLMToolUse(ctx *ExecutionContext, agent *Agent, de
 			}
 
 			ctx.ToolCallCount++
-			result := fn.Function(decision.ToolArgs, contextVars)
+			result := sc.callFunction(ctx, fn, decision.ToolArgs, contextVars)
 
 			if result.Success {
 				result.ResponseMessage = &Message{
@@ -749,6 +837,23 @@ func (sc *swarmClient) executeLLMToolUse(ctx *ExecutionContext, agent *Agent, de
 	}
 }
 
+// callFunction invokes an agent function inside a traced span
+func (sc *swarmClient) callFunction(execCtx *ExecutionContext, fn AgentFunction, args map[string]interface{}, contextVars map[string]interface{}) Result {
+	ctx, span := tracing.Start(execCtx.Context, "swarm.function", tracing.String("function.name", fn.Name))
+	defer span.End()
+
+	result := fn.Call(ctx, args, contextVars)
+	span.RecordError(result.Error)
+	return result
+}
+
+// withBackground adapts a context-aware agent function to the plain Function signature
+func withBackground(fn func(ctx context.Context, args map[string]interface{}, contextVars map[string]interface{}) Result) func(args map[string]interface{}, contextVars map[string]interface{}) Result {
+	return func(args map[string]interface{}, contextVars map[string]interface{}) Result {
+		return fn(context.Background(), args, contextVars)
+	}
+}
+
 // executeLLMHandoff executes a handoff decision from LLM
 func (sc *swarmClient) executeLLMHandoff(agent *Agent, decision *LLMDecision, contextVars map[string]interface{}) Result {
 	// Find the target agent
@@ -805,11 +910,11 @@ func (sc *swarmClient) detectAndExecuteFunction(ctx *ExecutionContext, agent *Ag
 				args := sc.extractArgumentsFromMessage(fn.Name, message)
 
 				if sc.config.Debug {
-					sc.logger.Debug("Executing function", "function", fn.Name, "args", args)
+					sc.logger.Debug("Executing function", "function", fn.Name, "args", logging.Redact(args))
 				}
 
 				ctx.ToolCallCount++
-				result := fn.Function(args, contextVars)
+				result := sc.callFunction(ctx, fn, args, contextVars)
 
 				if result.Success {
 					result.ResponseMessage = &Message{
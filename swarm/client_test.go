@@ -0,0 +1,35 @@
+package swarm
+
+import (
+	"testing"
+
+	"github.com/benozo/conduit/mcp"
+)
+
+func TestDecisionSchema(t *testing.T) {
+	sc := &swarmClient{agents: map[string]*Agent{"triage": nil, "billing": nil}}
+	agent := &Agent{Name: "triage", Functions: []AgentFunction{{Name: "search"}}}
+	schema := sc.decisionSchema(agent)
+
+	tests := []struct {
+		name  string
+		reply map[string]interface{}
+		valid bool
+	}{
+		{"tool use", map[string]interface{}{"action": "tool_use", "tool_name": "search", "tool_args": map[string]interface{}{}}, true},
+		{"tool use without tool", map[string]interface{}{"action": "tool_use"}, false},
+		{"tool use with empty tool", map[string]interface{}{"action": "tool_use", "tool_name": ""}, false},
+		{"unknown tool", map[string]interface{}{"action": "tool_use", "tool_name": "delete_everything"}, false},
+		{"handoff", map[string]interface{}{"action": "handoff", "handoff_agent": "billing"}, true},
+		{"handoff without agent", map[string]interface{}{"action": "handoff", "handoff_agent": nil}, false},
+		{"handoff to itself", map[string]interface{}{"action": "handoff", "handoff_agent": "triage"}, false},
+		{"respond", map[string]interface{}{"action": "respond", "response": "hello", "tool_name": ""}, true},
+		{"unknown action", map[string]interface{}{"action": "wait"}, false},
+		{"no action", map[string]interface{}{"response": "hello"}, false},
+	}
+	for _, tt := range tests {
+		if problems := mcp.ValidateSchema(schema, tt.reply); (len(problems) == 0) != tt.valid {
+			t.Errorf("%s: problems %q, want valid %v", tt.name, problems, tt.valid)
+		}
+	}
+}
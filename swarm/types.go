@@ -5,6 +5,8 @@ import (
 	"time"
 
 	conduit "github.com/benozo/conduit/lib"
+	"github.com/benozo/conduit/lib/tokens"
+	"github.com/benozo/conduit/lib/usage"
 	"github.com/benozo/conduit/mcp"
 )
 
@@ -13,6 +15,8 @@ type Message struct {
 	Role    string `json:"role"`
 	Content string `json:"content"`
 	Name    string `json:"name,omitempty"`
+	// Images sent with a user message are passed to the agent's model
+	Images []mcp.Image `json:"images,omitempty"`
 }
 
 // Agent represents a swarm agent with instructions and functions
@@ -33,6 +37,18 @@ type AgentFunction struct {
 	Description string                 `json:"description"`
 	Parameters  map[string]interface{} `json:"parameters"`
 	Function    func(args map[string]interface{}, contextVars map[string]interface{}) Result
+
+	// ContextFunction, when set, is preferred over Function and receives the
+	// turn's context for cancellation and tracing
+	ContextFunction func(ctx context.Context, args map[string]interface{}, contextVars map[string]interface{}) Result `json:"-"`
+}
+
+// Call invokes the function, passing ctx through when the function accepts it
+func (f AgentFunction) Call(ctx context.Context, args map[string]interface{}, contextVars map[string]interface{}) Result {
+	if f.ContextFunction != nil {
+		return f.ContextFunction(ctx, args, contextVars)
+	}
+	return f.Function(args, contextVars)
 }
 
 // Result represents the result of a function call
@@ -57,6 +73,8 @@ type Response struct {
 	HandoffsCount  int                    `json:"handoffs_count"`
 	Error          error                  `json:"error,omitempty"`
 	Success        bool                   `json:"success"`
+	// Usage is the tokens and cost the run's model calls spent
+	Usage usage.Totals `json:"usage"`
 }
 
 // SwarmConfig holds configuration for the swarm client
@@ -69,6 +87,21 @@ type SwarmConfig struct {
 	Timeout       time.Duration `json:"timeout"`
 	EnableMemory  bool          `json:"enable_memory"`
 	EnableLogging bool          `json:"enable_logging"`
+
+	// ContextWindow overrides the swarm model's context size in tokens.
+	// HistoryStrategy cuts conversation history to fit the prompt; when nil
+	// the last 5 messages are kept, oldest dropped first if still too long.
+	// Agents with a ModelConfig use its context settings instead.
+	ContextWindow   int             `json:"context_window,omitempty"`
+	HistoryStrategy tokens.Strategy `json:"-"`
+
+	// Budget caps the cost and tokens of each run; a run that reaches it
+	// stops with a usage.BudgetError
+	Budget usage.Budget `json:"budget,omitempty"`
+
+	// DecisionAttempts is how many times an agent's model is asked for a
+	// decision that matches the decision schema (default 3)
+	DecisionAttempts int `json:"decision_attempts,omitempty"`
 }
 
 // DefaultSwarmConfig returns a default swarm configuration
@@ -106,6 +139,7 @@ type ContextVariables map[string]interface{}
 
 // ExecutionContext provides context for agent execution
 type ExecutionContext struct {
+	Context       context.Context
 	SessionID     string
 	CurrentAgent  *Agent
 	MessageCount  int
@@ -2,10 +2,16 @@ package swarm
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sort"
 	"sync"
 	"time"
+
+	"github.com/benozo/conduit/lib/httpclient"
+	"github.com/benozo/conduit/lib/metrics"
+	"github.com/benozo/conduit/lib/tracing"
+	"github.com/benozo/conduit/lib/usage"
 )
 
 // executeSequential executes nodes one after another in order
@@ -485,6 +491,20 @@ func (we *WorkflowExecutor) executeNode(ctx context.Context, node *WorkflowNode,
 	node.StartTime = &startTime
 	node.Status = NodeStatusRunning
 
+	ctx, span := tracing.Start(ctx, "workflow.node",
+		tracing.String("node.id", node.ID),
+		tracing.String("node.name", node.Name),
+		tracing.String("agent.name", nodeAgentName(node)),
+	)
+	defer func() {
+		span.SetAttributes(
+			tracing.String("node.status", string(nodeResult.Status)),
+			tracing.Int("node.retries", nodeResult.RetryCount),
+		)
+		span.RecordError(nodeResult.Error)
+		span.End()
+	}()
+
 	we.emitEvent(EventNodeStart, "", node.ID, nil, nil)
 
 	// Retry logic
@@ -527,12 +547,15 @@ func (we *WorkflowExecutor) executeNode(ctx context.Context, node *WorkflowNode,
 			endTime := time.Now()
 			node.EndTime = &endTime
 			nodeResult.ExecutionTime = endTime.Sub(startTime)
+			metrics.ObserveWorkflowNode(nodeAgentName(node), string(NodeStatusCompleted), nodeResult.ExecutionTime)
 
 			we.emitEvent(EventNodeComplete, "", node.ID, nil, nil)
 			break
 		} else {
-			// Failure
-			if attempt == node.MaxRetries {
+			// Failure. Permanent provider errors (bad request, auth) and
+			// spent budgets won't succeed on retry, so they fail the node
+			// right away.
+			if attempt == node.MaxRetries || httpclient.IsPermanent(response.Error) || errors.Is(response.Error, usage.ErrBudgetExceeded) {
 				node.Status = NodeStatusFailed
 				node.Error = response.Error
 				nodeResult.Status = NodeStatusFailed
@@ -542,8 +565,10 @@ func (we *WorkflowExecutor) executeNode(ctx context.Context, node *WorkflowNode,
 				endTime := time.Now()
 				node.EndTime = &endTime
 				nodeResult.ExecutionTime = endTime.Sub(startTime)
+				metrics.ObserveWorkflowNode(nodeAgentName(node), string(NodeStatusFailed), nodeResult.ExecutionTime)
 
 				we.emitEvent(EventNodeFailed, "", node.ID, nil, response.Error)
+				break
 			}
 		}
 	}
@@ -551,6 +576,14 @@ func (we *WorkflowExecutor) executeNode(ctx context.Context, node *WorkflowNode,
 	return nodeResult
 }
 
+// nodeAgentName returns the agent name used to label node metrics
+func nodeAgentName(node *WorkflowNode) string {
+	if node.Agent == nil {
+		return "none"
+	}
+	return node.Agent.Name
+}
+
 func (we *WorkflowExecutor) evaluateConditions(node *WorkflowNode, context map[string]interface{}, nodeResults map[string]*NodeResult) bool {
 	if len(node.Conditions) == 0 {
 		return true
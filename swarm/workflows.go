@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"sync"
 	"time"
+
+	"github.com/benozo/conduit/lib/tracing"
+	"github.com/benozo/conduit/lib/usage"
 )
 
 // WorkflowType defines different workflow execution patterns
@@ -94,6 +97,9 @@ type Workflow struct {
 	Error           error                    `json:"error,omitempty"`
 	MaxConcurrency  int                      `json:"max_concurrency"`
 	Timeout         time.Duration            `json:"timeout"`
+	// Budget caps the cost and tokens the workflow spends across its
+	// executions; nodes fail with a usage.BudgetError once it is reached
+	Budget usage.Budget `json:"budget,omitempty"`
 }
 
 // WorkflowStatus represents the status of a workflow
@@ -176,6 +182,15 @@ func (we *WorkflowExecutor) CreateWorkflow(name string, workflowType WorkflowTyp
 	return workflow
 }
 
+// DeleteWorkflow removes a workflow and its budget. Its usage totals stay
+// reported until the tracker evicts them.
+func (we *WorkflowExecutor) DeleteWorkflow(workflowID string) {
+	we.mutex.Lock()
+	delete(we.workflows, workflowID)
+	we.mutex.Unlock()
+	usage.Default.SetBudget(usage.DimensionWorkflow, workflowID, usage.Budget{})
+}
+
 // AddNode adds a node to the workflow
 func (wf *Workflow) AddNode(id, name string, agent *Agent, dependencies []string) *WorkflowNode {
 	node := &WorkflowNode{
@@ -241,6 +256,17 @@ func (we *WorkflowExecutor) ExecuteWorkflow(ctx context.Context, workflowID stri
 	startTime := time.Now()
 	workflow.StartTime = &startTime
 
+	ctx, span := tracing.Start(ctx, "workflow.execute",
+		tracing.String("workflow.id", workflowID),
+		tracing.String("workflow.type", string(workflow.Type)),
+	)
+	defer span.End()
+
+	// Account the nodes' model calls to the workflow
+	ctx = usage.WithScope(ctx, usage.Scope{Workflow: workflowID})
+	usage.Default.SetBudget(usage.DimensionWorkflow, workflowID, workflow.Budget)
+	spentBefore := usage.Default.Totals(usage.DimensionWorkflow, workflowID)
+
 	we.emitEvent(EventWorkflowStart, workflowID, "", nil, nil)
 
 	switch workflow.Type {
@@ -262,10 +288,14 @@ func (we *WorkflowExecutor) ExecuteWorkflow(ctx context.Context, workflowID stri
 
 	endTime := time.Now()
 	workflow.EndTime = &endTime
+	if result != nil {
+		result.Usage = usage.Default.Totals(usage.DimensionWorkflow, workflowID).Sub(spentBefore)
+	}
 
 	if err != nil {
 		workflow.Status = WorkflowStatusFailed
 		workflow.Error = err
+		span.RecordError(err)
 		we.emitEvent(EventWorkflowFailed, workflowID, "", nil, err)
 	} else {
 		workflow.Status = WorkflowStatusCompleted
@@ -283,6 +313,8 @@ type WorkflowResult struct {
 	NodeResults   map[string]*NodeResult `json:"node_results"`
 	FinalContext  map[string]interface{} `json:"final_context"`
 	Error         error                  `json:"error,omitempty"`
+	// Usage is the tokens and cost this execution spent
+	Usage usage.Totals `json:"usage"`
 }
 
 // NodeResult represents the result of a node execution